@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"telegram-notifier/internal/alertmanager"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+)
+
+// maxAlertPayload bounds webhook bodies; Alertmanager groups are small unless max_alerts is unset
+const maxAlertPayload = 1024 * 1024
+
+// alertReceiver turns Alertmanager webhook POSTs into notifications
+type alertReceiver struct {
+	service *notifier.Service
+	tmpl    *template.Template
+	secret  string
+	timeout time.Duration
+	maxSize int // Room for the message, from NOTIFIER_MAX_OUTPUT_SIZE
+}
+
+// ServeHTTP renders and delivers one alert group per request
+// Alertmanager retries on 5xx, so only failures that weren't spooled are reported as such
+func (a *alertReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// SECURITY: Constant-time comparison so the credential can't be guessed byte by byte
+	if a.secret != "" {
+		given, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(a.secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var payload alertmanager.Payload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAlertPayload)).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.Version != "4" {
+		slog.Warn("Unexpected Alertmanager payload version", "version", payload.Version)
+	}
+
+	title, message, err := alertmanager.Render(a.tmpl, payload, a.maxSize)
+	if err != nil {
+		slog.Error("Rendering alert template failed", logging.Err(err))
+		http.Error(w, "rendering alert failed", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+	_, err = a.service.SendMessage(ctx, title, message)
+	flushTraces()
+	switch {
+	case errors.Is(err, notifier.ErrSpooled):
+		slog.Warn("Alert delivery failed, notification spooled for retry", "receiver", payload.Receiver, logging.Err(err))
+	case err != nil:
+		slog.Error("Alert delivery failed", "receiver", payload.Receiver, logging.Err(err))
+		http.Error(w, "delivery failed", http.StatusBadGateway)
+		return
+	default:
+		slog.Info("Delivered alert", "status", payload.Status, "alerts", len(payload.Alerts), "receiver", payload.Receiver)
+	}
+	w.WriteHeader(http.StatusOK)
+}
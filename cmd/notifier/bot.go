@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"telegram-notifier/internal/bot"
+	"telegram-notifier/internal/botauth"
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/state"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// cancelAction is the button action dismissing a confirmation prompt
+const cancelAction = "cancel"
+
+// newBot registers the interactive commands the daemon answers
+func newBot(cfg *config.Config, notifierService *notifier.Service) *bot.Bot {
+	client := telegram.NewClient(cfg, nil)
+	b := bot.New(client, cfg.GetBotPolicy())
+	b.Handle(notifier.AckAction, bot.Command{
+		Permission: botauth.PermissionControl,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			return acknowledge(ctx, notifierService, req)
+		},
+	})
+	if cfg.AckReaction != "" {
+		b.HandleReaction(cfg.AckReaction, notifier.AckAction)
+	}
+	outputs := state.NewOutputs(cfg.GetOutputsDir(), constants.OutputRetention)
+	b.Handle(notifier.MoreAction, bot.Command{
+		Permission: botauth.PermissionView,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			return showMore(ctx, client, outputs, req)
+		},
+	})
+	systemdService := systemd.NewService(systemd.NewCommandExecutor(), cfg)
+	b.Handle("logs", bot.Command{
+		Permission: botauth.PermissionView,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			return recentLogs(ctx, cfg, systemdService, req.Args)
+		},
+	})
+	b.Handle("status", bot.Command{
+		Permission: botauth.PermissionView,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			return unitsStatus(ctx, cfg, systemdService)
+		},
+	})
+	for _, action := range []string{systemd.ActionRestart, systemd.ActionStop} {
+		b.Handle(action, bot.Command{
+			Permission: botauth.PermissionControl,
+			Handle: func(ctx context.Context, req bot.Request) (string, error) {
+				if req.Button {
+					return controlUnit(ctx, cfg, client, systemdService, notifierService, action, req)
+				}
+				return confirmControl(ctx, client, action, req)
+			},
+		})
+	}
+	b.Handle(cancelAction, bot.Command{
+		Permission: botauth.PermissionControl,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			return cancelPrompt(ctx, client, req)
+		},
+	})
+	b.Handle("mute", bot.Command{
+		Permission: botauth.PermissionControl,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			name, until, err := parseMute(req.Args)
+			if err != nil {
+				return markdown.Escape(err.Error()), nil
+			}
+			if name, err = notifierService.Snooze(name, until); err != nil {
+				return "", err
+			}
+			return muteResult(name, until, cfg.FormatDateTime), nil
+		},
+	})
+	b.Handle("unmute", bot.Command{
+		Permission: botauth.PermissionControl,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			if len(req.Args) != 1 {
+				return "usage: /unmute <unit>", nil
+			}
+			name, until, err := parseSnooze([]string{req.Args[0], snoozeOff})
+			if err != nil {
+				return markdown.Escape(err.Error()), nil
+			}
+			if name, err = notifierService.Snooze(name, until); err != nil {
+				return "", err
+			}
+			return muteResult(name, until, cfg.FormatDateTime), nil
+		},
+	})
+	b.Handle("snooze", bot.Command{
+		Permission: botauth.PermissionControl,
+		Handle: func(ctx context.Context, req bot.Request) (string, error) {
+			name, until, err := parseSnooze(req.Args)
+			if err != nil {
+				return markdown.Escape(err.Error()), nil
+			}
+			if name, err = notifierService.Snooze(name, until); err != nil {
+				return "", err
+			}
+			return snoozeResult(name, until, cfg.FormatDateTime), nil
+		},
+	})
+	return b
+}
+
+// runBot answers bot commands until ctx is cancelled: through a webhook when NOTIFIER_BOT_WEBHOOK_URL is set,
+// otherwise by long polling, which is also the fallback when Telegram refuses the webhook
+func runBot(ctx context.Context, cfg *config.Config, b *bot.Bot) {
+	client := telegram.NewClient(cfg, nil)
+	if cfg.BotWebhookURL != "" {
+		secret := cfg.BotWebhookSecret
+		if secret == "" {
+			// Registered anew on every start, so a fresh secret costs nothing
+			secret = randomSecret()
+		}
+		// Bound before registering, so Telegram is never pointed at an address nothing answers on
+		ln, err := net.Listen("tcp", cfg.BotWebhookAddr)
+		if err == nil {
+			if err = client.SetWebhook(ctx, cfg.BotWebhookURL, secret); err != nil {
+				ln.Close()
+			}
+		}
+		if err == nil {
+			webhookCtx, stopWebhook := context.WithCancel(ctx)
+			webhook := b.Webhook(secret)
+			go webhook.Run(webhookCtx)
+			slog.Info("Answering bot commands through a webhook", "url", cfg.BotWebhookURL, "addr", cfg.BotWebhookAddr, "tls", cfg.BotWebhookCert != nil)
+			err = serveListener(webhookCtx, ln, webhook, cfg.BotWebhookCert)
+			stopWebhook()
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+		}
+		slog.Warn("Receiving bot updates through a webhook failed, polling instead", logging.Err(err))
+	}
+
+	// getUpdates is refused while a webhook is registered, as after switching back from webhook mode
+	if err := client.DeleteWebhook(ctx); err != nil {
+		slog.Warn("Removing bot webhook failed", logging.Err(err))
+	}
+	slog.Info("Answering bot commands")
+	b.Run(ctx)
+}
+
+// randomSecret returns a webhook secret token for when none is configured
+func randomSecret() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// acknowledge handles "/ack [unit]", Acknowledge buttons and reactions; without a unit every unacknowledged failure is taken
+// A reaction takes the failure of the alert it was put on
+func acknowledge(ctx context.Context, notifierService *notifier.Service, req bot.Request) (string, error) {
+	// Usernames like "@john_doe" would open an italic entity in the replies
+	by := req.User.Name()
+	if req.Reaction {
+		name, err := notifierService.AcknowledgeMessage(ctx, req.ChatID, req.MessageID, by)
+		if err != nil || name == "" {
+			return "", err
+		}
+		return fmt.Sprintf("✋ %s acknowledged by %s", markdown.Code(name), markdown.Escape(by)), nil
+	}
+	if len(req.Args) == 0 {
+		acked, err := notifierService.AcknowledgeAll(ctx, by)
+		if err != nil {
+			return "", err
+		}
+		if len(acked) == 0 {
+			return "Nothing to acknowledge", nil
+		}
+		codes := make([]string, len(acked))
+		for i, name := range acked {
+			codes[i] = markdown.Code(name)
+		}
+		return fmt.Sprintf("✋ Acknowledged by %s: %s", markdown.Escape(by), strings.Join(codes, ", ")), nil
+	}
+
+	name := req.Args[0]
+	acked, err := notifierService.Acknowledge(ctx, name, by)
+	if err != nil {
+		return "", err
+	}
+	if !acked {
+		return fmt.Sprintf("%s isn't failing or was already acknowledged", markdown.Code(name)), nil
+	}
+	return fmt.Sprintf("✋ %s acknowledged by %s", markdown.Code(name), markdown.Escape(by)), nil
+}
+
+// showMore handles "Show more" buttons, replying with the chunk of stored output before the button's offset
+// The reply carries the next button until the start of the output is reached
+func showMore(ctx context.Context, client *telegram.Client, outputs *state.OutputStore, req bot.Request) (string, error) {
+	var id, offset string
+	if len(req.Args) > 0 {
+		id, offset, _ = strings.Cut(req.Args[0], ":")
+	}
+	end, err := strconv.Atoi(offset)
+	if err != nil || end <= 0 {
+		return "Invalid output reference", nil
+	}
+	output, err := outputs.Load(id)
+	if errors.Is(err, state.ErrOutputExpired) {
+		return "This output is no longer kept", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	text := output.Text[:min(end, len(output.Text))]
+	start := validation.TailStart(text, constants.ShowMoreChunkSize, validation.UTF16Length)
+	// Start at a line boundary, unless the chunk is one long line
+	if i := strings.IndexByte(text[start:], '\n'); start > 0 && i >= 0 && i < len(text)-start-1 {
+		start += i + 1
+	}
+
+	reply := fmt.Sprintf("📜 *Earlier output of* %s\n```\n%s\n```", markdown.Code(output.Service), markdown.Literal(text[start:]))
+	var buttons [][]telegram.Button
+	if start > 0 {
+		buttons = append(buttons, notifier.MoreButton(id, start))
+	}
+	if err := client.Reply(ctx, req.ChatID, req.MessageID, reply, buttons...); err != nil {
+		return "", err
+	}
+	return "Earlier output posted", nil
+}
+
+// recentLogs handles "/logs <unit> [lines]", answering with the unit's latest journal entries
+func recentLogs(ctx context.Context, cfg *config.Config, systemdService *systemd.Service, args []string) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "usage: /logs <unit> [lines]", nil
+	}
+	name := validation.NormalizeUnitName(args[0])
+	if validation.ValidateServiceName(name) != nil {
+		return "Invalid unit name " + markdown.Code(args[0]), nil
+	}
+	lines := constants.DefaultLogsLines
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > constants.MaxLogsLines {
+			return fmt.Sprintf("Lines must be a number from 1 to %d", constants.MaxLogsLines), nil
+		}
+		lines = n
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	logs, err := systemdService.RecentLogs(ctx, name, lines)
+	if err != nil {
+		return "", err
+	}
+
+	// SECURITY: The logs go to the chat like a notification, so they're redacted like one
+	logs = validation.FilterSecrets(logs)
+	header := fmt.Sprintf("📜 *Latest logs of* %s\n```\n", markdown.Code(name))
+	footer := "\n```"
+	maxSize := constants.TelegramMaxMessageSize - constants.MessageSafetyMargin - validation.UTF16Length(header+footer)
+	return header + markdown.Literal(validation.TruncateMessage(logs, maxSize)) + footer, nil
+}
+
+// unitsStatus handles "/status", answering with a table of the units named in the services file or reported before
+// Their states come from one batched systemctl query; failures, snoozes and maintenance windows are noted alongside
+func unitsStatus(ctx context.Context, cfg *config.Config, systemdService *systemd.Service) (string, error) {
+	services, err := state.New(cfg.GetServiceStateFile()).All()
+	if err != nil {
+		return "", err
+	}
+	windows, err := state.NewMaintenance(cfg.GetMaintenanceFile()).Active()
+	if err != nil {
+		return "", err
+	}
+
+	names := watchedUnits(cfg, services)
+	if len(names) == 0 {
+		return "No units configured or reported yet", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	statuses, err := systemdService.UnitStatuses(ctx, names)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var table strings.Builder
+	w := tabwriter.NewWriter(&table, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, "UNIT\tSTATE\tRESULT\tSINCE\tLAST EXIT\tNOTE")
+	for _, st := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", st.Name, unitState(st), orDash(st.Result),
+			statusTime(cfg, st.Since), statusTime(cfg, st.LastExit), unitNote(state.Lookup(services, st.Name), windows, st.Name, now))
+	}
+	w.Flush()
+
+	header := "📋 *Units*\n```\n"
+	footer := "\n```"
+	maxSize := constants.TelegramMaxMessageSize - constants.MessageSafetyMargin - validation.UTF16Length(header+footer)
+	body := strings.TrimRight(table.String(), "\n")
+	return header + markdown.Literal(validation.TruncateHead(body, maxSize, validation.UTF16Length)) + footer, nil
+}
+
+// watchedUnits returns the units with a section in the services file or a recorded run, sorted
+// Sections name units as systemctl would read them, so "backup" lists backup.service; recorded runs are kept
+// by the name they ran as, and jobs wrapped by "telegram-notifier run" have no unit to query and are left out
+func watchedUnits(cfg *config.Config, services map[string]state.Service) []string {
+	var names []string
+	add := func(name string) {
+		if validation.ValidateServiceName(name) == nil && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for name := range cfg.ServicePolicies {
+		add(validation.NormalizeUnitName(name))
+	}
+	for name := range services {
+		if validation.NormalizeUnitName(name) == name {
+			add(name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// unitState renders ActiveState with SubState when it adds something, e.g. "active/running" but just "failed"
+func unitState(st systemd.UnitStatus) string {
+	switch {
+	case st.LoadState == "not-found":
+		return "not-found"
+	case st.SubState == "" || st.SubState == st.ActiveState:
+		return orDash(st.ActiveState)
+	}
+	return st.ActiveState + "/" + st.SubState
+}
+
+// unitNote summarizes what the notifier knows about a unit: its failures, acknowledgement, snooze or maintenance
+func unitNote(st state.Service, windows state.Maintenance, name string, now time.Time) string {
+	var notes []string
+	if st.Failures > 0 {
+		notes = append(notes, fmt.Sprintf("%d failed", st.Failures))
+	}
+	if st.Ack != nil {
+		notes = append(notes, "acked by "+st.Ack.By)
+	}
+	switch {
+	case st.Muted():
+		notes = append(notes, "muted")
+	case st.Snoozed(now):
+		notes = append(notes, "snoozed")
+	}
+	if _, ok := windows.Covers(name, now); ok {
+		notes = append(notes, "maintenance")
+	}
+	if len(notes) == 0 {
+		return "-"
+	}
+	return strings.Join(notes, ", ")
+}
+
+// statusTime formats a /status timestamp in the configured time zone, "-" when unknown
+func statusTime(cfg *config.Config, t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.In(cfg.GetTimeLocation()).Format(constants.StatusTimeFormat)
+}
+
+// orDash keeps table columns aligned when a value is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// confirmControl handles "/restart <unit>" and "/stop <unit>", replying with a prompt whose button runs the action
+// The button carries when it was issued, so a prompt left in the chat can't restart a unit days later
+func confirmControl(ctx context.Context, client *telegram.Client, action string, req bot.Request) (string, error) {
+	if len(req.Args) != 1 {
+		return fmt.Sprintf("usage: /%s <unit>", action), nil
+	}
+	// SECURITY: ControlUnit puts the name after "--" as well; a name like "-Hhost.service" is still refused here
+	name := validation.NormalizeUnitName(req.Args[0])
+	if strings.HasPrefix(name, "-") || validation.ValidateServiceName(name) != nil {
+		return "Invalid unit name " + markdown.Code(req.Args[0]), nil
+	}
+	data := fmt.Sprintf("%s:%d:%s", action, time.Now().Unix(), name)
+	if len(data) > telegram.MaxCallbackData {
+		return fmt.Sprintf("%s is too long a name for a confirmation button", markdown.Code(name)), nil
+	}
+
+	verb := actionVerb(action)
+	prompt := fmt.Sprintf("⚠️ %s %s? Confirm within %d minutes.", verb, markdown.Code(name), int(constants.ConfirmationTimeout.Minutes()))
+	buttons := []telegram.Button{{Text: "✅ " + verb, CallbackData: data}, {Text: "✖️ Cancel", CallbackData: cancelAction}}
+	return "", client.Reply(ctx, req.ChatID, req.MessageID, prompt, buttons)
+}
+
+// controlUnit runs a confirmed restart or stop, records who did it in the audit log and turns the prompt into the outcome
+func controlUnit(ctx context.Context, cfg *config.Config, client *telegram.Client, systemdService *systemd.Service, notifierService *notifier.Service, action string, req bot.Request) (string, error) {
+	var issued, name string
+	if len(req.Args) > 0 {
+		issued, name, _ = strings.Cut(req.Args[0], ":")
+	}
+	unix, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil || strings.HasPrefix(name, "-") || validation.ValidateServiceName(name) != nil {
+		return "Invalid confirmation", nil
+	}
+	chatID := strconv.FormatInt(req.ChatID, 10)
+	if time.Since(time.Unix(unix, 0)) > constants.ConfirmationTimeout {
+		if err := client.EditMessage(ctx, chatID, req.MessageID, fmt.Sprintf("⌛ %s %s wasn't confirmed in time", actionVerb(action), markdown.Code(name))); err != nil {
+			slog.Warn("Updating confirmation prompt failed", logging.Err(err))
+		}
+		return fmt.Sprintf("This confirmation expired; send /%s again", action), nil
+	}
+
+	by := req.User.Name()
+	slog.Info("Running confirmed unit action", "action", action, logging.KeyService, name, "user", req.User.ID, "by", by)
+	runCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	actionErr := systemdService.ControlUnit(runCtx, action, name)
+
+	result, outcome := history.ResultRestarted, fmt.Sprintf("🔄 %s restarted by %s", markdown.Code(name), markdown.Escape(by))
+	if action == systemd.ActionStop {
+		result, outcome = history.ResultStopped, fmt.Sprintf("⏹ %s stopped by %s", markdown.Code(name), markdown.Escape(by))
+	}
+	notifierService.RecordUnitAction(name, result, by, actionErr)
+	if actionErr != nil {
+		slog.Warn("Unit action failed", "action", action, logging.KeyService, name, logging.Err(actionErr))
+		outcome = fmt.Sprintf("⚠️ %s of %s failed: %s", actionVerb(action), markdown.Code(name), markdown.Escape(validation.SanitizeErrorMessage(actionErr)))
+	}
+	if err := client.EditMessage(ctx, chatID, req.MessageID, outcome); err != nil {
+		slog.Warn("Updating confirmation prompt failed", logging.Err(err))
+	}
+	return outcome, nil
+}
+
+// cancelPrompt handles a confirmation prompt's Cancel button, replacing the prompt so its buttons are gone
+func cancelPrompt(ctx context.Context, client *telegram.Client, req bot.Request) (string, error) {
+	if !req.Button {
+		return "Nothing to cancel", nil
+	}
+	text := "✖️ Cancelled by " + markdown.Escape(req.User.Name())
+	if err := client.EditMessage(ctx, strconv.FormatInt(req.ChatID, 10), req.MessageID, text); err != nil {
+		return "", err
+	}
+	return "Cancelled", nil
+}
+
+// actionVerb names a unit action for prompts and outcomes
+func actionVerb(action string) string {
+	if action == systemd.ActionStop {
+		return "Stop"
+	}
+	return "Restart"
+}
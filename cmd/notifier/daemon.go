@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"text/template"
+	"time"
+
+	"telegram-notifier/internal/alertmanager"
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/metrics"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/spool"
+)
+
+// runDaemon delivers spooled notifications in the background until stopped
+// Pairs with NOTIFIER_ASYNC=true so hook invocations only render and spool
+func runDaemon(args []string) {
+	cfg := loadConfig()
+	if !cfg.SpoolEnabled {
+		fatal(categoryConfig, codeSpoolDisabled, "Daemon requires the spool (NOTIFIER_SPOOL_ENABLED=true)")
+	}
+
+	// The template file is read before the sandbox hides it
+	var alertTemplate *template.Template
+	if cfg.AlertmanagerAddr != "" {
+		tmpl, err := alertmanager.LoadTemplate(cfg.AlertTemplate, cfg.FormatDateTime)
+		if err != nil {
+			fatal(categoryConfig, codeConfigInvalid, "Loading alert template failed", logging.Err(err))
+		}
+		alertTemplate = tmpl
+	}
+	harden(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sp := spool.New(cfg.GetSpoolDir(), cfg.SpoolMaxEntries, cfg.SpoolMaxAttempts)
+	health := newDaemonHealth(sp, cfg.LivenessFile)
+	opts := []notifier.Option{notifier.WithMetrics(health)}
+
+	if cfg.MetricsAddr != "" {
+		collector := metrics.New()
+		collector.RegisterGauge("spool_entries", "Notifications waiting in the spool.", func() float64 {
+			return float64(sp.Len())
+		})
+		opts = append(opts, notifier.WithMetrics(collector))
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", collector.Handler())
+		mux.Handle("/healthz", health)
+		slog.Info("Serving monitoring endpoints", "metrics", "http://"+cfg.MetricsAddr+"/metrics", "health", "http://"+cfg.MetricsAddr+"/healthz")
+		go serveHTTP(ctx, cfg.MetricsAddr, mux, nil)
+	}
+
+	// The daemon is what delivers the spool, so its own sends go out right away even in async mode:
+	// spooling them would delay reminders and alerts by an interval and lose their message IDs
+	direct := *cfg
+	direct.Async = false
+	notifierService := newNotifierService(&direct, opts...)
+
+	go serveSocket(ctx, cfg, notifierService)
+
+	if cfg.GetBotPolicy().Enabled() {
+		go runBot(ctx, cfg, newBot(cfg, notifierService))
+	}
+
+	if cfg.WatchesManager() {
+		go watchManager(ctx, cfg, notifierService)
+	}
+
+	if cfg.SyslogListen != "" {
+		go receiveSyslog(ctx, cfg, notifierService)
+	}
+
+	if alertTemplate != nil {
+		receiver := &alertReceiver{
+			service: notifierService,
+			tmpl:    alertTemplate,
+			secret:  cfg.AlertmanagerSecret,
+			timeout: cfg.CommandTimeout,
+			maxSize: cfg.MaxOutputSize,
+		}
+		slog.Info("Receiving Alertmanager webhooks", "url", "http://"+cfg.AlertmanagerAddr+"/")
+		go serveHTTP(ctx, cfg.AlertmanagerAddr, receiver, nil)
+	}
+	slog.Info("Daemon started", "spool", cfg.GetSpoolDir(), "interval", cfg.DaemonInterval, "heartbeat_interval", cfg.HeartbeatInterval)
+
+	ticker := time.NewTicker(cfg.DaemonInterval)
+	defer ticker.Stop()
+
+	// A nil channel never fires, leaving heartbeats off
+	var heartbeats <-chan time.Time
+	if cfg.HeartbeatInterval > 0 {
+		heartbeatTicker := time.NewTicker(cfg.HeartbeatInterval)
+		defer heartbeatTicker.Stop()
+		heartbeats = heartbeatTicker.C
+	}
+
+	flush := true
+	for {
+		if flush {
+			health.recordFlush(flushOnce(ctx, cfg, notifierService))
+			escalateOnce(ctx, cfg, notifierService)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Daemon stopping")
+			return
+		case <-heartbeats:
+			heartbeatOnce(ctx, cfg, notifierService, health)
+			flush = false
+		case <-ticker.C:
+			flush = true
+		}
+	}
+}
+
+// serveHTTP serves one of the daemon's listeners until ctx is cancelled, over TLS when cert isn't nil
+// A failing listener is logged rather than stopping delivery
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, cert *tls.Certificate) {
+	ln, err := net.Listen("tcp", addr)
+	if err == nil {
+		err = serveListener(ctx, ln, handler, cert)
+	}
+	if err != nil {
+		slog.Warn("HTTP server failed", "addr", addr, logging.Err(err))
+	}
+}
+
+// serveListener serves handler on a bound listener until ctx is cancelled, over TLS when cert isn't nil
+// Returns nil once ctx is cancelled
+func serveListener(ctx context.Context, ln net.Listener, handler http.Handler, cert *tls.Certificate) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if cert != nil {
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*cert}, MinVersion: tls.VersionTLS12}
+		err = server.ServeTLS(ln, "", "")
+	} else {
+		err = server.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// heartbeatOnce sends a single bounded heartbeat reflecting daemon health
+func heartbeatOnce(ctx context.Context, cfg *config.Config, notifierService *notifier.Service, health *daemonHealth) {
+	heartbeatCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+
+	report := health.report()
+	if err := sendHeartbeat(heartbeatCtx, cfg, notifierService, report.Status == healthOK, report.SpoolEntries); err != nil {
+		slog.Warn("Heartbeat failed", logging.Err(err))
+	}
+}
+
+// escalateOnce sends the reminders that are due for unacknowledged failures
+func escalateOnce(ctx context.Context, cfg *config.Config, notifierService *notifier.Service) {
+	escalateCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+
+	sent, err := notifierService.Escalate(escalateCtx)
+	flushTraces()
+	if err != nil {
+		slog.Warn("Escalation failed", logging.Err(err))
+	}
+	if sent > 0 {
+		slog.Info("Sent failure reminders", "count", sent)
+	}
+}
+
+// flushOnce runs a single bounded spool flush, logging failures instead of exiting
+func flushOnce(ctx context.Context, cfg *config.Config, notifierService *notifier.Service) error {
+	flushCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+
+	result, err := notifierService.FlushSpool(flushCtx)
+	flushTraces()
+	if err != nil {
+		slog.Warn("Flush failed", "delivered", result.Delivered, "remaining", result.Remaining, logging.Err(err))
+		return err
+	}
+	if result.Delivered > 0 {
+		slog.Info("Delivered spooled notifications", "count", result.Delivered)
+	}
+	return nil
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// System-wide configuration file documented in the README
+const systemConfigFile = "/etc/systemd/system.conf.d/telegram-notifier.conf"
+
+// doctorCommandTimeout bounds each diagnostic command
+const doctorCommandTimeout = 5 * time.Second
+
+// journalReaderGroups grant read access to the system journal
+var journalReaderGroups = []string{"systemd-journal", "adm", "wheel"}
+
+// checkStatus is the outcome of a single diagnostic check
+type checkStatus string
+
+const (
+	checkOK   checkStatus = "OK"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+)
+
+// checkResult describes one diagnostic finding and how to fix it
+type checkResult struct {
+	name   string
+	status checkStatus
+	detail string
+	fix    string
+}
+
+// doctor collects diagnostic results
+type doctor struct {
+	results []checkResult
+}
+
+func (d *doctor) add(name string, status checkStatus, detail, fix string) {
+	d.results = append(d.results, checkResult{name: name, status: status, detail: detail, fix: fix})
+}
+
+// runDoctor checks the environment the notifier depends on and prints remediation steps
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	var services serviceList
+	fs.Var(&services, "service", "audit this unit's files for credentials in Environment= (repeatable)")
+	fs.Parse(args)
+
+	d := &doctor{}
+
+	d.checkCommands()
+	d.checkJournalAccess()
+	d.checkConfigFile()
+	cfg := d.checkConfig()
+	if cfg != nil {
+		d.checkTelegram(cfg)
+		d.checkChannels(cfg)
+		d.checkForumTopics(cfg)
+		d.checkBotAccess(cfg)
+		d.checkBotWebhook(cfg)
+	}
+	for _, service := range services {
+		d.checkUnitEnvironment(service)
+	}
+
+	failed := false
+	for _, r := range d.results {
+		fmt.Printf("[%-4s] %s: %s\n", r.status, r.name, r.detail)
+		if r.fix != "" && r.status != checkOK {
+			fmt.Printf("       → %s\n", r.fix)
+		}
+		if r.status == checkFail {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkCommands verifies systemctl and journalctl are installed and runnable
+func (d *doctor) checkCommands() {
+	for _, name := range []string{"systemctl", "journalctl"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			d.add(name, checkFail, "not found in PATH", "install systemd or add its binaries to PATH")
+			continue
+		}
+
+		output, _, err := runDiagnostic(name, "--version")
+		if err != nil {
+			d.add(name, checkFail, fmt.Sprintf("%s failed: %s", path, validation.SanitizeErrorMessage(err)), "check that systemd is functional on this host")
+			continue
+		}
+		d.add(name, checkOK, fmt.Sprintf("%s (%s)", path, firstOutputLine(output)), "")
+	}
+}
+
+// checkJournalAccess verifies the current user can read the journal scopes it needs
+func (d *doctor) checkJournalAccess() {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return
+	}
+
+	if _, _, err := runDiagnostic("journalctl", "--user", "-n", "1", "--no-pager", "-q"); err != nil {
+		d.add("user journal", checkWarn, "cannot read: "+validation.SanitizeErrorMessage(err),
+			"user services need a running user manager; enable lingering with 'loginctl enable-linger'")
+	} else {
+		d.add("user journal", checkOK, "readable", "")
+	}
+
+	if os.Geteuid() == 0 {
+		d.add("system journal", checkOK, "readable (running as root)", "")
+		return
+	}
+
+	_, stderr, err := runDiagnostic("journalctl", "--system", "-n", "1", "--no-pager", "-q")
+	group := journalGroupMembership()
+	switch {
+	case err != nil:
+		d.add("system journal", checkWarn, "cannot read: "+validation.SanitizeErrorMessage(err),
+			"add the user to the systemd-journal group to include system service logs")
+	case group != "":
+		d.add("system journal", checkOK, fmt.Sprintf("readable (member of %s)", group), "")
+	case strings.Contains(stderr, "insufficient permissions") || strings.Contains(stderr, "not seeing messages"):
+		d.add("system journal", checkWarn, "only this user's messages are visible",
+			"run 'sudo usermod -aG systemd-journal $USER' and log in again to include system service logs")
+	default:
+		d.add("system journal", checkWarn, "not a member of "+strings.Join(journalReaderGroups, "/"),
+			"run 'sudo usermod -aG systemd-journal $USER' if system service logs are missing from notifications")
+	}
+}
+
+// checkConfigFile verifies the documented environment file exists and is private
+func (d *doctor) checkConfigFile() {
+	path, err := configFilePath()
+	if err != nil {
+		d.add("config file", checkWarn, "cannot determine home directory", "")
+		return
+	}
+	fix := "create it as shown in the README 'For System Services' section, or run 'telegram-notifier init'"
+	if os.Geteuid() != 0 {
+		fix = "create it as shown in the README 'For User Services' section, or run 'telegram-notifier init'"
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		d.add("config file", checkWarn, path+" not found", fix)
+		return
+	}
+
+	// SECURITY: The file holds the bot token and must not be readable by other users
+	if info.Mode().Perm()&0o077 != 0 {
+		d.add("config file", checkWarn, fmt.Sprintf("%s is accessible by other users (mode %04o)", path, info.Mode().Perm()),
+			"run 'chmod 600 "+path+"'")
+		return
+	}
+	d.add("config file", checkOK, path, "")
+
+	// environment.d is only read by the user manager at startup
+	if os.Geteuid() != 0 {
+		env, _, err := runDiagnostic("systemctl", "--user", "show-environment")
+		loaded := strings.Contains(string(env), "TELEGRAM_BOT_TOKEN=") ||
+			strings.Contains(string(env), "NOTIFIER_TOKEN_SOURCE="+constants.TokenSourceKeyring)
+		if err == nil && !loaded {
+			d.add("user manager environment", checkWarn, "TELEGRAM_BOT_TOKEN is not loaded by the user manager",
+				"run 'systemctl --user daemon-reexec' or log out and back in")
+		}
+	}
+}
+
+// configFilePath returns the environment file documented for this user: environment.d for
+// regular users, the system manager's drop-in for root
+func configFilePath() (string, error) {
+	if os.Geteuid() == 0 {
+		return systemConfigFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "environment.d", "telegram-notifier.conf"), nil
+}
+
+// checkConfig validates the configuration visible to this process
+func (d *doctor) checkConfig() *config.Config {
+	cfg, err := config.New()
+	if err != nil {
+		fix := "set TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID in the config file or environment"
+		if os.Getenv("NOTIFIER_TOKEN_SOURCE") == constants.TokenSourceKeyring {
+			fix = "run 'telegram-notifier init --keyring' in a desktop session to store the token; the keyring must be unlocked"
+		}
+		d.add("configuration", checkFail, validation.SanitizeErrorMessage(err), fix)
+		return nil
+	}
+	d.add("configuration", checkOK, "valid", "")
+	for _, warning := range cfg.Warnings() {
+		d.add("configuration", checkWarn, warning, "adjust the setting unless this is intended")
+	}
+	return cfg
+}
+
+// checkTelegram verifies connectivity and the bot token without sending a message
+func (d *doctor) checkTelegram(cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+	defer cancel()
+
+	start := time.Now()
+	username, err := telegram.NewClient(cfg, nil).GetMe(ctx)
+	if err != nil {
+		fix := "check network access to api.telegram.org (DNS, proxy, firewall)"
+		if telegram.IsPermanentError(err) {
+			fix = "check TELEGRAM_BOT_TOKEN; get a new token from @BotFather if it was revoked"
+		}
+		d.add("telegram", checkFail, validation.SanitizeErrorMessage(err), fix)
+		return
+	}
+	d.add("telegram", checkOK, fmt.Sprintf("reachable as @%s in %s; run 'telegram-notifier test' to verify the chat",
+		username, time.Since(start).Round(time.Millisecond)), "")
+}
+
+// checkChannels resolves chats configured by @username and checks the bot may post there
+// The numeric ID is shown since bot buttons and NOTIFIER_BOT_ALLOWED_CHATS need it
+func (d *doctor) checkChannels(cfg *config.Config) {
+	client := telegram.NewClient(cfg, nil)
+	for _, username := range channelTargets(cfg) {
+		name := "chat " + username
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+		chat, err := client.GetChat(ctx, username)
+		if err != nil {
+			cancel()
+			d.add(name, checkFail, validation.SanitizeErrorMessage(err),
+				"check the username; the channel or group must be public and the bot a member of it")
+			continue
+		}
+		member, err := client.GetBotMembership(ctx, username)
+		cancel()
+
+		detail := fmt.Sprintf("%s %q, numeric ID %d", chat.Type, chat.Title, chat.ID)
+		switch {
+		case err != nil:
+			d.add(name, checkWarn, detail+"; membership unknown: "+validation.SanitizeErrorMessage(err), "")
+		case !member.CanPost(chat.Type):
+			d.add(name, checkFail, detail+"; the bot can't post here",
+				"add the bot as an administrator with the \"Post messages\" right")
+		default:
+			d.add(name, checkOK, detail, "")
+		}
+	}
+}
+
+// checkForumTopics verifies every chat notifications go to is a forum where the bot may create topics
+func (d *doctor) checkForumTopics(cfg *config.Config) {
+	if !cfg.ForumTopics {
+		return
+	}
+	client := telegram.NewClient(cfg, nil)
+	for _, chatID := range targetChats(cfg) {
+		name := "forum topics in " + chatID
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+		chat, err := client.GetChat(ctx, chatID)
+		if err != nil {
+			cancel()
+			d.add(name, checkFail, validation.SanitizeErrorMessage(err), "check the chat ID and that the bot is a member")
+			continue
+		}
+		member, err := client.GetBotMembership(ctx, chatID)
+		cancel()
+
+		switch {
+		case !chat.IsForum:
+			d.add(name, checkWarn, "not a forum; notifications go to the chat as usual",
+				"enable Topics in the group's settings, or unset NOTIFIER_FORUM_TOPICS")
+		case err != nil:
+			d.add(name, checkWarn, "membership unknown: "+validation.SanitizeErrorMessage(err), "")
+		case member.Status != "creator" && !member.CanManageTopics:
+			d.add(name, checkFail, "the bot can't create topics; notifications go to the general topic",
+				"give the bot the \"Manage topics\" admin right")
+		default:
+			d.add(name, checkOK, "the bot creates a topic per service", "")
+		}
+	}
+}
+
+// targetChats lists the distinct chats notifications may go to
+func targetChats(cfg *config.Config) []string {
+	chats := []string{cfg.ChatID}
+	for _, chat := range cfg.SeverityChats {
+		if chat != "" && !slices.Contains(chats, chat) {
+			chats = append(chats, chat)
+		}
+	}
+	for _, p := range cfg.ServicePolicies {
+		if p.Chat != "" && !slices.Contains(chats, p.Chat) {
+			chats = append(chats, p.Chat)
+		}
+	}
+	slices.Sort(chats)
+	return chats
+}
+
+// channelTargets lists the distinct @username chats notifications may go to
+func channelTargets(cfg *config.Config) []string {
+	var usernames []string
+	for _, chat := range targetChats(cfg) {
+		if strings.HasPrefix(chat, "@") {
+			usernames = append(usernames, chat)
+		}
+	}
+	return usernames
+}
+
+// checkBotAccess summarizes who may use interactive bot commands
+func (d *doctor) checkBotAccess(cfg *config.Config) {
+	policy := cfg.GetBotPolicy()
+	switch {
+	case len(policy.Users) == 0:
+		d.add("bot access", checkOK, "interactive commands disabled (NOTIFIER_BOT_USERS not set)", "")
+	case len(policy.Chats) == 0:
+		d.add("bot access", checkWarn, "users are configured but no chat is allowed",
+			"set NOTIFIER_BOT_ALLOWED_CHATS to numeric chat IDs; channel usernames can't be matched")
+	default:
+		d.add("bot access", checkOK, fmt.Sprintf("%d user(s) in %d chat(s)", len(policy.Users), len(policy.Chats)), "")
+	}
+}
+
+// checkBotWebhook compares the webhook Telegram has registered with the configured mode and reports delivery errors
+// The daemon registers or removes the webhook when it starts, so a mismatch only means it hasn't run since the change
+func (d *doctor) checkBotWebhook(cfg *config.Config) {
+	if !cfg.GetBotPolicy().Enabled() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPTimeout)
+	defer cancel()
+	info, err := telegram.NewClient(cfg, nil).GetWebhookInfo(ctx)
+	if err != nil {
+		d.add("bot webhook", checkWarn, validation.SanitizeErrorMessage(err), "check network access to the Telegram API")
+		return
+	}
+
+	switch {
+	case cfg.BotWebhookURL == "" && info.URL == "":
+		d.add("bot webhook", checkOK, "not used; the daemon polls for commands", "")
+	case cfg.BotWebhookURL == "":
+		d.add("bot webhook", checkWarn, "registered at "+info.URL+" while NOTIFIER_BOT_WEBHOOK_URL is unset",
+			"restart the daemon, which removes the webhook and polls instead")
+	case info.URL != cfg.BotWebhookURL:
+		d.add("bot webhook", checkWarn, "not registered at "+cfg.BotWebhookURL, "restart the daemon, which registers it")
+	case info.LastErrorMessage != "":
+		at := cfg.FormatDateTime(time.Unix(info.LastErrorDate, 0))
+		d.add("bot webhook", checkWarn, fmt.Sprintf("Telegram's last delivery failed at %s: %s (%d update(s) waiting)", at, info.LastErrorMessage, info.PendingUpdateCount),
+			fmt.Sprintf("check that %s reaches %s and presents a certificate Telegram trusts", cfg.BotWebhookURL, cfg.BotWebhookAddr))
+	default:
+		d.add("bot webhook", checkOK, fmt.Sprintf("registered at %s, %d update(s) waiting", info.URL, info.PendingUpdateCount), "")
+	}
+}
+
+// checkUnitEnvironment warns about credentials set inline with Environment= in a unit's files
+// Those are readable by every local user via "systemctl show", so they belong in a private
+// EnvironmentFile= or LoadCredential= instead
+func (d *doctor) checkUnitEnvironment(service string) {
+	name := "unit environment (" + service + ")"
+	paths := unitFiles(service)
+	if len(paths) == 0 {
+		d.add(name, checkWarn, "unit not found in the user or system manager", "check the unit name")
+		return
+	}
+
+	findings := systemd.AuditEnvironment(paths)
+	if len(findings) == 0 {
+		d.add(name, checkOK, fmt.Sprintf("no inline credentials in %d file(s)", len(paths)), "")
+		return
+	}
+	for _, f := range findings {
+		d.add(name, checkWarn, fmt.Sprintf("%s:%d sets %s with Environment=, visible to all users in 'systemctl show'", f.Path, f.Line, f.Variable),
+			"move it to an EnvironmentFile= readable only by the service user (chmod 600) or use LoadCredential=")
+	}
+}
+
+// unitFiles returns a unit's fragment and drop-in paths, from the user manager first
+func unitFiles(service string) []string {
+	for _, scope := range [][]string{{"--user"}, nil} {
+		args := append(scope, "show", "--property=FragmentPath,DropInPaths", "--no-pager", "--", service)
+		output, _, err := runDiagnostic("systemctl", args...)
+		if err != nil {
+			continue
+		}
+
+		var paths []string
+		for _, line := range strings.Split(string(output), "\n") {
+			key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+			if key == "FragmentPath" || key == "DropInPaths" {
+				paths = append(paths, strings.Fields(value)...)
+			}
+		}
+		if len(paths) > 0 {
+			return paths
+		}
+	}
+	return nil
+}
+
+// serviceList collects repeated --service flags
+type serviceList []string
+
+func (l *serviceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *serviceList) Set(v string) error {
+	v = validation.NormalizeUnitName(v)
+	if err := validation.ValidateServiceName(v); err != nil {
+		return err
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+// runDiagnostic runs a fixed diagnostic command and returns stdout and stderr
+// SECURITY: Arguments are constants; exec.CommandContext avoids any shell
+func runDiagnostic(name string, args ...string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	return output, stderr.String(), err
+}
+
+// journalGroupMembership returns the first journal reader group the user belongs to
+func journalGroupMembership() string {
+	current, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	gids, err := current.GroupIds()
+	if err != nil {
+		return ""
+	}
+	for _, gid := range gids {
+		group, err := user.LookupGroupId(gid)
+		if err != nil {
+			continue
+		}
+		for _, name := range journalReaderGroups {
+			if group.Name == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// firstOutputLine returns the first line of command output
+func firstOutputLine(output []byte) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return line
+}
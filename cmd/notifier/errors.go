@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// Fatal error categories reported by --error-format=json
+// Wrappers use these to tell misconfiguration apart from delivery problems
+const (
+	categoryUsage      = "usage"      // Bad command-line arguments
+	categoryConfig     = "config"     // Invalid or incomplete configuration
+	categoryValidation = "validation" // Rejected input such as service names
+	categoryDelivery   = "delivery"   // Telegram or fallback delivery failed
+	categoryStorage    = "storage"    // Local files: history, unit files
+)
+
+// Stable error codes; messages may change, codes don't
+const (
+	codeInvalidArguments   = "invalid_arguments"
+	codeConfigInvalid      = "config_invalid"
+	codeSpoolDisabled      = "spool_disabled"
+	codeInvalidServiceName = "invalid_service_name"
+	codeInvalidInput       = "invalid_input"
+	codeDeliveryFailed     = "delivery_failed"
+	codeFlushFailed        = "flush_failed"
+	codeHistoryUnreadable  = "history_unreadable"
+	codeInstallFailed      = "install_failed"
+	codeHardeningFailed    = "hardening_failed"
+	codeInitFailed         = "init_failed"
+	codeCheckFailed        = "check_failed"
+	codeStateFailed        = "state_failed"
+	codeWatchFailed        = "watch_failed"
+)
+
+// errorReport is the --error-format=json object written to stderr
+type errorReport struct {
+	Code     string            `json:"code"`
+	Category string            `json:"category"`
+	Message  string            `json:"message"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// fatal reports an unrecoverable error and exits with status 1
+// args are slog key/value pairs; in JSON mode the error attribute joins the message
+// and the rest become details
+func fatal(category, code, msg string, args ...any) {
+	if errorFormat != errorFormatJSON {
+		logging.Fatal(msg, args...)
+	}
+
+	report := errorReport{Code: code, Category: category, Message: msg}
+	r := slog.NewRecord(time.Time{}, slog.LevelError, msg, 0)
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		value := a.Value.Resolve().String()
+		if a.Key == logging.KeyError {
+			report.Message += ": " + value
+			return true
+		}
+		if report.Details == nil {
+			report.Details = make(map[string]string)
+		}
+		report.Details[a.Key] = value
+		return true
+	})
+	writeErrorReport(report)
+	os.Exit(1)
+}
+
+// usageFatal reports invalid arguments, with usage help in text mode
+func usageFatal(msg string) {
+	if errorFormat == errorFormatJSON {
+		writeErrorReport(errorReport{Code: codeInvalidArguments, Category: categoryUsage, Message: msg})
+		os.Exit(1)
+	}
+	printError(msg)
+	printUsage()
+	os.Exit(1)
+}
+
+// writeErrorReport writes a single-line JSON error to stderr
+// SECURITY: Messages and details are filtered like log output
+func writeErrorReport(report errorReport) {
+	report.Message = validation.FilterSecrets(report.Message)
+	for k, v := range report.Details {
+		report.Details[k] = validation.FilterSecrets(v)
+	}
+	if err := json.NewEncoder(os.Stderr).Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", report.Message)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/socket"
+)
+
+// dispatch sends a notification through the daemon when one listens on the socket, otherwise directly
+// The daemon keeps connections to Telegram warm, so hooks return without TLS handshakes
+// Async mode spools locally instead, since the daemon answers only after its send
+func dispatch(ctx context.Context, cfg *config.Config, req sendRequest) (notifier.Report, error) {
+	if path := cfg.GetSocketPath(); path != "" && !cfg.Async {
+		// The daemon gets the full send timeout, so the answer may take slightly longer
+		socketCtx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout+socket.ResponseGrace)
+		defer cancel()
+
+		resp, err := socket.Send(socketCtx, path, socket.Request{
+			ServiceName: req.serviceName,
+			ExitInfo:    req.exitInfo,
+			ServiceDesc: req.serviceDesc,
+			Message:     req.customMessage,
+			Logs:        req.logs,
+			Title:       req.title,
+		})
+		switch {
+		case err == nil:
+			slog.Debug("Sent through daemon", "socket", path)
+			return resp.Report, resp.Err(req.serviceName)
+		case !errors.Is(err, socket.ErrUnavailable):
+			// The daemon may have sent it already; sending again could duplicate the message
+			return notifier.Report{}, err
+		}
+		slog.Debug("Daemon not reachable, sending directly", logging.Err(err))
+	}
+
+	notifierService := newNotifierService(cfg)
+	if req.title != "" {
+		return notifierService.SendMessage(ctx, req.title, req.customMessage)
+	}
+	return notifierService.SendServiceNotification(ctx, req.exitInfo, req.serviceName, req.serviceDesc, req.customMessage, req.logs)
+}
+
+// serveSocket answers fast-path requests from hooks until ctx is cancelled
+// Requests are sent with the daemon's own service, right away: queueing them for its spool would only add delay
+func serveSocket(ctx context.Context, cfg *config.Config, notifierService *notifier.Service) {
+	path := cfg.GetSocketPath()
+	listener, err := socket.Listen(path)
+	if err != nil {
+		slog.Warn("Socket fast path unavailable, hooks will send directly", "socket", path, logging.Err(err))
+		return
+	}
+
+	slog.Info("Accepting notifications on socket", "socket", path)
+	socket.Serve(ctx, listener, cfg.CommandTimeout, func(ctx context.Context, req socket.Request) (notifier.Report, error) {
+		defer flushTraces()
+		if req.Title != "" {
+			return notifierService.SendMessage(ctx, req.Title, req.Message)
+		}
+		return notifierService.SendServiceNotification(ctx, req.ExitInfo, req.ServiceName, req.ServiceDesc, req.Message, req.Logs)
+	})
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"telegram-notifier/internal/logging"
+)
+
+// runFlush retries delivery of spooled notifications and reports the outcome
+func runFlush(args []string) {
+	cfg := loadConfig()
+	harden(cfg)
+	if !cfg.SpoolEnabled {
+		fatal(categoryConfig, codeSpoolDisabled, "Spool is disabled (NOTIFIER_SPOOL_ENABLED=false)")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	result, err := newNotifierService(cfg).FlushSpool(ctx)
+	flushTraces()
+	if err != nil {
+		fatal(categoryDelivery, codeFlushFailed, "Flush failed", "delivered", result.Delivered, "remaining", result.Remaining, logging.Err(err))
+	}
+	fmt.Printf("Flushed spool: %d notification(s) delivered\n", result.Delivered)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/initsys"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/sandbox"
+	"telegram-notifier/internal/systemd"
+)
+
+// sandboxReadOnly are system locations the notifier and its children read:
+// libraries, certificates, unit files, /proc and /sys for health snapshots, and the journal
+var sandboxReadOnly = []string{
+	"/etc", "/usr", "/lib", "/lib64", "/bin", "/sbin",
+	"/proc", "/sys", "/run", "/dev", "/var/log/journal",
+}
+
+// sandboxLoaders match the dynamic loader, which the kernel executes for every dynamic binary
+var sandboxLoaders = []string{"/lib*/ld-*.so*", "/usr/lib*/ld-*.so*", "/lib/*/ld-*.so*", "/usr/lib/*/ld-*.so*"}
+
+// harden drops root privileges and applies the sandbox when configured
+// Runs after configuration is loaded, so credentials are already in memory
+func harden(cfg *config.Config) {
+	if cfg.RunAsUser != "" && os.Geteuid() == 0 {
+		if err := sandbox.DropPrivileges(cfg.RunAsUser); err != nil {
+			fatal(categoryConfig, codeHardeningFailed, "Dropping privileges failed", "user", cfg.RunAsUser, logging.Err(err))
+		}
+		slog.Debug("Dropped privileges", "user", cfg.RunAsUser)
+	}
+
+	if !cfg.Sandbox {
+		return
+	}
+	err := sandbox.Restrict(sandboxPolicy(cfg))
+	if errors.Is(err, sandbox.ErrUnsupported) {
+		// Best effort: an old kernel shouldn't cost the notification
+		slog.Warn("Sandbox not applied", logging.Err(err))
+		return
+	}
+	if err != nil {
+		fatal(categoryConfig, codeHardeningFailed, "Applying sandbox failed", logging.Err(err))
+	}
+	slog.Debug("Sandbox applied")
+}
+
+// sandboxPolicy allows writing only to notifier state and executing only systemctl,
+// journalctl, the configured init system's commands and configured version commands
+func sandboxPolicy(cfg *config.Config) sandbox.Policy {
+	policy := sandbox.Policy{
+		ReadOnly: append([]string(nil), sandboxReadOnly...),
+		ReadWrite: []string{
+			"/dev/null",
+			cfg.StateDir,
+			cfg.GetSpoolDir(),
+			filepath.Dir(cfg.GetHistoryFile()),
+			filepath.Dir(cfg.GetDeadLetterFile()),
+		},
+	}
+	if socketPath := cfg.GetSocketPath(); socketPath != "" {
+		policy.ReadWrite = append(policy.ReadWrite, filepath.Dir(socketPath))
+	}
+	if filepath.IsAbs(cfg.SyslogListen) {
+		policy.ReadWrite = append(policy.ReadWrite, filepath.Dir(cfg.SyslogListen))
+	}
+	if cfg.LivenessFile != "" {
+		policy.ReadWrite = append(policy.ReadWrite, filepath.Dir(cfg.LivenessFile))
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		policy.ReadOnly = append(policy.ReadOnly,
+			filepath.Join(home, ".config/systemd"),
+			filepath.Join(home, ".local/share/systemd"))
+	}
+
+	for _, name := range append([]string{"systemctl", "journalctl"}, initsys.Commands(cfg.InitSystem)...) {
+		if path, err := exec.LookPath(name); err == nil {
+			policy.Exec = append(policy.Exec, path)
+		}
+	}
+	for _, pattern := range sandboxLoaders {
+		matches, _ := filepath.Glob(pattern)
+		policy.Exec = append(policy.Exec, matches...)
+	}
+
+	// Log files are read along with their rotated copies next to them
+	for _, path := range cfg.LogFiles {
+		policy.ReadOnly = append(policy.ReadOnly, filepath.Dir(path))
+	}
+	if len(cfg.Containers) > 0 {
+		policy.ReadOnly = append(policy.ReadOnly, filepath.Dir(cfg.ContainerSocket))
+	}
+	policy.ReadOnly = append(policy.ReadOnly, initsys.ReadPaths(cfg)...)
+
+	// Version sources name their files and binaries explicitly; execstart can't be known upfront
+	for _, source := range cfg.VersionSources {
+		kind, arg, _ := strings.Cut(source, ":")
+		switch kind {
+		case systemd.VersionSourceFile:
+			policy.ReadOnly = append(policy.ReadOnly, arg)
+		case systemd.VersionSourceCommand:
+			if fields := strings.Fields(arg); len(fields) > 0 {
+				policy.Exec = append(policy.Exec, fields[0])
+			}
+		}
+	}
+	return policy
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/metrics"
+	"telegram-notifier/internal/spool"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// Health status values reported by /healthz
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+)
+
+// daemonHealth tracks the daemon's own state for supervision
+// It observes deliveries like a metrics collector, so it sees every Telegram contact
+type daemonHealth struct {
+	mu                  sync.Mutex
+	started             time.Time
+	lastTelegramSuccess time.Time
+	lastFlush           time.Time
+	lastFlushErr        string
+	spool               *spool.Spool
+	livenessFile        string
+}
+
+// healthReport is the /healthz response body
+type healthReport struct {
+	Status              string     `json:"status"`
+	UptimeSeconds       int64      `json:"uptime_seconds"`
+	LastTelegramSuccess *time.Time `json:"last_telegram_success,omitempty"`
+	LastFlush           *time.Time `json:"last_flush,omitempty"`
+	LastFlushError      string     `json:"last_flush_error,omitempty"`
+	SpoolEntries        int        `json:"spool_entries"`
+}
+
+func newDaemonHealth(sp *spool.Spool, livenessFile string) *daemonHealth {
+	return &daemonHealth{started: time.Now(), spool: sp, livenessFile: livenessFile}
+}
+
+// Observe records successful Telegram deliveries
+func (h *daemonHealth) Observe(obs metrics.Observation) {
+	if obs.Result != history.ResultDelivered || obs.Backend != telegram.BackendName {
+		return
+	}
+	h.mu.Lock()
+	h.lastTelegramSuccess = time.Now()
+	h.mu.Unlock()
+}
+
+// recordFlush notes the outcome of a flush cycle and touches the liveness file on success
+func (h *daemonHealth) recordFlush(err error) {
+	h.mu.Lock()
+	h.lastFlush = time.Now()
+	h.lastFlushErr = ""
+	if err != nil {
+		h.lastFlushErr = validation.SanitizeErrorMessage(err)
+	}
+	h.mu.Unlock()
+
+	if err == nil && h.livenessFile != "" {
+		if err := touchFile(h.livenessFile); err != nil {
+			slog.Warn("Updating liveness file failed", "path", h.livenessFile, logging.Err(err))
+		}
+	}
+}
+
+// report snapshots the current health
+// A failing flush with notifications still waiting means delivery is stuck
+func (h *daemonHealth) report() healthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := healthReport{
+		Status:         healthOK,
+		UptimeSeconds:  int64(time.Since(h.started).Seconds()),
+		LastFlushError: h.lastFlushErr,
+		SpoolEntries:   h.spool.Len(),
+	}
+	if !h.lastTelegramSuccess.IsZero() {
+		t := h.lastTelegramSuccess
+		r.LastTelegramSuccess = &t
+	}
+	if !h.lastFlush.IsZero() {
+		t := h.lastFlush
+		r.LastFlush = &t
+	}
+	if h.lastFlushErr != "" && r.SpoolEntries > 0 {
+		r.Status = healthDegraded
+	}
+	return r
+}
+
+// ServeHTTP serves /healthz; degraded health returns 503 so probes can act on it
+func (h *daemonHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.report()
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != healthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// touchFile creates path if needed and sets its modification time to now
+func touchFile(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	now := time.Now()
+	return os.Chtimes(path, now, now)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/heartbeat"
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/spool"
+)
+
+// heartbeatTitle heads the "all quiet" Telegram message
+const heartbeatTitle = "Heartbeat"
+
+// runHeartbeat signals that the notifier is alive, for timer-driven dead-man switches
+// With NOTIFIER_HEARTBEAT_URL it pings the monitoring service; otherwise it sends an "all quiet" message
+func runHeartbeat(args []string) {
+	cfg := loadConfig()
+	harden(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	// Notifications stuck in the spool mean Telegram is unreachable, which a heartbeat shouldn't hide
+	pending := 0
+	if cfg.SpoolEnabled {
+		pending = spool.New(cfg.GetSpoolDir(), cfg.SpoolMaxEntries, cfg.SpoolMaxAttempts).Len()
+	}
+
+	err := sendHeartbeat(ctx, cfg, newNotifierService(cfg), pending == 0, pending)
+	flushTraces()
+	if err != nil {
+		fatal(categoryDelivery, codeDeliveryFailed, "Heartbeat failed", logging.Err(err))
+	}
+	if !quiet {
+		fmt.Println("Heartbeat sent")
+	}
+}
+
+// sendHeartbeat pings the configured URL, or sends an "all quiet" Telegram message without one
+// healthy=false pings the failure endpoint so the monitoring service alerts immediately
+func sendHeartbeat(ctx context.Context, cfg *config.Config, notifierService *notifier.Service, healthy bool, pending int) error {
+	if cfg.HeartbeatURL != "" {
+		pinger := heartbeat.NewPinger(cfg.HeartbeatURL, httpclient.New(cfg))
+		return pinger.Ping(ctx, healthy)
+	}
+
+	message := "All quiet: the notifier is running."
+	if pending > 0 {
+		message = fmt.Sprintf("The notifier is running, but %d notification(s) are waiting in the spool.", pending)
+	}
+	_, err := notifierService.SendMessage(ctx, heartbeatTitle, message)
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// runHistory prints recorded notification attempts from the audit log
+func runHistory(args []string) {
+	cfg := loadConfig()
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	service := fs.String("service", "", "only show notifications for this service")
+	var since sinceFlag
+	fs.Var(&since, "since", "only show notifications newer than this (e.g. 24h, 7d)")
+	limit := fs.Int("limit", 20, "max records to show (0 = all)")
+	fs.Parse(args)
+
+	if *service != "" {
+		*service = validation.NormalizeUnitName(*service)
+		if err := validation.ValidateServiceName(*service); err != nil {
+			fatal(categoryValidation, codeInvalidServiceName, "Invalid service name", logging.Err(err))
+		}
+	}
+
+	filter := history.Filter{Service: *service, Limit: *limit}
+	if since > 0 {
+		filter.Since = time.Now().Add(-time.Duration(since))
+	}
+
+	records, err := history.New(cfg.GetHistoryFile(), constants.HistoryMaxFileSize).Query(filter)
+	if err != nil {
+		fatal(categoryStorage, codeHistoryUnreadable, "Reading history failed", logging.Err(err))
+	}
+	if len(records) == 0 {
+		fmt.Println("No notifications recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSERVICE\tRESULT\tBACKEND\tATTEMPTS\tHTTP\tLATENCY\tHASH\tERROR")
+	for _, rec := range records {
+		if rec.IsAction() {
+			detail := "by " + rec.By
+			if rec.Error != "" {
+				detail += ": " + rec.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t-\t-\t%s\n", cfg.FormatDateTime(rec.Time), rec.Service, rec.Result, detail)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%dms\t%s\t%s\n",
+			cfg.FormatDateTime(rec.Time), rec.Service, rec.Result, rec.Backend,
+			rec.Attempts, formatStatuses(rec.AttemptLog), rec.LatencyMS, rec.MessageHash, rec.Error)
+	}
+	w.Flush()
+}
+
+// formatStatuses lists per-attempt HTTP statuses, "-" for requests without a response
+func formatStatuses(attempts []history.Attempt) string {
+	if len(attempts) == 0 {
+		return "-"
+	}
+	statuses := make([]string, len(attempts))
+	for i, a := range attempts {
+		statuses[i] = "-"
+		if a.Status != 0 {
+			statuses[i] = strconv.Itoa(a.Status)
+		}
+	}
+	return strings.Join(statuses, ",")
+}
+
+// sinceFlag is a look-back duration that also accepts whole days ("7d")
+type sinceFlag time.Duration
+
+func (f *sinceFlag) String() string {
+	return time.Duration(*f).String()
+}
+
+func (f *sinceFlag) Set(value string) error {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid day count %q", value)
+		}
+		*f = sinceFlag(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+	*f = sinceFlag(d)
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/keyring"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// Environment variables written by init
+const (
+	botTokenVar    = "TELEGRAM_BOT_TOKEN"
+	chatIDVar      = "TELEGRAM_CHAT_ID"
+	tokenSourceVar = "NOTIFIER_TOKEN_SOURCE"
+)
+
+// managerEnvPrefix wraps assignments in system.conf.d files: DefaultEnvironment="KEY=value"
+const managerEnvPrefix = "DefaultEnvironment="
+
+// runInit creates or updates the config file, optionally moving the bot token into the keyring
+// Existing settings are kept, so running it on a working setup migrates it in place
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	useKeyring := fs.Bool("keyring", false, "store the bot token in the Secret Service keyring instead of the config file")
+	fs.Parse(args)
+
+	// The Secret Service lives on a login session's bus; the system manager has none
+	if *useKeyring && os.Geteuid() == 0 {
+		fatal(categoryUsage, codeInvalidArguments, "--keyring is only available for user services; run init as the user owning them")
+	}
+
+	path, err := configFilePath()
+	if err != nil {
+		fatal(categoryStorage, codeInitFailed, "Cannot determine config file location", logging.Err(err))
+	}
+	file, err := readEnvFile(path)
+	if err != nil {
+		fatal(categoryStorage, codeInitFailed, "Reading config file failed", "path", path, logging.Err(err))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), constants.KeyringTimeout)
+	defer cancel()
+
+	stdin := bufio.NewReader(os.Stdin)
+	token := file.value(botTokenVar, os.Getenv(botTokenVar))
+	tokenStored := false
+	if token == "" && *useKeyring {
+		// Re-running after a migration keeps the stored token
+		if stored, err := keyring.LookupBotToken(ctx); err == nil {
+			token, tokenStored = stored, true
+		}
+	}
+	if token == "" {
+		token = promptValue(stdin, "Bot token from @BotFather")
+	}
+	chatID := file.value(chatIDVar, os.Getenv(chatIDVar))
+	if chatID == "" {
+		chatID = promptValue(stdin, "Chat ID")
+	}
+
+	for name, value := range map[string]string{botTokenVar: token, chatIDVar: chatID} {
+		if err := validateEnvValue(value); err != nil {
+			fatal(categoryValidation, codeInvalidInput, "Invalid "+name, logging.Err(err))
+		}
+	}
+	if err := validation.ValidateBotToken(token); err != nil {
+		fatal(categoryValidation, codeInvalidInput, "Invalid "+botTokenVar, logging.Err(err))
+	}
+	if err := validation.ValidateChatID(chatID); err != nil {
+		fatal(categoryValidation, codeInvalidInput, "Invalid "+chatIDVar, logging.Err(err))
+	}
+
+	file.set(chatIDVar, chatID)
+	if *useKeyring {
+		if !tokenStored {
+			if err := storeBotToken(ctx, token); err != nil {
+				fatal(categoryStorage, codeInitFailed, "Storing bot token in keyring failed", logging.Err(err))
+			}
+		}
+		// SECURITY: The plaintext copy is removed only after the keyring returned it intact
+		file.remove(botTokenVar)
+		file.set(tokenSourceVar, constants.TokenSourceKeyring)
+	} else {
+		file.set(botTokenVar, token)
+		file.remove(tokenSourceVar)
+	}
+
+	if err := file.write(); err != nil {
+		fatal(categoryStorage, codeInitFailed, "Writing config file failed", "path", path, logging.Err(err))
+	}
+
+	if *useKeyring {
+		fmt.Println("Bot token stored in the keyring")
+	}
+	fmt.Printf("Wrote %s\n", path)
+	if os.Geteuid() == 0 {
+		fmt.Println("Run 'systemctl daemon-reload' to apply it, then 'telegram-notifier doctor' to check the setup")
+	} else {
+		// environment.d is only read when the user manager starts
+		fmt.Println("Run 'systemctl --user daemon-reexec' (or log in again) to apply it, then 'telegram-notifier doctor' to check the setup")
+	}
+}
+
+// storeBotToken saves the token and reads it back, so a keyring that silently drops writes is caught
+func storeBotToken(ctx context.Context, token string) error {
+	if err := keyring.StoreBotToken(ctx, token); err != nil {
+		return err
+	}
+	stored, err := keyring.LookupBotToken(ctx)
+	if err != nil {
+		return err
+	}
+	if stored != token {
+		return fmt.Errorf("keyring returned a different token than was stored")
+	}
+	return nil
+}
+
+// promptValue asks for a required value on stdin, exiting when none is given
+func promptValue(stdin *bufio.Reader, label string) string {
+	fmt.Fprintf(os.Stderr, "%s: ", label)
+	line, err := stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		fatal(categoryUsage, codeInvalidInput, "Reading "+label+" failed", logging.Err(err))
+	}
+	value := strings.TrimSpace(line)
+	if value == "" {
+		fatal(categoryUsage, codeInvalidInput, label+" is required")
+	}
+	return value
+}
+
+// validateEnvValue rejects values that would break or extend the environment file
+// SECURITY: A newline would let the value inject further assignments
+func validateEnvValue(value string) error {
+	if strings.ContainsAny(value, " \t\r\n\"'\\") {
+		return fmt.Errorf("must not contain whitespace, quotes or backslashes")
+	}
+	return nil
+}
+
+// envFile is a systemd environment file edited in place; comments and unrelated settings are kept
+type envFile struct {
+	path    string
+	manager bool // system.conf.d syntax
+	lines   []string
+}
+
+// readEnvFile loads path, starting an empty file when it doesn't exist yet
+func readEnvFile(path string) (*envFile, error) {
+	f := &envFile{path: path, manager: path == systemConfigFile}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if f.manager {
+			f.lines = []string{"[Manager]"}
+		}
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	return f, nil
+}
+
+// assignment parses line as KEY=value in the file's syntax; comments and sections are not assignments
+func (f *envFile) assignment(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if f.manager {
+		rest, found := strings.CutPrefix(line, managerEnvPrefix)
+		if !found {
+			return "", "", false
+		}
+		line = strings.Trim(rest, `"`)
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	return strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`), ok
+}
+
+// value returns the last assignment of key, or fallback when the file doesn't set it
+func (f *envFile) value(key, fallback string) string {
+	result := fallback
+	for _, line := range f.lines {
+		if k, v, ok := f.assignment(line); ok && k == key && v != "" {
+			result = v
+		}
+	}
+	return result
+}
+
+// set replaces the first assignment of key and drops any others, appending when there is none
+func (f *envFile) set(key, value string) {
+	entry := key + "=" + value
+	if f.manager {
+		entry = managerEnvPrefix + `"` + entry + `"`
+	}
+
+	replaced := false
+	lines := f.lines[:0]
+	for _, line := range f.lines {
+		if k, _, ok := f.assignment(line); ok && k == key {
+			if replaced {
+				continue
+			}
+			line, replaced = entry, true
+		}
+		lines = append(lines, line)
+	}
+	if !replaced {
+		lines = append(lines, entry)
+	}
+	f.lines = lines
+}
+
+// remove drops every assignment of key
+func (f *envFile) remove(key string) {
+	lines := f.lines[:0]
+	for _, line := range f.lines {
+		if k, _, ok := f.assignment(line); ok && k == key {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+}
+
+// write atomically replaces the file, readable by its owner only
+// SECURITY: The file may hold the bot token; the temp file is created 0600 before any content is written
+func (f *envFile) write() error {
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".telegram-notifier-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strings.Join(f.lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
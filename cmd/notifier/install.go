@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// System-wide unit directory used with --system
+const systemUnitDir = "/etc/systemd/system"
+
+// handlerUnitName is the OnFailure= template unit referenced by monitored services
+const handlerUnitName = "telegram-notify@.service"
+
+// handlerUnitTemplate mirrors the sample unit; %s is replaced with the binary path
+const handlerUnitTemplate = `# Universal template service for sending Telegram notifications
+# Installed by telegram-notifier install
+
+[Unit]
+Description=Send Telegram notification for service %%i
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+
+# %%i = service name that triggered this notification
+ExecStart=%s %%i
+`
+
+// runInstall writes the notification handler template unit for user or system services
+func runInstall(args []string) {
+	fs := flag.NewFlagSet("install", flag.ExitOnError)
+	system := fs.Bool("system", false, "install for system services instead of user services")
+	force := fs.Bool("force", false, "overwrite an existing unit file")
+	fs.Parse(args)
+
+	binary, err := os.Executable()
+	if err != nil {
+		fatal(categoryStorage, codeInstallFailed, "Cannot determine binary path", logging.Err(err))
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	unitDir := systemUnitDir
+	reloadCmd := "sudo systemctl daemon-reload"
+	if !*system {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fatal(categoryStorage, codeInstallFailed, "Cannot determine home directory", logging.Err(err))
+		}
+		unitDir = filepath.Join(home, ".config", "systemd", "user")
+		reloadCmd = "systemctl --user daemon-reload"
+	}
+
+	if err := os.MkdirAll(unitDir, 0o755); err != nil {
+		fatal(categoryStorage, codeInstallFailed, "Creating unit directory failed", logging.Err(err))
+	}
+
+	unitPath, err := validation.SanitizePath(unitDir, handlerUnitName)
+	if err != nil {
+		fatal(categoryStorage, codeInstallFailed, "Invalid unit path", logging.Err(err))
+	}
+
+	if _, err := os.Stat(unitPath); err == nil && !*force {
+		fatal(categoryStorage, codeInstallFailed, "Unit file already exists (use --force to overwrite)", "path", unitPath)
+	}
+
+	content := fmt.Sprintf(handlerUnitTemplate, binary)
+	if err := os.WriteFile(unitPath, []byte(content), 0o644); err != nil {
+		fatal(categoryStorage, codeInstallFailed, "Writing unit file failed", logging.Err(err))
+	}
+
+	fmt.Printf("Installed %s\n\n", unitPath)
+	fmt.Println("Next steps:")
+	fmt.Printf("  1. Reload systemd: %s\n", reloadCmd)
+	fmt.Println("  2. Add to monitored services: OnFailure=telegram-notify@%n.service")
+	fmt.Println("  3. Verify delivery: telegram-notifier test")
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/kube"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/validation"
+)
+
+// kubeRetryDelay spaces out reconnects after the API server or network failed
+const kubeRetryDelay = 10 * time.Second
+
+// defaultKubeLogLines is how much of the container log a notification includes
+const defaultKubeLogLines = 50
+
+// defaultKubeWorkers bounds how many finished jobs are reported at once
+// Reading pod logs is the slow part; sends still queue behind the Telegram rate limiter
+const defaultKubeWorkers = 4
+
+// runKube watches Kubernetes Jobs and notifies when they fail, or finish with --always
+// Uses the pod's service account in-cluster, otherwise the current kubeconfig context via kubectl
+func runKube(args []string) {
+	fs := flag.NewFlagSet("kube", flag.ExitOnError)
+	kubeconfig := fs.String("kubeconfig", "", "kubeconfig file (default: KUBECONFIG or ~/.kube/config, in-cluster config inside a pod)")
+	kubeContext := fs.String("context", "", "kubeconfig context (default: current context)")
+	namespace := fs.String("namespace", "", "namespace to watch (default: the context's or service account's)")
+	allNamespaces := fs.Bool("all-namespaces", false, "watch jobs in every namespace")
+	selector := fs.String("selector", "", "only watch jobs matching this label selector (e.g. team=data)")
+	always := fs.Bool("always", false, "notify about completed jobs too, not only failed ones")
+	lines := fs.Int("lines", defaultKubeLogLines, "container log lines included in notifications")
+	workers := fs.Int("workers", defaultKubeWorkers, "jobs reported concurrently when several finish together")
+	fs.Parse(args)
+	if *workers < 1 {
+		usageFatal("--workers must be at least 1")
+	}
+
+	cfg := loadConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	kcfg, err := loadKubeConfig(ctx, cfg, *kubeconfig, *kubeContext)
+	if err != nil {
+		fatal(categoryConfig, codeConfigInvalid, "Loading Kubernetes configuration failed", logging.Err(err))
+	}
+
+	// kubectl has resolved the credentials; the watch itself only needs the network
+	harden(cfg)
+
+	client := kube.NewClient(kcfg)
+	w := &jobWatcher{
+		client:  client,
+		service: newNotifierService(cfg),
+		cfg:     cfg,
+		seen:    map[string]bool{},
+		started: time.Now(),
+		always:  *always,
+		lines:   *lines,
+		pending: make(chan finishedJob, *workers),
+	}
+	switch {
+	case *allNamespaces:
+		w.namespace = ""
+	case *namespace != "":
+		w.namespace = *namespace
+	default:
+		w.namespace = client.Namespace()
+	}
+	w.selector = *selector
+	w.start(*workers)
+	defer w.stop()
+
+	// Fail fast on missing RBAC permissions or a wrong server instead of retrying forever
+	resourceVersion, err := w.list(ctx)
+	if err != nil {
+		fatal(categoryConfig, codeWatchFailed, "Listing Kubernetes jobs failed", logging.Err(err))
+	}
+	slog.Info("Watching Kubernetes jobs", "namespace", w.namespaceLabel(), "selector", w.selector)
+
+	for ctx.Err() == nil {
+		resourceVersion, err = client.WatchJobs(ctx, w.namespace, w.selector, resourceVersion, w.observe)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		if !errors.Is(err, kube.ErrGone) {
+			slog.Warn("Watching Kubernetes jobs failed, retrying", "retry_in", kubeRetryDelay, logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(kubeRetryDelay):
+			}
+		}
+		// Events may have been missed; listing again catches jobs that finished meanwhile
+		if rv, err := w.list(ctx); err == nil {
+			resourceVersion = rv
+		} else if ctx.Err() == nil {
+			slog.Warn("Listing Kubernetes jobs failed", logging.Err(err))
+		}
+	}
+	slog.Info("Stopped watching Kubernetes jobs")
+}
+
+// loadKubeConfig picks the in-cluster service account unless a kubeconfig was asked for
+func loadKubeConfig(ctx context.Context, cfg *config.Config, path, contextName string) (kube.Config, error) {
+	if path == "" && contextName == "" && kube.InCluster() {
+		return kube.InClusterConfig()
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	return kube.KubeconfigConfig(ctx, path, contextName)
+}
+
+// jobWatcher turns job status changes into notifications, once per job
+type jobWatcher struct {
+	client    *kube.Client
+	service   *notifier.Service
+	cfg       *config.Config
+	namespace string // Empty watches all namespaces
+	selector  string
+	seen      map[string]bool // UIDs of finished jobs already handled
+	started   time.Time       // Jobs finished before this are history, not news
+	always    bool
+	lines     int
+	pending   chan finishedJob // Jobs waiting for a worker; a full queue holds up the watch
+	workers   sync.WaitGroup
+}
+
+// finishedJob is a job to report with the condition that finished it
+type finishedJob struct {
+	job       kube.Job
+	condition *kube.Condition
+}
+
+// start runs the workers reporting finished jobs
+func (w *jobWatcher) start(workers int) {
+	for range workers {
+		w.workers.Add(1)
+		go func() {
+			defer w.workers.Done()
+			for f := range w.pending {
+				w.notify(f.job, f.condition)
+			}
+		}()
+	}
+}
+
+// stop waits for queued and in-flight reports, each bounded by its own timeout
+func (w *jobWatcher) stop() {
+	close(w.pending)
+	w.workers.Wait()
+}
+
+// list handles the current jobs and returns the resource version to watch from
+func (w *jobWatcher) list(ctx context.Context) (string, error) {
+	jobs, resourceVersion, err := w.client.ListJobs(ctx, w.namespace, w.selector)
+	if err != nil {
+		return "", err
+	}
+	for _, job := range jobs {
+		w.observe(kube.EventAdded, job)
+	}
+	return resourceVersion, nil
+}
+
+// observe queues a job for notification the first time it is seen finished
+// Only the watch goroutine calls it, so seen needs no locking
+func (w *jobWatcher) observe(eventType string, job kube.Job) {
+	uid := job.Metadata.UID
+	if eventType == kube.EventDeleted {
+		delete(w.seen, uid)
+		return
+	}
+	finished := job.Finished()
+	if finished == nil || w.seen[uid] {
+		return
+	}
+	w.seen[uid] = true
+
+	// Successes without --always are still recorded, so a job that recovers resets its failures
+	if finished.LastTransitionTime.Before(w.started) {
+		return
+	}
+	w.pending <- finishedJob{job: job, condition: finished}
+}
+
+// notify sends the job result with the tail of its container log
+func (w *jobWatcher) notify(job kube.Job, finished *kube.Condition) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.CommandTimeout)
+	defer cancel()
+
+	run := notifier.JobRun{
+		Name:    kubeJobName(job),
+		Command: fmt.Sprintf("Kubernetes Job %s/%s", job.Metadata.Namespace, job.Metadata.Name),
+		Runtime: job.Runtime(),
+		Quiet:   !w.always,
+	}
+	output, exitCode := w.jobOutput(ctx, job)
+	switch {
+	case job.Succeeded():
+		run.ExitCode = 0
+	case exitCode > 0:
+		run.ExitCode = exitCode
+	default:
+		// Deadlines and evictions fail jobs without a container exit code
+		run.ExitCode = 1
+	}
+	if !job.Succeeded() {
+		output = strings.TrimSpace(output + fmt.Sprintf("\n\nJob failed: %s: %s", finished.Reason, finished.Message))
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	run.Output = output
+
+	_, err := w.service.SendJobNotification(ctx, run)
+	flushTraces()
+	switch {
+	case errors.Is(err, notifier.ErrSpooled):
+		slog.Warn("Delivery failed, notification spooled for retry", logging.KeyService, run.Name, logging.Err(err))
+	case err != nil:
+		slog.Error("Notification failed", logging.KeyService, run.Name, logging.Err(err))
+	default:
+		slog.Info("Notified about Kubernetes job", logging.KeyService, run.Name, "job", job.Metadata.Name, "succeeded", job.Succeeded())
+	}
+}
+
+// jobOutput returns the log tail of the job's latest pod and the exit code of its failed container
+// Pods may already be gone; the notification is sent without logs then
+func (w *jobWatcher) jobOutput(ctx context.Context, job kube.Job) (string, int) {
+	pods, err := w.client.JobPods(ctx, job)
+	if err != nil {
+		return "Unable to list job pods: " + validation.SanitizeErrorMessage(err), -1
+	}
+	if len(pods) == 0 {
+		return "", -1
+	}
+
+	// Prefer the newest pod that failed; with retries, earlier ones failed the same way
+	pod := pods[0]
+	container, exitCode := pod.FailedContainer()
+	for _, p := range pods {
+		if name, code := p.FailedContainer(); code > 0 {
+			pod, container, exitCode = p, name, code
+			break
+		}
+	}
+
+	log, err := w.client.PodLog(ctx, pod, container, w.lines, constants.MaxStdinSize)
+	if err != nil {
+		return "Unable to read pod log: " + validation.SanitizeErrorMessage(err), exitCode
+	}
+	return strings.TrimSpace(log), exitCode
+}
+
+// kubeJobName labels notifications and history by CronJob, so runs of a schedule group together
+// Names are cut to the job name limit; Kubernetes names only use characters it allows
+func kubeJobName(job kube.Job) string {
+	name := job.CronJob()
+	if name == "" {
+		name = job.Metadata.Name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// namespaceLabel describes the watched namespaces for logs
+func (w *jobWatcher) namespaceLabel() string {
+	if w.namespace == "" {
+		return "(all)"
+	}
+	return w.namespace
+}
@@ -3,23 +3,97 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"strconv"
+	"slices"
 	"strings"
 
+	"telegram-notifier/internal/backend"
 	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/deadletter"
+	"telegram-notifier/internal/heartbeat"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/initsys"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/logsource"
 	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/spool"
+	"telegram-notifier/internal/state"
 	"telegram-notifier/internal/systemd"
 	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/tracing"
 	"telegram-notifier/internal/validation"
 )
 
+// subcommand is a named entry point of the CLI
+type subcommand struct {
+	summary string
+	run     func(args []string)
+}
+
+// subcommands maps names to handlers; anything else falls through to legacy send parsing
+// Populated in init since handlers reference printUsage, which lists subcommands
+var subcommands map[string]subcommand
+
+func init() {
+	subcommands = map[string]subcommand{
+		"send":        {"Send a service notification", runSend},
+		"test":        {"Send a test message to verify configuration", runTest},
+		"install":     {"Install the telegram-notify@.service handler unit", runInstall},
+		"flush":       {"Retry notifications spooled while Telegram was unreachable", runFlush},
+		"history":     {"Show recorded notification attempts", runHistory},
+		"stats":       {"Summarize recent notification activity per service", runStats},
+		"daemon":      {"Deliver spooled notifications in the background", runDaemon},
+		"doctor":      {"Diagnose systemd, journal, configuration and Telegram setup", runDoctor},
+		"heartbeat":   {"Signal that the notifier is alive (ping URL or \"all quiet\" message)", runHeartbeat},
+		"run":         {"Run a command and notify when it fails (cron jobs, scripts)", runRun},
+		"smart":       {"Check disk health with smartctl and notify about new problems", runSmart},
+		"updates":     {"Summarize package updates and pending reboots from apt/dnf logs", runUpdates},
+		"kube":        {"Watch Kubernetes Jobs and notify when they fail", runKube},
+		"init":        {"Create or migrate the config file, optionally moving the bot token into the keyring", runInit},
+		"snooze":      {"Mute one unit or job for a while", runSnooze},
+		"maintenance": {"Hold back notifications during planned maintenance", runMaintenance},
+	}
+}
+
+// Global flags are accepted anywhere on the command line for all commands, up to "--" or a wrapped command
+const (
+	verboseFlag     = "--verbose"
+	quietFlag       = "--quiet"
+	reportFlag      = "--report"
+	errorFormatFlag = "--error-format"
+)
+
+// reportJSON is the only supported --report format
+const reportJSON = "json"
+
+// Supported --error-format values
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+var (
+	verbose      bool   // --verbose: diagnostic logging to stderr
+	quiet        bool   // --quiet: suppress success output on stdout
+	reportFormat string // --report: machine-readable result on stdout
+	errorFormat  string // --error-format: how fatal errors are written to stderr
+)
+
 func main() {
+	args, err := extractGlobalFlags(os.Args)
+	if err != nil {
+		usageFatal(err.Error())
+	}
+	os.Args = args
+
+	// Configuration may fail to load, so start with defaults; loadConfig applies the configured format
+	logging.Setup(logging.Options{Debug: verbose, PriorityPrefix: logging.StderrIsJournal()})
+
 	if len(os.Args) < 2 {
-		printError("Missing required arguments")
-		printUsage()
-		os.Exit(1)
+		usageFatal("Missing required arguments")
 	}
 
 	if os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
@@ -27,222 +101,175 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Load and validate configuration from environment
-	cfg, err := config.New()
-	if err != nil {
-		// SECURITY: Sanitize error messages to prevent information disclosure
-		log.Fatalf("Configuration error: %s", validation.SanitizeErrorMessage(err))
+	if cmd, ok := subcommands[os.Args[1]]; ok {
+		cmd.run(os.Args[2:])
+		return
 	}
 
-	// Create context with timeout to prevent indefinite hangs
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
-	defer cancel()
+	// Legacy invocation without a subcommand: flags or positional arguments
+	runSendArgs(os.Args)
+}
 
-	// Parse command-line arguments with validation
-	exitInfo, serviceName, serviceDesc, customMessage, err := parseCommandLineArgs(os.Args)
+// loadConfig loads and validates configuration from environment, exiting on failure
+func loadConfig() *config.Config {
+	cfg, err := config.New()
 	if err != nil {
-		printError(validation.SanitizeErrorMessage(err))
-		printUsage()
-		os.Exit(1)
+		// SECURITY: Sanitize error messages to prevent information disclosure
+		fatal(categoryConfig, codeConfigInvalid, "Configuration error", logging.Err(err))
 	}
 
-	// SECURITY: Validate service name early to prevent injection attacks
-	if err := validation.ValidateServiceName(serviceName); err != nil {
-		log.Fatalf("Invalid service name: %s", validation.SanitizeErrorMessage(err))
+	// SECURITY: Apply custom redaction before anything configuration-dependent is logged or sent
+	validation.SetRedactor(cfg.GetRedactor())
+	logging.Setup(logging.Options{
+		Format:         cfg.LogFormat,
+		Debug:          verbose || cfg.Debug,
+		PriorityPrefix: cfg.LogPriorityPrefix,
+	})
+	tracing.Setup(cfg.OTLPEndpoint, cfg.OTelServiceName)
+	for _, warning := range cfg.Warnings() {
+		slog.Warn("Configuration warning", "warning", warning)
 	}
 
-	// Initialize services with dependency injection for testability
-	commandExecutor := systemd.NewCommandExecutor()
-	systemdService := systemd.NewService(commandExecutor, cfg)
-	telegramClient := telegram.NewClient(cfg, nil)
-	notifierService := notifier.New(systemdService, telegramClient, cfg)
-
-	// Send notification with full error context
-	if err := notifierService.SendServiceNotification(ctx, exitInfo, serviceName, serviceDesc, customMessage); err != nil {
-		if notifErr, ok := err.(*notifier.NotificationError); ok {
-			log.Fatalf("Notification failed - %s: %s", notifErr.Op, validation.SanitizeErrorMessage(notifErr.Err))
-		}
-		log.Fatalf("Notification failed: %s", validation.SanitizeErrorMessage(err))
+	// Async mode hands delivery to the daemon or flush timer via the spool
+	if cfg.Async && !cfg.SpoolEnabled {
+		fatal(categoryConfig, codeConfigInvalid, "Configuration error: NOTIFIER_ASYNC requires NOTIFIER_SPOOL_ENABLED=true")
 	}
-
-	fmt.Printf("Notification sent successfully for service: %s (exit code: %d, status: %s)\n",
-		serviceName,
-		exitInfo.ProcessExitCode,
-		map[bool]string{true: "succeeded", false: "failed"}[exitInfo.ServiceSuccess])
+	return cfg
 }
 
-// parseCommandLineArgs determines execution mode and extracts arguments
-// Supports two modes: systemd integration (automatic) and manual testing
-func parseCommandLineArgs(args []string) (systemd.ExitCodeInfo, string, string, string, error) {
-	var exitInfo systemd.ExitCodeInfo
-
-	// Detect systemd context by checking for systemd environment variables
-	exitStatusEnv := os.Getenv("EXIT_STATUS")
-	serviceResultEnv := os.Getenv("SERVICE_RESULT")
-	mainPidEnv := os.Getenv("MAINPID")
-	invocationIDEnv := os.Getenv("INVOCATION_ID")
-
-	inSystemdContext := exitStatusEnv != "" || serviceResultEnv != "" || mainPidEnv != "" || invocationIDEnv != ""
-
-	// Create temporary service for systemd mode detection
-	tempConfig := &config.Config{}
-	tempConfig.SetDefaults()
-	systemdService := systemd.NewService(systemd.NewCommandExecutor(), tempConfig)
+// flushTraces exports buffered spans; failures are logged and never change the exit status
+func flushTraces() {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.TraceExportTimeout)
+	defer cancel()
 
-	// Auto-detect mode: systemd integration if in systemd context or single arg
-	if inSystemdContext || len(args) == 2 {
-		return parseSystemdMode(args, systemdService)
-	} else if len(args) >= 3 {
-		return parseManualMode(args)
+	if err := tracing.Flush(ctx); err != nil {
+		slog.Warn("Exporting traces failed", logging.Err(err))
 	}
-
-	return exitInfo, "", "", "", fmt.Errorf("invalid number of arguments")
 }
 
-// parseSystemdMode handles systemd ExecStartPost/ExecStopPost execution
-// Reads exit code from systemd environment variables or systemctl
-func parseSystemdMode(args []string, systemdService *systemd.Service) (systemd.ExitCodeInfo, string, string, string, error) {
-	serviceName := args[1]
-
-	// SECURITY: Validate service name immediately to prevent injection
-	if err := validation.ValidateServiceName(serviceName); err != nil {
-		return systemd.ExitCodeInfo{}, "", "", "", fmt.Errorf("invalid service name: %w", err)
-	}
-
-	// Get exit code info from systemd (uses environment vars + systemctl)
-	exitInfo, err := systemdService.GetServiceExitCodeInfo(context.Background(), serviceName)
-	if err != nil {
-		log.Printf("Warning: failed to get exit code info: %s", validation.SanitizeErrorMessage(err))
-	}
-
-	// Parse optional service description and custom message
-	var serviceDesc, customMessage string
-	if len(args) >= 3 {
-		if len(args) >= 4 {
-			serviceDesc = args[2]
-			customMessage = args[3]
-		} else {
-			// Auto-detect if arg is status message or description
-			if isStatusMessage(args[2]) {
-				customMessage = args[2]
-			} else {
-				serviceDesc = args[2]
+// wrappers are the subcommands whose first positional argument starts a command of their own,
+// with their flags that take no value, so the argument after one of those is positional
+var wrappers = map[string][]string{"run": {"always"}}
+
+// extractGlobalFlags removes flags shared by all commands from args
+// Scanning stops at "--", and at the start of the command a wrapper runs, which keeps its own arguments
+func extractGlobalFlags(args []string) ([]string, error) {
+	remaining := make([]string, 0, len(args))
+	var (
+		wrapper   bool
+		noValue   []string // The wrapper's flags that take no value
+		valueNext bool     // The previous argument was a wrapper flag whose value is the next one
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if (i > 0 && arg == "--") || (wrapper && !valueNext && !strings.HasPrefix(arg, "-")) {
+			return append(remaining, args[i:]...), nil
+		}
+		valueNext = false
+		switch {
+		case arg == verboseFlag:
+			verbose = true
+		case arg == quietFlag:
+			// ExecStopPost stdout lands in the monitored unit's journal
+			quiet = true
+		case arg == reportFlag || strings.HasPrefix(arg, reportFlag+"="):
+			value, err := globalFlagValue(args, &i, reportFlag)
+			if err != nil {
+				return nil, err
+			}
+			if value != reportJSON {
+				return nil, fmt.Errorf("unsupported report format %q (supported: %s)", value, reportJSON)
+			}
+			reportFormat = value
+		case arg == errorFormatFlag || strings.HasPrefix(arg, errorFormatFlag+"="):
+			value, err := globalFlagValue(args, &i, errorFormatFlag)
+			if err != nil {
+				return nil, err
+			}
+			if value != errorFormatText && value != errorFormatJSON {
+				return nil, fmt.Errorf("unsupported error format %q (supported: %s, %s)", value, errorFormatText, errorFormatJSON)
+			}
+			errorFormat = value
+		default:
+			if flags, ok := wrappers[arg]; ok && len(remaining) == 1 {
+				wrapper, noValue = true, flags
+			} else if wrapper && strings.HasPrefix(arg, "-") {
+				name := strings.TrimLeft(arg, "-")
+				valueNext = !strings.Contains(name, "=") && !slices.Contains(noValue, name)
 			}
+			remaining = append(remaining, arg)
 		}
 	}
-
-	return exitInfo, serviceName, serviceDesc, customMessage, nil
+	return remaining, nil
 }
 
-// parseManualMode handles manual invocation for testing
-// Usage: telegram-notifier <exit_code> <service_name> [description] [message]
-func parseManualMode(args []string) (systemd.ExitCodeInfo, string, string, string, error) {
-	exitCodeStr := args[1]
-	serviceName := args[2]
+// globalFlagValue returns the value of a "--flag value" or "--flag=value" argument at args[*i]
+// Advances *i past a separate value argument
+func globalFlagValue(args []string, i *int, name string) (string, error) {
+	if value, ok := strings.CutPrefix(args[*i], name+"="); ok {
+		return value, nil
+	}
+	if *i+1 >= len(args) {
+		return "", fmt.Errorf("%s requires a format", name)
+	}
+	*i++
+	return args[*i], nil
+}
 
-	// SECURITY: Validate service name to prevent injection
-	if err := validation.ValidateServiceName(serviceName); err != nil {
-		return systemd.ExitCodeInfo{}, "", "", "", fmt.Errorf("invalid service name: %w", err)
+// newInitSystem creates the provider for the configured init system
+func newInitSystem(cfg *config.Config) notifier.InitSystem {
+	commandExecutor := systemd.NewCommandExecutor()
+	switch cfg.InitSystem {
+	case constants.InitSystemOpenRC:
+		return initsys.NewOpenRC(commandExecutor, cfg)
+	case constants.InitSystemRunit:
+		return initsys.NewRunit(commandExecutor, cfg)
+	case constants.InitSystemLaunchd:
+		return initsys.NewLaunchd(commandExecutor, cfg)
+	case constants.InitSystemWindows:
+		return initsys.NewWindows(commandExecutor, cfg)
 	}
+	return systemd.NewService(commandExecutor, cfg)
+}
 
-	// Parse and validate exit code
-	code, err := strconv.Atoi(exitCodeStr)
+// newNotifierService wires up services with dependency injection for testability
+// extra options are applied after the configuration-driven ones
+func newNotifierService(cfg *config.Config, extra ...notifier.Option) *notifier.Service {
+
+	primary := telegram.NewClient(cfg, nil)
+	if cfg.ForumTopics {
+		primary.UseTopics(state.NewTopics(cfg.GetTopicsFile()))
+	}
+	var telegramClient notifier.TelegramClient = primary
+	fallbackBackend, err := backend.New(cfg)
 	if err != nil {
-		return systemd.ExitCodeInfo{}, "", "", "", fmt.Errorf("invalid exit code '%s': %w", exitCodeStr, err)
+		fatal(categoryConfig, codeConfigInvalid, "Configuration error", logging.Err(err))
 	}
-
-	// SECURITY: Ensure exit code is in valid range (0-255)
-	if err := validation.ValidateExitCode(code); err != nil {
-		return systemd.ExitCodeInfo{}, "", "", "", err
+	if fallbackBackend != nil {
+		telegramClient = backend.NewFailoverClient(telegramClient, fallbackBackend)
 	}
 
-	exitInfo := systemd.ExitCodeInfo{
-		ProcessExitCode: code,
-		ServiceSuccess:  (code == 0),
-		ExitStatus:      systemd.GetExitStatusString(code),
-		InvocationID:    os.Getenv("INVOCATION_ID"),
+	opts := []notifier.Option{
+		notifier.WithDeadLetter(deadletter.New(cfg.GetDeadLetterFile())),
+		notifier.WithState(state.New(cfg.GetServiceStateFile())),
+		notifier.WithEditor(primary),
+		notifier.WithMaintenance(state.NewMaintenance(cfg.GetMaintenanceFile())),
+		notifier.WithFullOutputs(state.NewOutputs(cfg.GetOutputsDir(), constants.OutputRetention)),
 	}
-
-	// Parse optional service description and custom message
-	var serviceDesc, customMessage string
-	if len(args) >= 4 {
-		if isStatusMessage(args[3]) {
-			customMessage = args[3]
-			if len(args) >= 5 {
-				serviceDesc = args[4]
-			}
-		} else {
-			serviceDesc = args[3]
-			if len(args) >= 5 {
-				customMessage = args[4]
-			}
-		}
+	if cfg.HistoryEnabled {
+		opts = append(opts, notifier.WithHistory(history.New(cfg.GetHistoryFile(), constants.HistoryMaxFileSize)))
 	}
-
-	return exitInfo, serviceName, serviceDesc, customMessage, nil
-}
-
-// isStatusMessage heuristically detects if argument is a status message
-// Used to auto-detect argument order when description/message are swapped
-func isStatusMessage(arg string) bool {
-	lowerArg := strings.ToLower(arg)
-	statusWords := []string{"success", "fail", "complete", "error", "start", "stop"}
-	for _, word := range statusWords {
-		if strings.Contains(lowerArg, word) {
-			return true
-		}
+	for unit, path := range cfg.LogFiles {
+		opts = append(opts, notifier.WithOutputSource(unit, logsource.NewFile(path, cfg.StateDir, constants.MaxStdinSize)))
 	}
-	return false
-}
-
-func printError(msg string) {
-	fmt.Fprintf(os.Stderr, "Error: %s\n\n", msg)
-}
-
-func printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  Mode 1 - Manual (for testing):")
-	fmt.Println("    ./telegram-notifier <exit_code> <service_name> [custom_message]")
-	fmt.Println("")
-	fmt.Println("  Mode 2 - Systemd Integration:")
-	fmt.Println("    ./telegram-notifier <service_name> [custom_message]")
-	fmt.Println("    ./telegram-notifier <service_name> [service_description] [custom_message]")
-	fmt.Println("    (Uses $EXIT_STATUS, $SERVICE_RESULT, and other environment variables)")
-	fmt.Println("")
-	fmt.Println("Examples:")
-	fmt.Println("  # Manual mode")
-	fmt.Println("  ./telegram-notifier 0 my-backup.service \"Backup completed\"")
-	fmt.Println("  ./telegram-notifier 200 my-app.service \"Application failed with CHDIR error\"")
-	fmt.Println("")
-	fmt.Println("  # Systemd mode (in ExecStartPost/ExecStopPost)")
-	fmt.Println("  ExecStartPost=/usr/local/bin/telegram-notifier %n")
-	fmt.Println("  ExecStopPost=/usr/local/bin/telegram-notifier %n")
-	fmt.Println("")
-	fmt.Println("Security:")
-	fmt.Println("  Service names must match systemd naming conventions (alphanumeric, :_.@-)")
-	fmt.Println("  Shell metacharacters are rejected to prevent command injection")
-	fmt.Println("  Exit codes must be in range 0-255")
-	fmt.Println("  Sensitive data is automatically filtered from output")
-	fmt.Println("  Command execution is rate-limited to prevent abuse")
-	fmt.Println("")
-	fmt.Println("Privacy:")
-	fmt.Println("  Set NOTIFIER_HOSTNAME_ALIAS to use a custom hostname in notifications")
-	fmt.Println("  All error messages are sanitized before logging")
-	fmt.Println("")
-	fmt.Println("Configuration (set in ~/.config/environment.d/*.conf):")
-	fmt.Println("  TELEGRAM_BOT_TOKEN       - Telegram bot token (required)")
-	fmt.Println("  TELEGRAM_CHAT_ID         - Telegram chat ID (required)")
-	fmt.Println("  NOTIFIER_HOSTNAME_ALIAS  - Custom hostname for privacy")
-	fmt.Println("  TZ                       - Timezone (e.g., America/New_York, UTC)")
-	fmt.Println("  NOTIFIER_COMMAND_TIMEOUT - Max command execution time (default: 30s)")
-	fmt.Println("  NOTIFIER_MAX_OUTPUT_SIZE - Max output characters (default: 2500)")
-	fmt.Println("")
-	fmt.Println("Exit Codes:")
-	fmt.Println("  0   - SUCCESS")
-	fmt.Println("  1   - Generic failure")
-	fmt.Println("  126 - Command cannot execute")
-	fmt.Println("  127 - Command not found")
-	fmt.Println("  200 - Change directory failed (CHDIR)")
-	fmt.Println("  203 - Cannot execute (EXEC)")
-	fmt.Println("  See systemd.exec(5) for full list")
+	for unit, container := range cfg.Containers {
+		opts = append(opts, notifier.WithOutputSource(unit, logsource.NewContainer(cfg.ContainerSocket, container, cfg.StateDir, constants.MaxStdinSize)))
+	}
+	if len(cfg.PingURLs) > 0 {
+		opts = append(opts, notifier.WithRunPings(heartbeat.NewRunPings(cfg.PingURLs, httpclient.New(cfg))))
+	}
+	if cfg.SpoolEnabled {
+		opts = append(opts, notifier.WithSpool(spool.New(cfg.GetSpoolDir(), cfg.SpoolMaxEntries, cfg.SpoolMaxAttempts)))
+	}
+	return notifier.New(newInitSystem(cfg), telegramClient, cfg, append(opts, extra...)...)
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/state"
+	"telegram-notifier/internal/validation"
+)
+
+// defaultMaintenanceDuration is used when "maintenance on" gets no --duration
+const defaultMaintenanceDuration = time.Hour
+
+// runMaintenance opens, closes or lists planned maintenance windows, during which runs aren't reported
+func runMaintenance(args []string) {
+	cfg := loadConfig()
+	store := state.NewMaintenance(cfg.GetMaintenanceFile())
+
+	action := "status"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("maintenance "+action, flag.ExitOnError)
+	service := fs.String("service", "", "unit or job to hold back (default: all)")
+	duration := sinceFlag(defaultMaintenanceDuration)
+	fs.Var(&duration, "duration", "how long the window lasts (e.g. 2h, 1d)")
+	fs.Parse(args)
+
+	name := state.AllServices
+	if *service != "" {
+		if validation.ValidateServiceName(*service) != nil && validation.ValidateJobName(*service) != nil {
+			fatal(categoryValidation, codeInvalidServiceName, "Invalid unit or job name", "name", *service)
+		}
+		name = *service
+	}
+
+	switch action {
+	case "on":
+		if duration <= 0 {
+			usageFatal("--duration must be positive")
+		}
+		until := time.Now().Add(time.Duration(duration))
+		if err := store.Start(name, until); err != nil {
+			fatal(categoryStorage, codeStateFailed, "Starting maintenance failed", logging.Err(err))
+		}
+		fmt.Printf("Maintenance for %s until %s\n", maintenanceTarget(name), cfg.FormatDateTime(until))
+	case "off":
+		found, err := store.End(name)
+		if err != nil {
+			fatal(categoryStorage, codeStateFailed, "Ending maintenance failed", logging.Err(err))
+		}
+		if !found {
+			fmt.Printf("No maintenance for %s\n", maintenanceTarget(name))
+			return
+		}
+		fmt.Printf("Maintenance for %s ended\n", maintenanceTarget(name))
+	case "status":
+		windows, err := store.Active()
+		if err != nil {
+			fatal(categoryStorage, codeStateFailed, "Reading maintenance windows failed", logging.Err(err))
+		}
+		printMaintenance(windows, cfg.FormatDateTime)
+	default:
+		usageFatal(fmt.Sprintf("unknown maintenance action %q (use on, off or status)", action))
+	}
+}
+
+// maintenanceTarget describes what a window covers
+func maintenanceTarget(name string) string {
+	if name == state.AllServices {
+		return "all units and jobs"
+	}
+	return name
+}
+
+// printMaintenance lists active windows, soonest ending first
+func printMaintenance(windows state.Maintenance, format func(time.Time) string) {
+	if len(windows) == 0 {
+		fmt.Println("No maintenance in progress")
+		return
+	}
+	names := make([]string, 0, len(windows))
+	for name := range windows {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return windows[names[i]].Before(windows[names[j]]) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tUNTIL\tREMAINING")
+	for _, name := range names {
+		until := windows[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", maintenanceTarget(name), format(until), time.Until(until).Round(time.Minute))
+	}
+	w.Flush()
+}
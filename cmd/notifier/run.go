@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/validation"
+)
+
+// Exit statuses used by shells when a command can't be run
+const (
+	exitCannotExecute = 126
+	exitNotFound      = 127
+	exitSignalBase    = 128
+)
+
+// runRun executes a command and notifies about its result, for cron jobs and scripts outside systemd
+// Output is passed through unchanged and the command's exit status is returned, so the wrapper
+// is transparent to whatever invoked it
+func runRun(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	name := fs.String("name", "", "job name shown in notifications and history (default: command name)")
+	always := fs.Bool("always", false, "notify on success too, not only on failure")
+	fs.Parse(args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		usageFatal("run requires a command: telegram-notifier run [--name N] [--always] -- command [args...]")
+	}
+	if *name == "" {
+		*name = filepath.Base(command[0])
+	}
+	if err := validation.ValidateJobName(*name); err != nil {
+		fatal(categoryValidation, codeInvalidInput, "Invalid job name", logging.Err(err))
+	}
+
+	cfg := loadConfig()
+
+	output := &tailBuffer{max: constants.MaxStdinSize}
+	start := time.Now()
+	exitCode, runErr := runWrapped(command, output)
+	job := notifier.JobRun{
+		Name:     *name,
+		Command:  strings.Join(command, " "),
+		ExitCode: exitCode,
+		Signaled: runErr != nil && exitCode > exitSignalBase,
+		Output:   output.String(),
+		Runtime:  time.Since(start),
+		// Quiet successes are still recorded: they reset the failure count, end reminders and send the recovery
+		Quiet: !*always,
+	}
+	if runErr != nil {
+		job.Output = strings.TrimSpace(job.Output + "\n" + validation.SanitizeErrorMessage(runErr))
+	}
+	if strings.TrimSpace(job.Output) == "" {
+		job.Output = "(no output)"
+	}
+
+	// Hardening applies to delivery only; the wrapped command runs with the caller's privileges
+	harden(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	report, err := newNotifierService(cfg).SendJobNotification(ctx, job)
+	flushTraces()
+	if reportFormat != "" {
+		printReport(job.Name, report, err)
+	}
+	if err != nil {
+		// A failed job keeps its own exit status; the caller cares about the job more than the notification
+		if exitCode != 0 && !errors.Is(err, notifier.ErrSpooled) {
+			slog.Error("Notification failed", logging.KeyService, job.Name, logging.Err(err))
+			os.Exit(exitCode)
+		}
+		handleSendError(err)
+	}
+	os.Exit(exitCode)
+}
+
+// runWrapped runs command with stdin passed through and stdout/stderr copied to output
+// Returns the exit status a shell would report
+func runWrapped(command []string, output io.Writer) (int, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+			return exitNotFound, err
+		}
+		return exitCannotExecute, err
+	}
+
+	// Forward termination requests so the job can clean up; the notification still goes out
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return exitSignalBase + int(status.Signal()), fmt.Errorf("killed by signal %s", status.Signal())
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return exitCannotExecute, err
+	}
+	return 0, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+// Safe for concurrent writers, since stdout and stderr are copied from separate goroutines
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.ToValidUTF8(string(t.buf), "�")
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// maxTitleLength bounds free-form notification titles
+const maxTitleLength = 256
+
+// sendRequest holds the parsed arguments of a send invocation
+type sendRequest struct {
+	exitInfo      systemd.ExitCodeInfo
+	serviceName   string
+	serviceDesc   string
+	customMessage string
+	logs          string // Shown as the command output instead of the journal
+	title         string // Free-form notification title; no unit is involved when set
+	plain         bool   // Sent without systemd: serviceName is a job name and customMessage the output
+}
+
+// runSend handles "telegram-notifier send --service ..." using explicit flags
+func runSend(args []string) {
+	runSendArgs(append([]string{"send"}, args...))
+}
+
+// runSendArgs sends a service notification; args[0] is the program or subcommand name
+func runSendArgs(args []string) {
+	cfg := loadConfig()
+	harden(cfg)
+
+	// Create context with timeout to prevent indefinite hangs
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	// Parse command-line arguments with validation
+	req, err := parseCommandLineArgs(args, cfg)
+	if err != nil {
+		usageFatal(validation.SanitizeErrorMessage(err))
+	}
+
+	if req.title != "" {
+		sendFreeForm(ctx, cfg, req)
+		return
+	}
+	if req.plain {
+		sendPlain(ctx, cfg, req)
+		return
+	}
+	exitInfo, serviceName := req.exitInfo, req.serviceName
+
+	// SECURITY: Validate service name early to prevent injection attacks
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		fatal(categoryValidation, codeInvalidServiceName, "Invalid service name", logging.Err(err))
+	}
+
+	// Send notification with full error context
+	report, err := dispatch(ctx, cfg, req)
+	flushTraces()
+	if reportFormat != "" {
+		printReport(serviceName, report, err)
+	}
+	if err != nil {
+		handleSendError(err)
+		return
+	}
+
+	if quiet || reportFormat != "" {
+		return
+	}
+
+	if report.Queued {
+		fmt.Printf("Notification queued for service: %s (exit code: %d)\n", serviceName, exitInfo.ProcessExitCode)
+		return
+	}
+	if report.Suppressed {
+		fmt.Printf("Notification suppressed for service: %s (exit code: %d)\n", serviceName, exitInfo.ProcessExitCode)
+		return
+	}
+
+	fmt.Printf("Notification sent successfully for service: %s (exit code: %d, status: %s)\n",
+		serviceName,
+		exitInfo.ProcessExitCode,
+		map[bool]string{true: "succeeded", false: "failed"}[exitInfo.ServiceSuccess])
+}
+
+// sendFreeForm sends a notification that isn't tied to a systemd unit
+func sendFreeForm(ctx context.Context, cfg *config.Config, req sendRequest) {
+	report, err := dispatch(ctx, cfg, req)
+	flushTraces()
+	if reportFormat != "" {
+		printReport(notifier.AdHocService, report, err)
+	}
+	if err != nil {
+		handleSendError(err)
+		return
+	}
+
+	if quiet || reportFormat != "" {
+		return
+	}
+	if report.Queued {
+		fmt.Printf("Notification queued: %s\n", req.title)
+		return
+	}
+	fmt.Printf("Notification sent successfully: %s\n", req.title)
+}
+
+// sendPlain reports a command's result without asking systemd anything, like a job wrapped by run
+// The daemon's socket is skipped, since it only takes systemd unit notifications
+func sendPlain(ctx context.Context, cfg *config.Config, req sendRequest) {
+	output := req.customMessage
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	job := notifier.JobRun{
+		Name:     req.serviceName,
+		Command:  req.serviceDesc,
+		ExitCode: req.exitInfo.ProcessExitCode,
+		Signaled: req.exitInfo.ExitSignal != "",
+		Output:   output,
+	}
+	report, err := newNotifierService(cfg).SendJobNotification(ctx, job)
+	flushTraces()
+	if reportFormat != "" {
+		printReport(job.Name, report, err)
+	}
+	if err != nil {
+		handleSendError(err)
+		return
+	}
+
+	if quiet || reportFormat != "" {
+		return
+	}
+	switch {
+	case report.Queued:
+		fmt.Printf("Notification queued for job: %s (exit code: %d)\n", job.Name, job.ExitCode)
+	case report.Suppressed:
+		fmt.Printf("Notification suppressed for job: %s (exit code: %d)\n", job.Name, job.ExitCode)
+	default:
+		fmt.Printf("Notification sent successfully for job: %s (exit code: %d)\n", job.Name, job.ExitCode)
+	}
+}
+
+// sendReport is the --report json result written to stdout
+type sendReport struct {
+	Service    string `json:"service"`
+	Delivered  bool   `json:"delivered"`
+	Queued     bool   `json:"queued"`
+	Suppressed bool   `json:"suppressed"`
+	Spooled    bool   `json:"spooled"`
+	MessageID  int64  `json:"message_id,omitempty"`
+	Backend    string `json:"backend,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+	Attempts   int    `json:"attempts"`
+	DurationMS int64  `json:"duration_ms"`
+	Truncated  bool   `json:"truncated"`
+	Redactions int    `json:"redactions"`
+	Error      string `json:"error,omitempty"`
+}
+
+// printReport writes the outcome of a send as a single JSON object on stdout
+func printReport(service string, report notifier.Report, err error) {
+	out := sendReport{
+		Service:    service,
+		Delivered:  report.Delivered,
+		Queued:     report.Queued,
+		Suppressed: report.Suppressed,
+		Spooled:    report.Spooled,
+		MessageID:  report.MessageID,
+		Backend:    report.Backend,
+		Severity:   report.Severity,
+		Attempts:   report.Attempts,
+		DurationMS: report.Duration.Milliseconds(),
+		Truncated:  report.Truncated,
+		Redactions: report.Redactions,
+	}
+	if err != nil {
+		// SECURITY: Sanitize error messages like all other output
+		out.Error = validation.SanitizeErrorMessage(err)
+	}
+	json.NewEncoder(os.Stdout).Encode(out)
+}
+
+// handleSendError reports a failed send, exiting non-zero unless it was spooled
+func handleSendError(err error) {
+	// Spooled notifications are retried later, so don't fail the calling unit
+	if errors.Is(err, notifier.ErrSpooled) {
+		slog.Warn("Delivery failed, notification spooled for retry", logging.Err(err))
+		return
+	}
+	if notifErr, ok := err.(*notifier.NotificationError); ok {
+		category, code := categoryDelivery, codeDeliveryFailed
+		if notifErr.Op == notifier.OpValidation {
+			category, code = categoryValidation, codeInvalidInput
+		}
+		fatal(category, code, "Notification failed", "op", notifErr.Op, logging.KeyService, notifErr.Service, logging.Err(notifErr.Err))
+	}
+	fatal(categoryDelivery, codeDeliveryFailed, "Notification failed", logging.Err(err))
+}
+
+// parseCommandLineArgs determines execution mode and extracts arguments
+// Supports two modes: systemd integration (automatic) and manual testing
+// In plain mode (NOTIFIER_PLAIN or --plain) the init system is never asked for the exit status
+func parseCommandLineArgs(args []string, cfg *config.Config) (sendRequest, error) {
+	// Detect systemd context by checking for systemd environment variables
+	exitStatusEnv := os.Getenv("EXIT_STATUS")
+	serviceResultEnv := os.Getenv("SERVICE_RESULT")
+	mainPidEnv := os.Getenv("MAINPID")
+	invocationIDEnv := os.Getenv("INVOCATION_ID")
+
+	inSystemdContext := exitStatusEnv != "" || serviceResultEnv != "" || mainPidEnv != "" || invocationIDEnv != ""
+
+	initSystem := newInitSystem(cfg)
+	plain := cfg.Plain
+
+	// Explicit flags take precedence over positional argument heuristics
+	if len(args) >= 2 && strings.HasPrefix(args[1], "-") {
+		return parseFlagMode(args, initSystem, plain)
+	}
+
+	// Legacy positional mode: systemd integration if in systemd context or single arg
+	if inSystemdContext || len(args) == 2 {
+		if plain {
+			return sendRequest{}, fmt.Errorf("plain mode needs flags: send --service <name> [--exit-code N] [--output file]")
+		}
+		return parseSystemdMode(args, initSystem)
+	} else if len(args) >= 3 {
+		req, err := parseManualMode(args)
+		req.plain = plain
+		return req, err
+	}
+
+	return sendRequest{}, fmt.Errorf("invalid number of arguments")
+}
+
+// parseFlagMode parses explicit flags, avoiding positional guessing entirely
+// Usage: telegram-notifier --service <name> [--exit-code N] [--description D] [--message M] [--logs file]
+// Or, for free-form notifications: telegram-notifier --title <title> [--message M]
+// Without --exit-code, the exit status is read from systemd like in systemd mode, or from $EXIT_STATUS in plain mode
+func parseFlagMode(args []string, initSystem notifier.InitSystem, plain bool) (sendRequest, error) {
+	fs := flag.NewFlagSet("telegram-notifier", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	serviceName := fs.String("service", "", "systemd unit name (required)")
+	exitCode := fs.Int("exit-code", -1, "process exit code (default: read from systemd)")
+	serviceDesc := fs.String("description", "", "service description (default: from systemd)")
+	customMessage := fs.String("message", "", "custom message instead of journal output (\"-\" reads stdin)")
+	title := fs.String("title", "", "send a free-form notification with this title instead of a service notification")
+	plainFlag := fs.Bool("plain", false, "don't use systemd: --service names a job, its output comes from --output or --message")
+	outputFile := fs.String("output", "", "file whose end is sent as the output in plain mode (\"-\" reads stdin)")
+	logsFile := fs.String("logs", "", "file whose end is shown as the command output instead of the journal (\"-\" reads stdin)")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return sendRequest{}, err
+	}
+	if fs.NArg() > 0 {
+		return sendRequest{}, fmt.Errorf("unexpected positional arguments with flags: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *customMessage == "-" && (*logsFile == "-" || *outputFile == "-") {
+		return sendRequest{}, fmt.Errorf("only one of --message, --logs and --output can read stdin")
+	}
+
+	// "--message -" reads the message from stdin, e.g. piped job output
+	message := *customMessage
+	if message == "-" {
+		stdinMessage, err := readStdinTail(os.Stdin, constants.MaxStdinSize)
+		if err != nil {
+			return sendRequest{}, fmt.Errorf("reading message from stdin: %w", err)
+		}
+		message = stdinMessage
+	}
+
+	if *title != "" {
+		if *serviceName != "" || *exitCode >= 0 || *serviceDesc != "" || *logsFile != "" {
+			return sendRequest{}, fmt.Errorf("--title can't be combined with --service, --exit-code, --description or --logs")
+		}
+		if len(*title) > maxTitleLength {
+			return sendRequest{}, fmt.Errorf("title too long: %d bytes (max %d)", len(*title), maxTitleLength)
+		}
+		return sendRequest{title: *title, customMessage: message}, nil
+	}
+
+	if *serviceName == "" {
+		return sendRequest{}, fmt.Errorf("--service or --title is required")
+	}
+	if *plainFlag || plain {
+		// Plain notifications have no journal to replace, so logs are their output
+		if *logsFile != "" {
+			if *outputFile != "" {
+				return sendRequest{}, fmt.Errorf("--logs can't be combined with --output")
+			}
+			*outputFile = *logsFile
+		}
+		return parsePlainFlags(*serviceName, *exitCode, *serviceDesc, message, *outputFile)
+	}
+	if *outputFile != "" {
+		return sendRequest{}, fmt.Errorf("--output is only used in plain mode; the output of units is read from the journal")
+	}
+
+	// SECURITY: Validate service name immediately to prevent injection
+	*serviceName = validation.NormalizeUnitName(*serviceName)
+	if err := validation.ValidateServiceName(*serviceName); err != nil {
+		return sendRequest{}, fmt.Errorf("invalid service name: %w", err)
+	}
+
+	var exitInfo systemd.ExitCodeInfo
+	if *exitCode >= 0 {
+		// SECURITY: Ensure exit code is in valid range (0-255)
+		if err := validation.ValidateExitCode(*exitCode); err != nil {
+			return sendRequest{}, err
+		}
+		exitInfo = systemd.ExitCodeInfo{
+			ProcessExitCode: *exitCode,
+			ServiceSuccess:  (*exitCode == 0),
+			ExitStatus:      systemd.GetExitStatusString(*exitCode),
+			InvocationID:    os.Getenv("INVOCATION_ID"),
+		}
+	} else {
+		info, err := initSystem.GetServiceExitCodeInfo(context.Background(), *serviceName)
+		if err != nil {
+			slog.Warn("Failed to get exit code info", logging.KeyService, *serviceName, logging.Err(err))
+		}
+		exitInfo = info
+	}
+
+	// "--logs -" takes the output from stdin, e.g. a wrapped script's, so the journal isn't read
+	var logs string
+	if *logsFile != "" {
+		output, err := readOutputFile(*logsFile)
+		if err != nil {
+			return sendRequest{}, err
+		}
+		logs = output
+		if strings.TrimSpace(logs) == "" {
+			logs = "(no output)"
+		}
+	}
+
+	return sendRequest{exitInfo: exitInfo, serviceName: *serviceName, serviceDesc: *serviceDesc, customMessage: message, logs: logs}, nil
+}
+
+// parsePlainFlags builds a plain-mode request: name is a job name rather than a unit,
+// the exit code comes from --exit-code or $EXIT_STATUS, and the output from --output or --message
+func parsePlainFlags(name string, exitCode int, desc, message, outputFile string) (sendRequest, error) {
+	// SECURITY: Job names are validated like the ones of run
+	if err := validation.ValidateJobName(name); err != nil {
+		return sendRequest{}, err
+	}
+	if exitCode < 0 {
+		status := os.Getenv("EXIT_STATUS")
+		if status == "" {
+			return sendRequest{}, fmt.Errorf("plain mode needs --exit-code or $EXIT_STATUS")
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return sendRequest{}, fmt.Errorf("invalid $EXIT_STATUS %q: %w", status, err)
+		}
+		exitCode = code
+	}
+	// SECURITY: Ensure exit code is in valid range (0-255)
+	if err := validation.ValidateExitCode(exitCode); err != nil {
+		return sendRequest{}, err
+	}
+
+	if outputFile != "" {
+		if message != "" {
+			return sendRequest{}, fmt.Errorf("--output can't be combined with --message")
+		}
+		output, err := readOutputFile(outputFile)
+		if err != nil {
+			return sendRequest{}, err
+		}
+		message = output
+	}
+
+	exitInfo := systemd.ExitCodeInfo{
+		ProcessExitCode: exitCode,
+		ServiceSuccess:  exitCode == 0,
+		ExitStatus:      systemd.GetExitStatusString(exitCode),
+	}
+	return sendRequest{exitInfo: exitInfo, serviceName: name, serviceDesc: desc, customMessage: message, plain: true}, nil
+}
+
+// readOutputFile reads the end of a file, or of stdin for "-"
+func readOutputFile(path string) (string, error) {
+	if path == "-" {
+		output, err := readStdinTail(os.Stdin, constants.MaxStdinSize)
+		if err != nil {
+			return "", fmt.Errorf("reading output from stdin: %w", err)
+		}
+		return output, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("reading output: %w", err)
+	}
+	defer f.Close()
+	output, err := readStdinTail(f, constants.MaxStdinSize)
+	if err != nil {
+		return "", fmt.Errorf("reading output from %s: %w", path, err)
+	}
+	return output, nil
+}
+
+// readStdinTail reads r to EOF keeping only the last maxSize bytes
+// The end of piped output is usually the most relevant part, matching TruncateMessage
+func readStdinTail(r io.Reader, maxSize int) (string, error) {
+	buf := make([]byte, 0, maxSize)
+	chunk := make([]byte, 32*1024)
+
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			buf = append(buf, chunk[:n]...)
+			if len(buf) > maxSize {
+				buf = append(buf[:0], buf[len(buf)-maxSize:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.ToValidUTF8(string(buf), "�"), nil
+}
+
+// parseSystemdMode handles systemd ExecStartPost/ExecStopPost execution
+// Reads exit code from systemd environment variables or systemctl
+func parseSystemdMode(args []string, initSystem notifier.InitSystem) (sendRequest, error) {
+	serviceName := validation.NormalizeUnitName(args[1])
+
+	// SECURITY: Validate service name immediately to prevent injection
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return sendRequest{}, fmt.Errorf("invalid service name: %w", err)
+	}
+
+	// Get exit code info from the init system (for systemd: environment vars + systemctl)
+	exitInfo, err := initSystem.GetServiceExitCodeInfo(context.Background(), serviceName)
+	if err != nil {
+		slog.Warn("Failed to get exit code info", logging.KeyService, serviceName, logging.Err(err))
+	}
+
+	// Parse optional service description and custom message
+	var serviceDesc, customMessage string
+	if len(args) >= 3 {
+		if len(args) >= 4 {
+			serviceDesc = args[2]
+			customMessage = args[3]
+		} else {
+			// Auto-detect if arg is status message or description
+			if isStatusMessage(args[2]) {
+				customMessage = args[2]
+			} else {
+				serviceDesc = args[2]
+			}
+		}
+	}
+
+	return sendRequest{exitInfo: exitInfo, serviceName: serviceName, serviceDesc: serviceDesc, customMessage: customMessage}, nil
+}
+
+// parseManualMode handles manual invocation for testing
+// Usage: telegram-notifier <exit_code> <service_name> [description] [message]
+func parseManualMode(args []string) (sendRequest, error) {
+	exitCodeStr := args[1]
+	serviceName := validation.NormalizeUnitName(args[2])
+
+	// SECURITY: Validate service name to prevent injection
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return sendRequest{}, fmt.Errorf("invalid service name: %w", err)
+	}
+
+	// Parse and validate exit code
+	code, err := strconv.Atoi(exitCodeStr)
+	if err != nil {
+		return sendRequest{}, fmt.Errorf("invalid exit code '%s': %w", exitCodeStr, err)
+	}
+
+	// SECURITY: Ensure exit code is in valid range (0-255)
+	if err := validation.ValidateExitCode(code); err != nil {
+		return sendRequest{}, err
+	}
+
+	exitInfo := systemd.ExitCodeInfo{
+		ProcessExitCode: code,
+		ServiceSuccess:  (code == 0),
+		ExitStatus:      systemd.GetExitStatusString(code),
+		InvocationID:    os.Getenv("INVOCATION_ID"),
+	}
+
+	// Parse optional service description and custom message
+	var serviceDesc, customMessage string
+	if len(args) >= 4 {
+		if isStatusMessage(args[3]) {
+			customMessage = args[3]
+			if len(args) >= 5 {
+				serviceDesc = args[4]
+			}
+		} else {
+			serviceDesc = args[3]
+			if len(args) >= 5 {
+				customMessage = args[4]
+			}
+		}
+	}
+
+	return sendRequest{exitInfo: exitInfo, serviceName: serviceName, serviceDesc: serviceDesc, customMessage: customMessage}, nil
+}
+
+// isStatusMessage heuristically detects if argument is a status message
+// Used to auto-detect argument order when description/message are swapped
+func isStatusMessage(arg string) bool {
+	lowerArg := strings.ToLower(arg)
+	statusWords := []string{"success", "fail", "complete", "error", "start", "stop"}
+	for _, word := range statusWords {
+		if strings.Contains(lowerArg, word) {
+			return true
+		}
+	}
+	return false
+}
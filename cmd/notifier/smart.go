@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/smart"
+)
+
+// smartTitle heads disk health notifications
+const smartTitle = "Disk health warning"
+
+// runSmart checks disks with smartctl and notifies about problems not reported before
+// Meant for a timer running as root, since smartctl needs raw device access
+func runSmart(args []string) {
+	fs := flag.NewFlagSet("smart", flag.ExitOnError)
+	all := fs.Bool("all", false, "report every current problem, including ones already notified")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	devices, err := checkDisks(ctx, cfg)
+	if err != nil {
+		fatal(categoryConfig, codeCheckFailed, "Disk health check failed", logging.Err(err))
+	}
+
+	// smartctl has run; privileges and sandbox only need to cover delivery
+	harden(cfg)
+
+	statePath := filepath.Join(cfg.StateDir, smart.StateFileName)
+	state, err := smart.LoadState(statePath)
+	if err != nil {
+		slog.Warn("Reading disk health state failed, reporting all problems", logging.Err(err))
+		state = smart.State{}
+	}
+
+	report := devices
+	if !*all {
+		report = state.Unreported(devices)
+	}
+	if len(report) > 0 {
+		_, err := newNotifierService(cfg).SendMessage(ctx, smartTitle, formatSmartReport(report))
+		flushTraces()
+		// Unsent problems stay unreported so the next run tries again
+		if err != nil && !errors.Is(err, notifier.ErrSpooled) {
+			handleSendError(err)
+		}
+	}
+
+	state.Update(devices)
+	if err := state.Save(statePath); err != nil {
+		fatal(categoryStorage, codeStateFailed, "Saving disk health state failed", logging.Err(err))
+	}
+
+	if !quiet {
+		fmt.Printf("Checked %d disk(s), %d with new problems\n", len(devices), len(report))
+	}
+}
+
+// checkDisks runs the SMART checks on configured or scanned devices
+// Devices that can't be read are logged and skipped; it fails only when none could be checked
+func checkDisks(ctx context.Context, cfg *config.Config) ([]smart.Device, error) {
+	names := cfg.SmartDevices
+	if len(names) == 0 {
+		scanned, err := smart.Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names = scanned
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no disks found; set NOTIFIER_SMART_DEVICES")
+	}
+
+	limits := smart.Limits{MaxTemperature: cfg.SmartMaxTemperature, MaxWearPercent: cfg.SmartMaxWear}
+	var devices []smart.Device
+	var lastErr error
+	for _, name := range names {
+		device, err := smart.Check(ctx, name, limits)
+		if err != nil {
+			slog.Warn("Reading SMART data failed", "device", name, logging.Err(err))
+			lastErr = err
+			continue
+		}
+		devices = append(devices, device)
+	}
+	if len(devices) == 0 {
+		return nil, lastErr
+	}
+	return devices, nil
+}
+
+// formatSmartReport lists problems per disk, identified by model and serial for replacement
+// Lines are code spans since attribute names and models contain Markdown characters like "_"
+func formatSmartReport(devices []smart.Device) string {
+	var b strings.Builder
+	for i, d := range devices {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		label := d.Name
+		switch {
+		case d.Model != "" && d.Serial != "":
+			label += fmt.Sprintf(" (%s, S/N %s)", d.Model, d.Serial)
+		case d.Model != "":
+			label += fmt.Sprintf(" (%s)", d.Model)
+		}
+		fmt.Fprintf(&b, "`%s`\n", label)
+		for _, breach := range d.Breaches {
+			fmt.Fprintf(&b, "- `%s`\n", breach.Detail)
+		}
+	}
+	return b.String()
+}
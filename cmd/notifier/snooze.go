@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/state"
+	"telegram-notifier/internal/validation"
+)
+
+// snoozeOff ends a snooze in place of a duration
+const snoozeOff = "off"
+
+// runSnooze mutes one unit or job for a while, ends its snooze, or lists snoozed ones without arguments
+func runSnooze(args []string) {
+	cfg := loadConfig()
+	store := state.New(cfg.GetServiceStateFile())
+
+	if len(args) == 0 {
+		snoozed, err := store.Snoozed(time.Now())
+		if err != nil {
+			fatal(categoryStorage, codeStateFailed, "Reading service state failed", logging.Err(err))
+		}
+		printSnoozed(snoozed, cfg.FormatDateTime)
+		return
+	}
+
+	name, until, err := parseSnooze(args)
+	if err != nil {
+		usageFatal(err.Error())
+	}
+	name, err = store.Snooze(name, until)
+	if err != nil {
+		fatal(categoryStorage, codeStateFailed, "Snoozing failed", logging.Err(err))
+	}
+	// The answer is written for the bot's Markdown; the terminal has no use for code spans
+	fmt.Println(strings.ReplaceAll(snoozeResult(name, until, cfg.FormatDateTime), "`", ""))
+}
+
+// parseSnooze reads "unit [duration|off]", shared by the snooze command and /snooze
+// A zero time means the snooze ends
+func parseSnooze(args []string) (string, time.Time, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", time.Time{}, errors.New("usage: snooze <unit> [duration|off]")
+	}
+	name := args[0]
+	if validation.ValidateServiceName(name) != nil && validation.ValidateJobName(name) != nil {
+		return "", time.Time{}, fmt.Errorf("invalid unit or job name %q", name)
+	}
+
+	duration := sinceFlag(constants.DefaultSnoozeDuration)
+	if len(args) == 2 {
+		if args[1] == snoozeOff {
+			return name, time.Time{}, nil
+		}
+		if err := duration.Set(args[1]); err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid duration %q (e.g. 30m, 2h, 1d or off)", args[1])
+		}
+		if duration == 0 {
+			return name, time.Time{}, nil
+		}
+	}
+	return name, time.Now().Add(time.Duration(duration)), nil
+}
+
+// parseMute reads "unit [duration]" for /mute; without a duration the unit stays muted until /unmute
+func parseMute(args []string) (string, time.Time, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", time.Time{}, errors.New("usage: /mute <unit> [duration]")
+	}
+	if len(args) == 2 {
+		return parseSnooze(args)
+	}
+	name := args[0]
+	if validation.ValidateServiceName(name) != nil && validation.ValidateJobName(name) != nil {
+		return "", time.Time{}, fmt.Errorf("invalid unit or job name %q", name)
+	}
+	return name, state.Forever, nil
+}
+
+// muteResult describes a mute that was set or ended
+func muteResult(name string, until time.Time, format func(time.Time) string) string {
+	switch {
+	case until.IsZero():
+		return fmt.Sprintf("🔔 `%s` unmuted", name)
+	case until.Equal(state.Forever):
+		return fmt.Sprintf("🔇 `%s` muted until /unmute", name)
+	}
+	return fmt.Sprintf("🔇 `%s` muted until %s", name, format(until))
+}
+
+// snoozeResult describes a snooze that was set or ended
+func snoozeResult(name string, until time.Time, format func(time.Time) string) string {
+	if until.IsZero() {
+		return fmt.Sprintf("🔔 `%s` is no longer snoozed", name)
+	}
+	return fmt.Sprintf("💤 `%s` snoozed until %s", name, format(until))
+}
+
+// printSnoozed lists snoozed units and jobs, soonest waking first
+func printSnoozed(snoozed map[string]time.Time, format func(time.Time) string) {
+	if len(snoozed) == 0 {
+		fmt.Println("Nothing snoozed")
+		return
+	}
+	names := make([]string, 0, len(snoozed))
+	for name := range snoozed {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return snoozed[names[i]].Before(snoozed[names[j]]) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tUNTIL\tREMAINING")
+	for _, name := range names {
+		until := snoozed[name]
+		if until.Equal(state.Forever) {
+			fmt.Fprintf(w, "%s\tuntil unmuted\t-\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, format(until), time.Until(until).Round(time.Minute))
+	}
+	w.Flush()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// defaultStatsWindow is how far back stats looks without --since
+const defaultStatsWindow = 7 * 24 * time.Hour
+
+// runStats prints per-service run outcomes and delivery health from the audit log
+func runStats(args []string) {
+	cfg := loadConfig()
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := sinceFlag(defaultStatsWindow)
+	fs.Var(&since, "since", "summarize notifications newer than this (e.g. 7d, 12h)")
+	service := fs.String("service", "", "only summarize this service")
+	fs.Parse(args)
+
+	if *service != "" {
+		*service = validation.NormalizeUnitName(*service)
+		if err := validation.ValidateServiceName(*service); err != nil {
+			fatal(categoryValidation, codeInvalidServiceName, "Invalid service name", logging.Err(err))
+		}
+	}
+
+	filter := history.Filter{Service: *service}
+	if since > 0 {
+		filter.Since = time.Now().Add(-time.Duration(since))
+	}
+
+	records, err := history.New(cfg.GetHistoryFile(), constants.HistoryMaxFileSize).Query(filter)
+	if err != nil {
+		fatal(categoryStorage, codeHistoryUnreadable, "Reading history failed", logging.Err(err))
+	}
+	if len(records) == 0 {
+		fmt.Println("No notifications recorded")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tNOTIFICATIONS\tSUCCESS\tFAILURE\tAVG RUNTIME\tSPOOLED\tUNDELIVERED\tDELIVERY FAILURE RATE\tAVG LATENCY")
+	for _, st := range history.Summarize(records) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%d\t%d\t%.1f%%\t%s\n",
+			st.Service, st.Notifications, st.Successes, st.Failures, formatAverage(st.AvgRuntime),
+			st.Spooled, st.DeliveryFailures, st.DeliveryFailureRate()*100, formatAverage(st.AvgLatency))
+	}
+	w.Flush()
+}
+
+// formatAverage renders an average duration, or "-" when nothing was measured
+func formatAverage(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/ratelimit"
+	"telegram-notifier/internal/syslog"
+)
+
+// receiveSyslog reports syslog messages passing the configured severity, program and source filters until ctx is cancelled
+// Messages are sent one at a time; ones arriving meanwhile wait in the socket's receive buffer
+// Repeats of a message and messages beyond the rate limit are dropped, so a flood can't bury the chat
+func receiveSyslog(ctx context.Context, cfg *config.Config, notifierService *notifier.Service) {
+	conn, err := syslog.Listen(cfg.SyslogListen)
+	if err != nil {
+		slog.Warn("Syslog receiver unavailable", "addr", cfg.SyslogListen, logging.Err(err))
+		return
+	}
+	filter := syslog.Filter{MaxSeverity: cfg.SyslogSeverity, Programs: cfg.SyslogPrograms, Sources: cfg.SyslogSources}
+	repeats := syslog.NewRepeats(constants.SyslogRepeatWindow)
+	limiter := ratelimit.NewTokenBucket(constants.SyslogBurst, constants.SyslogRefillRate)
+	limited := false // A message was dropped since the last one reported
+	slog.Info("Receiving syslog messages", "addr", cfg.SyslogListen,
+		"severity", syslog.SeverityName(cfg.SyslogSeverity), "programs", cfg.SyslogPrograms)
+
+	err = syslog.Serve(ctx, conn, func(m syslog.Message) {
+		switch {
+		case !filter.Match(m):
+			// Not one of the messages to report
+		case repeats.Seen(m, time.Now()):
+			slog.Debug("Syslog message repeated, not reported again", "host", m.Hostname, "program", m.AppName)
+		case !limiter.Allow():
+			// Warn once per flood rather than for every message in it
+			if !limited {
+				slog.Warn("Syslog messages arriving too fast, dropping them", "host", m.Hostname, "program", m.AppName)
+			}
+			limited = true
+		default:
+			limited = false
+			reportSyslogMessage(ctx, cfg, notifierService, m)
+		}
+	}, func(err error) {
+		slog.Debug("Ignoring malformed syslog message", logging.Err(err))
+	})
+	if err != nil {
+		slog.Warn("Syslog receiver failed", "addr", cfg.SyslogListen, logging.Err(err))
+	}
+}
+
+// reportSyslogMessage sends a notification titled by the program, severity and sending host, e.g. "sshd err on router1"
+func reportSyslogMessage(ctx context.Context, cfg *config.Config, notifierService *notifier.Service, m syslog.Message) {
+	title := m.AppName
+	if title == "" {
+		title = "syslog"
+	}
+	title += " " + syslog.SeverityName(m.Severity)
+	if m.Hostname != "" {
+		title += " on " + m.Hostname
+	}
+	slog.Info("Syslog message", "host", m.Hostname, "program", m.AppName, "severity", syslog.SeverityName(m.Severity))
+
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	_, err := notifierService.SendMessage(sendCtx, title, markdown.Escape(m.Text))
+	flushTraces()
+	if err != nil {
+		slog.Warn("Reporting syslog message failed", "host", m.Hostname, "program", m.AppName, logging.Err(err))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/telegram"
+)
+
+// runTest sends a test message directly to Telegram to verify credentials and connectivity
+// Bypasses the spool and fallback so problems with the primary channel surface immediately
+func runTest(args []string) {
+	cfg := loadConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	message := fmt.Sprintf("*Test Notification* ✅\n\n- 🖥️  *Host:* `%s`\n- 🕒  *Date/Time:* `%s`\n\nTelegram notifier is configured correctly.",
+		cfg.GetHostname(), cfg.FormatDateTime(time.Now()))
+
+	start := time.Now()
+	delivery, err := telegram.NewClient(cfg, nil).Send(ctx, message)
+	if err != nil {
+		fatal(categoryDelivery, codeDeliveryFailed, "Test notification failed", logging.Err(err))
+	}
+
+	fmt.Printf("Test notification sent (message id: %d, attempts: %d, %s)\n",
+		delivery.MessageID, delivery.Attempts, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/updates"
+)
+
+// defaultUpdatesWindow is how far back the first run looks, before any window was reported
+const defaultUpdatesWindow = 24 * time.Hour
+
+// maxListedPackages bounds each section of the summary; kernels and desktops update hundreds at once
+const maxListedPackages = 40
+
+// Titles of package update notifications
+const (
+	updatesTitle       = "Packages updated"
+	updatesFailedTitle = "Package update failed"
+)
+
+// updateSections orders the summary and names each action's heading
+var updateSections = []struct{ action, heading, verb string }{
+	{updates.ActionUpgrade, "Upgraded", "upgraded"},
+	{updates.ActionInstall, "Installed", "installed"},
+	{updates.ActionRemove, "Removed", "removed"},
+	{updates.ActionDowngrade, "Downgraded", "downgraded"},
+	{updates.ActionReinstall, "Reinstalled", "reinstalled"},
+}
+
+// runUpdates summarizes package changes since the last report and whether a reboot is pending
+// Meant for ExecStopPost= of apt-daily-upgrade.service or dnf-automatic-install.service
+func runUpdates(args []string) {
+	fs := flag.NewFlagSet("updates", flag.ExitOnError)
+	var since sinceFlag
+	fs.Var(&since, "since", "report changes newer than this (e.g. 2h, 1d) instead of since the last report")
+	fs.Parse(args)
+
+	cfg := loadConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.CommandTimeout)
+	defer cancel()
+
+	statePath := filepath.Join(cfg.StateDir, updates.StateFileName)
+	now := time.Now()
+	from := now.Add(-defaultUpdatesWindow)
+	if since > 0 {
+		from = now.Add(-time.Duration(since))
+	} else if last, err := updates.LastReported(statePath); err != nil {
+		slog.Warn("Reading update state failed", logging.Err(err))
+	} else if !last.IsZero() {
+		from = last
+	}
+
+	source, transactions, err := updates.Collect(from)
+	if err != nil {
+		fatal(categoryConfig, codeCheckFailed, "Reading package manager log failed", "log", source, logging.Err(err))
+	}
+	reboot, rebootPkgs := updates.RebootRequired(ctx)
+
+	// The logs have been read; privileges and sandbox only need to cover delivery
+	harden(cfg)
+
+	title, message := formatUpdates(transactions, reboot, rebootPkgs)
+	if message == "" {
+		if !quiet {
+			fmt.Printf("No package changes since %s\n", from.Format(time.DateTime))
+		}
+	} else {
+		_, err := newNotifierService(cfg).SendMessage(ctx, title, message)
+		flushTraces()
+		// The window stays open so the next run reports these changes again
+		if err != nil && !errors.Is(err, notifier.ErrSpooled) {
+			handleSendError(err)
+		}
+		if !quiet {
+			fmt.Printf("Reported %d package transaction(s) from %s\n", len(transactions), source)
+		}
+	}
+
+	if err := updates.SaveReported(statePath, now); err != nil {
+		fatal(categoryStorage, codeStateFailed, "Saving update state failed", logging.Err(err))
+	}
+}
+
+// formatUpdates renders the summary; the message is empty when nothing changed
+// Package names and versions are code spans since they contain Markdown characters
+func formatUpdates(transactions []updates.Transaction, reboot bool, rebootPkgs []string) (string, string) {
+	byAction := map[string][]updates.Change{}
+	var commands, failures []string
+	for _, tx := range transactions {
+		for _, c := range tx.Changes {
+			byAction[c.Action] = append(byAction[c.Action], c)
+		}
+		if tx.Command != "" && !slices.Contains(commands, tx.Command) {
+			commands = append(commands, tx.Command)
+		}
+		if tx.Error != "" {
+			failures = append(failures, tx.Error)
+		}
+	}
+	if len(byAction) == 0 && len(failures) == 0 {
+		return "", ""
+	}
+
+	var b strings.Builder
+	var counts []string
+	for _, s := range updateSections {
+		if n := len(byAction[s.action]); n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", n, s.verb))
+		}
+	}
+	if len(counts) > 0 {
+		fmt.Fprintf(&b, "*%s*", strings.Join(counts, ", "))
+	}
+	switch {
+	case len(commands) == 1:
+		fmt.Fprintf(&b, " via `%s`", commands[0])
+	case len(transactions) > 1:
+		fmt.Fprintf(&b, " in %d runs", len(transactions))
+	}
+	b.WriteString("\n")
+
+	for _, s := range updateSections {
+		changes := byAction[s.action]
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n*%s:*\n", s.heading)
+		for i, c := range changes {
+			if i == maxListedPackages {
+				fmt.Fprintf(&b, "- … and %d more\n", len(changes)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- `%s`\n", formatChange(c))
+		}
+	}
+
+	title := updatesTitle
+	if len(failures) > 0 {
+		title = updatesFailedTitle
+		b.WriteString("\n*Errors:*\n")
+		for _, f := range failures {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+
+	if reboot {
+		b.WriteString("\n🔁 *Reboot required*")
+		if len(rebootPkgs) > 0 {
+			fmt.Fprintf(&b, " (`%s`)", strings.Join(rebootPkgs, "`, `"))
+		}
+		b.WriteString("\n")
+	}
+	return title, b.String()
+}
+
+// formatChange renders "name old → new", or "name version" without a previous version
+func formatChange(c updates.Change) string {
+	switch {
+	case c.From != "":
+		return fmt.Sprintf("%s %s → %s", c.Package, c.From, c.To)
+	case c.To != "":
+		return c.Package + " " + c.To
+	default:
+		return c.Package
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+func printError(msg string) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n\n", msg)
+}
+
+func printUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  telegram-notifier <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-8s %s\n", name, subcommands[name].summary)
+	}
+	fmt.Println("")
+	fmt.Println("Global flags:")
+	fmt.Println("  --verbose      Log systemctl/journalctl calls, scopes, retries and timings to stderr")
+	fmt.Println("  --quiet        Don't print the success line (keeps it out of the unit's journal)")
+	fmt.Println("  --report json  Print the send result as a JSON object on stdout")
+	fmt.Println("  --error-format json")
+	fmt.Println("                 Write fatal errors as JSON (code, category, message) on stderr")
+	fmt.Println("")
+	fmt.Println("  Flags (recommended):")
+	fmt.Println("    ./telegram-notifier send --service <name> [--exit-code N] [--description D] [--message M] [--logs file]")
+	fmt.Println("    (Without --exit-code the exit status is read from systemd)")
+	fmt.Println("    ./telegram-notifier send --title <title> [--message M]   (free-form, not tied to a unit)")
+	fmt.Println("    (--message - reads the message from stdin; --logs - reads the command output from stdin instead of the journal)")
+	fmt.Println("    ./telegram-notifier send --plain --service <job> [--exit-code N] [--output file]   (no systemd; $EXIT_STATUS, --output - reads stdin)")
+	fmt.Println("")
+	fmt.Println("  Legacy positional modes (argument roles are guessed from their content):")
+	fmt.Println("")
+	fmt.Println("  Mode 1 - Manual (for testing):")
+	fmt.Println("    ./telegram-notifier <exit_code> <service_name> [custom_message]")
+	fmt.Println("")
+	fmt.Println("  Mode 2 - Systemd Integration:")
+	fmt.Println("    ./telegram-notifier <service_name> [custom_message]")
+	fmt.Println("    ./telegram-notifier <service_name> [service_description] [custom_message]")
+	fmt.Println("    (Uses $EXIT_STATUS, $SERVICE_RESULT, and other environment variables)")
+	fmt.Println("")
+	fmt.Println("  Other commands:")
+	fmt.Println("    ./telegram-notifier test")
+	fmt.Println("    ./telegram-notifier init [--keyring]   (writes the config file; --keyring keeps the token out of it)")
+	fmt.Println("    ./telegram-notifier install [--system] [--force]")
+	fmt.Println("    ./telegram-notifier doctor [--service name.service]...   (also audits the unit for inline credentials)")
+	fmt.Println("    ./telegram-notifier run [--name N] [--always] -- command [args...]   (cron jobs outside systemd)")
+	fmt.Println("    ./telegram-notifier smart [--all]   (from a root timer; needs smartmontools)")
+	fmt.Println("    ./telegram-notifier updates [--since 1d]   (from ExecStopPost= of apt-daily-upgrade.service or dnf-automatic-install.service)")
+	fmt.Println("    ./telegram-notifier kube [--namespace NS | --all-namespaces] [--selector L] [--always]   (long-running, in-cluster or via kubectl)")
+	fmt.Println("    ./telegram-notifier maintenance on|off|status [--service unit] [--duration 2h]   (no notifications for the unit, or all, meanwhile)")
+	fmt.Println("    ./telegram-notifier snooze [unit [1h|off]]   (mutes one unit; without arguments lists snoozed ones)")
+	fmt.Println("    ./telegram-notifier flush")
+	fmt.Println("    ./telegram-notifier history [--service name.service] [--since 24h] [--limit 20]")
+	fmt.Println("    ./telegram-notifier stats [--since 7d] [--service name.service]")
+	fmt.Println("    ./telegram-notifier daemon   (pairs with NOTIFIER_ASYNC)")
+	fmt.Println("    ./telegram-notifier heartbeat   (from a timer; pings NOTIFIER_HEARTBEAT_URL when set)")
+	fmt.Println("")
+	fmt.Println("Examples:")
+	fmt.Println("  # Flags")
+	fmt.Println("  ./telegram-notifier send --service my-backup.service --exit-code 0 --message \"Backup completed\"")
+	fmt.Println("  ExecStopPost=/usr/local/bin/telegram-notifier send --service %n")
+	fmt.Println("  some-job 2>&1 | ./telegram-notifier send --service job.service --exit-code $? --message -")
+	fmt.Println("  out=$(backup.sh 2>&1); code=$?; echo \"$out\" | ./telegram-notifier send --service backup.service --exit-code $code --logs -")
+	fmt.Println("  df -h / | ./telegram-notifier send --title \"Disk almost full\" --message -")
+	fmt.Println("  backup.sh > backup.log 2>&1; ./telegram-notifier send --plain --service backup --exit-code $? --output backup.log")
+	fmt.Println("")
+	fmt.Println("  # Manual mode")
+	fmt.Println("  ./telegram-notifier 0 my-backup.service \"Backup completed\"")
+	fmt.Println("  ./telegram-notifier 200 my-app.service \"Application failed with CHDIR error\"")
+	fmt.Println("")
+	fmt.Println("  # Systemd mode (in ExecStartPost/ExecStopPost)")
+	fmt.Println("  ExecStartPost=/usr/local/bin/telegram-notifier %n")
+	fmt.Println("  ExecStopPost=/usr/local/bin/telegram-notifier %n")
+	fmt.Println("")
+	fmt.Println("Security:")
+	fmt.Println("  Unit names must follow systemd's grammar (prefix[@instance].type, alphanumeric, :_.- and \\xNN escapes)")
+	fmt.Println("  Names without a unit type are services: backup means backup.service, backup.timer is kept")
+	fmt.Println("  Non-ASCII characters are escaped as \\xNN like systemd-escape; /mnt/data means mnt-data.mount")
+	fmt.Println("  Shell metacharacters are rejected to prevent command injection")
+	fmt.Println("  Exit codes must be in range 0-255")
+	fmt.Println("  Sensitive data is automatically filtered from output")
+	fmt.Println("  Command execution is rate-limited to prevent abuse")
+	fmt.Println("")
+	fmt.Println("Privacy:")
+	fmt.Println("  Set NOTIFIER_HOSTNAME_ALIAS to use a custom hostname in notifications")
+	fmt.Println("  All error messages are sanitized before logging")
+	fmt.Println("")
+	fmt.Println("Configuration (set in ~/.config/environment.d/*.conf):")
+	fmt.Println("  TELEGRAM_BOT_TOKEN       - Telegram bot token (required)")
+	fmt.Println("  TELEGRAM_CHAT_ID         - Telegram chat ID (required)")
+	fmt.Println("  NOTIFIER_TOKEN_SOURCE    - keyring reads the bot token from the Secret Service")
+	fmt.Println("  NOTIFIER_HOSTNAME_ALIAS  - Custom hostname for privacy")
+	fmt.Println("  TZ                       - Timezone (e.g., America/New_York, UTC)")
+	fmt.Println("  NOTIFIER_COMMAND_TIMEOUT - Max command execution time (default: 30s)")
+	fmt.Println("  NOTIFIER_MAX_OUTPUT_SIZE - Max output characters (default: 2500)")
+	fmt.Println("  NOTIFIER_SPOOL_DIR       - Undelivered notification spool (default: <state dir>/spool)")
+	fmt.Println("")
+	fmt.Println("Exit Codes:")
+	fmt.Println("  0   - SUCCESS")
+	fmt.Println("  1   - Generic failure")
+	fmt.Println("  126 - Command cannot execute")
+	fmt.Println("  127 - Command not found")
+	fmt.Println("  200 - Change directory failed (CHDIR)")
+	fmt.Println("  203 - Cannot execute (EXEC)")
+	fmt.Println("  See systemd.exec(5) for full list")
+}
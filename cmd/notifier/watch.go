@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/systemd"
+)
+
+// watchManager reports daemon reloads and manual restarts and reloads of watched units until ctx is cancelled
+// journalctl is started again whenever it exits, so a restarted journald doesn't end the watch
+func watchManager(ctx context.Context, cfg *config.Config, notifierService *notifier.Service) {
+	systemdService := systemd.NewService(systemd.NewCommandExecutor(), cfg)
+	slog.Info("Watching the service manager", "daemon_reload", cfg.WatchDaemonReload, "units", cfg.WatchUnits)
+	for {
+		err := systemdService.WatchManagerEvents(ctx, func(event systemd.ManagerEvent) {
+			reportManagerEvent(ctx, cfg, notifierService, event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Watching the service manager failed", "retry_in", constants.ManagerWatchRetry, logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(constants.ManagerWatchRetry):
+		}
+	}
+}
+
+// reportManagerEvent sends a notification for an event the configuration asks for
+func reportManagerEvent(ctx context.Context, cfg *config.Config, notifierService *notifier.Service, event systemd.ManagerEvent) {
+	title := "systemd reloaded"
+	switch {
+	case event.Kind == systemd.EventDaemonReload && cfg.WatchDaemonReload:
+	case event.Kind != systemd.EventDaemonReload && cfg.WatchesUnit(event.Unit):
+		title = event.Unit + " " + event.Kind + "ed"
+	default:
+		return
+	}
+	slog.Info("Service manager event", "event", event.Kind, logging.KeyService, event.Unit)
+
+	sendCtx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+	// Log messages carry unit names with "_" and other Markdown characters
+	_, err := notifierService.SendMessage(sendCtx, title, markdown.Escape(event.Message))
+	flushTraces()
+	if err != nil {
+		slog.Warn("Reporting service manager event failed", "event", event.Kind, logging.KeyService, event.Unit, logging.Err(err))
+	}
+}
@@ -0,0 +1,226 @@
+// Package alertmanager renders Prometheus Alertmanager webhook payloads as notifications
+// Messages come from Go text/template definitions, so users can reshape them without rebuilding
+package alertmanager
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/validation"
+)
+
+// Alert statuses
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Names of the templates rendering a payload
+const (
+	TitleTemplate   = "title"
+	MessageTemplate = "message"
+)
+
+// Payload is the body of an Alertmanager webhook (version 4)
+type Payload struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            Alerts            `json:"alerts"`
+}
+
+// Alert is a single alert of a group
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Alerts is a list of alerts, filterable by status in templates
+type Alerts []Alert
+
+// Firing returns the alerts still firing
+func (as Alerts) Firing() Alerts {
+	return as.withStatus(StatusFiring)
+}
+
+// Resolved returns the alerts that resolved
+func (as Alerts) Resolved() Alerts {
+	return as.withStatus(StatusResolved)
+}
+
+func (as Alerts) withStatus(status string) Alerts {
+	var out Alerts
+	for _, a := range as {
+		if a.Status == status {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// defaultTemplates render a group like Alertmanager's own notifiers: a "[FIRING:N] name" title
+// and one block per alert with its summary, description and distinguishing labels
+const defaultTemplates = `
+{{- define "title" -}}
+[{{ .Status | upper }}{{ if eq .Status "firing" }}:{{ len .Alerts.Firing }}{{ end }}] {{ or (index .GroupLabels "alertname") (index .CommonLabels "alertname") "Alertmanager" }}
+{{- end -}}
+
+{{- define "message" -}}
+{{ range $i, $a := .Alerts -}}
+{{ if $i }}
+{{ end -}}
+{{ if eq .Status "firing" }}🔥{{ else }}✅{{ end }} *{{ index .Labels "alertname" | escape }}*{{ with index .Labels "severity" }} ({{ . | escape }}){{ end }}
+{{ with index .Annotations "summary" }}{{ . | escape }}
+{{ end -}}
+{{ with index .Annotations "description" }}{{ . | escape }}
+{{ end -}}
+{{ with labels .Labels "alertname" "severity" }}Labels: {{ code . }}
+{{ end -}}
+{{ if eq .Status "firing" }}Since: {{ datetime .StartsAt | code }}{{ else }}Resolved: {{ datetime .EndsAt | code }}{{ end }}
+{{ end -}}
+{{ if .TruncatedAlerts }}
+… and {{ .TruncatedAlerts }} more alerts
+{{ end -}}
+{{- end -}}
+`
+
+// LoadTemplate returns the built-in templates, with definitions from path replacing them when set
+// datetime formats timestamps like the rest of the notification
+func LoadTemplate(path string, datetime func(time.Time) string) (*template.Template, error) {
+	tmpl, err := template.New("alertmanager").Funcs(templateFuncs(datetime)).Parse(defaultTemplates)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return tmpl, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(string(data)); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// templateFuncs are the helpers available to templates besides the text/template builtins
+func templateFuncs(datetime func(time.Time) string) template.FuncMap {
+	return template.FuncMap{
+		"upper":    strings.ToUpper,
+		"join":     strings.Join,
+		"escape":   markdown.Escape,
+		"code":     markdown.Code,
+		"labels":   formatLabels,
+		"datetime": datetime,
+	}
+}
+
+// maxFieldSize bounds each label and annotation value, so one verbose description can't crowd out the other alerts
+const maxFieldSize = 500
+
+// Render executes the title and message templates for a payload
+// Values are cut to maxFieldSize and the alerts that don't fit the message in maxSize are left out and counted
+// in TruncatedAlerts before formatting, so the message never has to be cut through its Markdown
+func Render(tmpl *template.Template, p Payload, maxSize int) (string, string, error) {
+	p = p.bounded()
+	var title strings.Builder
+	if err := tmpl.ExecuteTemplate(&title, TitleTemplate, p); err != nil {
+		return "", "", err
+	}
+
+	message, err := renderMessage(tmpl, p)
+	if err != nil || len(p.Alerts) <= 1 || validation.UTF16Length(message) <= maxSize {
+		return strings.TrimSpace(title.String()), message, err
+	}
+
+	// Find the most alerts that fit; a single alert is kept even when it doesn't
+	all, truncated := p.Alerts, p.TruncatedAlerts
+	fits := func(n int) (string, bool, error) {
+		p.Alerts, p.TruncatedAlerts = all[:n], truncated+len(all)-n
+		m, err := renderMessage(tmpl, p)
+		return m, err == nil && validation.UTF16Length(m) <= maxSize, err
+	}
+	low, high := 1, len(all)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if _, ok, err := fits(mid); err != nil {
+			return "", "", err
+		} else if ok {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	message, _, err = fits(low)
+	return strings.TrimSpace(title.String()), message, err
+}
+
+// renderMessage executes the message template
+func renderMessage(tmpl *template.Template, p Payload) (string, error) {
+	var message strings.Builder
+	if err := tmpl.ExecuteTemplate(&message, MessageTemplate, p); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(message.String()), nil
+}
+
+// bounded returns a copy of p with label and annotation values cut to maxFieldSize
+func (p Payload) bounded() Payload {
+	p.GroupLabels = boundValues(p.GroupLabels)
+	p.CommonLabels = boundValues(p.CommonLabels)
+	p.CommonAnnotations = boundValues(p.CommonAnnotations)
+	alerts := make(Alerts, len(p.Alerts))
+	for i, a := range p.Alerts {
+		a.Labels = boundValues(a.Labels)
+		a.Annotations = boundValues(a.Annotations)
+		alerts[i] = a
+	}
+	p.Alerts = alerts
+	return p
+}
+
+// boundValues copies values, cutting long ones to maxFieldSize
+func boundValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = validation.TruncateHead(v, maxFieldSize, validation.UTF16Length)
+	}
+	return out
+}
+
+// formatLabels renders labels as sorted "k=v" pairs, leaving out the given names
+func formatLabels(labels map[string]string, omit ...string) string {
+	var pairs []string
+	for k, v := range labels {
+		skip := false
+		for _, o := range omit {
+			skip = skip || k == o
+		}
+		if !skip {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
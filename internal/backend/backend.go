@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/validation"
+)
+
+// Supported fallback backend names for NOTIFIER_FALLBACK
+const (
+	NameNtfy    = "ntfy"
+	NameWebhook = "webhook"
+	NameEmail   = "email"
+)
+
+// Backend delivers a rendered notification through an alternative channel
+type Backend interface {
+	Name() string
+	Send(ctx context.Context, message string) error
+}
+
+// Limiter is implemented by backends that cap message size in their own unit
+// The failover trims Telegram-sized messages to fit before handing them over
+type Limiter interface {
+	Limit() (maxSize int, measure validation.Measure)
+}
+
+// Formatter is implemented by backends that don't render Telegram's Markdown
+// The failover hands them Format's result instead of the Markdown source
+type Formatter interface {
+	Format(message string) string
+}
+
+// New creates the fallback backend selected in configuration
+// Returns nil without error when no fallback is configured
+func New(cfg *config.Config) (Backend, error) {
+	httpClient := httpclient.New(cfg)
+
+	switch cfg.Fallback {
+	case "":
+		return nil, nil
+	case NameNtfy:
+		if cfg.NtfyURL == "" {
+			return nil, fmt.Errorf("NOTIFIER_NTFY_URL must be set for the ntfy fallback")
+		}
+		return NewNtfy(cfg.NtfyURL, cfg.NtfyToken, httpClient), nil
+	case NameWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("NOTIFIER_WEBHOOK_URL must be set for the webhook fallback")
+		}
+		return NewWebhook(cfg.WebhookURL, cfg.WebhookSecret, httpClient), nil
+	case NameEmail:
+		if cfg.SMTPAddr == "" || cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+			return nil, fmt.Errorf("NOTIFIER_SMTP_ADDR, NOTIFIER_SMTP_FROM and NOTIFIER_SMTP_TO must be set for the email fallback")
+		}
+		return NewEmail(cfg.SMTPAddr, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPTo), nil
+	default:
+		return nil, fmt.Errorf("unknown fallback backend %q", cfg.Fallback)
+	}
+}
+
+// checkResponse converts non-2xx HTTP responses into errors
+func checkResponse(name string, resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", name, resp.StatusCode)
+	}
+	return nil
+}
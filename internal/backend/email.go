@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/markdown"
+)
+
+// Email delivers notifications over SMTP
+// STARTTLS is used automatically when the server advertises it
+type Email struct {
+	addr     string
+	user     string
+	password string
+	from     string
+	to       []string
+}
+
+// NewEmail creates an SMTP backend; user and password are optional
+func NewEmail(addr, user, password, from string, to []string) *Email {
+	return &Email{addr: addr, user: user, password: password, from: from, to: to}
+}
+
+func (e *Email) Name() string {
+	return NameEmail
+}
+
+// Format shows the text Telegram would display, since mail is sent as text/plain
+func (e *Email) Format(message string) string {
+	return markdown.Plain(message)
+}
+
+// Send mails the message as plain text
+// The connection is closed when ctx ends, so a stalled server can't outlive the caller
+func (e *Email) Send(ctx context.Context, message string) error {
+	host, _, err := net.SplitHostPort(e.addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return fmt.Errorf("smtp error: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := e.send(conn, host, message); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("smtp send cancelled: %w", ctx.Err())
+		}
+		return fmt.Errorf("smtp error: %w", err)
+	}
+	return nil
+}
+
+// send runs the SMTP conversation smtp.SendMail would, over an existing connection
+func (e *Email) send(conn net.Conn, host, message string) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.user != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.user, e.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.buildMessage(message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage formats RFC 5322 headers and body
+// SECURITY: Header values come from configuration only; CR/LF are stripped defensively
+func (e *Email) buildMessage(body string) []byte {
+	clean := func(s string) string {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", clean(e.from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean(strings.Join(e.to, ", ")))
+	b.WriteString("Subject: Automated Notification\r\n")
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// Primary is the main delivery path (the Telegram client)
+type Primary interface {
+	Send(ctx context.Context, message string, opts ...telegram.SendOption) (telegram.Delivery, error)
+}
+
+// FailoverClient delivers through the primary client and falls back to a
+// secondary backend when the primary fails after its own retries
+type FailoverClient struct {
+	primary  Primary
+	fallback Backend
+}
+
+// NewFailoverClient wraps primary with a fallback backend
+func NewFailoverClient(primary Primary, fallback Backend) *FailoverClient {
+	return &FailoverClient{primary: primary, fallback: fallback}
+}
+
+// Send tries the primary client first, then the fallback
+// The failover is noted at the top of the message so readers know Telegram was unreachable.
+// If both fail, the primary error is returned so the notification can still be spooled.
+// Send options such as buttons only apply to the primary; fallback backends get the text alone
+func (f *FailoverClient) Send(ctx context.Context, message string, opts ...telegram.SendOption) (telegram.Delivery, error) {
+	delivery, primaryErr := f.primary.Send(ctx, message, opts...)
+	if primaryErr == nil {
+		return delivery, nil
+	}
+
+	note := fmt.Sprintf("⚠️ *Failover:* Telegram delivery failed (%s); delivered via %s.\n\n",
+		validation.SanitizeErrorMessage(primaryErr), f.fallback.Name())
+
+	message = note + message
+	if formatter, ok := f.fallback.(Formatter); ok {
+		message = formatter.Format(message)
+	}
+	// The header naming the unit is worth more than the end of the output here
+	if limiter, ok := f.fallback.(Limiter); ok {
+		maxSize, measure := limiter.Limit()
+		message = validation.TruncateHead(message, maxSize, measure)
+	}
+
+	delivery.Attempts++
+	start := time.Now()
+	err := f.fallback.Send(ctx, message)
+	delivery.AttemptLog = append(delivery.AttemptLog, telegram.Attempt{Backend: f.fallback.Name(), Latency: time.Since(start)})
+	if err != nil {
+		return delivery, fmt.Errorf("%w (fallback %s also failed: %s)", primaryErr, f.fallback.Name(), validation.SanitizeErrorMessage(err))
+	}
+
+	delivery.MessageID = 0
+	delivery.Backend = f.fallback.Name()
+	return delivery, nil
+}
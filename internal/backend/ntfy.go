@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/validation"
+)
+
+// ntfyMaxMessageBytes is ntfy's default message size limit; larger bodies become attachments or are rejected
+const ntfyMaxMessageBytes = 4096
+
+// Ntfy publishes notifications to an ntfy topic URL (e.g. https://ntfy.sh/my-topic)
+type Ntfy struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// NewNtfy creates an ntfy backend; token is optional for protected topics
+func NewNtfy(url, token string, httpClient *http.Client) *Ntfy {
+	return &Ntfy{url: url, token: token, httpClient: httpClient}
+}
+
+func (n *Ntfy) Name() string {
+	return NameNtfy
+}
+
+// Limit reports ntfy's message limit, which is counted in bytes rather than characters
+func (n *Ntfy) Limit() (int, validation.Measure) {
+	return ntfyMaxMessageBytes, validation.ByteLength
+}
+
+// Send publishes the message body with Markdown rendering enabled
+func (n *Ntfy) Send(ctx context.Context, message string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Title", "Automated Notification")
+	req.Header.Set("Markdown", "yes")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy http error: %w", err)
+	}
+	defer httpclient.DrainAndClose(resp.Body)
+
+	return checkResponse(n.Name(), resp)
+}
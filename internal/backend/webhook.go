@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/markdown"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>", the GitHub-style convention
+// Receivers recompute the HMAC over the raw body with the shared secret and compare in constant time
+const SignatureHeader = "X-Signature"
+
+// WebhookPayload is the JSON body posted to generic webhook receivers
+type WebhookPayload struct {
+	Text string `json:"text"`
+}
+
+// Webhook posts notifications as JSON to an arbitrary HTTP endpoint
+type Webhook struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewWebhook creates a generic webhook backend; bodies are signed when secret is set
+func NewWebhook(url, secret string, httpClient *http.Client) *Webhook {
+	return &Webhook{url: url, secret: secret, httpClient: httpClient}
+}
+
+func (w *Webhook) Name() string {
+	return NameWebhook
+}
+
+// Format shows the text Telegram would display; receivers get the text as is
+func (w *Webhook) Format(message string) string {
+	return markdown.Plain(message)
+}
+
+// Send posts the message as {"text": "..."}
+func (w *Webhook) Send(ctx context.Context, message string) error {
+	body, err := json.Marshal(WebhookPayload{Text: message})
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook http error: %w", err)
+	}
+	defer httpclient.DrainAndClose(resp.Body)
+
+	return checkResponse(w.Name(), resp)
+}
+
+// Sign returns the SignatureHeader value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
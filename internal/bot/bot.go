@@ -0,0 +1,186 @@
+// Package bot answers the commands and button presses users send to the notifier's bot
+// Every request is checked against the access policy before its handler runs
+package bot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/botauth"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// retryDelay is the pause after a failed getUpdates, so an outage doesn't turn into a request loop
+const retryDelay = 5 * time.Second
+
+// API is the part of the Telegram client the bot uses
+type API interface {
+	GetUpdates(ctx context.Context, offset int64, wait time.Duration) ([]telegram.Update, error)
+	AnswerCallback(ctx context.Context, callbackID, text string) error
+	Reply(ctx context.Context, chatID, replyTo int64, text string, buttons ...[]telegram.Button) error
+}
+
+// Request is a command or button press from an authorized user
+type Request struct {
+	ChatID    int64
+	MessageID int64 // The command message, or the message carrying the pressed button
+	User      telegram.User
+	Args      []string // Words after the command, or the button's data after "action:"
+	Button    bool     // Sent by pressing a button rather than typing a command
+	Reaction  bool     // Sent by reacting to MessageID rather than typing a command
+}
+
+// Handler answers a request with the text shown to the user
+// A typed command that posted its own reply, such as a prompt with buttons, answers with an empty text
+type Handler func(ctx context.Context, req Request) (string, error)
+
+// Command is an action reachable as "/name args" or through a button with data "name:arg"
+type Command struct {
+	Permission botauth.Permission
+	Handle     Handler
+}
+
+// Bot dispatches updates to registered commands
+type Bot struct {
+	api       API
+	policy    botauth.Policy
+	commands  map[string]Command
+	reactions map[string]string // Emoji to the command it runs
+}
+
+// New creates a bot answering users allowed by policy
+func New(api API, policy botauth.Policy) *Bot {
+	return &Bot{api: api, policy: policy, commands: map[string]Command{}, reactions: map[string]string{}}
+}
+
+// Handle registers a command by name, without the leading slash
+func (b *Bot) Handle(name string, cmd Command) {
+	b.commands[name] = cmd
+}
+
+// HandleReaction runs the command name when a user adds emoji to a message
+// The command gets the message in Request.MessageID and no arguments; its answer is only logged
+func (b *Bot) HandleReaction(emoji, name string) {
+	b.reactions[emoji] = name
+}
+
+// Run polls for updates until ctx is cancelled
+func (b *Bot) Run(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := b.api.GetUpdates(ctx, offset, constants.BotPollWait)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Fetching bot updates failed", logging.Err(err))
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, u := range updates {
+			offset = max(offset, u.UpdateID+1)
+			b.HandleUpdate(ctx, u)
+		}
+	}
+}
+
+// HandleUpdate answers a single update; updates that aren't commands are ignored
+func (b *Bot) HandleUpdate(ctx context.Context, u telegram.Update) {
+	switch {
+	case u.CallbackQuery != nil:
+		b.handleCallback(ctx, u.CallbackQuery)
+	case u.Message != nil && u.Message.From != nil && strings.HasPrefix(u.Message.Text, "/"):
+		b.handleMessage(ctx, u.Message)
+	case u.Reaction != nil && u.Reaction.User != nil:
+		b.handleReaction(ctx, u.Reaction)
+	}
+}
+
+// handleMessage runs a typed command and replies with its answer
+func (b *Bot) handleMessage(ctx context.Context, msg *telegram.IncomingMessage) {
+	fields := strings.Fields(msg.Text)
+	// In groups commands may be addressed as "/ack@NotifierBot"
+	name, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	cmd, ok := b.commands[name]
+	if !ok {
+		return
+	}
+	req := Request{ChatID: msg.Chat.ID, MessageID: msg.MessageID, User: *msg.From, Args: fields[1:]}
+
+	answer, deny := b.run(ctx, name, cmd, req)
+	if deny {
+		// Unknown chats get no answer, so the bot doesn't reveal itself to strangers
+		if !b.policy.Chats[msg.Chat.ID] {
+			return
+		}
+	}
+	if answer == "" {
+		return
+	}
+	if err := b.api.Reply(ctx, msg.Chat.ID, msg.MessageID, answer); err != nil {
+		slog.Warn("Answering bot command failed", "command", name, logging.Err(err))
+	}
+}
+
+// handleCallback runs a button's action and shows its answer to the user who pressed it
+func (b *Bot) handleCallback(ctx context.Context, cq *telegram.CallbackQuery) {
+	name, arg, _ := strings.Cut(cq.Data, ":")
+	answer := "Unknown action"
+	if cmd, ok := b.commands[name]; ok && cq.Message != nil {
+		req := Request{ChatID: cq.Message.Chat.ID, MessageID: cq.Message.MessageID, User: cq.From, Button: true}
+		if arg != "" {
+			req.Args = []string{arg}
+		}
+		answer, _ = b.run(ctx, name, cmd, req)
+	}
+	// Button answers are plain toasts, where Markdown markers would show literally
+	answer = strings.NewReplacer("`", "", "*", "").Replace(answer)
+	if err := b.api.AnswerCallback(ctx, cq.ID, answer); err != nil {
+		slog.Warn("Answering button press failed", "action", name, logging.Err(err))
+	}
+}
+
+// handleReaction runs the command of each registered emoji the user added
+// Nothing is posted in reply: a command worth reacting for shows its effect on the message itself
+func (b *Bot) handleReaction(ctx context.Context, r *telegram.Reaction) {
+	for emoji, name := range b.reactions {
+		cmd, ok := b.commands[name]
+		if !ok || !r.Added(emoji) {
+			continue
+		}
+		req := Request{ChatID: r.Chat.ID, MessageID: r.MessageID, User: *r.User, Reaction: true}
+		answer, deny := b.run(ctx, name, cmd, req)
+		if !deny {
+			slog.Debug("Answered bot reaction", "command", name, "answer", answer)
+		}
+	}
+}
+
+// run authorizes and executes a command, returning the answer and whether it was refused
+func (b *Bot) run(ctx context.Context, name string, cmd Command, req Request) (string, bool) {
+	// SECURITY: Both the chat and the user must be allowed before anything runs
+	if err := b.policy.Authorize(req.ChatID, req.User.ID, cmd.Permission); err != nil {
+		slog.Warn("Refused bot command", "command", name, "user", req.User.ID, "chat", req.ChatID, logging.Err(err))
+		return "⛔ Not authorized", true
+	}
+
+	slog.Info("Running bot command", "command", name, "user", req.User.ID, "chat", req.ChatID)
+	answer, err := cmd.Handle(ctx, req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return "Cancelled", false
+		}
+		slog.Warn("Bot command failed", "command", name, logging.Err(err))
+		return "⚠️ " + validation.SanitizeErrorMessage(err), false
+	}
+	return answer, false
+}
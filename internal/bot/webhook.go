@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"telegram-notifier/internal/telegram"
+)
+
+// webhookQueueSize bounds updates received but not handled yet; Telegram retries the ones refused when it's full
+const webhookQueueSize = 100
+
+// maxUpdateSize bounds webhook bodies; an update is a message or button press, far below this
+const maxUpdateSize = 1024 * 1024
+
+// Webhook receives the updates Telegram posts to a registered webhook
+// Requests are answered as soon as the update is queued, and Run handles the queue in order like polling would
+type Webhook struct {
+	bot     *Bot
+	secret  string
+	updates chan telegram.Update
+}
+
+// Webhook creates a receiver accepting requests that carry secret, as given to SetWebhook
+func (b *Bot) Webhook(secret string) *Webhook {
+	return &Webhook{bot: b, secret: secret, updates: make(chan telegram.Update, webhookQueueSize)}
+}
+
+// Run handles received updates until ctx is cancelled
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		select {
+		case u := <-w.updates:
+			w.bot.HandleUpdate(ctx, u)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ServeHTTP queues one update per request
+// A slow command must not hold the request open: Telegram would take that as a failure and send the update again
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// SECURITY: Anyone who finds the URL could otherwise post commands as any user; compared in constant time
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(telegram.SecretTokenHeader)), []byte(w.secret)) != 1 {
+		slog.Warn("Refused webhook request not signed by Telegram", "remote", r.RemoteAddr)
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var u telegram.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxUpdateSize)).Decode(&u); err != nil {
+		http.Error(rw, "invalid update: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case w.updates <- u:
+		rw.WriteHeader(http.StatusOK)
+	default:
+		http.Error(rw, "busy", http.StatusServiceUnavailable)
+	}
+}
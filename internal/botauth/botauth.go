@@ -0,0 +1,99 @@
+// Package botauth decides who may use the bot's interactive commands and buttons
+// Both the chat and the sender must be allowed, so a leaked group invite alone grants nothing
+package botauth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Permission is the access level a command requires
+type Permission string
+
+// Permissions in increasing order of privilege; each includes the ones before it
+const (
+	PermissionView    Permission = "view"    // Read-only: status, logs
+	PermissionControl Permission = "control" // Changes state: restart, stop, mute
+)
+
+// rank orders permissions so a higher one satisfies a lower requirement
+var rank = map[Permission]int{
+	PermissionView:    1,
+	PermissionControl: 2,
+}
+
+// Users maps Telegram user IDs to their highest permission
+type Users map[int64]Permission
+
+// ErrUnauthorized is returned for chats or users not allowed to run a command
+var ErrUnauthorized = errors.New("not authorized")
+
+// Policy lists the chats the bot answers in and the users allowed to act
+// The zero value denies everything
+type Policy struct {
+	Chats map[int64]bool // Chats whose messages and button presses are handled
+	Users Users          // Telegram user IDs and their highest permission
+}
+
+// Authorize checks that userID, writing in chatID, holds at least need
+// SECURITY: Unknown chats are rejected before users, so allowlisted users can't drive the bot from other groups
+func (p Policy) Authorize(chatID, userID int64, need Permission) error {
+	if !p.Chats[chatID] {
+		return fmt.Errorf("chat %d: %w", chatID, ErrUnauthorized)
+	}
+	granted, ok := p.Users[userID]
+	if !ok || rank[granted] < rank[need] {
+		return fmt.Errorf("user %d needs %s permission: %w", userID, need, ErrUnauthorized)
+	}
+	return nil
+}
+
+// Enabled reports whether anyone could be authorized at all
+func (p Policy) Enabled() bool {
+	return len(p.Chats) > 0 && len(p.Users) > 0
+}
+
+// ParseChats parses a comma-separated list of chat IDs
+func ParseChats(v string) (map[int64]bool, error) {
+	chats := map[int64]bool{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q", item)
+		}
+		chats[id] = true
+	}
+	return chats, nil
+}
+
+// ParseUsers parses "userID=permission;userID=permission" mappings
+func ParseUsers(v string) (Users, error) {
+	users := Users{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		idText, permText, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q (expected userID=%s|%s)", entry, PermissionView, PermissionControl)
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(idText), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid user ID %q", idText)
+		}
+		perm := Permission(strings.ToLower(strings.TrimSpace(permText)))
+		if _, known := rank[perm]; !known {
+			return nil, fmt.Errorf("unknown permission %q for user %d (use %s or %s)", permText, id, PermissionView, PermissionControl)
+		}
+		users[id] = perm
+	}
+	return users, nil
+}
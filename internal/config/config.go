@@ -1,40 +1,172 @@
 package config
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"telegram-notifier/internal/botauth"
 	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/keyring"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/schedule"
+	"telegram-notifier/internal/severity"
+	"telegram-notifier/internal/syslog"
+	"telegram-notifier/internal/validation"
 )
 
 // Config holds all application configuration loaded from environment variables
 type Config struct {
-	BotToken            string         // Telegram bot token (TELEGRAM_BOT_TOKEN)
-	ChatID              string         // Telegram chat ID (TELEGRAM_CHAT_ID)
-	CommandTimeout      time.Duration  // Max time for command execution
-	HTTPTimeout         time.Duration  // Max time for HTTP requests
-	JournalLookback     time.Duration  // How far back to look in journal
-	MaxOutputSize       int            // Max characters in output messages
-	TruncationMsgSize   int            // Size of truncation message
-	DateTimeFormat      string         // Format string for timestamps
-	JournalSinceDefault string         // Default since parameter for journal
-	HostnameAlias       string         // Privacy: custom hostname for notifications
-	TimeLocation        *time.Location // Timezone for timestamp formatting
+	BotToken            string            // Telegram bot token (TELEGRAM_BOT_TOKEN)
+	TokenSource         string            // Where the bot token comes from: env or keyring
+	ChatID              string            // Telegram chat ID (TELEGRAM_CHAT_ID)
+	CommandTimeout      time.Duration     // Max time for command execution
+	HTTPTimeout         time.Duration     // Max time for HTTP requests
+	ConnectTimeout      time.Duration     // Max time to open a connection, TLS handshake included
+	IdleConnTimeout     time.Duration     // How long idle connections are kept for reuse (0 disables keep-alive)
+	HTTP2               bool              // Negotiate HTTP/2 with servers that offer it
+	JournalLookback     time.Duration     // How far back to look in journal
+	UnitPaths           []string          // Extra directories searched for unit files, before the standard ones
+	MaxOutputSize       int               // Max characters in output messages
+	TruncationMsgSize   int               // Size of truncation message
+	DateTimeFormat      string            // Format string for timestamps
+	HostnameAlias       string            // Privacy: custom hostname for notifications
+	ChannelSignature    string            // Line signing posts to @channel chats; "{host}" becomes the hostname
+	ForumTopics         bool              // Post each service's notifications in its own forum topic
+	TimeLocation        *time.Location    // Timezone for timestamp formatting
+	IncludeHealth       bool              // Append system health snapshot to failure notifications
+	SystemErrorLines    int               // Last error-priority lines of the system journal appended to failures; 0 disables
+	IncludeCgroup       bool              // Append the unit's task, memory and CPU use against its limits to failures
+	IncludeDenials      bool              // Append SELinux and AppArmor denials logged during the run to failures
+	Formatting          string            // markdown or entities: how Telegram is told which parts are formatted
+	SuccessFormat       string            // full or brief (one line, no journal lookup) for successful runs
+	Policy              string            // always, failure-only or recovery: which runs are reported
+	FailureThresholds   map[string]int    // Consecutive failures before alerting, per unit, job or "*"
+	RuntimeDeviation    int               // Warn when a run takes this many times longer or shorter than its average; 0 disables
+	QuietHours          []schedule.Window // Daily windows in TimeLocation during which successes aren't sent
+	QuietFailures       bool              // Failures and recoveries are still sent during quiet hours
+	Escalate            []string          // Units and jobs ("*" for all) whose failures repeat until acknowledged
+	EscalationInterval  time.Duration     // Wait before the first repeat; doubles after each
+	Severity            severity.Rules    // Exit codes and signal deaths mapped to info, warning or critical
+	SeverityChats       map[string]string // Chat each severity level is sent to instead of TELEGRAM_CHAT_ID
+	SilentSeverities    []string          // Levels sent without a notification sound
+	EscalateSeverities  []string          // Levels whose failures repeat until acknowledged, whatever the unit
+	ServicesFile        string            // Per-service policy overrides (NOTIFIER_SERVICES_FILE)
+	ServicePolicies     map[string]ServicePolicy
+	VersionSources      map[string]string // Per-service version source (file:, command:, execstart)
+	LogFiles            map[string]string // Per-service log file read instead of the journal
+	Containers          map[string]string // Per-service container whose logs are read instead of the journal
+	ContainerSocket     string            // Docker or Podman API socket the container logs are read from
+	IncludeIP           bool              // Show primary IPv4/IPv6 addresses next to the hostname
+	IPInterfaces        []string          // Interfaces considered for IP lookup (empty = all)
+	HiddenFields        map[string]bool   // Notification header fields to omit
+	StateDir            string            // Directory for persistent state (spool, history)
+	SpoolEnabled        bool              // Persist undelivered notifications for retry
+	SpoolDir            string            // Directory holding undelivered notifications
+	SpoolMaxEntries     int               // Max spooled notifications before oldest are dropped
+	SpoolMaxAttempts    int               // Delivery attempts before a spooled notification is dead-lettered
+	DeadLetterFile      string            // Append-only log of notifications that were never delivered
+	RateLimitQueueSize  int               // Max notifications waiting for a rate limit token
+	Fallback            string            // Secondary backend when Telegram fails (ntfy, webhook, email)
+	NtfyURL             string            // ntfy topic URL for the ntfy fallback
+	NtfyToken           string            // Optional ntfy access token
+	WebhookURL          string            // Endpoint for the generic webhook fallback
+	WebhookSecret       string            // Shared secret for HMAC-SHA256 signing of webhook bodies (empty disables)
+	SMTPAddr            string            // SMTP server host:port for the email fallback
+	SMTPUser            string            // Optional SMTP username
+	SMTPPassword        string            // Optional SMTP password
+	SMTPFrom            string            // Sender address for the email fallback
+	SMTPTo              []string          // Recipient addresses for the email fallback
+	Async               bool              // Spool notifications and let the daemon deliver them
+	DaemonInterval      time.Duration     // How often the daemon flushes the spool
+	WatchDaemonReload   bool              // Daemon reports systemd reloading its configuration
+	WatchUnits          []string          // Units ("*" for all) whose manual restarts and reloads the daemon reports
+	Socket              string            // Daemon Unix socket for fast-path sends ("off" disables, empty uses the state dir)
+	HistoryEnabled      bool              // Record every notification attempt in the audit log
+	HistoryFile         string            // Delivery audit log location
+	Debug               bool              // Log command executions, scopes and retries to stderr
+	Plain               bool              // Never run systemctl or journalctl; sends take exit code and output from flags, the environment or stdin
+	InitSystem          string            // systemd, openrc, runit, launchd or windows: what services are looked up in
+	SyslogFile          string            // Where OpenRC and runit services without a log file of their own log
+	MetricsAddr         string            // Daemon listen address for /metrics and /healthz (empty disables)
+	AlertmanagerAddr    string            // Daemon listen address for Alertmanager webhooks (empty disables)
+	AlertmanagerSecret  string            // Bearer credential Alertmanager must send (required unless AlertmanagerAddr is loopback)
+	AlertTemplate       string            // text/template file overriding the alert title and message
+	SyslogListen        string            // Daemon syslog receiver: UDP host:port or unix datagram socket path (empty disables)
+	SyslogSeverity      int               // Least severe syslog level reported, 0 (emerg) to 7 (debug)
+	SyslogPrograms      []string          // Syslog app names reported (empty for all)
+	SyslogSources       []netip.Prefix    // Addresses a UDP syslog receiver accepts messages from (empty for any)
+	LivenessFile        string            // Touched by the daemon after each successful flush
+	LogFormat           string            // Log output format: text or json
+	LogPriorityPrefix   bool              // Prefix log lines with syslog priorities for journald (auto-detected)
+	OTLPEndpoint        string            // OpenTelemetry collector for trace export (empty disables)
+	OTelServiceName     string            // service.name resource attribute on exported traces
+	HeartbeatURL        string            // healthchecks.io-style ping URL for heartbeats (empty sends a Telegram message instead)
+	HeartbeatInterval   time.Duration     // How often the daemon sends heartbeats (0 disables)
+	PingURLs            map[string]string // Per-unit or job ping URL hit after each run ("*" for the rest)
+	RedactionFile       string            // Extra redaction patterns, disabled built-ins and allowlist
+	RedactionMode       string            // strict (default) or lenient keyword matching
+	Redaction           validation.RedactionRules
+	RedactionEngine     string                     // regex (default), gitleaks or none
+	RedactionRuleset    string                     // gitleaks config used by the gitleaks engine
+	GitleaksRules       validation.GitleaksRuleset // Compiled RedactionRuleset
+	TelegramAPIURL      string                     // Bot API base URL; a local Bot API server can replace api.telegram.org
+	CAFile              string                     // PEM bundle trusted in addition to the system roots
+	TLSRootCAs          *x509.CertPool             // System roots plus CAFile; nil uses the system pool
+	TLSMinVersion       uint16                     // Minimum TLS version for outgoing HTTPS
+	TLSPins             [][]byte                   // SHA-256 SubjectPublicKeyInfo pins for the Telegram endpoint
+	TLSClientCertFile   string                     // PEM client certificate for mTLS
+	TLSClientKeyFile    string                     // PEM private key for TLSClientCertFile
+	TLSClientCert       *tls.Certificate           // Loaded client certificate; nil disables mTLS
+	RunAsUser           string                     // Unprivileged user to switch to when started as root
+	Sandbox             bool                       // Confine filesystem and exec access with Landlock
+	BotAllowedChats     map[int64]bool             // Chats where interactive bot commands are accepted (default: TELEGRAM_CHAT_ID)
+	BotUsers            botauth.Users              // Users allowed to run interactive commands, with their permission
+	AckReaction         string                     // Emoji that acknowledges the failure alert it's put on; empty disables
+	BotWebhookURL       string                     // Public HTTPS URL Telegram posts bot updates to; empty polls with getUpdates
+	BotWebhookAddr      string                     // Listen address of the webhook receiver
+	BotWebhookSecret    string                     // Token Telegram must send with webhook requests; empty generates one per start
+	BotWebhookCertFile  string                     // PEM certificate for serving the webhook over TLS without a proxy
+	BotWebhookKeyFile   string                     // PEM private key for BotWebhookCertFile
+	BotWebhookCert      *tls.Certificate           // Loaded webhook certificate; nil serves plain HTTP for a reverse proxy
+	SmartDevices        []string                   // Disks checked by the smart command (empty scans with smartctl --scan)
+	SmartMaxTemperature int                        // Celsius; 0 disables the temperature check
+	SmartMaxWear        int                        // NVMe endurance used, in percent; 0 disables the wear check
 }
 
 // New creates and validates configuration from environment variables
 // SECURITY: Validates required credentials exist before proceeding
 func New() (*Config, error) {
 	cfg := &Config{}
-	cfg.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
 	cfg.ChatID = os.Getenv("TELEGRAM_CHAT_ID")
+	if err := cfg.loadBotToken(); err != nil {
+		return nil, err
+	}
 
 	// Fail fast if required credentials missing
 	if cfg.BotToken == "" || cfg.ChatID == "" {
 		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID must be set")
 	}
+	// A malformed token or chat would otherwise only surface as an opaque 401 or 400 when sending
+	if err := validation.ValidateBotToken(cfg.BotToken); err != nil {
+		return nil, fmt.Errorf("%w; check TELEGRAM_BOT_TOKEN", err)
+	}
+	if err := validation.ValidateChatID(cfg.ChatID); err != nil {
+		return nil, fmt.Errorf("TELEGRAM_CHAT_ID: %w", err)
+	}
 
 	// Load defaults first, then override with environment variables
 	cfg.SetDefaults()
@@ -44,19 +176,137 @@ func New() (*Config, error) {
 	return cfg, nil
 }
 
+// loadBotToken reads the bot token from the environment or, with NOTIFIER_TOKEN_SOURCE=keyring, the Secret Service
+// An explicit TELEGRAM_BOT_TOKEN still wins so a one-off override needs no keyring
+func (c *Config) loadBotToken() error {
+	c.BotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+	c.TokenSource = os.Getenv("NOTIFIER_TOKEN_SOURCE")
+	switch c.TokenSource {
+	case "":
+		c.TokenSource = constants.TokenSourceEnv
+	case constants.TokenSourceEnv, constants.TokenSourceKeyring:
+	default:
+		return fmt.Errorf("invalid NOTIFIER_TOKEN_SOURCE %q (use %s or %s)", c.TokenSource, constants.TokenSourceEnv, constants.TokenSourceKeyring)
+	}
+
+	if c.BotToken != "" || c.TokenSource != constants.TokenSourceKeyring {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), constants.KeyringTimeout)
+	defer cancel()
+	token, err := keyring.LookupBotToken(ctx)
+	if err != nil {
+		return fmt.Errorf("NOTIFIER_TOKEN_SOURCE=keyring: %w", err)
+	}
+	c.BotToken = token
+	return nil
+}
+
 // SetDefaults initializes configuration with sensible default values
 func (c *Config) SetDefaults() {
 	c.CommandTimeout = constants.DefaultCommandTimeout
 	c.HTTPTimeout = constants.DefaultHTTPTimeout
+	c.ConnectTimeout = constants.DefaultConnectTimeout
+	c.IdleConnTimeout = constants.DefaultIdleConnTimeout
+	c.HTTP2 = true
 	c.JournalLookback = constants.DefaultJournalLookback
+	c.UnitPaths = nil
 	c.MaxOutputSize = constants.DefaultMaxOutputSize
 	c.TruncationMsgSize = constants.DefaultTruncationMsgSize
 	c.DateTimeFormat = constants.DefaultDateTimeFormat
-	c.JournalSinceDefault = constants.DefaultJournalSince
 	c.HostnameAlias = ""
+	c.ChannelSignature = ""
+	c.ForumTopics = false
+	c.IncludeHealth = false
+	c.SystemErrorLines = 0
+	c.IncludeCgroup = false
+	c.IncludeDenials = false
+	c.Formatting = constants.FormattingMarkdown
+	c.SuccessFormat = constants.SuccessFormatFull
+	c.Policy = constants.PolicyAlways
+	c.FailureThresholds = map[string]int{}
+	c.RuntimeDeviation = constants.DefaultRuntimeDeviation
+	c.QuietHours = nil
+	c.QuietFailures = true
+	c.Escalate = nil
+	c.EscalationInterval = constants.DefaultEscalationInterval
+	c.Severity = nil
+	c.SeverityChats = map[string]string{}
+	c.SilentSeverities = nil
+	c.EscalateSeverities = nil
+	c.ServicesFile = ""
+	c.ServicePolicies = map[string]ServicePolicy{}
+	c.VersionSources = map[string]string{}
+	c.LogFiles = map[string]string{}
+	c.Containers = map[string]string{}
+	c.ContainerSocket = constants.DefaultContainerSocket
+	c.IncludeIP = false
+	c.IPInterfaces = nil
+	c.HiddenFields = map[string]bool{}
+	c.StateDir = defaultStateDir()
+	c.SpoolEnabled = true
+	c.SpoolDir = ""
+	c.SpoolMaxEntries = constants.DefaultSpoolMaxEntries
+	c.SpoolMaxAttempts = constants.DefaultSpoolMaxAttempts
+	c.DeadLetterFile = ""
+	c.RateLimitQueueSize = constants.RateLimitQueueSize
+	c.Async = false
+	c.Plain = false
+	c.InitSystem = defaultInitSystem
+	c.SyslogFile = constants.DefaultSyslogFile
+	c.DaemonInterval = constants.DefaultDaemonInterval
+	c.WatchDaemonReload = false
+	c.WatchUnits = nil
+	c.Socket = ""
+	c.HistoryEnabled = true
+	c.HistoryFile = ""
+	c.Debug = false
+	c.MetricsAddr = ""
+	c.AlertmanagerAddr = ""
+	c.AlertmanagerSecret = ""
+	c.AlertTemplate = ""
+	c.SyslogListen = ""
+	c.SyslogSeverity = constants.DefaultSyslogSeverity
+	c.SyslogPrograms = nil
+	c.SyslogSources = nil
+	c.LivenessFile = ""
+	c.HeartbeatURL = ""
+	c.HeartbeatInterval = 0
+	c.PingURLs = map[string]string{}
+	c.RedactionFile = ""
+	c.RedactionMode = constants.RedactionStrict
+	c.RedactionEngine = constants.RedactionEngineRegex
+	c.RedactionRuleset = ""
+	c.TelegramAPIURL = constants.DefaultTelegramAPIURL
+	c.CAFile = ""
+	c.TLSRootCAs = nil
+	c.TLSMinVersion = tls.VersionTLS12
+	c.TLSPins = nil
+	c.TLSClientCertFile = ""
+	c.TLSClientKeyFile = ""
+	c.TLSClientCert = nil
+	c.RunAsUser = ""
+	c.Sandbox = false
+	c.AckReaction = constants.DefaultAckReaction
+	c.BotWebhookURL = ""
+	c.BotWebhookAddr = constants.DefaultBotWebhookAddr
+	c.BotWebhookSecret = ""
+	c.BotWebhookCertFile = ""
+	c.BotWebhookKeyFile = ""
+	c.BotWebhookCert = nil
+	c.SmartDevices = nil
+	c.SmartMaxTemperature = 0
+	c.SmartMaxWear = constants.DefaultSmartMaxWear
+	c.Redaction = validation.RedactionRules{}
+	c.LogFormat = constants.LogFormatText
+	// journald parses priority prefixes, so enable them whenever stderr goes to the journal
+	c.LogPriorityPrefix = logging.StderrIsJournal()
+	c.OTLPEndpoint = ""
+	c.OTelServiceName = "telegram-notifier"
 
-	// Use TZ environment variable or system local time
-	c.TimeLocation = getTimeLocation()
+	// The Go runtime already applies a valid TZ to time.Local; loadFromEnv checks it explicitly
+	c.TimeLocation = time.Local
 }
 
 // loadFromEnv loads and parses configuration from environment variables
@@ -65,7 +315,7 @@ func (c *Config) loadFromEnv() error {
 	// Map of environment variable name to parsing function
 	parsers := map[string]func(string) error{
 		"NOTIFIER_COMMAND_TIMEOUT": func(v string) error {
-			d, err := time.ParseDuration(v)
+			d, err := parseDurationRange(v, constants.MinCommandTimeout, constants.MaxCommandTimeout)
 			if err != nil {
 				return err
 			}
@@ -73,78 +323,1409 @@ func (c *Config) loadFromEnv() error {
 			return nil
 		},
 		"NOTIFIER_HTTP_TIMEOUT": func(v string) error {
-			d, err := time.ParseDuration(v)
+			d, err := parseDurationRange(v, constants.MinHTTPTimeout, constants.MaxHTTPTimeout)
 			if err != nil {
 				return err
 			}
 			c.HTTPTimeout = d
 			return nil
 		},
+		"NOTIFIER_CONNECT_TIMEOUT": func(v string) error {
+			d, err := parseDurationRange(v, constants.MinConnectTimeout, constants.MaxConnectTimeout)
+			if err != nil {
+				return err
+			}
+			c.ConnectTimeout = d
+			return nil
+		},
+		"NOTIFIER_IDLE_CONN_TIMEOUT": func(v string) error {
+			// 0 disables keep-alive
+			d, err := parseDurationRange(v, 0, constants.MaxIdleConnTimeout)
+			if err != nil {
+				return err
+			}
+			c.IdleConnTimeout = d
+			return nil
+		},
+		"NOTIFIER_HTTP2": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.HTTP2 = enabled
+			return nil
+		},
 		"NOTIFIER_JOURNAL_LOOKBACK": func(v string) error {
-			d, err := time.ParseDuration(v)
+			d, err := parseDurationRange(v, constants.MinJournalLookback, constants.MaxJournalLookback)
 			if err != nil {
 				return err
 			}
 			c.JournalLookback = d
 			return nil
 		},
+		"NOTIFIER_UNIT_PATHS": func(v string) error {
+			dirs, err := parseUnitPaths(v)
+			if err != nil {
+				return err
+			}
+			c.UnitPaths = dirs
+			return nil
+		},
 		"NOTIFIER_MAX_OUTPUT_SIZE": func(v string) error {
 			size, err := strconv.Atoi(v)
 			if err != nil {
 				return err
 			}
+			if size < constants.MinMaxOutputSize || size > constants.TelegramMaxMessageSize {
+				return fmt.Errorf("must be between %d and %d", constants.MinMaxOutputSize, constants.TelegramMaxMessageSize)
+			}
 			c.MaxOutputSize = size
 			return nil
 		},
 		"NOTIFIER_DATETIME_FORMAT": func(v string) error {
+			if err := validateDateTimeFormat(v); err != nil {
+				return err
+			}
 			c.DateTimeFormat = v
 			return nil
 		},
-		"NOTIFIER_JOURNAL_SINCE_DEFAULT": func(v string) error {
-			c.JournalSinceDefault = v
-			return nil
-		},
 		"NOTIFIER_HOSTNAME_ALIAS": func(v string) error {
 			// PRIVACY: Allow users to set custom hostname alias
 			c.HostnameAlias = v
 			return nil
 		},
-	}
-
-	// Parse each environment variable if present
-	for envVar, parser := range parsers {
-		if val := os.Getenv(envVar); val != "" {
-			if err := parser(val); err != nil {
-				return fmt.Errorf("parsing %s: %w", envVar, err)
+		"NOTIFIER_INCLUDE_HEALTH": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
 			}
-		}
-	}
-
-	// Reload timezone in case TZ was changed
-	c.TimeLocation = getTimeLocation()
-
-	return nil
-}
-
-// getTimeLocation loads timezone from TZ environment variable or uses system local
-// PRIVACY: Respects user's timezone preference for timestamp formatting
-func getTimeLocation() *time.Location {
-	if tz := os.Getenv("TZ"); tz != "" {
-		if loc, err := time.LoadLocation(tz); err == nil {
-			return loc
-		}
-	}
-	return time.Local
-}
-
-// GetTimeLocation returns the configured timezone
-func (c *Config) GetTimeLocation() *time.Location {
-	return c.TimeLocation
-}
-
-// FormatDateTime formats a timestamp according to configured timezone and format
-func (c *Config) FormatDateTime(t time.Time) string {
-	return t.In(c.TimeLocation).Format(c.DateTimeFormat)
+			c.IncludeHealth = enabled
+			return nil
+		},
+		"NOTIFIER_SYSTEM_ERRORS": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 0 || n > constants.MaxSystemErrorLines {
+				return fmt.Errorf("must be between 0 (disabled) and %d", constants.MaxSystemErrorLines)
+			}
+			c.SystemErrorLines = n
+			return nil
+		},
+		"NOTIFIER_INCLUDE_CGROUP": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.IncludeCgroup = enabled
+			return nil
+		},
+		"NOTIFIER_INCLUDE_DENIALS": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.IncludeDenials = enabled
+			return nil
+		},
+		"NOTIFIER_FORUM_TOPICS": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.ForumTopics = enabled
+			return nil
+		},
+		"NOTIFIER_FORMATTING": func(v string) error {
+			formatting := strings.ToLower(v)
+			if formatting != constants.FormattingMarkdown && formatting != constants.FormattingEntities {
+				return fmt.Errorf("must be %q or %q", constants.FormattingMarkdown, constants.FormattingEntities)
+			}
+			c.Formatting = formatting
+			return nil
+		},
+		"NOTIFIER_SUCCESS_FORMAT": func(v string) error {
+			format := strings.ToLower(v)
+			if format != constants.SuccessFormatFull && format != constants.SuccessFormatBrief {
+				return fmt.Errorf("must be %q or %q", constants.SuccessFormatFull, constants.SuccessFormatBrief)
+			}
+			c.SuccessFormat = format
+			return nil
+		},
+		"NOTIFIER_POLICY": func(v string) error {
+			policy := strings.ToLower(v)
+			switch policy {
+			case constants.PolicyAlways, constants.PolicyFailureOnly, constants.PolicyRecovery:
+				c.Policy = policy
+				return nil
+			}
+			return fmt.Errorf("must be %q, %q or %q", constants.PolicyAlways, constants.PolicyFailureOnly, constants.PolicyRecovery)
+		},
+		"NOTIFIER_FAILURE_THRESHOLD": func(v string) error {
+			thresholds, err := parseFailureThresholds(v)
+			if err != nil {
+				return err
+			}
+			c.FailureThresholds = thresholds
+			return nil
+		},
+		"NOTIFIER_RUNTIME_DEVIATION": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n != 0 && n < 2 {
+				return fmt.Errorf("must be 0 (disabled) or a factor of at least 2")
+			}
+			c.RuntimeDeviation = n
+			return nil
+		},
+		"NOTIFIER_QUIET_HOURS": func(v string) error {
+			windows, err := schedule.ParseWindows(v)
+			if err != nil {
+				return err
+			}
+			c.QuietHours = windows
+			return nil
+		},
+		"NOTIFIER_QUIET_HOURS_FAILURES": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.QuietFailures = enabled
+			return nil
+		},
+		"NOTIFIER_CHANNEL_SIGNATURE": func(v string) error {
+			if validation.UTF16Length(v) > constants.MaxChannelSignature || strings.ContainsAny(v, "\r\n") {
+				return fmt.Errorf("must be a single line of at most %d characters", constants.MaxChannelSignature)
+			}
+			c.ChannelSignature = v
+			return nil
+		},
+		"NOTIFIER_ESCALATE": func(v string) error {
+			c.Escalate = splitList(v)
+			return nil
+		},
+		"NOTIFIER_ESCALATION_INTERVAL": func(v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			if d < time.Minute {
+				return fmt.Errorf("must be at least 1m")
+			}
+			c.EscalationInterval = d
+			return nil
+		},
+		"NOTIFIER_SEVERITY": func(v string) error {
+			rules, err := severity.Parse(v)
+			if err != nil {
+				return err
+			}
+			c.Severity = rules
+			return nil
+		},
+		"NOTIFIER_SEVERITY_CHATS": func(v string) error {
+			chats, err := parseSeverityChats(v)
+			if err != nil {
+				return err
+			}
+			c.SeverityChats = chats
+			return nil
+		},
+		"NOTIFIER_SILENT_SEVERITY": func(v string) error {
+			levels, err := parseSeverityLevels(v)
+			if err != nil {
+				return err
+			}
+			c.SilentSeverities = levels
+			return nil
+		},
+		"NOTIFIER_ESCALATE_SEVERITY": func(v string) error {
+			levels, err := parseSeverityLevels(v)
+			if err != nil {
+				return err
+			}
+			c.EscalateSeverities = levels
+			return nil
+		},
+		"NOTIFIER_SERVICES_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			policies, err := LoadServicePolicies(v)
+			if err != nil {
+				return err
+			}
+			c.ServicesFile = v
+			c.ServicePolicies = policies
+			return nil
+		},
+		"NOTIFIER_VERSION_SOURCES": func(v string) error {
+			sources, err := parseVersionSources(v)
+			if err != nil {
+				return err
+			}
+			c.VersionSources = sources
+			return nil
+		},
+		"NOTIFIER_LOG_FILES": func(v string) error {
+			files, err := parseLogFiles(v)
+			if err != nil {
+				return err
+			}
+			c.LogFiles = files
+			return nil
+		},
+		"NOTIFIER_CONTAINERS": func(v string) error {
+			containers, err := parseContainers(v)
+			if err != nil {
+				return err
+			}
+			c.Containers = containers
+			return nil
+		},
+		"NOTIFIER_CONTAINER_SOCKET": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.ContainerSocket = filepath.Clean(v)
+			return nil
+		},
+		"NOTIFIER_INCLUDE_IP": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.IncludeIP = enabled
+			return nil
+		},
+		"NOTIFIER_IP_INTERFACES": func(v string) error {
+			c.IPInterfaces = splitList(v)
+			return nil
+		},
+		"NOTIFIER_HIDE_FIELDS": func(v string) error {
+			hidden := map[string]bool{}
+			for _, field := range splitList(v) {
+				field = strings.ToLower(field)
+				if !slices.Contains(constants.NotificationFields, field) {
+					return fmt.Errorf("unknown field %q (valid: %s)", field, strings.Join(constants.NotificationFields, ", "))
+				}
+				hidden[field] = true
+			}
+			c.HiddenFields = hidden
+			return nil
+		},
+		"NOTIFIER_STATE_DIR": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.StateDir = filepath.Clean(v)
+			return nil
+		},
+		"NOTIFIER_SPOOL_ENABLED": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.SpoolEnabled = enabled
+			return nil
+		},
+		"NOTIFIER_SPOOL_DIR": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.SpoolDir = filepath.Clean(v)
+			return nil
+		},
+		"NOTIFIER_SPOOL_MAX_ENTRIES": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 1 {
+				return fmt.Errorf("must be at least 1")
+			}
+			c.SpoolMaxEntries = n
+			return nil
+		},
+		"NOTIFIER_SPOOL_MAX_ATTEMPTS": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("must not be negative")
+			}
+			c.SpoolMaxAttempts = n
+			return nil
+		},
+		"NOTIFIER_QUEUE_SIZE": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 1 {
+				return fmt.Errorf("must be at least 1")
+			}
+			c.RateLimitQueueSize = n
+			return nil
+		},
+		"NOTIFIER_FALLBACK": func(v string) error {
+			c.Fallback = strings.ToLower(strings.TrimSpace(v))
+			return nil
+		},
+		"NOTIFIER_NTFY_URL": func(v string) error {
+			return parseHTTPURL(v, &c.NtfyURL)
+		},
+		"NOTIFIER_NTFY_TOKEN": func(v string) error {
+			c.NtfyToken = v
+			return nil
+		},
+		"NOTIFIER_WEBHOOK_URL": func(v string) error {
+			return parseHTTPURL(v, &c.WebhookURL)
+		},
+		"NOTIFIER_WEBHOOK_SECRET": func(v string) error {
+			c.WebhookSecret = v
+			return nil
+		},
+		"NOTIFIER_SMTP_ADDR": func(v string) error {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return err
+			}
+			c.SMTPAddr = v
+			return nil
+		},
+		"NOTIFIER_SMTP_USER": func(v string) error {
+			c.SMTPUser = v
+			return nil
+		},
+		"NOTIFIER_SMTP_PASSWORD": func(v string) error {
+			c.SMTPPassword = v
+			return nil
+		},
+		"NOTIFIER_SMTP_FROM": func(v string) error {
+			c.SMTPFrom = v
+			return nil
+		},
+		"NOTIFIER_SMTP_TO": func(v string) error {
+			c.SMTPTo = splitList(v)
+			return nil
+		},
+		"NOTIFIER_ASYNC": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.Async = enabled
+			return nil
+		},
+		"NOTIFIER_PLAIN": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.Plain = enabled
+			return nil
+		},
+		"NOTIFIER_INIT_SYSTEM": func(v string) error {
+			initSystem := strings.ToLower(v)
+			switch initSystem {
+			case constants.InitSystemSystemd, constants.InitSystemOpenRC, constants.InitSystemRunit,
+				constants.InitSystemLaunchd, constants.InitSystemWindows:
+				c.InitSystem = initSystem
+				return nil
+			}
+			return fmt.Errorf("must be %q, %q, %q, %q or %q", constants.InitSystemSystemd, constants.InitSystemOpenRC,
+				constants.InitSystemRunit, constants.InitSystemLaunchd, constants.InitSystemWindows)
+		},
+		"NOTIFIER_SYSLOG_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.SyslogFile = v
+			return nil
+		},
+		"NOTIFIER_DAEMON_INTERVAL": func(v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			if d < time.Second {
+				return fmt.Errorf("must be at least 1s")
+			}
+			c.DaemonInterval = d
+			return nil
+		},
+		"NOTIFIER_WATCH_DAEMON_RELOAD": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.WatchDaemonReload = enabled
+			return nil
+		},
+		"NOTIFIER_WATCH_UNITS": func(v string) error {
+			c.WatchUnits = splitList(v)
+			return nil
+		},
+		"NOTIFIER_SOCKET": func(v string) error {
+			if v != constants.SocketOff && !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path or %q", constants.SocketOff)
+			}
+			c.Socket = v
+			return nil
+		},
+		"NOTIFIER_HISTORY_ENABLED": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.HistoryEnabled = enabled
+			return nil
+		},
+		"NOTIFIER_HISTORY_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.HistoryFile = filepath.Clean(v)
+			return nil
+		},
+		"NOTIFIER_METRICS_ADDR": func(v string) error {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return err
+			}
+			c.MetricsAddr = v
+			return nil
+		},
+		"NOTIFIER_ALERTMANAGER_ADDR": func(v string) error {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return err
+			}
+			c.AlertmanagerAddr = v
+			return nil
+		},
+		"NOTIFIER_ALERTMANAGER_SECRET": func(v string) error {
+			c.AlertmanagerSecret = v
+			return nil
+		},
+		"NOTIFIER_ALERT_TEMPLATE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.AlertTemplate = filepath.Clean(v)
+			return nil
+		},
+		"NOTIFIER_SYSLOG_LISTEN": func(v string) error {
+			if !filepath.IsAbs(v) {
+				if _, _, err := net.SplitHostPort(v); err != nil {
+					return fmt.Errorf("must be a host:port or an absolute socket path: %w", err)
+				}
+			}
+			c.SyslogListen = v
+			return nil
+		},
+		"NOTIFIER_SYSLOG_SEVERITY": func(v string) error {
+			level, err := syslog.ParseSeverity(v)
+			if err != nil {
+				return err
+			}
+			c.SyslogSeverity = level
+			return nil
+		},
+		"NOTIFIER_SYSLOG_PROGRAMS": func(v string) error {
+			c.SyslogPrograms = splitList(v)
+			return nil
+		},
+		"NOTIFIER_SYSLOG_ALLOWED_SOURCES": func(v string) error {
+			sources, err := parsePrefixes(v)
+			if err != nil {
+				return err
+			}
+			c.SyslogSources = sources
+			return nil
+		},
+		"NOTIFIER_LOG_FORMAT": func(v string) error {
+			format := strings.ToLower(v)
+			if format != constants.LogFormatText && format != constants.LogFormatJSON {
+				return fmt.Errorf("must be %q or %q", constants.LogFormatText, constants.LogFormatJSON)
+			}
+			c.LogFormat = format
+			return nil
+		},
+		"NOTIFIER_LOG_PRIORITY_PREFIX": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.LogPriorityPrefix = enabled
+			return nil
+		},
+		// Standard OpenTelemetry variables, shared with other instrumented software
+		"OTEL_EXPORTER_OTLP_ENDPOINT": func(v string) error {
+			return parseHTTPURL(v, &c.OTLPEndpoint)
+		},
+		"OTEL_SERVICE_NAME": func(v string) error {
+			c.OTelServiceName = v
+			return nil
+		},
+		"NOTIFIER_HEARTBEAT_URL": func(v string) error {
+			return parseHTTPURL(v, &c.HeartbeatURL)
+		},
+		"NOTIFIER_PING_URLS": func(v string) error {
+			urls, err := parsePingURLs(v)
+			if err != nil {
+				return err
+			}
+			c.PingURLs = urls
+			return nil
+		},
+		"NOTIFIER_HEARTBEAT_INTERVAL": func(v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			if d != 0 && d < time.Minute {
+				return fmt.Errorf("must be 0 (disabled) or at least 1m")
+			}
+			c.HeartbeatInterval = d
+			return nil
+		},
+		"NOTIFIER_REDACTION_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			rules, err := validation.LoadRedactionRules(v)
+			if err != nil {
+				return err
+			}
+			c.RedactionFile = v
+			c.Redaction = rules
+			return nil
+		},
+		"NOTIFIER_REDACTION_MODE": func(v string) error {
+			mode := strings.ToLower(v)
+			if mode != constants.RedactionStrict && mode != constants.RedactionLenient {
+				return fmt.Errorf("must be %q or %q", constants.RedactionStrict, constants.RedactionLenient)
+			}
+			c.RedactionMode = mode
+			return nil
+		},
+		"NOTIFIER_REDACTION_ENGINE": func(v string) error {
+			engine := strings.ToLower(v)
+			switch engine {
+			case constants.RedactionEngineRegex, constants.RedactionEngineGitleaks, constants.RedactionEngineNone:
+				c.RedactionEngine = engine
+				return nil
+			}
+			return fmt.Errorf("must be %q, %q or %q", constants.RedactionEngineRegex, constants.RedactionEngineGitleaks, constants.RedactionEngineNone)
+		},
+		"NOTIFIER_REDACTION_RULESET": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			ruleset, err := validation.LoadGitleaksRules(v)
+			if err != nil {
+				return err
+			}
+			c.RedactionRuleset = v
+			c.GitleaksRules = ruleset
+			return nil
+		},
+		"NOTIFIER_BOT_ALLOWED_CHATS": func(v string) error {
+			chats, err := botauth.ParseChats(v)
+			if err != nil {
+				return err
+			}
+			c.BotAllowedChats = chats
+			return nil
+		},
+		"NOTIFIER_BOT_USERS": func(v string) error {
+			users, err := botauth.ParseUsers(v)
+			if err != nil {
+				return err
+			}
+			c.BotUsers = users
+			return nil
+		},
+		"NOTIFIER_BOT_ACK_REACTION": func(v string) error {
+			if strings.EqualFold(v, "off") {
+				c.AckReaction = ""
+				return nil
+			}
+			if utf8.RuneCountInString(v) > 4 || strings.IndexFunc(v, func(r rune) bool { return r < utf8.RuneSelf }) >= 0 {
+				return fmt.Errorf("must be a single emoji such as %s, or \"off\"", constants.DefaultAckReaction)
+			}
+			c.AckReaction = v
+			return nil
+		},
+		"NOTIFIER_BOT_WEBHOOK_URL": func(v string) error {
+			u, err := url.Parse(v)
+			if err != nil {
+				return err
+			}
+			if u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("must be an https:// URL")
+			}
+			// Telegram delivers webhooks to these ports only
+			if port := u.Port(); port != "" && !slices.Contains([]string{"443", "80", "88", "8443"}, port) {
+				return fmt.Errorf("port must be 443, 80, 88 or 8443")
+			}
+			c.BotWebhookURL = v
+			return nil
+		},
+		"NOTIFIER_BOT_WEBHOOK_ADDR": func(v string) error {
+			if _, _, err := net.SplitHostPort(v); err != nil {
+				return err
+			}
+			c.BotWebhookAddr = v
+			return nil
+		},
+		"NOTIFIER_BOT_WEBHOOK_SECRET": func(v string) error {
+			if !constants.WebhookSecretPattern.MatchString(v) {
+				return fmt.Errorf("must be 1-256 letters, digits, _ or -")
+			}
+			c.BotWebhookSecret = v
+			return nil
+		},
+		"NOTIFIER_BOT_WEBHOOK_CERT": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.BotWebhookCertFile = v
+			return nil
+		},
+		"NOTIFIER_BOT_WEBHOOK_KEY": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.BotWebhookKeyFile = v
+			return nil
+		},
+		"NOTIFIER_SMART_DEVICES": func(v string) error {
+			devices := splitList(v)
+			for _, device := range devices {
+				if !strings.HasPrefix(device, "/dev/") {
+					return fmt.Errorf("%q must be a /dev path", device)
+				}
+			}
+			c.SmartDevices = devices
+			return nil
+		},
+		"NOTIFIER_SMART_MAX_TEMP": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return fmt.Errorf("must be 0 (disabled) or a temperature in °C")
+			}
+			c.SmartMaxTemperature = n
+			return nil
+		},
+		"NOTIFIER_SMART_MAX_WEAR": func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return err
+			}
+			if n < 0 || n > 100 {
+				return fmt.Errorf("must be between 0 (disabled) and 100")
+			}
+			c.SmartMaxWear = n
+			return nil
+		},
+		"TELEGRAM_API_URL": func(v string) error {
+			if err := parseHTTPURL(v, &c.TelegramAPIURL); err != nil {
+				return err
+			}
+			c.TelegramAPIURL = strings.TrimRight(c.TelegramAPIURL, "/")
+			return nil
+		},
+		"NOTIFIER_CA_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			pool, err := loadCertPool(v)
+			if err != nil {
+				return err
+			}
+			c.CAFile = v
+			c.TLSRootCAs = pool
+			return nil
+		},
+		"NOTIFIER_TLS_MIN_VERSION": func(v string) error {
+			switch v {
+			case "1.2":
+				c.TLSMinVersion = tls.VersionTLS12
+			case "1.3":
+				c.TLSMinVersion = tls.VersionTLS13
+			default:
+				return fmt.Errorf("must be 1.2 or 1.3")
+			}
+			return nil
+		},
+		"NOTIFIER_TLS_PIN_SHA256": func(v string) error {
+			for _, item := range splitList(v) {
+				pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(item, "sha256/"))
+				if err != nil || len(pin) != sha256.Size {
+					return fmt.Errorf("%q is not a base64 SHA-256 hash", item)
+				}
+				c.TLSPins = append(c.TLSPins, pin)
+			}
+			return nil
+		},
+		"NOTIFIER_TLS_CLIENT_CERT": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.TLSClientCertFile = v
+			return nil
+		},
+		"NOTIFIER_TLS_CLIENT_KEY": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.TLSClientKeyFile = v
+			return nil
+		},
+		"NOTIFIER_RUN_AS": func(v string) error {
+			if strings.ContainsAny(v, " \t/:") {
+				return fmt.Errorf("must be a user name")
+			}
+			c.RunAsUser = v
+			return nil
+		},
+		"NOTIFIER_SANDBOX": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.Sandbox = enabled
+			return nil
+		},
+		"NOTIFIER_LIVENESS_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.LivenessFile = v
+			return nil
+		},
+		"NOTIFIER_DEBUG": func(v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			c.Debug = enabled
+			return nil
+		},
+		"NOTIFIER_DEADLETTER_FILE": func(v string) error {
+			if !filepath.IsAbs(v) {
+				return fmt.Errorf("must be an absolute path")
+			}
+			c.DeadLetterFile = filepath.Clean(v)
+			return nil
+		},
+	}
+
+	// Parse each environment variable if present
+	for envVar, parser := range parsers {
+		if val := os.Getenv(envVar); val != "" {
+			if err := parser(val); err != nil {
+				return fmt.Errorf("parsing %s: %w", envVar, err)
+			}
+		}
+	}
+
+	// The hook reports systemd units by full name, so a bare name configures the service of that name
+	if c.InitSystem == constants.InitSystemSystemd {
+		c.Containers = normalizeUnitKeys(c.Containers)
+		c.LogFiles = normalizeUnitKeys(c.LogFiles)
+		c.VersionSources = normalizeUnitKeys(c.VersionSources)
+	}
+
+	if c.RedactionEngine == constants.RedactionEngineGitleaks && c.RedactionRuleset == "" {
+		return fmt.Errorf("NOTIFIER_REDACTION_RULESET must be set for the gitleaks redaction engine")
+	}
+
+	// SECURITY: Without a credential, anyone who can reach the receiver could post alerts in the chat
+	if c.AlertmanagerAddr != "" && c.AlertmanagerSecret == "" && !isLoopback(c.AlertmanagerAddr) {
+		return fmt.Errorf("NOTIFIER_ALERTMANAGER_SECRET must be set unless NOTIFIER_ALERTMANAGER_ADDR is a loopback address")
+	}
+
+	// Certificates and keys are set separately but only usable together
+	if c.TLSClientCertFile != "" || c.TLSClientKeyFile != "" {
+		cert, err := loadKeyPair(c.TLSClientCertFile, c.TLSClientKeyFile, "NOTIFIER_TLS_CLIENT")
+		if err != nil {
+			return fmt.Errorf("loading TLS client certificate: %w", err)
+		}
+		c.TLSClientCert = cert
+	}
+	if c.BotWebhookCertFile != "" || c.BotWebhookKeyFile != "" {
+		cert, err := loadKeyPair(c.BotWebhookCertFile, c.BotWebhookKeyFile, "NOTIFIER_BOT_WEBHOOK")
+		if err != nil {
+			return fmt.Errorf("loading webhook certificate: %w", err)
+		}
+		c.BotWebhookCert = cert
+	}
+
+	// Reload timezone in case TZ was changed
+	loc, err := getTimeLocation()
+	if err != nil {
+		return fmt.Errorf("parsing TZ: %w", err)
+	}
+	c.TimeLocation = loc
+
+	return nil
+}
+
+// isLoopback reports whether a host:port listen address only accepts connections from this machine
+// An empty host listens on all interfaces
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loadKeyPair loads a certificate and key configured by the <prefix>_CERT and <prefix>_KEY variables
+// SECURITY: Refuses world-readable keys, which would let any local user impersonate the notifier
+func loadKeyPair(certFile, keyFile, prefix string) (*tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("%s_CERT and %s_KEY must be set together", prefix, prefix)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0o007 != 0 {
+		return nil, fmt.Errorf("private key %s is accessible by other users (chmod o-rwx)", keyFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// parseVersionSources parses "unit=kind[:arg];unit=kind[:arg]" version source mappings
+// Supported kinds: file:/path/VERSION, command:/path/bin --version, execstart
+func parseVersionSources(v string) (map[string]string, error) {
+	sources := map[string]string{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		unit, spec, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(unit) == "" || strings.TrimSpace(spec) == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected unit=source)", entry)
+		}
+
+		spec = strings.TrimSpace(spec)
+		kind, arg, _ := strings.Cut(spec, ":")
+		switch kind {
+		case "file", "command":
+			if strings.TrimSpace(arg) == "" {
+				return nil, fmt.Errorf("version source %q requires an argument", kind)
+			}
+		case "execstart":
+		default:
+			return nil, fmt.Errorf("unknown version source %q", kind)
+		}
+
+		sources[strings.TrimSpace(unit)] = spec
+	}
+	return sources, nil
+}
+
+// defaultStateDir returns the per-user or system state directory
+// Root uses /var/lib; users follow the XDG base directory spec
+func defaultStateDir() string {
+	if os.Geteuid() == 0 {
+		return constants.DefaultSystemStateDir
+	}
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, constants.StateDirName)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", constants.StateDirName)
+	}
+	return filepath.Join(os.TempDir(), constants.StateDirName)
+}
+
+// parseHTTPURL validates an http(s) URL and stores it in dst
+func parseHTTPURL(v string, dst *string) error {
+	u, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("must be an http(s) URL")
+	}
+	*dst = v
+	return nil
+}
+
+// parseLogFiles parses "unit=/path;unit=/path" mapping units to the log files they write
+func parseLogFiles(v string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		unit, path, ok := strings.Cut(entry, "=")
+		unit, path = strings.TrimSpace(unit), strings.TrimSpace(path)
+		if !ok || unit == "" || path == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected unit=/path/to/log)", entry)
+		}
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("%s: log file must be an absolute path", unit)
+		}
+		files[unit] = filepath.Clean(path)
+	}
+	return files, nil
+}
+
+// parseContainers parses "unit=container;unit=container" mapping units to the containers they run
+func parseContainers(v string) (map[string]string, error) {
+	containers := map[string]string{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		unit, container, ok := strings.Cut(entry, "=")
+		unit, container = strings.TrimSpace(unit), strings.TrimSpace(container)
+		if !ok || unit == "" || container == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected unit=container)", entry)
+		}
+		if !constants.ContainerNamePattern.MatchString(container) {
+			return nil, fmt.Errorf("%s: invalid container name or ID %q", unit, container)
+		}
+		containers[unit] = container
+	}
+	return containers, nil
+}
+
+// normalizeUnitKeys returns units with their keys named as systemctl would read them
+func normalizeUnitKeys(units map[string]string) map[string]string {
+	normalized := make(map[string]string, len(units))
+	for unit, v := range units {
+		normalized[validation.NormalizeUnitName(unit)] = v
+	}
+	return normalized
+}
+
+// parseFailureThresholds parses "name=N;name=N" where name is a unit, a job or "*"
+func parseFailureThresholds(v string) (map[string]int, error) {
+	thresholds := map[string]int{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, count, ok := strings.Cut(entry, "=")
+		name, count = strings.TrimSpace(name), strings.TrimSpace(count)
+		if !ok || name == "" || count == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected name=count)", entry)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%s: threshold must be a positive number", name)
+		}
+		thresholds[name] = n
+	}
+	return thresholds, nil
+}
+
+// parseSeverityChats parses "level=chat;level=chat" where chat is a numeric chat ID or an @channel username
+func parseSeverityChats(v string) (map[string]string, error) {
+	chats := map[string]string{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		level, chat, ok := strings.Cut(entry, "=")
+		chat = strings.TrimSpace(chat)
+		if !ok || chat == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected level=chat)", entry)
+		}
+		level, err := severity.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		if !isChat(chat) {
+			return nil, fmt.Errorf("%s: invalid chat %q (expected a chat ID or @channel)", level, chat)
+		}
+		chats[level] = chat
+	}
+	return chats, nil
+}
+
+// parseSeverityLevels parses a comma-separated list of severity levels
+func parseSeverityLevels(v string) ([]string, error) {
+	var levels []string
+	for _, item := range splitList(v) {
+		level, err := severity.ParseLevel(item)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// parsePingURLs parses "name=url;name=url" where name is a unit, a job or "*"
+func parsePingURLs(v string) (map[string]string, error) {
+	urls := map[string]string{}
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, pingURL, ok := strings.Cut(entry, "=")
+		name, pingURL = strings.TrimSpace(name), strings.TrimSpace(pingURL)
+		if !ok || name == "" || pingURL == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected name=url)", name)
+		}
+		if err := parseHTTPURL(pingURL, &pingURL); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		urls[name] = pingURL
+	}
+	return urls, nil
+}
+
+// loadCertPool returns the system roots plus the PEM certificates in path
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return pool, nil
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty items
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parsePrefixes parses a comma-separated list of IP addresses and CIDR ranges; an address is a range of its own
+func parsePrefixes(v string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range splitList(v) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", item)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// parseUnitPaths parses a comma-separated list of unit file directories
+// SECURITY: Directories must be absolute, and existing ones must not be world-writable, where anyone could plant a unit file;
+// missing ones are accepted since generator output such as /run/systemd/generator only appears at boot
+func parseUnitPaths(v string) ([]string, error) {
+	var dirs []string
+	for _, dir := range splitList(v) {
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("%s: must be an absolute path", dir)
+		}
+		dir = filepath.Clean(dir)
+		info, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		case !info.IsDir():
+			return nil, fmt.Errorf("%s: not a directory", dir)
+		case info.Mode().Perm()&0o002 != 0:
+			return nil, fmt.Errorf("%s: is world-writable", dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// getTimeLocation loads timezone from TZ environment variable or uses system local
+// PRIVACY: Respects user's timezone preference for timestamp formatting
+// An invalid TZ is an error: the Go runtime would silently use UTC and timestamps would look plausible but wrong
+func getTimeLocation() (*time.Location, error) {
+	tz := os.Getenv("TZ")
+	if tz == "" {
+		return time.Local, nil
+	}
+	// POSIX allows a leading ":", and an absolute path names a zoneinfo file, as in TZ=:/etc/localtime
+	name := strings.TrimPrefix(tz, ":")
+	if filepath.IsAbs(name) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return time.LoadLocationFromTZData(tz, data)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q (expected an IANA name such as Europe/Berlin or UTC)", tz)
+	}
+	return loc, nil
+}
+
+// Warnings lists settings that are valid on their own but likely not what was meant
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.ConnectTimeout > c.HTTPTimeout {
+		warnings = append(warnings, fmt.Sprintf("NOTIFIER_CONNECT_TIMEOUT (%s) exceeds NOTIFIER_HTTP_TIMEOUT (%s), which cuts connection attempts short",
+			c.ConnectTimeout, c.HTTPTimeout))
+	}
+	if limit := constants.TelegramMaxMessageSize - constants.MessageSafetyMargin; c.MaxOutputSize > limit {
+		warnings = append(warnings, fmt.Sprintf("NOTIFIER_MAX_OUTPUT_SIZE (%d) is more than fits next to the header; output is cut to about %d characters anyway",
+			c.MaxOutputSize, limit))
+	}
+	if c.SyslogListen != "" && !filepath.IsAbs(c.SyslogListen) && len(c.SyslogSources) == 0 && !isLoopback(c.SyslogListen) {
+		warnings = append(warnings, fmt.Sprintf("NOTIFIER_SYSLOG_LISTEN (%s) accepts messages from anyone who can reach it; limit senders with NOTIFIER_SYSLOG_ALLOWED_SOURCES",
+			c.SyslogListen))
+	}
+	if c.MetricsAddr != "" && !isLoopback(c.MetricsAddr) {
+		warnings = append(warnings, fmt.Sprintf("NOTIFIER_METRICS_ADDR (%s) serves /metrics and /healthz without authentication to anyone who can reach it; bind to 127.0.0.1 unless a firewall or proxy guards it",
+			c.MetricsAddr))
+	}
+	return warnings
+}
+
+// parseDurationRange parses a Go duration and checks it lies within [min, max]
+func parseDurationRange(v string, min, max time.Duration) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d < min || d > max {
+		return 0, fmt.Errorf("%s is out of range (must be between %s and %s)", d, min, max)
+	}
+	return d, nil
+}
+
+// validateDateTimeFormat rejects layouts that aren't Go reference-time layouts, such as strftime's "%Y-%m-%d",
+// which would print the same literal text in every notification
+func validateDateTimeFormat(layout string) error {
+	if len(layout) > constants.MaxDateTimeFormatLen {
+		return fmt.Errorf("must be at most %d characters", constants.MaxDateTimeFormatLen)
+	}
+	// Two different instants format identically only when the layout contains no time elements
+	a := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(layout)
+	b := time.Date(2012, 11, 22, 16, 17, 18, 0, time.UTC).Format(layout)
+	if a == b {
+		return fmt.Errorf("%q has no date or time elements; use Go's reference time, e.g. \"2006-01-02 15:04:05\"", layout)
+	}
+	return nil
+}
+
+// GetTimeLocation returns the configured timezone
+func (c *Config) GetTimeLocation() *time.Location {
+	return c.TimeLocation
+}
+
+// FormatDateTime formats a timestamp according to configured timezone and format
+func (c *Config) FormatDateTime(t time.Time) string {
+	return t.In(c.TimeLocation).Format(c.DateTimeFormat)
+}
+
+// GetSpoolDir returns the configured spool directory or its default under StateDir
+func (c *Config) GetSpoolDir() string {
+	if c.SpoolDir != "" {
+		return c.SpoolDir
+	}
+	return filepath.Join(c.StateDir, constants.SpoolDirName)
+}
+
+// GetSocketPath returns the daemon socket, its default under StateDir, or "" when disabled
+func (c *Config) GetSocketPath() string {
+	switch c.Socket {
+	case constants.SocketOff:
+		return ""
+	case "":
+		return filepath.Join(c.StateDir, constants.SocketFileName)
+	}
+	return filepath.Clean(c.Socket)
+}
+
+// GetDeadLetterFile returns the configured dead-letter log or its default under StateDir
+func (c *Config) GetDeadLetterFile() string {
+	if c.DeadLetterFile != "" {
+		return c.DeadLetterFile
+	}
+	return filepath.Join(c.StateDir, constants.DeadLetterFileName)
+}
+
+// GetHistoryFile returns the configured audit log or its default under StateDir
+func (c *Config) GetHistoryFile() string {
+	if c.HistoryFile != "" {
+		return c.HistoryFile
+	}
+	return filepath.Join(c.StateDir, constants.HistoryFileName)
+}
+
+// GetServiceStateFile returns where each service's last outcome is kept
+func (c *Config) GetServiceStateFile() string {
+	return filepath.Join(c.StateDir, constants.ServiceStateFileName)
+}
+
+// GetMaintenanceFile returns where planned maintenance windows are kept
+func (c *Config) GetMaintenanceFile() string {
+	return filepath.Join(c.StateDir, constants.MaintenanceFileName)
+}
+
+// GetTopicsFile returns where the forum topic created for each service is remembered
+func (c *Config) GetTopicsFile() string {
+	return filepath.Join(c.StateDir, constants.TopicsFileName)
+}
+
+// GetRateLimitFile returns where the Telegram rate limit tokens are shared between processes
+func (c *Config) GetRateLimitFile() string {
+	return filepath.Join(c.StateDir, constants.RateLimitFileName)
+}
+
+// GetOutputsDir returns where truncated notifications' full output is kept for "Show more"
+func (c *Config) GetOutputsDir() string {
+	return filepath.Join(c.StateDir, constants.OutputsDirName)
+}
+
+// GetFailureThreshold returns how many consecutive failures of a unit or job are needed before alerting
+// Units match with or without their ".service" suffix; "*" covers the rest
+func (c *Config) GetFailureThreshold(name string) int {
+	for _, key := range []string{name, strings.TrimSuffix(name, ".service"), "*"} {
+		if n, ok := c.FailureThresholds[key]; ok {
+			return n
+		}
+	}
+	return 1
+}
+
+// GetSeverity returns the severity level of a run from its exit code, or death by signal
+func (c *Config) GetSeverity(exitCode int, signaled, success bool) string {
+	return c.Severity.Level(exitCode, signaled, success)
+}
+
+// GetSeverityChat returns the chat a severity level is routed to, or "" for TELEGRAM_CHAT_ID
+func (c *Config) GetSeverityChat(level string) string {
+	return c.SeverityChats[level]
+}
+
+// IsSilentSeverity reports whether notifications of a severity level are sent without sound
+func (c *Config) IsSilentSeverity(level string) bool {
+	return slices.Contains(c.SilentSeverities, level)
+}
+
+// ShouldEscalate reports whether a unit's or job's failures repeat until acknowledged,
+// because the unit is listed or the failure's severity level escalates
+// Units match with or without their ".service" suffix
+func (c *Config) ShouldEscalate(name, level string) bool {
+	if slices.Contains(c.EscalateSeverities, level) {
+		return true
+	}
+	for _, e := range c.Escalate {
+		if e == "*" || e == name || e == strings.TrimSuffix(name, ".service") {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchesUnit reports whether the daemon reports manual restarts and reloads of a unit
+func (c *Config) WatchesUnit(name string) bool {
+	for _, w := range c.WatchUnits {
+		if w == "*" || w == name || w == strings.TrimSuffix(name, ".service") {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchesManager reports whether the daemon follows the service manager's journal for reloads and unit jobs
+func (c *Config) WatchesManager() bool {
+	return c.WatchDaemonReload || len(c.WatchUnits) > 0
+}
+
+// GetRedactionRules returns the redaction file rules combined with the redaction mode
+func (c *Config) GetRedactionRules() validation.RedactionRules {
+	rules := c.Redaction
+	rules.Lenient = c.RedactionMode == constants.RedactionLenient
+	return rules
+}
+
+// GetRedactor returns the redaction engine selected by NOTIFIER_REDACTION_ENGINE
+// The redaction file's extra patterns and allowlist apply to the gitleaks engine too
+func (c *Config) GetRedactor() validation.Redactor {
+	switch c.RedactionEngine {
+	case constants.RedactionEngineNone:
+		return validation.NopRedactor()
+	case constants.RedactionEngineGitleaks:
+		return validation.NewGitleaksRedactor(c.GitleaksRules, c.GetRedactionRules())
+	default:
+		return validation.NewRegexRedactor(c.GetRedactionRules())
+	}
+}
+
+// GetBotPolicy returns who may use interactive bot commands
+// Without NOTIFIER_BOT_ALLOWED_CHATS only the notification chat is allowed; channel usernames can't match and allow none
+func (c *Config) GetBotPolicy() botauth.Policy {
+	chats := c.BotAllowedChats
+	if len(chats) == 0 {
+		chats = map[int64]bool{}
+		if id, err := strconv.ParseInt(c.ChatID, 10, 64); err == nil {
+			chats[id] = true
+		}
+	}
+	return botauth.Policy{Chats: chats, Users: c.BotUsers}
+}
+
+// IsFieldVisible reports whether a notification header field should be displayed
+func (c *Config) IsFieldVisible(field string) bool {
+	return !c.HiddenFields[field]
+}
+
+// GetChannelSignature returns the signature line for posts to chat, or "" when it isn't a channel username
+// Bots post to channels anonymously, so with several hosts sharing a channel the signature names the sender
+func (c *Config) GetChannelSignature(chat string) string {
+	if c.ChannelSignature == "" || !strings.HasPrefix(chat, "@") {
+		return ""
+	}
+	return strings.ReplaceAll(c.ChannelSignature, "{host}", c.GetHostname())
 }
 
 // GetHostname returns the configured hostname alias or actual hostname
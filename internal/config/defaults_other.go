@@ -0,0 +1,8 @@
+//go:build !windows
+
+package config
+
+import "telegram-notifier/internal/constants"
+
+// defaultInitSystem looks services up in systemd unless NOTIFIER_INIT_SYSTEM says otherwise
+const defaultInitSystem = constants.InitSystemSystemd
@@ -0,0 +1,8 @@
+//go:build windows
+
+package config
+
+import "telegram-notifier/internal/constants"
+
+// defaultInitSystem looks services up in the service control manager on Windows builds
+const defaultInitSystem = constants.InitSystemWindows
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/schedule"
+	"telegram-notifier/internal/validation"
+)
+
+// chatsSection names the services file section defining chat aliases instead of a service
+const chatsSection = "chats"
+
+// ServicePolicy overrides the global notification settings for one unit or job
+// Unset fields keep the setting from the environment
+type ServicePolicy struct {
+	Notify        string            // always, failure-only or recovery, overriding NOTIFIER_POLICY
+	Threshold     int               // Consecutive failures before alerting; 0 keeps NOTIFIER_FAILURE_THRESHOLD
+	QuietHours    []schedule.Window // Replaces NOTIFIER_QUIET_HOURS when QuietHoursSet
+	QuietHoursSet bool              // quiet_hours was given, possibly as "none"
+	Chat          string            // Chat ID or @channel the service's notifications go to
+	Escalate      *bool             // Overrides NOTIFIER_ESCALATE for the service
+}
+
+// LoadServicePolicies reads a services file: a section per unit or job holding "key: value" lines
+//
+//	[chats]
+//	ops: -1001234567890
+//
+//	[backup.service]
+//	notify: failure-only
+//	threshold: 3
+//	quiet_hours: 23:00-07:00
+//	chat: ops
+//	escalate: true
+//
+// The [chats] section names chats for "chat:"; blank lines and lines starting with # are ignored
+func LoadServicePolicies(path string) (map[string]ServicePolicy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	policies := map[string]ServicePolicy{}
+	aliases := map[string]string{}
+	chatLines := map[string]int{} // Where each service's chat was set, for errors about unknown aliases
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, ok = strings.CutSuffix(name, "]")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("line %d: invalid section %q (expected [unit])", lineNo, line)
+			}
+			if name != chatsSection && validation.ValidateServiceName(name) != nil && validation.ValidateJobName(name) != nil {
+				return nil, fmt.Errorf("line %d: invalid unit or job name %q", lineNo, name)
+			}
+			if _, dup := policies[name]; dup {
+				return nil, fmt.Errorf("line %d: %s is declared twice", lineNo, name)
+			}
+			section = name
+			if section != chatsSection {
+				policies[section] = ServicePolicy{}
+			}
+			continue
+		}
+
+		// "key: value" or "key = value"; values such as quiet hours contain colons themselves
+		sep := strings.IndexAny(line, ":=")
+		if sep < 0 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:sep])), strings.TrimSpace(line[sep+1:])
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: %s is outside a [unit] section", lineNo, key)
+		}
+
+		if section == chatsSection {
+			if !isChat(value) {
+				return nil, fmt.Errorf("line %d: invalid chat %q for %s (expected a chat ID or @channel)", lineNo, value, key)
+			}
+			aliases[key] = value
+			continue
+		}
+
+		p := policies[section]
+		if err := p.set(key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, section, err)
+		}
+		if key == "chat" {
+			chatLines[section] = lineNo
+		}
+		policies[section] = p
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Aliases may be defined after the services using them
+	for name, p := range policies {
+		if p.Chat == "" || isChat(p.Chat) {
+			continue
+		}
+		chat, ok := aliases[p.Chat]
+		if !ok {
+			return nil, fmt.Errorf("line %d: %s: unknown chat %q (define it in [%s] or use a chat ID)", chatLines[name], name, p.Chat, chatsSection)
+		}
+		p.Chat = chat
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// set applies one "key: value" line of a service's section
+func (p *ServicePolicy) set(key, value string) error {
+	switch key {
+	case "notify":
+		notify := strings.ToLower(value)
+		switch notify {
+		case constants.PolicyAlways, constants.PolicyFailureOnly, constants.PolicyRecovery:
+			p.Notify = notify
+			return nil
+		}
+		return fmt.Errorf("notify must be %q, %q or %q", constants.PolicyAlways, constants.PolicyFailureOnly, constants.PolicyRecovery)
+	case "threshold":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("threshold must be a positive number")
+		}
+		p.Threshold = n
+	case "quiet_hours":
+		p.QuietHoursSet = true
+		if strings.EqualFold(value, "none") {
+			p.QuietHours = nil
+			return nil
+		}
+		windows, err := schedule.ParseWindows(value)
+		if err != nil {
+			return err
+		}
+		p.QuietHours = windows
+	case "chat":
+		p.Chat = value
+	case "escalate":
+		escalate, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("escalate must be true or false")
+		}
+		p.Escalate = &escalate
+	default:
+		return fmt.Errorf("unknown setting %q (expected notify, threshold, quiet_hours, chat or escalate)", key)
+	}
+	return nil
+}
+
+// isChat reports whether v is a numeric chat ID or an @channel username
+func isChat(v string) bool {
+	return validation.ValidateChatID(v) == nil
+}
+
+// GetServicePolicy returns the overrides declared for a unit or job in the services file
+// Units match with or without their ".service" suffix
+func (c *Config) GetServicePolicy(name string) (ServicePolicy, bool) {
+	for _, key := range []string{name, strings.TrimSuffix(name, ".service")} {
+		if p, ok := c.ServicePolicies[key]; ok {
+			return p, true
+		}
+	}
+	return ServicePolicy{}, false
+}
+
+// GetNotifyPolicy returns which runs of a unit or job are reported: its notify setting, or NOTIFIER_POLICY
+func (c *Config) GetNotifyPolicy(name string) string {
+	if p, ok := c.GetServicePolicy(name); ok && p.Notify != "" {
+		return p.Notify
+	}
+	return c.Policy
+}
@@ -9,21 +9,188 @@ import (
 const (
 	DefaultCommandTimeout  = 30 * time.Second
 	DefaultHTTPTimeout     = 10 * time.Second
+	DefaultConnectTimeout  = 10 * time.Second
+	DefaultIdleConnTimeout = 90 * time.Second
 	DefaultJournalLookback = 30 * time.Second
+	VersionCommandTimeout  = 5 * time.Second
+	TraceExportTimeout     = 5 * time.Second
+	KeyringTimeout         = 10 * time.Second
+	CoreDumpLookback       = 10 * time.Minute // How long before a notification a crash's core dump is looked for
+	RunStartSlack          = 1 * time.Minute  // Slack before a run's start in which kernel and audit records are still matched to it
 )
 
+// Accepted ranges for configurable timeouts; values outside them are configuration errors
+const (
+	MinCommandTimeout  = 1 * time.Second
+	MaxCommandTimeout  = 10 * time.Minute
+	MinHTTPTimeout     = 1 * time.Second
+	MaxHTTPTimeout     = 5 * time.Minute
+	MinConnectTimeout  = 100 * time.Millisecond
+	MaxConnectTimeout  = 1 * time.Minute
+	MaxIdleConnTimeout = 1 * time.Hour
+	MinJournalLookback = 1 * time.Second
+	MaxJournalLookback = 24 * time.Hour
+)
+
+// DefaultTelegramAPIURL is the public Bot API endpoint
+const DefaultTelegramAPIURL = "https://api.telegram.org"
+
 // Size limits
 const (
 	DefaultMaxOutputSize     = 2500
+	MinMaxOutputSize         = 100
 	DefaultTruncationMsgSize = 30
 	TelegramMaxMessageSize   = 4096
 	MessageSafetyMargin      = 500
+	MaxVersionLength         = 100
+	MaxStdinSize             = 1024 * 1024
+	MaxJournalLines          = 5000        // Journal lines kept per query; earlier ones are dropped while reading
+	MaxJournalLineBytes      = 16 * 1024   // Longer journal lines are cut
+	MaxJournalBytes          = 1024 * 1024 // Journal output kept per query; earlier lines are dropped while reading
+	MaxSystemErrorLines      = 50          // Most system journal error lines a failure notification takes
+	MaxSystemErrorsSize      = 1000        // Characters the system errors excerpt may take; its oldest lines go first
+	MaxBacktraceFrames       = 10          // Stack frames of the crashed thread a core dump summary shows
+	MaxDenialLines           = 10          // Most SELinux and AppArmor denials a failure notification takes, keeping the latest
+	MaxDenialsSize           = 1000        // Characters the denials excerpt may take
+	MaxOOMKillLines          = 5           // Most OOM killer messages an out-of-memory report shows, keeping the latest
 )
 
+// Persistent state
+const (
+	DefaultSystemStateDir   = "/var/lib/telegram-notifier"
+	StateDirName            = "telegram-notifier"
+	SpoolDirName            = "spool"
+	DefaultSpoolMaxEntries  = 100
+	DefaultSpoolMaxAttempts = 10
+	DefaultDaemonInterval   = 1 * time.Minute
+	ManagerWatchRetry       = 30 * time.Second // Wait before following the manager's journal again after journalctl exits
+	DeadLetterFileName      = "deadletter.jsonl"
+	HistoryFileName         = "history.jsonl"
+	HistoryMaxFileSize      = 5 * 1024 * 1024
+	SocketFileName          = "notifier.sock"
+	SocketOff               = "off" // NOTIFIER_SOCKET value disabling the daemon fast path
+	UnitCacheFileName       = "units.json"
+	ServiceStateFileName    = "services.json"
+	MaintenanceFileName     = "maintenance.json"
+	TopicsFileName          = "topics.json"
+	RateLimitFileName       = "ratelimit.json"   // Telegram rate limit tokens, shared by every notifier process
+	OutputsDirName          = "outputs"          // Full output of truncated notifications, paged by "Show more"
+	OutputRetention         = 7 * 24 * time.Hour // How long "Show more" can page a notification's output
+	ShowMoreChunkSize       = 3500               // UTF-16 units of output per "Show more" reply
+)
+
+// DefaultSmartMaxWear is the NVMe endurance used (percent) reported by the smart command
+const DefaultSmartMaxWear = 90
+
 // Time formatting
 const (
 	DefaultDateTimeFormat = "02-Jan 15:04:05"
-	DefaultJournalSince   = "1 minute ago"
+	MaxDateTimeFormatLen  = 64
+	MaxChannelSignature   = 128 // Characters in NOTIFIER_CHANNEL_SIGNATURE
+)
+
+// Notification header fields that can be hidden via NOTIFIER_HIDE_FIELDS
+const (
+	FieldHost         = "host"
+	FieldTimestamp    = "timestamp"
+	FieldFailingSince = "failing_since"
+	FieldExitCode     = "exit_code"
+	FieldRuntime      = "runtime"
+	FieldService      = "service"
+	FieldDescription  = "description"
+	FieldStartedBy    = "started_by"
+	FieldDependencies = "dependencies"
+	FieldUnitChanged  = "unit_changed"
+	FieldInvocationID = "invocation_id"
+	FieldVersion      = "version"
+)
+
+// NotificationFields lists all hideable header fields in display order
+var NotificationFields = []string{
+	FieldHost, FieldTimestamp, FieldFailingSince, FieldExitCode, FieldRuntime,
+	FieldService, FieldDescription, FieldStartedBy, FieldDependencies, FieldUnitChanged,
+	FieldInvocationID, FieldVersion,
+}
+
+// Log formats selectable via NOTIFIER_LOG_FORMAT
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Redaction modes selectable via NOTIFIER_REDACTION_MODE
+const (
+	RedactionStrict  = "strict"  // Keywords followed by whitespace, ":" or "=" are redacted
+	RedactionLenient = "lenient" // Keywords must be assigned with ":" or "=", so prose like "token bucket" survives
+)
+
+// Redaction engines selectable via NOTIFIER_REDACTION_ENGINE
+const (
+	RedactionEngineRegex    = "regex"    // Built-in patterns adjusted by NOTIFIER_REDACTION_FILE
+	RedactionEngineGitleaks = "gitleaks" // Rules of a gitleaks config (NOTIFIER_REDACTION_RULESET) instead of the built-ins
+	RedactionEngineNone     = "none"     // Nothing is redacted; for hosts whose output is trusted
+)
+
+// Telegram formatting modes selectable via NOTIFIER_FORMATTING
+const (
+	FormattingMarkdown = "markdown" // Telegram parses the message with parse_mode Markdown
+	FormattingEntities = "entities" // The Markdown is parsed here and sent as plain text with an entities array
+)
+
+// Init systems selectable via NOTIFIER_INIT_SYSTEM
+const (
+	InitSystemSystemd = "systemd" // Exit status, output and unit details from systemctl and the journal
+	InitSystemOpenRC  = "openrc"  // rc-service and /etc/init.d scripts, output from their log files or syslog
+	InitSystemRunit   = "runit"   // sv and service directories, output from svlogd or syslog
+	InitSystemLaunchd = "launchd" // launchctl print on macOS, output from the job's log files or the unified log
+	InitSystemWindows = "windows" // The service control manager or Task Scheduler, output from the Event Log
+)
+
+// LaunchdLogWindow is how far back the unified log is searched for a launchd job's output
+const LaunchdLogWindow = 10 * time.Minute
+
+// Event Log lookups for Windows services and scheduled tasks
+const (
+	EventLogWindow     = 10 * time.Minute // How far back the Event Log is searched for a service's entries
+	MaxEventLogEntries = 20               // Entries kept per log, newest first
+)
+
+// Container logs read through the Docker or Podman API instead of the journal
+const (
+	DefaultContainerSocket = "/var/run/docker.sock" // Podman serves the same API at /run/podman/podman.sock
+	ContainerLogLookback   = time.Hour              // How far back the first read of a container's logs goes
+)
+
+// DefaultSyslogSeverity reports received syslog messages of severity err (3) and worse
+const DefaultSyslogSeverity = 3
+
+// Received syslog messages are reported at this pace at most, so a chatty or hostile sender can't flood the chat
+const (
+	SyslogBurst        = 10               // Messages reported back to back
+	SyslogRefillRate   = 30 * time.Second // One more message may follow per interval once the burst is spent
+	SyslogRepeatWindow = 10 * time.Minute // The same message from the same host is reported once per window
+)
+
+// DefaultSyslogFile is where OpenRC and runit services' output is looked for when they have no log file of their own
+const DefaultSyslogFile = "/var/log/messages"
+
+// Success notification formats selectable via NOTIFIER_SUCCESS_FORMAT
+const (
+	SuccessFormatFull  = "full"  // Same layout as failures, with the run's output
+	SuccessFormatBrief = "brief" // One line; the journal isn't read for successful runs
+)
+
+// Notification policies selectable via NOTIFIER_POLICY
+const (
+	PolicyAlways      = "always"       // Every run is reported
+	PolicyFailureOnly = "failure-only" // Successful runs are recorded but not reported
+	PolicyRecovery    = "recovery"     // Failures, and the first success after one
+)
+
+// Bot token sources selectable via NOTIFIER_TOKEN_SOURCE
+const (
+	TokenSourceEnv     = "env"     // TELEGRAM_BOT_TOKEN from the environment file
+	TokenSourceKeyring = "keyring" // Secret Service keyring, written by "telegram-notifier init --keyring"
 )
 
 // HTTP retry configuration
@@ -36,9 +203,34 @@ const (
 
 // Rate limiting for Telegram API
 const (
-	RateLimitTokens      = 10
-	RateLimitRefillRate  = 1 * time.Second
-	RateLimitMaxWaitTime = 5 * time.Second
+	RateLimitTokens       = 10
+	RateLimitRefillRate   = 1 * time.Second
+	RateLimitMaxWaitTime  = 5 * time.Second
+	RateLimitQueueSize    = 50
+	RateLimitQueueMaxWait = 30 * time.Second // Longest a queued notification waits before it's spooled instead
+)
+
+// Run time compared against a unit's history
+const (
+	DefaultRuntimeDeviation = 3                // Warn when a run takes 3× longer or shorter than usual
+	RuntimeBaselineRecords  = 100              // Most recent history records the average is taken from
+	MinRuntimeBaselineRuns  = 5                // Runs needed before the average is trusted
+	MinRuntimeDeviation     = 10 * time.Second // Runs this short, now and on average, vary too much to warn about
+)
+
+// Interactive bot and escalation
+const (
+	BotPollWait               = 30 * time.Second // getUpdates long-poll duration
+	DefaultAckReaction        = "👍"              // Reaction acknowledging the failure it's put on
+	MaxTopicName              = 128              // Longest forum topic name Telegram accepts
+	DefaultLogsLines          = 20               // Journal entries /logs shows without a count
+	MaxLogsLines              = 200              // Most journal entries /logs reads
+	StatusTimeFormat          = "Jan 02 15:04"   // Timestamps in the /status table
+	ConfirmationTimeout       = 5 * time.Minute  // How long a /restart or /stop confirmation button works
+	DefaultBotWebhookAddr     = "127.0.0.1:8443" // Webhook receiver address, behind a reverse proxy unless given a certificate
+	DefaultEscalationInterval = 15 * time.Minute // First repeat of an unacknowledged failure; doubles each time
+	MaxEscalationInterval     = 4 * time.Hour
+	DefaultSnoozeDuration     = time.Hour // "/snooze unit" without a duration
 )
 
 // Rate limiting for command execution (prevent abuse)
@@ -48,54 +240,95 @@ const (
 	CommandRateLimitMaxWait    = 10 * time.Second
 )
 
+// UnitTypes are the unit type suffixes systemd knows; names without one are services
+var UnitTypes = []string{"service", "socket", "device", "mount", "automount", "swap", "target", "path", "timer", "slice", "scope"}
+
+// MaxUnitNameLength is systemd's limit on unit names
+const MaxUnitNameLength = 255
+
 // Validation patterns
 var (
-	ServiceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9:_.@-]+\.service$`)
-	ExitCodeMin        = 0
-	ExitCodeMax        = 255
+	// prefix[@instance].type, as systemd-analyze and unit_name_is_valid() accept; an empty instance is a template
+	ServiceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9:_.\\-]+(@[a-zA-Z0-9:_.\\-]*)?\.(service|socket|device|mount|automount|swap|target|path|timer|slice|scope)$`)
+	JobNamePattern     = regexp.MustCompile(`^[a-zA-Z0-9:_.@-]{1,64}$`)
+	// <bot ID>:<35-character secret>, as issued by @BotFather
+	BotTokenPattern = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]{35}$`)
+	// secret_token of setWebhook, echoed by Telegram in every webhook request
+	WebhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+	// Numeric chat ID: positive for users, negative for groups, -100... for supergroups and channels
+	ChatIDPattern = regexp.MustCompile(`^-?[1-9]\d{0,18}$`)
+	// @username of a public channel or group: 5-32 letters, digits and underscores, starting with a letter
+	ChatUsernamePattern = regexp.MustCompile(`^@[A-Za-z][A-Za-z0-9_]{4,31}$`)
+	ExitCodeMin         = 0
+	ExitCodeMax         = 255
+	// Container name or ID, as docker and podman accept them
+	ContainerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,254}$`)
 )
 
+// SecretPattern is a built-in redaction rule; Name lets users disable it
+type SecretPattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Lenient *regexp.Regexp // Used in lenient mode; nil means Pattern applies in both modes
+	Hints   []string       // Lowercase text every match contains one of; lines without any skip the regex
+	Secret  int            // Submatch holding the secret value, which allow rules are checked against; 0 for the whole match
+}
+
+// keywordPattern builds a strict/lenient pair for "<keyword> <separator> <value>" rules
+// The strict form accepts whitespace as a separator; the lenient one requires ":" or "="
+// The value is the pattern's last group, so allow rules see the secret without its keyword
+func keywordPattern(name, keywords, value string, hints ...string) SecretPattern {
+	pattern := regexp.MustCompile(keywords + `[\s:=]+['"]?` + value)
+	return SecretPattern{
+		Name:    name,
+		Pattern: pattern,
+		Lenient: regexp.MustCompile(keywords + `\s*[:=]\s*['"]?` + value),
+		Hints:   hints,
+		Secret:  pattern.NumSubexp(),
+	}
+}
+
 // Secret patterns for filtering (enhanced)
-var SecretPatterns = []*regexp.Regexp{
+var SecretPatterns = []SecretPattern{
 	// Passwords and API keys
-	regexp.MustCompile(`(?i)(password|passwd|pwd)[\s:=]+['"]?([^\s'"]+)`),
-	regexp.MustCompile(`(?i)(api[_-]?key|apikey)[\s:=]+['"]?([^\s'"]+)`),
-	regexp.MustCompile(`(?i)(secret|token)[\s:=]+['"]?([^\s'"]+)`),
-	regexp.MustCompile(`(?i)(auth[_-]?token)[\s:=]+['"]?([^\s'"]+)`),
+	keywordPattern("password", `(?i)(password|passwd|pwd)`, `([^\s'"]+)`, "pass", "pwd"),
+	keywordPattern("api_key", `(?i)(api[_-]?key|apikey)`, `([^\s'"]+)`, "key"),
+	keywordPattern("secret_token", `(?i)(secret|token)`, `([^\s'"]+)`, "secret", "token"),
+	keywordPattern("auth_token", `(?i)(auth[_-]?token)`, `([^\s'"]+)`, "token"),
 
 	// Bearer tokens
-	regexp.MustCompile(`(?i)bearer\s+([a-zA-Z0-9\-._~+/]+=*)`),
+	{Name: "bearer", Pattern: regexp.MustCompile(`(?i)bearer\s+([a-zA-Z0-9\-._~+/]+=*)`), Hints: []string{"bearer"}, Secret: 1},
 
 	// SSH/TLS keys (all types)
-	regexp.MustCompile(`-----BEGIN\s+(?:RSA|DSA|EC|OPENSSH|ENCRYPTED)?\s*PRIVATE\s+KEY-----`),
+	{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN\s+(?:RSA|DSA|EC|OPENSSH|ENCRYPTED)?\s*PRIVATE\s+KEY-----`), Hints: []string{"-----begin"}},
 
 	// Cloud provider keys
-	regexp.MustCompile(`(?i)(aws_secret_access_key|aws_access_key_id)[\s:=]+['"]?([^\s'"]+)`),
-	regexp.MustCompile(`(?i)(gcp|google)[-_]?(service[-_]?account|credentials)[\s:=]+['"]?([^\s'"]+)`),
-	regexp.MustCompile(`(?i)(azure|az)[-_]?(key|secret|token)[\s:=]+['"]?([^\s'"]+)`),
+	keywordPattern("cloud_aws", `(?i)(aws_secret_access_key|aws_access_key_id)`, `([^\s'"]+)`, "aws_"),
+	keywordPattern("cloud_gcp", `(?i)(gcp|google)[-_]?(service[-_]?account|credentials)`, `([^\s'"]+)`, "gcp", "google"),
+	keywordPattern("cloud_azure", `(?i)(azure|az)[-_]?(key|secret|token)`, `([^\s'"]+)`, "az"),
 
 	// Database connection strings
-	regexp.MustCompile(`(?i)[a-z]+://[^:/@\s]+:([^@/\s]+)@`),
-	regexp.MustCompile(`(?i)(mongodb|postgresql|mysql|redis)://[^\s]+`),
+	{Name: "url_credentials", Pattern: regexp.MustCompile(`(?i)[a-z]+://[^:/@\s]+:([^@/\s]+)@`), Hints: []string{"://"}, Secret: 1},
+	{Name: "database_url", Pattern: regexp.MustCompile(`(?i)(mongodb|postgresql|mysql|redis)://[^\s]+`), Hints: []string{"://"}},
 
 	// JWT tokens
-	regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	{Name: "jwt", Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), Hints: []string{"eyj"}},
 
 	// GitHub/GitLab tokens
-	regexp.MustCompile(`(?i)(gh[pousr]_[A-Za-z0-9]{36,})`),
-	regexp.MustCompile(`(?i)(glpat-[A-Za-z0-9\-_]{20,})`),
+	{Name: "github_token", Pattern: regexp.MustCompile(`(?i)(gh[pousr]_[A-Za-z0-9]{36,})`), Hints: []string{"gh"}},
+	{Name: "gitlab_token", Pattern: regexp.MustCompile(`(?i)(glpat-[A-Za-z0-9\-_]{20,})`), Hints: []string{"glpat-"}},
 
 	// Generic base64-encoded secrets
-	regexp.MustCompile(`(?i)(secret|key|token|password|credential)[\s:=]+['"]?([A-Za-z0-9+/]{32,}={0,2})`),
+	keywordPattern("base64_secret", `(?i)(secret|key|token|password|credential)`, `([A-Za-z0-9+/]{32,}={0,2})`, "secret", "key", "token", "password", "credential"),
 
 	// OAuth tokens
-	regexp.MustCompile(`(?i)(access_token|refresh_token)[\s:=]+['"]?([^\s'"]+)`),
+	keywordPattern("oauth_token", `(?i)(access_token|refresh_token)`, `([^\s'"]+)`, "_token"),
 
 	// Slack tokens
-	regexp.MustCompile(`xox[baprs]-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24,}`),
+	{Name: "slack_token", Pattern: regexp.MustCompile(`xox[baprs]-[0-9]{10,13}-[0-9]{10,13}-[a-zA-Z0-9]{24,}`), Hints: []string{"xox"}},
 
 	// Generic credentials in environment variable format
-	regexp.MustCompile(`(?i)(export\s+)?[A-Z_]+_(PASSWORD|SECRET|KEY|TOKEN)=['"]([^'"]+)['"]`),
+	{Name: "env_credential", Pattern: regexp.MustCompile(`(?i)(export\s+)?[A-Z_]+_(PASSWORD|SECRET|KEY|TOKEN)=['"]([^'"]+)['"]`), Hints: []string{"_password", "_secret", "_key", "_token"}, Secret: 3},
 }
 
 const OutputTruncatedMsg = "...(output truncated)\n\n"
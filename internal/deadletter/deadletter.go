@@ -0,0 +1,80 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reasons a notification is dead-lettered
+const (
+	ReasonPermanentError   = "permanent_error"
+	ReasonSpoolDisabled    = "spool_disabled"
+	ReasonSpoolFailed      = "spool_failed"
+	ReasonSpoolEvicted     = "spool_evicted"
+	ReasonAttemptsExceeded = "attempts_exceeded"
+)
+
+// Record describes a notification that could not be delivered
+type Record struct {
+	Time      time.Time `json:"time"`
+	Service   string    `json:"service"`
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	Message   string    `json:"message"`
+}
+
+// Log appends dead-lettered notifications to a JSON lines file for auditing
+// SECURITY: File is created 0600 since messages may contain command output
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New creates a dead-letter log writing to path
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the dead-letter file location
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends a dead-letter record
+func (l *Log) Record(rec Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = rec.Time
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding dead-letter record: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("creating dead-letter directory: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening dead-letter log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing dead-letter log: %w", err)
+	}
+	return nil
+}
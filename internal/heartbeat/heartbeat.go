@@ -0,0 +1,76 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"telegram-notifier/internal/httpclient"
+)
+
+// failSuffix is appended to ping URLs to signal failure (healthchecks.io convention)
+const failSuffix = "/fail"
+
+// kumaPushPath identifies Uptime Kuma push monitors, which take the result as a status parameter
+const kumaPushPath = "/api/push/"
+
+// Pinger reports liveness to a healthchecks.io-style ping URL
+// The monitoring service alerts when pings stop arriving, so silence from this host is detectable
+type Pinger struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewPinger creates a pinger for a ping URL
+func NewPinger(pingURL string, httpClient *http.Client) *Pinger {
+	return &Pinger{url: strings.TrimRight(pingURL, "/"), httpClient: httpClient}
+}
+
+// Ping reports the host as alive, or as failing when healthy is false
+func (p *Pinger) Ping(ctx context.Context, healthy bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingTarget(p.url, healthy), nil)
+	if err != nil {
+		return fmt.Errorf("request creation error: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		// SECURITY: Ping URLs embed a secret check ID; don't echo the URL in errors
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("heartbeat ping failed: %w", err)
+	}
+	defer httpclient.DrainAndClose(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat ping returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// pingTarget returns the URL signalling success or failure for a ping URL
+// Uptime Kuma push URLs get status=up/down; others follow healthchecks.io's "/fail" suffix
+func pingTarget(pingURL string, healthy bool) string {
+	u, err := url.Parse(pingURL)
+	if err != nil || !strings.Contains(u.Path, kumaPushPath) {
+		if healthy {
+			return pingURL
+		}
+		return pingURL + failSuffix
+	}
+
+	query := u.Query()
+	query.Set("status", "up")
+	query.Set("msg", "OK")
+	if !healthy {
+		query.Set("status", "down")
+		query.Set("msg", "failed")
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
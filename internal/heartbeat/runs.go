@@ -0,0 +1,51 @@
+package heartbeat
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultRunPing is the NOTIFIER_PING_URLS key applying to every unit and job without its own URL
+// Its URL may contain NamePlaceholder, e.g. a healthchecks.io slug URL
+const DefaultRunPing = "*"
+
+// NamePlaceholder in the default ping URL is replaced with the unit or job name
+const NamePlaceholder = "{name}"
+
+// RunPings pings a per-service URL after each monitored run, so a check alerts when runs stop
+type RunPings struct {
+	urls       map[string]string
+	httpClient *http.Client
+}
+
+// NewRunPings creates run pings for URLs keyed by unit name, job name or DefaultRunPing
+func NewRunPings(urls map[string]string, httpClient *http.Client) *RunPings {
+	return &RunPings{urls: urls, httpClient: httpClient}
+}
+
+// PingRun reports a run's result to the service's URL; services without one are skipped
+func (r *RunPings) PingRun(ctx context.Context, service string, success bool) error {
+	pingURL := r.URL(service)
+	if pingURL == "" {
+		return nil
+	}
+	return NewPinger(pingURL, r.httpClient).Ping(ctx, success)
+}
+
+// URL returns the ping URL for a unit or job, empty when none is configured
+// Units match with or without their ".service" suffix
+func (r *RunPings) URL(service string) string {
+	if u, ok := r.urls[service]; ok {
+		return u
+	}
+	if u, ok := r.urls[strings.TrimSuffix(service, ".service")]; ok {
+		return u
+	}
+	if u, ok := r.urls[DefaultRunPing]; ok {
+		name := strings.TrimSuffix(service, ".service")
+		return strings.ReplaceAll(u, NamePlaceholder, url.PathEscape(name))
+	}
+	return ""
+}
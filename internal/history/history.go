@@ -0,0 +1,186 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Delivery results recorded in the audit log
+const (
+	ResultDelivered = "delivered"
+	ResultQueued    = "queued"
+	ResultSpooled   = "spooled"
+	ResultFailed    = "failed"
+
+	// Results of records noting what a bot user did rather than a notification
+	ResultAcknowledged = "acknowledged" // Took on a service's failures
+	ResultRestarted    = "restarted"    // Restarted the unit with /restart
+	ResultStopped      = "stopped"      // Stopped the unit with /stop
+)
+
+// Outcomes of the monitored service run
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Record is a single notification attempt in the audit log
+type Record struct {
+	Time        time.Time `json:"time"`
+	Service     string    `json:"service"`
+	Result      string    `json:"result"`
+	Backend     string    `json:"backend,omitempty"`
+	MessageHash string    `json:"message_hash"`
+	MessageID   int64     `json:"message_id,omitempty"`
+	Attempts    int       `json:"attempts"`
+	LatencyMS   int64     `json:"latency_ms"`
+	Error       string    `json:"error,omitempty"`
+	Outcome     string    `json:"outcome,omitempty"`    // Service run result; empty for free-form notifications
+	RuntimeMS   int64     `json:"runtime_ms,omitempty"` // Service run duration, when systemd reports it
+	Retry       bool      `json:"retry,omitempty"`      // Redelivery of a spooled notification
+	By          string    `json:"by,omitempty"`         // Who acted, for records of user actions
+	AttemptLog  []Attempt `json:"attempt_log,omitempty"`
+}
+
+// IsAction reports whether the record notes a user's action, such as an acknowledgement, rather than a notification
+func (r Record) IsAction() bool {
+	return r.Result == ResultAcknowledged || r.Result == ResultRestarted || r.Result == ResultStopped
+}
+
+// Attempt is a single delivery request within a record
+type Attempt struct {
+	Backend   string `json:"backend"`
+	Status    int    `json:"status,omitempty"` // HTTP status; omitted when no response was received
+	LatencyMS int64  `json:"latency_ms"`
+	BackoffMS int64  `json:"backoff_ms,omitempty"` // Wait applied before the request
+}
+
+// Filter narrows history queries; zero values match everything
+type Filter struct {
+	Service string
+	Since   time.Time
+	Limit   int // Most recent N records (0 = all)
+}
+
+// Store is an append-only JSON lines audit log of notification attempts
+// The log is rotated to a single ".1" backup once it exceeds maxSize bytes.
+// SECURITY: Only a hash of the message is stored, never its content.
+type Store struct {
+	path    string
+	maxSize int64
+	mu      sync.Mutex
+}
+
+// New creates a history store at path
+func New(path string, maxSize int64) *Store {
+	return &Store{path: path, maxSize: maxSize}
+}
+
+// Path returns the audit log location
+func (s *Store) Path() string {
+	return s.path
+}
+
+// HashMessage returns a short, stable fingerprint of a message for correlation
+func HashMessage(message string) string {
+	sum := sha256.Sum256([]byte(message))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Append writes a record to the audit log
+func (s *Store) Append(rec Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding history record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("creating history directory: %w", err)
+	}
+	s.rotateIfNeeded()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening history log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing history log: %w", err)
+	}
+	return nil
+}
+
+// Query returns matching records, oldest first
+func (s *Store) Query(filter Filter) ([]Record, error) {
+	var records []Record
+
+	// Read the rotated backup first to keep chronological order
+	for _, path := range []string{s.path + ".1", s.path} {
+		recs, err := readRecords(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, recs...)
+	}
+
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
+
+// rotateIfNeeded moves the log aside once it exceeds the size cap
+func (s *Store) rotateIfNeeded() {
+	if s.maxSize <= 0 {
+		return
+	}
+	info, err := os.Stat(s.path)
+	if err != nil || info.Size() < s.maxSize {
+		return
+	}
+	os.Rename(s.path, s.path+".1")
+}
+
+// readRecords parses a JSON lines file, skipping malformed lines
+func readRecords(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening history log: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec Record
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		if filter.Service != "" && rec.Service != filter.Service {
+			continue
+		}
+		if !filter.Since.IsZero() && rec.Time.Before(filter.Since) {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,87 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// ServiceStats summarizes recorded activity for one service
+type ServiceStats struct {
+	Service          string
+	Notifications    int // Notifications sent for the service, excluding spool redeliveries
+	Successes        int // Runs reported as successful
+	Failures         int // Runs reported as failed
+	DeliveryFailures int // Notifications that could not be delivered or spooled
+	Spooled          int // Notifications spooled after a failed delivery
+	AvgRuntime       time.Duration
+	TimedRuns        int // Runs with a known run time, which AvgRuntime averages
+	AvgLatency       time.Duration
+}
+
+// DeliveryFailureRate returns the share of notifications that were lost
+func (s ServiceStats) DeliveryFailureRate() float64 {
+	if s.Notifications == 0 {
+		return 0
+	}
+	return float64(s.DeliveryFailures) / float64(s.Notifications)
+}
+
+// Summarize aggregates records per service, sorted by service name
+func Summarize(records []Record) []ServiceStats {
+	type totals struct {
+		stats                  ServiceStats
+		runtimeMS, runtimeRuns int64
+		latencyMS, deliveries  int64
+	}
+	byService := make(map[string]*totals)
+
+	for _, rec := range records {
+		t, ok := byService[rec.Service]
+		if !ok {
+			t = &totals{stats: ServiceStats{Service: rec.Service}}
+			byService[rec.Service] = t
+		}
+
+		if rec.Attempts > 0 {
+			t.latencyMS += rec.LatencyMS
+			t.deliveries++
+		}
+
+		// Redeliveries belong to a notification that was already counted; user actions aren't notifications
+		if rec.Retry || rec.IsAction() {
+			continue
+		}
+
+		t.stats.Notifications++
+		switch rec.Outcome {
+		case OutcomeSuccess:
+			t.stats.Successes++
+		case OutcomeFailure:
+			t.stats.Failures++
+		}
+		switch rec.Result {
+		case ResultFailed:
+			t.stats.DeliveryFailures++
+		case ResultSpooled:
+			t.stats.Spooled++
+		}
+		if rec.RuntimeMS > 0 {
+			t.runtimeMS += rec.RuntimeMS
+			t.runtimeRuns++
+		}
+	}
+
+	summary := make([]ServiceStats, 0, len(byService))
+	for _, t := range byService {
+		if t.runtimeRuns > 0 {
+			t.stats.AvgRuntime = time.Duration(t.runtimeMS/t.runtimeRuns) * time.Millisecond
+			t.stats.TimedRuns = int(t.runtimeRuns)
+		}
+		if t.deliveries > 0 {
+			t.stats.AvgLatency = time.Duration(t.latencyMS/t.deliveries) * time.Millisecond
+		}
+		summary = append(summary, t.stats)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Service < summary[j].Service })
+	return summary
+}
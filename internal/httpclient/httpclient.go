@@ -0,0 +1,162 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+)
+
+// tcpKeepAlive probes idle pooled connections, so NAT and firewall state doesn't expire under them
+const tcpKeepAlive = 30 * time.Second
+
+// maxIdleConnsPerHost keeps enough connections for retries and concurrent sends to one endpoint
+const maxIdleConnsPerHost = 4
+
+// maxDrain is how much of an unread response body is discarded to keep its connection reusable
+const maxDrain = 64 * 1024
+
+// transportKey identifies transports that can be shared: same TLS trust, pins and tuning
+type transportKey struct {
+	rootCAs     *x509.CertPool
+	minVersion  uint16
+	clientCert  *tls.Certificate
+	pins        string
+	connect     time.Duration
+	idleTimeout time.Duration
+	http2       bool
+}
+
+var (
+	transportsMu sync.Mutex
+	transports   = map[transportKey]*http.Transport{}
+)
+
+// New returns an HTTP client using the configured CA bundle, minimum TLS version and client certificate
+// The certificate is only presented to servers that request one, such as mTLS webhook receivers
+func New(cfg *config.Config) *http.Client {
+	return &http.Client{
+		Timeout:   cfg.HTTPTimeout,
+		Transport: sharedTransport(cfg, false),
+	}
+}
+
+// NewPinned is like New but also requires a configured public key pin when pins are set
+// SECURITY: Pins protect the bot token from interception by a trusted-but-hostile CA or proxy
+func NewPinned(cfg *config.Config) *http.Client {
+	return &http.Client{
+		Timeout:   cfg.HTTPTimeout,
+		Transport: sharedTransport(cfg, true),
+	}
+}
+
+// NewPinnedTimeout is like NewPinned with a different overall request timeout, for long-polling requests
+func NewPinnedTimeout(cfg *config.Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: sharedTransport(cfg, true),
+	}
+}
+
+// DrainAndClose discards what is left of a response body before closing it
+// A connection only goes back to the pool once its body was read to the end
+func DrainAndClose(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, maxDrain))
+	body.Close()
+}
+
+// sharedTransport returns the process-wide transport for the configuration, creating it on first use
+// Clients created for retries, fallbacks and pings then reuse the same warm connections
+func sharedTransport(cfg *config.Config, pinned bool) *http.Transport {
+	key := transportKey{
+		rootCAs:     cfg.TLSRootCAs,
+		minVersion:  cfg.TLSMinVersion,
+		clientCert:  cfg.TLSClientCert,
+		connect:     cfg.ConnectTimeout,
+		idleTimeout: cfg.IdleConnTimeout,
+		http2:       cfg.HTTP2,
+	}
+	if pinned {
+		key.pins = string(bytes.Join(cfg.TLSPins, nil))
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport
+	}
+	tc := tlsConfig(cfg)
+	if pinned && len(cfg.TLSPins) > 0 {
+		tc.VerifyConnection = verifyPins(cfg.TLSPins)
+	}
+	transport := newTransport(tc, cfg)
+	transports[key] = transport
+	return transport
+}
+
+func tlsConfig(cfg *config.Config) *tls.Config {
+	tc := &tls.Config{
+		RootCAs:    cfg.TLSRootCAs, // nil uses the system pool
+		MinVersion: cfg.TLSMinVersion,
+	}
+	if cfg.TLSClientCert != nil {
+		tc.Certificates = []tls.Certificate{*cfg.TLSClientCert}
+	}
+	return tc
+}
+
+// newTransport tunes the default transport's pooling, timeouts and protocol for cfg
+func newTransport(tc *tls.Config, cfg *config.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tc
+	connect := cfg.ConnectTimeout
+	if connect <= 0 {
+		connect = constants.DefaultConnectTimeout
+	}
+	transport.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: tcpKeepAlive}).DialContext
+	transport.TLSHandshakeTimeout = connect
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+
+	// Zero turns keep-alive off for networks where middleboxes silently drop idle connections
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	} else {
+		transport.DisableKeepAlives = true
+	}
+
+	// A non-nil empty map is how net/http is told not to upgrade to HTTP/2
+	if !cfg.HTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// verifyPins accepts a connection when any certificate in a verified chain has a pinned
+// SHA-256 hash of its SubjectPublicKeyInfo; pinning an intermediate survives leaf renewals
+// SECURITY: Only the chains built from the trust roots count; the server can append any certificate,
+// the pinned one included, to the list it sends
+func verifyPins(pins [][]byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				for _, pin := range pins {
+					if bytes.Equal(sum[:], pin) {
+						return nil
+					}
+				}
+			}
+		}
+		return errors.New("certificate pin mismatch: no certificate in the verified chain matches NOTIFIER_TLS_PIN_SHA256")
+	}
+}
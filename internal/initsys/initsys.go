@@ -0,0 +1,182 @@
+// Package initsys reports services of init systems other than systemd, for Alpine (OpenRC), Void (runit),
+// macOS (launchd) and Windows hosts
+// Most don't record why a service stopped or keep its output, so notifications carry the exit status
+// hooks pass on, the service's state and the end of its log file, syslog lines or Event Log entries
+// Lookups only systemd can answer, such as cgroup usage or core dumps, return ErrUnsupported
+package initsys
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logsource"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// ErrUnsupported is returned for lookups the init system can't answer
+var ErrUnsupported = errors.New("not supported by this init system")
+
+// unsupported answers the systemd-only lookups; the providers embed it
+type unsupported struct{}
+
+// GetServiceVersion reports no version, leaving the field out like systemd units without a version source
+func (unsupported) GetServiceVersion(ctx context.Context, serviceName string) (string, error) {
+	return "", nil
+}
+
+func (unsupported) FailedDependencies(ctx context.Context, serviceName string) ([]systemd.UnitStatus, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupported) SystemErrors(ctx context.Context, lines int) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (unsupported) CgroupUsage(ctx context.Context, serviceName string) (systemd.CgroupUsage, error) {
+	return systemd.CgroupUsage{}, ErrUnsupported
+}
+
+func (unsupported) UnitFileHashes(ctx context.Context, serviceName string) (map[string]string, error) {
+	return nil, ErrUnsupported
+}
+
+func (unsupported) ActivationCause(ctx context.Context, serviceName string) (systemd.Activation, error) {
+	return systemd.Activation{}, ErrUnsupported
+}
+
+func (unsupported) CoreDump(ctx context.Context, pid int, since time.Time) (systemd.CoreDump, error) {
+	return systemd.CoreDump{}, ErrUnsupported
+}
+
+func (unsupported) SecurityDenials(ctx context.Context, pid int, since time.Time) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (unsupported) OOMKills(ctx context.Context, serviceName string, pid int, since time.Time) (string, error) {
+	return "", ErrUnsupported
+}
+
+// Commands lists the programs a provider runs, for the sandbox to allow
+func Commands(initSystem string) []string {
+	switch initSystem {
+	case constants.InitSystemOpenRC:
+		return []string{"rc-service"}
+	case constants.InitSystemRunit:
+		return []string{"sv"}
+	case constants.InitSystemLaunchd:
+		return []string{"launchctl", "log"}
+	case constants.InitSystemWindows:
+		return []string{"sc.exe", "schtasks", "wevtutil"}
+	}
+	return nil
+}
+
+// ReadPaths lists the directories a provider reads scripts and logs from, for the sandbox to allow
+func ReadPaths(cfg *config.Config) []string {
+	switch cfg.InitSystem {
+	case constants.InitSystemOpenRC:
+		return []string{openRCScriptDir, openRCConfDir, "/var/log"}
+	case constants.InitSystemRunit:
+		return append(serviceDirs(), "/var/log")
+	}
+	return nil
+}
+
+// scriptName strips the ".service" suffix unit name normalization adds, giving the name the init system knows
+func scriptName(serviceName string) string {
+	return strings.TrimSuffix(serviceName, ".service")
+}
+
+// exitInfoFromEnv reads $EXIT_STATUS, which hooks set since most init systems don't pass one on
+// ok is false when it's unset or not a valid exit code
+func exitInfoFromEnv() (systemd.ExitCodeInfo, bool) {
+	code, err := strconv.Atoi(os.Getenv("EXIT_STATUS"))
+	if err != nil || validation.ValidateExitCode(code) != nil {
+		return systemd.ExitCodeInfo{}, false
+	}
+	return exitInfo(code), true
+}
+
+// exitInfo describes a run that ended with the given exit code
+func exitInfo(code int) systemd.ExitCodeInfo {
+	return systemd.ExitCodeInfo{
+		ProcessExitCode: code,
+		ServiceSuccess:  code == 0,
+		ExitStatus:      systemd.GetExitStatusString(code),
+	}
+}
+
+// readOutput reads what a service's own log file gained since the last notification,
+// or else the lines it logged to syslog near the end of the syslog file
+func readOutput(ctx context.Context, cfg *config.Config, name, logFile string) (systemd.CommandOutput, error) {
+	var output string
+	var err error
+	if logFile != "" {
+		output, err = logsource.NewFile(logFile, cfg.StateDir, constants.MaxStdinSize).Read(ctx)
+	} else {
+		output, err = syslogLines(cfg.SyslogFile, name, constants.MaxStdinSize)
+	}
+	if err != nil {
+		return systemd.CommandOutput{}, validation.FilterSecretsFromError(err)
+	}
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	return systemd.CommandOutput{
+		Markdown: "*Command Output*\n```\n" + markdown.Literal(validation.TruncateMessage(output, cfg.MaxOutputSize)) + "\n```",
+		Full:     output,
+	}, nil
+}
+
+// syslogLines returns the lines tagged "name:" or "name[pid]:" among the last maxBytes of a syslog file
+// The file is shared by every service, so its end is scanned rather than read from a bookmark
+func syslogLines(path, name string, maxBytes int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxBytes {
+		if _, err := f.Seek(info.Size()-maxBytes, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	text := strings.ToValidUTF8(string(content), "�")
+	if info.Size() > maxBytes {
+		// The first line was cut off
+		_, text, _ = strings.Cut(text, "\n")
+	}
+
+	// "Oct 18 02:25:50 host nginx[123]: message"
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		tag := strings.TrimSuffix(fields[4], ":")
+		if tag == name || strings.HasPrefix(tag, name+"[") {
+			_, message, _ := strings.Cut(line, fields[4])
+			lines = append(lines, fields[2]+" "+strings.TrimSpace(message))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,159 @@
+package initsys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// processNamePattern limits the program names put into a unified log predicate
+var processNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Launchd looks jobs up with launchctl print, by their label, e.g. "com.example.backup"
+type Launchd struct {
+	unsupported
+	executor systemd.CommandExecutor
+	config   *config.Config
+}
+
+// NewLaunchd creates a provider for launchd jobs
+func NewLaunchd(executor systemd.CommandExecutor, cfg *config.Config) *Launchd {
+	return &Launchd{executor: executor, config: cfg}
+}
+
+// GetServiceInfo describes the job by the program it runs, since launchd jobs have no description
+func (l *Launchd) GetServiceInfo(ctx context.Context, serviceName string) (systemd.ServiceInfo, error) {
+	job, err := l.print(ctx, serviceName)
+	if err != nil {
+		return systemd.ServiceInfo{}, err
+	}
+	return systemd.ServiceInfo{Name: serviceName, Description: job["program"]}, nil
+}
+
+// GetServiceExitCodeInfo takes the exit status from $EXIT_STATUS, or else from the job's
+// last exit code and terminating signal as launchctl print reports them
+func (l *Launchd) GetServiceExitCodeInfo(ctx context.Context, serviceName string) (systemd.ExitCodeInfo, error) {
+	if info, ok := exitInfoFromEnv(); ok {
+		return info, nil
+	}
+	job, err := l.print(ctx, serviceName)
+	if err != nil {
+		return exitInfo(0), err
+	}
+	// "last terminating signal = Killed: 9"
+	if _, number, ok := strings.Cut(job["last terminating signal"], ": "); ok {
+		if signal, err := strconv.Atoi(number); err == nil {
+			info := exitInfo(signal)
+			info.ServiceSuccess = false
+			info.ExitSignal = "killed"
+			return info, nil
+		}
+	}
+	// "(never exited)" before the first run ends
+	code, err := strconv.Atoi(job["last exit code"])
+	if err != nil || validation.ValidateExitCode(code) != nil {
+		return exitInfo(0), nil
+	}
+	return exitInfo(code), nil
+}
+
+// GetServiceCommandOutput reads what the job's StandardOutPath or StandardErrorPath file gained since
+// the last notification, or else the program's recent unified log entries
+func (l *Launchd) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (systemd.CommandOutput, error) {
+	job, err := l.print(ctx, serviceName)
+	if err != nil {
+		return systemd.CommandOutput{}, err
+	}
+	for _, key := range []string{"stdout path", "stderr path"} {
+		if path := job[key]; filepath.IsAbs(path) && path != os.DevNull {
+			return readOutput(ctx, l.config, scriptName(serviceName), path)
+		}
+	}
+
+	output, err := l.unifiedLog(ctx, filepath.Base(job["program"]))
+	if err != nil {
+		return systemd.CommandOutput{}, err
+	}
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	return systemd.CommandOutput{
+		Markdown: "*Command Output*\n```\n" + markdown.Literal(validation.TruncateMessage(output, l.config.MaxOutputSize)) + "\n```",
+		Full:     output,
+	}, nil
+}
+
+// print reads a job's properties from launchctl print, asking the user's GUI domain before the system one
+// Only the job's own top-level "key = value" lines are kept, not those of nested blocks
+// SECURITY: Validates the name before the label is passed to launchctl
+func (l *Launchd) print(ctx context.Context, serviceName string) (map[string]string, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return nil, validation.FilterSecretsFromError(err)
+	}
+	label := scriptName(serviceName)
+	domains := []string{"system/" + label}
+	if uid := os.Getuid(); uid != 0 {
+		domains = append([]string{"gui/" + strconv.Itoa(uid) + "/" + label}, domains...)
+	}
+
+	var lastErr error
+	for _, target := range domains {
+		output, err := l.executor.Execute(ctx, "launchctl", "print", target)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		job := map[string]string{}
+		depth := 0
+		for _, line := range strings.Split(string(output), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasSuffix(line, "{"):
+				depth++
+			case line == "}":
+				depth--
+			case depth == 1:
+				if key, value, ok := strings.Cut(line, " = "); ok {
+					job[key] = value
+				}
+			}
+		}
+		return job, nil
+	}
+	return nil, validation.FilterSecretsFromError(fmt.Errorf("looking up launchd job %s: %w", label, lastErr))
+}
+
+// unifiedLog returns the program's unified log entries of the last constants.LaunchdLogWindow as "time message" lines
+func (l *Launchd) unifiedLog(ctx context.Context, program string) (string, error) {
+	if !processNamePattern.MatchString(program) {
+		return "", fmt.Errorf("no program to search the unified log for")
+	}
+	window := strconv.Itoa(int(constants.LaunchdLogWindow.Minutes())) + "m"
+	tail, err := l.executor.ExecuteTail(ctx, constants.MaxJournalLines, "log", "show",
+		"--style", "compact", "--last", window, "--predicate", `process == "`+program+`"`)
+	if err != nil {
+		return "", validation.FilterSecretsFromError(fmt.Errorf("reading the unified log: %w", err))
+	}
+
+	// "2026-10-18 01:00:00.123 E  backup.sh[123:4567] message", after a header line
+	var lines []string
+	for _, line := range tail.Lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[3], program+"[") {
+			continue
+		}
+		_, message, _ := strings.Cut(line, fields[3])
+		lines = append(lines, fields[1]+" "+strings.TrimSpace(message))
+	}
+	return strings.Join(lines, "\n"), nil
+}
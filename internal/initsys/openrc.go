@@ -0,0 +1,109 @@
+package initsys
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// Where OpenRC keeps service scripts and their settings
+const (
+	openRCScriptDir = "/etc/init.d"
+	openRCConfDir   = "/etc/conf.d"
+)
+
+// OpenRC looks services up with rc-service and in their /etc/init.d scripts
+type OpenRC struct {
+	unsupported
+	executor systemd.CommandExecutor
+	config   *config.Config
+}
+
+// NewOpenRC creates a provider for OpenRC services
+func NewOpenRC(executor systemd.CommandExecutor, cfg *config.Config) *OpenRC {
+	return &OpenRC{executor: executor, config: cfg}
+}
+
+// GetServiceInfo reads the service's description= from its script or conf.d file
+func (o *OpenRC) GetServiceInfo(ctx context.Context, serviceName string) (systemd.ServiceInfo, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return systemd.ServiceInfo{}, validation.FilterSecretsFromError(err)
+	}
+	info := systemd.ServiceInfo{Name: serviceName, Description: scriptName(serviceName)}
+	if desc := scriptVars(scriptName(serviceName))["description"]; desc != "" {
+		info.Description = desc
+	}
+	return info, nil
+}
+
+// GetServiceExitCodeInfo takes the exit status from $EXIT_STATUS, or else from rc-service status:
+// a crashed service failed, a started or stopped one didn't
+// SECURITY: Validates the service name before it's passed to rc-service
+func (o *OpenRC) GetServiceExitCodeInfo(ctx context.Context, serviceName string) (systemd.ExitCodeInfo, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return exitInfo(0), validation.FilterSecretsFromError(err)
+	}
+	if info, ok := exitInfoFromEnv(); ok {
+		return info, nil
+	}
+	// Status exits non-zero for stopped and crashed services, but still prints the state
+	output, err := o.executor.Execute(ctx, "rc-service", scriptName(serviceName), "status")
+	status := strings.TrimSpace(string(output))
+	switch {
+	case strings.Contains(status, "crashed"):
+		return exitInfo(1), nil
+	case strings.Contains(status, "started"), strings.Contains(status, "stopped"):
+		return exitInfo(0), nil
+	}
+	if err == nil {
+		err = fmt.Errorf("unknown status %q", status)
+	}
+	return exitInfo(0), validation.FilterSecretsFromError(fmt.Errorf("querying service status: %w", err))
+}
+
+// GetServiceCommandOutput reads the service's output_log= or error_log= file, or its syslog lines
+func (o *OpenRC) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (systemd.CommandOutput, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return systemd.CommandOutput{}, validation.FilterSecretsFromError(err)
+	}
+	name := scriptName(serviceName)
+	vars := scriptVars(name)
+	logFile := vars["output_log"]
+	if logFile == "" {
+		logFile = vars["error_log"]
+	}
+	return readOutput(ctx, o.config, name, logFile)
+}
+
+// scriptVars reads the plain assignments of description, output_log and error_log from a service's
+// script and conf.d file, the latter taking precedence like when OpenRC sources them
+// Values that need the shell to expand them are skipped
+func scriptVars(name string) map[string]string {
+	vars := map[string]string{}
+	for _, path := range []string{filepath.Join(openRCScriptDir, name), filepath.Join(openRCConfDir, name)} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+			if !ok || (key != "description" && key != "output_log" && key != "error_log") {
+				continue
+			}
+			value = strings.Trim(value, `"'`)
+			if !strings.ContainsAny(value, "$`") {
+				vars[key] = value
+			}
+		}
+		f.Close()
+	}
+	return vars
+}
@@ -0,0 +1,97 @@
+package initsys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// Runit looks services up with sv and in their service directories
+type Runit struct {
+	unsupported
+	executor systemd.CommandExecutor
+	config   *config.Config
+}
+
+// NewRunit creates a provider for runit services
+func NewRunit(executor systemd.CommandExecutor, cfg *config.Config) *Runit {
+	return &Runit{executor: executor, config: cfg}
+}
+
+// serviceDirs are where runit distributions link enabled services, $SVDIR first like sv
+func serviceDirs() []string {
+	dirs := []string{"/var/service", "/etc/service", "/service", "/run/runit/service"}
+	if svdir := os.Getenv("SVDIR"); filepath.IsAbs(svdir) {
+		dirs = append([]string{svdir}, dirs...)
+	}
+	return dirs
+}
+
+// serviceDir finds the service's directory; empty when no service directory has it
+func serviceDir(name string) string {
+	for _, dir := range serviceDirs() {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.IsDir() {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// GetServiceInfo describes the service by its directory, since runit services have no description
+func (r *Runit) GetServiceInfo(ctx context.Context, serviceName string) (systemd.ServiceInfo, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return systemd.ServiceInfo{}, validation.FilterSecretsFromError(err)
+	}
+	dir := serviceDir(scriptName(serviceName))
+	if dir == "" {
+		return systemd.ServiceInfo{}, fmt.Errorf("service %s not found", scriptName(serviceName))
+	}
+	return systemd.ServiceInfo{Name: serviceName, Description: dir}, nil
+}
+
+// GetServiceExitCodeInfo takes the exit status from $EXIT_STATUS, which the service's finish script
+// can set from its first argument, or else from sv status: a service that's down but normally up failed
+// SECURITY: Validates the service name before it's passed to sv
+func (r *Runit) GetServiceExitCodeInfo(ctx context.Context, serviceName string) (systemd.ExitCodeInfo, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return exitInfo(0), validation.FilterSecretsFromError(err)
+	}
+	if info, ok := exitInfoFromEnv(); ok {
+		return info, nil
+	}
+	// "down: /var/service/app: 3s, normally up; run: log: (pid 120) 100s"
+	output, err := r.executor.Execute(ctx, "sv", "status", scriptName(serviceName))
+	if err != nil {
+		return exitInfo(0), validation.FilterSecretsFromError(fmt.Errorf("querying service status: %w", err))
+	}
+	status, _, _ := strings.Cut(string(output), ";")
+	if strings.HasPrefix(status, "down:") && strings.Contains(status, "normally up") {
+		return exitInfo(1), nil
+	}
+	return exitInfo(0), nil
+}
+
+// GetServiceCommandOutput reads what svlogd wrote to the service's current log, or its syslog lines
+// Void's services log to syslog through vlogger; Debian's and others' svlogd write /var/log/<name>/current
+func (r *Runit) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (systemd.CommandOutput, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return systemd.CommandOutput{}, validation.FilterSecretsFromError(err)
+	}
+	name := scriptName(serviceName)
+	candidates := []string{filepath.Join("/var/log", name, "current"), filepath.Join("/var/log/runit", name, "current")}
+	if dir := serviceDir(name); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "log/main/current"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return readOutput(ctx, r.config, name, path)
+		}
+	}
+	return readOutput(ctx, r.config, name, "")
+}
@@ -0,0 +1,293 @@
+package initsys
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"telegram-notifier/internal/config"
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/validation"
+)
+
+// Status codes a service or scheduled task commonly ends with, as Windows names them
+var windowsStatusNames = map[uint32]string{
+	0: "SUCCESS", 1: "ERROR_INVALID_FUNCTION", 2: "ERROR_FILE_NOT_FOUND", 3: "ERROR_PATH_NOT_FOUND",
+	5: "ERROR_ACCESS_DENIED", 267: "ERROR_DIRECTORY", 1053: "ERROR_SERVICE_REQUEST_TIMEOUT",
+	1067: "ERROR_PROCESS_ABORTED", 1069: "ERROR_SERVICE_LOGON_FAILED", 1077: "ERROR_SERVICE_NEVER_STARTED",
+	0x41301: "SCHED_S_TASK_RUNNING", 0x41303: "SCHED_S_TASK_HAS_NOT_RUN", 0x8004131F: "SCHED_E_ALREADY_RUNNING",
+	0x800710E0: "ERROR_OPERATOR_REFUSED", 0xC0000005: "STATUS_ACCESS_VIOLATION", 0xC0000017: "STATUS_NO_MEMORY",
+	0xC000013A: "STATUS_CONTROL_C_EXIT", 0xC0000409: "STATUS_STACK_BUFFER_OVERRUN",
+}
+
+// errorServiceSpecific is the Win32 exit code of a service that reported its own exit code instead
+const errorServiceSpecific = 1066
+
+// Windows looks services up in the service control manager with sc.exe, and scheduled tasks in the
+// Task Scheduler's root folder with schtasks when no service has the name
+type Windows struct {
+	unsupported
+	executor systemd.CommandExecutor
+	config   *config.Config
+}
+
+// NewWindows creates a provider for Windows services and scheduled tasks
+func NewWindows(executor systemd.CommandExecutor, cfg *config.Config) *Windows {
+	return &Windows{executor: executor, config: cfg}
+}
+
+// windowsJob is what sc.exe or schtasks reported about a service or scheduled task
+type windowsJob struct {
+	name   string            // Service or task name, without the ".service" suffix
+	task   bool              // A scheduled task rather than a service
+	fields map[string]string // "KEY : value" or "Key: value" lines, the first of each key
+}
+
+// GetServiceInfo describes a service by its display name and a task by its comment or the program it runs
+func (w *Windows) GetServiceInfo(ctx context.Context, serviceName string) (systemd.ServiceInfo, error) {
+	job, err := w.lookup(ctx, serviceName)
+	if err != nil {
+		return systemd.ServiceInfo{}, err
+	}
+	description := job.fields["DISPLAY_NAME"]
+	if job.task {
+		description = job.fields["Comment"]
+		if description == "" || description == "N/A" {
+			description = job.fields["Task To Run"]
+		}
+	}
+	return systemd.ServiceInfo{Name: serviceName, Description: description}, nil
+}
+
+// GetServiceExitCodeInfo takes the exit status from $EXIT_STATUS, or else from the service's Win32 or
+// service-specific exit code as the service control manager reports it, or from the task's last result
+func (w *Windows) GetServiceExitCodeInfo(ctx context.Context, serviceName string) (systemd.ExitCodeInfo, error) {
+	if info, ok := exitInfoFromEnv(); ok {
+		return info, nil
+	}
+	job, err := w.lookup(ctx, serviceName)
+	if err != nil {
+		return exitInfo(0), err
+	}
+
+	if job.task {
+		// schtasks prints the HRESULT as a signed decimal number
+		result, err := strconv.ParseInt(job.fields["Last Result"], 10, 64)
+		if err != nil {
+			return exitInfo(0), nil
+		}
+		status := uint32(result)
+		if status == 0x41301 || status == 0x41303 {
+			info := exitInfo(0)
+			info.ExitStatus = windowsStatusString(status)
+			return info, nil
+		}
+		return windowsExitInfo(status, windowsStatusString(status)), nil
+	}
+
+	// "WIN32_EXIT_CODE    : 1066  (0x42a)"
+	win32, err := strconv.ParseUint(firstField(job.fields["WIN32_EXIT_CODE"]), 10, 32)
+	if err != nil {
+		return exitInfo(0), nil
+	}
+	if win32 == errorServiceSpecific {
+		code, err := strconv.ParseUint(firstField(job.fields["SERVICE_EXIT_CODE"]), 10, 32)
+		if err == nil {
+			return windowsExitInfo(uint32(code), strconv.FormatUint(code, 10)+"/SERVICE_SPECIFIC"), nil
+		}
+	}
+	return windowsExitInfo(uint32(win32), windowsStatusString(uint32(win32))), nil
+}
+
+// GetServiceCommandOutput returns the service's recent Event Log entries: the service control manager's
+// messages about it and what it logged to the Application log under its own name
+// For a task, the Task Scheduler's messages about it, which need its operational log enabled
+func (w *Windows) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (systemd.CommandOutput, error) {
+	job, err := w.lookup(ctx, serviceName)
+	if err != nil {
+		return systemd.CommandOutput{}, err
+	}
+
+	var events []windowsEvent
+	if job.task {
+		events, err = w.events(ctx, "Microsoft-Windows-TaskScheduler/Operational", "Microsoft-Windows-TaskScheduler",
+			"EventData[Data[@Name='TaskName']='\\"+job.name+"']")
+	} else {
+		// The service control manager names services by their display name
+		name := job.fields["DISPLAY_NAME"]
+		if name == "" || strings.ContainsRune(name, '\'') {
+			name = job.name
+		}
+		events, err = w.events(ctx, "System", "Service Control Manager", "EventData[Data='"+name+"']")
+		if err == nil {
+			var own []windowsEvent
+			own, err = w.events(ctx, "Application", job.name, "")
+			events = append(events, own...)
+		}
+	}
+	if err != nil {
+		return systemd.CommandOutput{}, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].date < events[j].date })
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		lines = append(lines, event.String())
+	}
+	output := strings.Join(lines, "\n")
+	if strings.TrimSpace(output) == "" {
+		output = "(no output)"
+	}
+	return systemd.CommandOutput{
+		Markdown: "*Command Output*\n```\n" + markdown.Literal(validation.TruncateMessage(output, w.config.MaxOutputSize)) + "\n```",
+		Full:     output,
+	}, nil
+}
+
+// lookup reads a service's configuration and status from sc.exe, or a scheduled task's from schtasks
+// when sc.exe knows no service by that name
+// SECURITY: Validates the name, which leaves only letters, digits and ":_.-" to pass to the commands and XPath queries
+func (w *Windows) lookup(ctx context.Context, serviceName string) (windowsJob, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return windowsJob{}, validation.FilterSecretsFromError(err)
+	}
+	job := windowsJob{name: scriptName(serviceName), fields: map[string]string{}}
+
+	qc, err := w.executor.Execute(ctx, "sc.exe", "qc", job.name)
+	if err == nil {
+		var status []byte
+		status, err = w.executor.Execute(ctx, "sc.exe", "queryex", job.name)
+		if err != nil {
+			return windowsJob{}, validation.FilterSecretsFromError(fmt.Errorf("querying service %s: %w", job.name, err))
+		}
+		parseWindowsFields(job.fields, string(qc)+"\n"+string(status))
+		return job, nil
+	}
+
+	output, taskErr := w.executor.Execute(ctx, "schtasks", "/query", "/tn", job.name, "/v", "/fo", "list")
+	if taskErr != nil {
+		return windowsJob{}, validation.FilterSecretsFromError(fmt.Errorf("no service or scheduled task %s: %w", job.name, err))
+	}
+	job.task = true
+	parseWindowsFields(job.fields, string(output))
+	return job, nil
+}
+
+// parseWindowsFields adds the "key: value" lines of sc.exe and schtasks output to fields, keeping the first of each key
+// schtasks repeats a task's settings for each of its triggers
+func parseWindowsFields(fields map[string]string, output string) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if _, seen := fields[key]; !seen {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+}
+
+// windowsEvent is an Event Log entry
+type windowsEvent struct {
+	date        string // "2026-10-18T01:00:00.1230000Z", which sorts in time order
+	level       string
+	description string
+}
+
+// String renders the entry as "time [level: ]message", leaving out the level of informational entries
+func (e windowsEvent) String() string {
+	clock := e.date
+	if _, after, ok := strings.Cut(clock, "T"); ok {
+		clock = after
+	}
+	clock, _, _ = strings.Cut(clock, ".")
+	if e.level == "" || e.level == "Information" {
+		return clock + " " + e.description
+	}
+	return clock + " " + e.level + ": " + e.description
+}
+
+// events returns a log's entries from a provider within constants.EventLogWindow, at most
+// constants.MaxEventLogEntries of the newest; filter is an extra XPath condition on the event, if any
+func (w *Windows) events(ctx context.Context, logName, provider, filter string) ([]windowsEvent, error) {
+	query := fmt.Sprintf("*[System[Provider[@Name='%s'] and TimeCreated[timediff(@SystemTime) <= %d]]",
+		provider, constants.EventLogWindow.Milliseconds())
+	if filter != "" {
+		query += " and " + filter
+	}
+	query += "]"
+	output, err := w.executor.Execute(ctx, "wevtutil", "qe", logName, "/q:"+query,
+		"/c:"+strconv.Itoa(constants.MaxEventLogEntries), "/rd:true", "/f:text")
+	if err != nil {
+		return nil, validation.FilterSecretsFromError(fmt.Errorf("reading the %s event log: %w", logName, err))
+	}
+	return parseWindowsEvents(string(output)), nil
+}
+
+// parseWindowsEvents reads wevtutil's text format: an "Event[N]:" line, indented "Key: value" lines,
+// then "Description:" followed by the message on unindented lines
+func parseWindowsEvents(output string) []windowsEvent {
+	var events []windowsEvent
+	var event *windowsEvent
+	inDescription := false
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "Event[") {
+			events = append(events, windowsEvent{})
+			event = &events[len(events)-1]
+			inDescription = false
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if inDescription {
+			if line != "" {
+				event.description = strings.TrimSpace(event.description + " " + line)
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, ":")
+		switch key {
+		case "Date":
+			event.date = strings.TrimSpace(value)
+		case "Level":
+			event.level = strings.TrimSpace(value)
+		case "Description":
+			event.description = strings.TrimSpace(value)
+			inDescription = true
+		}
+	}
+	return events
+}
+
+// windowsExitInfo describes a run that ended with a Windows status code; they don't fit the 0-255 range of exit codes
+func windowsExitInfo(code uint32, status string) systemd.ExitCodeInfo {
+	return systemd.ExitCodeInfo{
+		ProcessExitCode: int(code),
+		ServiceSuccess:  code == 0,
+		ExitStatus:      status,
+	}
+}
+
+// windowsStatusString renders a status code like systemd's exit statuses, e.g. "1067/ERROR_PROCESS_ABORTED",
+// or with its hexadecimal form when it has no known name, e.g. "3221225794/0xC0000142"
+func windowsStatusString(code uint32) string {
+	if name, ok := windowsStatusNames[code]; ok {
+		return strconv.FormatUint(uint64(code), 10) + "/" + name
+	}
+	return fmt.Sprintf("%d/0x%08X", code, code)
+}
+
+// firstField returns the first whitespace-separated word of a value, e.g. "1066" of "1066  (0x42a)"
+func firstField(value string) string {
+	if fields := strings.Fields(value); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+// Package keyring keeps the bot token in the freedesktop Secret Service (GNOME Keyring, KWallet)
+// It drives libsecret's secret-tool, so no D-Bus client is linked into the binary
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretTool is the libsecret command-line client
+const secretTool = "secret-tool"
+
+// Item attributes identifying the bot token; secret-tool searches by exact attribute match
+const (
+	attrService  = "service"
+	serviceName  = "telegram-notifier"
+	attrKey      = "key"
+	botTokenKey  = "bot-token"
+	botTokenName = "Telegram notifier bot token"
+)
+
+// ErrNotFound is returned when the keyring holds no bot token
+// Worded without "token" so secret redaction leaves the message readable
+var ErrNotFound = errors.New("the keyring has no entry for the bot")
+
+// attributes returns the lookup attributes of the bot token item
+func attributes() []string {
+	return []string{attrService, serviceName, attrKey, botTokenKey}
+}
+
+// LookupBotToken reads the bot token from the user's default keyring
+func LookupBotToken(ctx context.Context) (string, error) {
+	if _, err := exec.LookPath(secretTool); err != nil {
+		return "", fmt.Errorf("%s not found in PATH (install libsecret-tools)", secretTool)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, secretTool, append([]string{"lookup"}, attributes()...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits 1 without output when nothing matches
+		if stderr.Len() == 0 && stdout.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", toolError("lookup", err, stderr.String())
+	}
+
+	token := strings.TrimRight(stdout.String(), "\r\n")
+	if token == "" {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+// StoreBotToken saves the bot token in the user's default keyring, replacing any previous one
+// SECURITY: The token is passed on stdin; arguments are visible to other users in /proc
+func StoreBotToken(ctx context.Context, token string) error {
+	if _, err := exec.LookPath(secretTool); err != nil {
+		return fmt.Errorf("%s not found in PATH (install libsecret-tools)", secretTool)
+	}
+
+	var stderr bytes.Buffer
+	args := append([]string{"store", "--label=" + botTokenName}, attributes()...)
+	cmd := exec.CommandContext(ctx, secretTool, args...)
+	cmd.Stdin = strings.NewReader(token)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return toolError("store", err, stderr.String())
+	}
+	return nil
+}
+
+// toolError reports a secret-tool failure with its first line of diagnostics
+// The Secret Service is reached over the session bus, so a missing bus is the usual cause
+func toolError(op string, err error, stderr string) error {
+	line, _, _ := strings.Cut(strings.TrimSpace(stderr), "\n")
+	if line == "" {
+		return fmt.Errorf("%s %s failed: %w", secretTool, op, err)
+	}
+	return fmt.Errorf("%s %s failed: %s", secretTool, op, line)
+}
@@ -0,0 +1,238 @@
+// Package kube is a minimal Kubernetes API client for watching Jobs and reading pod logs
+// Only what the job watcher needs is implemented, keeping the notifier free of client libraries
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// In-cluster service account files mounted into every pod
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	inClusterToken    = serviceAccountDir + "/token"
+	inClusterCA       = serviceAccountDir + "/ca.crt"
+	inClusterNS       = serviceAccountDir + "/namespace"
+)
+
+// maxErrorBody bounds how much of a failed response is read for its message
+const maxErrorBody = 64 * 1024
+
+// ErrGone is returned by Watch when the resource version expired and the caller must list again
+var ErrGone = errors.New("watch resource version expired")
+
+// Config locates the API server and holds the credentials for it
+type Config struct {
+	Server    string
+	Namespace string // Default namespace of the context or service account
+	Token     string
+	TokenFile string // Re-read per request; projected service account tokens rotate
+	TLS       *tls.Config
+}
+
+// InCluster reports whether the process runs in a pod with a service account
+func InCluster() bool {
+	_, err := os.Stat(inClusterToken)
+	return os.Getenv("KUBERNETES_SERVICE_HOST") != "" && err == nil
+}
+
+// InClusterConfig builds a config from the pod's service account
+func InClusterConfig() (Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return Config{}, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	caPEM, err := os.ReadFile(inClusterCA)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return Config{}, fmt.Errorf("no certificates in %s", inClusterCA)
+	}
+	namespace, _ := os.ReadFile(inClusterNS)
+
+	return Config{
+		Server:    "https://" + net.JoinHostPort(host, port),
+		Namespace: strings.TrimSpace(string(namespace)),
+		TokenFile: inClusterToken,
+		TLS:       &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+// kubeconfig is the part of "kubectl config view --minify --flatten -o json" the client uses
+type kubeconfig struct {
+	Clusters []struct {
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		User struct {
+			Token                 string          `json:"token"`
+			ClientCertificateData string          `json:"client-certificate-data"`
+			ClientKeyData         string          `json:"client-key-data"`
+			Exec                  json.RawMessage `json:"exec"`
+		} `json:"user"`
+	} `json:"users"`
+	Contexts []struct {
+		Context struct {
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+}
+
+// KubeconfigConfig resolves the current (or given) context of a kubeconfig
+// kubectl does the YAML parsing and merging of KUBECONFIG paths, flattening file references into data
+func KubeconfigConfig(ctx context.Context, path, contextName string) (Config, error) {
+	args := []string{"config", "view", "--raw", "--minify", "--flatten", "-o", "json"}
+	if path != "" {
+		args = append(args, "--kubeconfig", path)
+	}
+	if contextName != "" {
+		args = append(args, "--context", contextName)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return Config{}, fmt.Errorf("kubectl config view: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var kc kubeconfig
+	if err := json.Unmarshal(out, &kc); err != nil {
+		return Config{}, fmt.Errorf("parsing kubeconfig: %w", err)
+	}
+	if len(kc.Clusters) == 0 || len(kc.Users) == 0 {
+		return Config{}, errors.New("kubeconfig has no current context")
+	}
+	cluster, user := kc.Clusters[0].Cluster, kc.Users[0].User
+	if len(user.Exec) > 0 && user.Token == "" && user.ClientCertificateData == "" {
+		return Config{}, errors.New("kubeconfig uses an exec credential plugin, which isn't supported; use a service account token")
+	}
+
+	cfg := Config{
+		Server: cluster.Server,
+		Token:  user.Token,
+		TLS:    &tls.Config{InsecureSkipVerify: cluster.InsecureSkipTLSVerify, MinVersion: tls.VersionTLS12},
+	}
+	if len(kc.Contexts) > 0 {
+		cfg.Namespace = kc.Contexts[0].Context.Namespace
+	}
+	if cluster.CertificateAuthorityData != "" {
+		caPEM, err := base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData)
+		if err != nil {
+			return Config{}, fmt.Errorf("decoding cluster CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return Config{}, errors.New("no certificates in the cluster CA data")
+		}
+		cfg.TLS.RootCAs = pool
+	}
+	if user.ClientCertificateData != "" {
+		certPEM, err := base64.StdEncoding.DecodeString(user.ClientCertificateData)
+		if err != nil {
+			return Config{}, fmt.Errorf("decoding client certificate: %w", err)
+		}
+		keyPEM, err := base64.StdEncoding.DecodeString(user.ClientKeyData)
+		if err != nil {
+			return Config{}, fmt.Errorf("decoding client key: %w", err)
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return Config{}, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.TLS.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Client talks to the API server
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// NewClient returns a client for cfg; requests are bounded by their contexts since watches are long-lived
+func NewClient(cfg Config) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.TLS
+	return &Client{config: cfg, http: &http.Client{Transport: transport}}
+}
+
+// Namespace is the default namespace of the configuration, "default" when none is set
+func (c *Client) Namespace() string {
+	if c.config.Namespace == "" {
+		return "default"
+	}
+	return c.config.Namespace
+}
+
+// get performs an authenticated GET, returning the body of a successful response
+// The caller closes the body
+func (c *Client) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	u := strings.TrimSuffix(c.config.Server, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	bearer := c.config.Token
+	if c.config.TokenFile != "" {
+		data, err := os.ReadFile(c.config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading service account credentials: %w", err)
+		}
+		bearer = strings.TrimSpace(string(data))
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	// Failures carry a Status object explaining them, e.g. missing RBAC permissions
+	var status apiStatus
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(body, &status) == nil && status.Message != "" {
+		if resp.StatusCode == http.StatusGone {
+			return nil, fmt.Errorf("%w: %s", ErrGone, status.Message)
+		}
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, status.Message)
+	}
+	return nil, fmt.Errorf("GET %s: %s", path, resp.Status)
+}
+
+// apiStatus is the Kubernetes Status object returned for failed requests and watch errors
+type apiStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+	Code    int    `json:"code"`
+}
@@ -0,0 +1,282 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchTimeout makes the server end watches periodically so a silently dropped connection is noticed
+const watchTimeout = 5 * time.Minute
+
+// Watch event types
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+	EventBookmark = "BOOKMARK"
+	EventError    = "ERROR"
+)
+
+// ObjectMeta is the metadata shared by all objects
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	UID               string            `json:"uid"`
+	ResourceVersion   string            `json:"resourceVersion"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Labels            map[string]string `json:"labels"`
+	OwnerReferences   []struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"ownerReferences"`
+}
+
+// Condition is a Job status condition
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason"`
+	Message            string    `json:"message"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// Job is a batch/v1 Job
+type Job struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Selector struct {
+			MatchLabels map[string]string `json:"matchLabels"`
+		} `json:"selector"`
+	} `json:"spec"`
+	Status struct {
+		Conditions     []Condition `json:"conditions"`
+		StartTime      *time.Time  `json:"startTime"`
+		CompletionTime *time.Time  `json:"completionTime"`
+		Succeeded      int         `json:"succeeded"`
+		Failed         int         `json:"failed"`
+	} `json:"status"`
+}
+
+// Finished returns the terminal condition of the job: Complete or Failed, or nil while it runs
+func (j Job) Finished() *Condition {
+	for i, c := range j.Status.Conditions {
+		if (c.Type == "Complete" || c.Type == "Failed") && c.Status == "True" {
+			return &j.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// Succeeded reports whether the job finished successfully
+func (j Job) Succeeded() bool {
+	c := j.Finished()
+	return c != nil && c.Type == "Complete"
+}
+
+// Runtime is the time from start to the terminal condition, zero when unknown
+func (j Job) Runtime() time.Duration {
+	c := j.Finished()
+	if c == nil || j.Status.StartTime == nil {
+		return 0
+	}
+	end := c.LastTransitionTime
+	if j.Status.CompletionTime != nil {
+		end = *j.Status.CompletionTime
+	}
+	return end.Sub(*j.Status.StartTime)
+}
+
+// CronJob returns the name of the CronJob that created the job, if any
+func (j Job) CronJob() string {
+	for _, owner := range j.Metadata.OwnerReferences {
+		if owner.Kind == "CronJob" {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+// ContainerState is the terminated state of a container
+type ContainerState struct {
+	Terminated *struct {
+		ExitCode int    `json:"exitCode"`
+		Reason   string `json:"reason"`
+	} `json:"terminated"`
+}
+
+// Pod is a core/v1 Pod, reduced to what identifies a job's failed container
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   struct {
+		Phase             string `json:"phase"`
+		ContainerStatuses []struct {
+			Name  string         `json:"name"`
+			State ContainerState `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// FailedContainer returns the first container that exited non-zero, or the first terminated one
+// The exit code is -1 when no container has terminated
+func (p Pod) FailedContainer() (string, int) {
+	name, code := "", -1
+	for _, cs := range p.Status.ContainerStatuses {
+		t := cs.State.Terminated
+		if t == nil {
+			continue
+		}
+		if t.ExitCode != 0 {
+			return cs.Name, t.ExitCode
+		}
+		if name == "" {
+			name, code = cs.Name, 0
+		}
+	}
+	if name == "" && len(p.Status.ContainerStatuses) > 0 {
+		name = p.Status.ContainerStatuses[0].Name
+	}
+	return name, code
+}
+
+// jobList is a JobList; its resource version starts the watch
+type jobList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []Job `json:"items"`
+}
+
+// jobsPath is the collection path for jobs in namespace, or all namespaces when empty
+func jobsPath(namespace string) string {
+	if namespace == "" {
+		return "/apis/batch/v1/jobs"
+	}
+	return "/apis/batch/v1/namespaces/" + url.PathEscape(namespace) + "/jobs"
+}
+
+// ListJobs returns the jobs matching selector and the resource version to watch from
+func (c *Client) ListJobs(ctx context.Context, namespace, selector string) ([]Job, string, error) {
+	query := url.Values{}
+	if selector != "" {
+		query.Set("labelSelector", selector)
+	}
+	body, err := c.get(ctx, jobsPath(namespace), query)
+	if err != nil {
+		return nil, "", err
+	}
+	defer body.Close()
+
+	var list jobList
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("decoding job list: %w", err)
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// WatchJobs streams job changes after resourceVersion to fn until the server ends the watch
+// Returns the last resource version seen to resume from, and ErrGone when it expired
+func (c *Client) WatchJobs(ctx context.Context, namespace, selector, resourceVersion string, fn func(eventType string, job Job)) (string, error) {
+	query := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"resourceVersion":     {resourceVersion},
+		"timeoutSeconds":      {strconv.Itoa(int(watchTimeout.Seconds()))},
+	}
+	if selector != "" {
+		query.Set("labelSelector", selector)
+	}
+	body, err := c.get(ctx, jobsPath(namespace), query)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err == io.EOF {
+			return resourceVersion, nil
+		} else if err != nil {
+			return resourceVersion, fmt.Errorf("reading watch stream: %w", err)
+		}
+
+		if event.Type == EventError {
+			var status apiStatus
+			json.Unmarshal(event.Object, &status)
+			if status.Code == 410 {
+				return resourceVersion, fmt.Errorf("%w: %s", ErrGone, status.Message)
+			}
+			return resourceVersion, fmt.Errorf("watch error: %s", status.Message)
+		}
+
+		var job Job
+		if err := json.Unmarshal(event.Object, &job); err != nil {
+			return resourceVersion, fmt.Errorf("decoding watch event: %w", err)
+		}
+		resourceVersion = job.Metadata.ResourceVersion
+		if event.Type != EventBookmark {
+			fn(event.Type, job)
+		}
+	}
+}
+
+// JobPods returns the pods created for job, most recent first
+func (c *Client) JobPods(ctx context.Context, job Job) ([]Pod, error) {
+	var selector []string
+	for k, v := range job.Spec.Selector.MatchLabels {
+		selector = append(selector, k+"="+v)
+	}
+	if len(selector) == 0 {
+		selector = append(selector, "job-name="+job.Metadata.Name)
+	}
+	slices.Sort(selector)
+
+	path := "/api/v1/namespaces/" + url.PathEscape(job.Metadata.Namespace) + "/pods"
+	body, err := c.get(ctx, path, url.Values{"labelSelector": {strings.Join(selector, ",")}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var list struct {
+		Items []Pod `json:"items"`
+	}
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decoding pod list: %w", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Metadata.CreationTimestamp.After(list.Items[j].Metadata.CreationTimestamp)
+	})
+	return list.Items, nil
+}
+
+// PodLog returns the last lines of a container's log, reading at most limitBytes
+func (c *Client) PodLog(ctx context.Context, pod Pod, container string, lines, limitBytes int) (string, error) {
+	query := url.Values{
+		"tailLines":  {strconv.Itoa(lines)},
+		"limitBytes": {strconv.Itoa(limitBytes)},
+	}
+	if container != "" {
+		query.Set("container", container)
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(pod.Metadata.Namespace) + "/pods/" + url.PathEscape(pod.Metadata.Name) + "/log"
+	body, err := c.get(ctx, path, query)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, int64(limitBytes)))
+	return string(data), err
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package logging
+
+// StderrIsJournal reports whether stderr is connected to the systemd journal; never on this platform
+func StderrIsJournal() bool {
+	return false
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package logging
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// StderrIsJournal reports whether stderr is connected to the systemd journal
+// systemd sets JOURNAL_STREAM to "<device>:<inode>" of the stream it attached;
+// comparing with stderr avoids trusting a variable inherited by a redirected child
+func StderrIsJournal() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return stream == fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/validation"
+)
+
+// Attribute keys shared by all log records so output can be filtered consistently
+const (
+	KeyService      = "service"
+	KeyInvocationID = "invocation_id"
+	KeyAttempt      = "attempt"
+	KeyDuration     = "duration"
+	KeyError        = "error"
+)
+
+// Options configures the process-wide logger
+type Options struct {
+	Format         string    // constants.LogFormatText (default) or constants.LogFormatJSON
+	Debug          bool      // Include debug records (--verbose / NOTIFIER_DEBUG)
+	PriorityPrefix bool      // Prefix lines with syslog priorities (<3>) for journald
+	Output         io.Writer // Defaults to stderr
+}
+
+// level is shared by all handlers so Setup can raise or lower verbosity in place
+var level slog.LevelVar
+
+// Setup installs the default slog logger
+// SECURITY: All records pass through secret filtering before being written
+func Setup(opts Options) {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+
+	if opts.Debug {
+		level.Set(slog.LevelDebug)
+	} else {
+		level.Set(slog.LevelInfo)
+	}
+
+	var pw *priorityWriter
+	if opts.PriorityPrefix {
+		pw = &priorityWriter{w: out}
+		out = pw
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: &level}
+	var handler slog.Handler
+	if opts.Format == constants.LogFormatJSON {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	}
+	if pw != nil {
+		handler = &priorityHandler{Handler: handler, writer: pw}
+	}
+
+	slog.SetDefault(slog.New(&redactingHandler{Handler: handler}))
+}
+
+// DebugEnabled reports whether debug records are written
+func DebugEnabled() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+// Fatal logs an error and exits with status 1
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Err returns a sanitized error attribute
+func Err(err error) slog.Attr {
+	return slog.String(KeyError, validation.SanitizeErrorMessage(err))
+}
+
+// redactingHandler filters secrets from messages and string attributes
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	filtered := slog.NewRecord(r.Time, r.Level, validation.FilterSecrets(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		filtered.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, filtered)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactingHandler{Handler: h.Handler.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redactAttr filters secrets from string values, including nested groups
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(validation.FilterSecrets(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(validation.SanitizeErrorMessage(err))
+		} else {
+			a.Value = slog.StringValue(validation.FilterSecrets(fmt.Sprint(a.Value.Any())))
+		}
+	}
+	return a
+}
+
+// priorityWriter prefixes each write with the priority of the record being handled
+// journald parses a leading "<N>" as the syslog priority of the line
+type priorityWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string
+}
+
+func (p *priorityWriter) Write(b []byte) (int, error) {
+	// Single write so the prefix and line can't be split by other stderr writers
+	if _, err := p.w.Write(append([]byte(p.prefix), b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// priorityHandler sets the writer prefix for each record
+// The built-in handlers issue a single Write per record, so the prefix lands at line start
+type priorityHandler struct {
+	slog.Handler
+	writer *priorityWriter
+}
+
+func (h *priorityHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.writer.mu.Lock()
+	defer h.writer.mu.Unlock()
+	h.writer.prefix = syslogPriority(r.Level)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *priorityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &priorityHandler{Handler: h.Handler.WithAttrs(attrs), writer: h.writer}
+}
+
+func (h *priorityHandler) WithGroup(name string) slog.Handler {
+	return &priorityHandler{Handler: h.Handler.WithGroup(name), writer: h.writer}
+}
+
+// syslogPriority maps slog levels to syslog priority prefixes
+func syslogPriority(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "<3>"
+	case l >= slog.LevelWarn:
+		return "<4>"
+	case l >= slog.LevelInfo:
+		return "<6>"
+	default:
+		return "<7>"
+	}
+}
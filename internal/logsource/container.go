@@ -0,0 +1,216 @@
+package logsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/constants"
+)
+
+// Container reads a container's logs through the Docker Engine API on a unix socket, which Podman serves too
+// Like File, each read returns what was logged since the previous one; the first goes back constants.ContainerLogLookback
+type Container struct {
+	socket    string
+	container string
+	stateDir  string
+	maxBytes  int
+	client    *http.Client
+}
+
+// containerBookmark is when the previous read of a container's logs started
+type containerBookmark struct {
+	Since time.Time `json:"since"`
+}
+
+// NewContainer creates a source for the logs of a container, by name or ID, keeping read times under stateDir
+func NewContainer(socket, container, stateDir string, maxBytes int) *Container {
+	var dialer net.Dialer
+	return &Container{
+		socket:    socket,
+		container: container,
+		stateDir:  stateDir,
+		maxBytes:  maxBytes,
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// Location names the command showing the container's full logs, for "full output" hints in notifications
+func (c *Container) Location() string {
+	if strings.Contains(c.socket, "podman") {
+		return "podman logs " + c.container
+	}
+	return "docker logs " + c.container
+}
+
+// Read returns the container's stdout and stderr, interleaved as logged, since the last read
+// At most constants.MaxJournalLines lines and the last maxBytes are kept
+func (c *Container) Read(ctx context.Context) (string, error) {
+	start := time.Now()
+	since := start.Add(-constants.ContainerLogLookback)
+	if b, ok := c.loadBookmark(); ok && b.Since.After(since) {
+		since = b.Since
+	}
+
+	// Containers without a TTY have their streams multiplexed into frames
+	var inspect struct {
+		Config struct {
+			Tty bool
+		}
+	}
+	body, err := c.get(ctx, "/containers/"+url.PathEscape(c.container)+"/json", nil)
+	if err != nil {
+		return "", err
+	}
+	err = json.NewDecoder(io.LimitReader(body, int64(c.maxBytes))).Decode(&inspect)
+	body.Close()
+	if err != nil {
+		return "", fmt.Errorf("inspecting container %s: %w", c.container, err)
+	}
+
+	query := url.Values{
+		"stdout": {"1"},
+		"stderr": {"1"},
+		"since":  {fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond())},
+		"tail":   {strconv.Itoa(constants.MaxJournalLines)},
+	}
+	body, err = c.get(ctx, "/containers/"+url.PathEscape(c.container)+"/logs", query)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	var output []byte
+	if inspect.Config.Tty {
+		output, err = readTail(body, c.maxBytes)
+	} else {
+		output, err = readFrames(body, c.maxBytes)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading logs of container %s: %w", c.container, err)
+	}
+
+	if err := saveJSON(c.bookmarkPath(), containerBookmark{Since: start}); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(strings.ToValidUTF8(string(output), "�"), "\n"), nil
+}
+
+// get requests an API path, turning error responses into errors with the daemon's message
+func (c *Container) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	u := url.URL{Scheme: "http", Host: "docker", Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("container API at %s: %w", c.socket, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		return nil, fmt.Errorf("container API: %s", apiErr.Message)
+	}
+	return resp.Body, nil
+}
+
+// readFrames reads multiplexed log frames, an 8-byte header (stream, 3 zero bytes, big-endian size) followed by
+// the payload, keeping the last maxBytes of the payloads
+func readFrames(r io.Reader, maxBytes int) ([]byte, error) {
+	var buf []byte
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return keepTail(buf, maxBytes), nil
+			}
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if size > int64(maxBytes) {
+			// Only the end of an oversized frame can be kept
+			if _, err := io.CopyN(io.Discard, r, size-int64(maxBytes)); err != nil {
+				return nil, err
+			}
+			size = int64(maxBytes)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		buf = trimTail(append(buf, payload...), maxBytes)
+	}
+}
+
+// readTail reads a raw stream to its end, keeping the last maxBytes
+func readTail(r io.Reader, maxBytes int) ([]byte, error) {
+	var buf []byte
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		buf = trimTail(append(buf, chunk[:n]...), maxBytes)
+		if errors.Is(err, io.EOF) {
+			return keepTail(buf, maxBytes), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// trimTail drops all but the last maxBytes once buf has grown to twice that, so it isn't copied on every append
+func trimTail(buf []byte, maxBytes int) []byte {
+	if len(buf) > 2*maxBytes {
+		return append(buf[:0], buf[len(buf)-maxBytes:]...)
+	}
+	return buf
+}
+
+// keepTail returns the last maxBytes of buf
+func keepTail(buf []byte, maxBytes int) []byte {
+	if len(buf) > maxBytes {
+		return buf[len(buf)-maxBytes:]
+	}
+	return buf
+}
+
+// bookmarkPath keys read times by the socket and container, next to the log files' read positions
+func (c *Container) bookmarkPath() string {
+	sum := sha256.Sum256([]byte("container:" + c.socket + ":" + c.container))
+	return filepath.Join(c.stateDir, bookmarkDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (c *Container) loadBookmark() (containerBookmark, bool) {
+	data, err := os.ReadFile(c.bookmarkPath())
+	if err != nil {
+		return containerBookmark{}, false
+	}
+	var b containerBookmark
+	if json.Unmarshal(data, &b) != nil {
+		return containerBookmark{}, false
+	}
+	return b, true
+}
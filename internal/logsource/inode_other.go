@@ -0,0 +1,10 @@
+//go:build !unix
+
+package logsource
+
+import "os"
+
+// inode is unavailable here; rotation is detected by the file shrinking instead
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package logsource
+
+import (
+	"os"
+	"syscall"
+)
+
+// inode identifies the file behind a path, which changes when logrotate moves it away
+func inode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
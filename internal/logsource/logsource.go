@@ -0,0 +1,217 @@
+// Package logsource reads a unit's output from its own log file, or from the container it runs, instead of the journal
+// It serves minimal containers without journald, users without journal access and containerized workloads
+package logsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bookmarkDir holds per-file read positions under the state directory
+const bookmarkDir = "logfiles"
+
+// compressedSuffix matches rotated copies that were compressed and can't be read as text
+var compressedSuffix = regexp.MustCompile(`\.(gz|xz|zst|bz2)$`)
+
+// File reads what was appended to a log file since the previous notification
+// The read position survives rotation: when the file was replaced or truncated, the rest of
+// the rotated file ("app.log.1" or "app.log-20240115") is read before the new one
+type File struct {
+	path     string
+	stateDir string
+	maxBytes int64 // Only the last maxBytes are kept, like journal output
+}
+
+// bookmark is where the previous read of a file stopped
+type bookmark struct {
+	Inode  uint64 `json:"inode"` // Zero where the platform has no inodes; rotation is then detected by size only
+	Offset int64  `json:"offset"`
+}
+
+// NewFile creates a source for the log at path, keeping read positions under stateDir
+func NewFile(path, stateDir string, maxBytes int) *File {
+	return &File{path: path, stateDir: stateDir, maxBytes: int64(maxBytes)}
+}
+
+// Location names the file for "full output" hints in notifications
+func (f *File) Location() string {
+	return f.path
+}
+
+// Read returns the output appended since the last read; the first read returns the file's tail
+func (f *File) Read(ctx context.Context) (string, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	current := bookmark{Inode: inode(info), Offset: info.Size()}
+
+	prev, known := f.loadBookmark()
+	var parts []string
+	start := max(0, info.Size()-f.maxBytes)
+	if known {
+		sameFile := prev.Inode == current.Inode && prev.Offset <= info.Size()
+		if sameFile {
+			start = prev.Offset
+		} else {
+			// Rotated or truncated: the lines after the bookmark ended up in the rotated file
+			// With copytruncate the inode stays and the copy is the newest rotated file
+			copied := prev.Inode == current.Inode
+			if rotated := f.findRotated(prev.Inode, copied); rotated != "" {
+				if text, err := readRange(rotated, prev.Offset, f.maxBytes); err == nil && text != "" {
+					parts = append(parts, text)
+				}
+			}
+			start = 0
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	text, err := readFrom(file, start, info.Size(), f.maxBytes)
+	if err != nil {
+		return "", err
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+
+	if err := f.saveBookmark(current); err != nil {
+		return "", err
+	}
+	output := strings.Join(parts, "\n")
+	if int64(len(output)) > f.maxBytes {
+		output = output[int64(len(output))-f.maxBytes:]
+	}
+	return strings.TrimRight(output, "\n"), nil
+}
+
+// findRotated returns the rotated copy of the file with the given inode, or the newest one
+// when inodes aren't available or newest is set; compressed copies can't be read and are skipped
+func (f *File) findRotated(ino uint64, newest bool) string {
+	numbered, _ := filepath.Glob(f.path + ".*")
+	dated, _ := filepath.Glob(f.path + "-*")
+	var plain []string
+	for _, c := range append(numbered, dated...) {
+		if !compressedSuffix.MatchString(c) {
+			plain = append(plain, c)
+		}
+	}
+
+	type rotatedFile struct {
+		path string
+		info os.FileInfo
+	}
+	var files []rotatedFile
+	for _, c := range plain {
+		info, err := os.Stat(c)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if ino != 0 && !newest && inode(info) == ino {
+			return c
+		}
+		files = append(files, rotatedFile{c, info})
+	}
+	if (ino != 0 && !newest) || len(files) == 0 {
+		return ""
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().After(files[j].info.ModTime()) })
+	return files[0].path
+}
+
+// readRange reads a file from offset to its end, keeping at most maxBytes of the tail
+func readRange(path string, offset, maxBytes int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if offset > info.Size() {
+		return "", nil
+	}
+	return readFrom(file, offset, info.Size(), maxBytes)
+}
+
+// readFrom reads [start, end) of file, skipping ahead so at most maxBytes are read
+func readFrom(file *os.File, start, end, maxBytes int64) (string, error) {
+	start = max(start, end-maxBytes)
+	if start >= end {
+		return "", nil
+	}
+	data := make([]byte, end-start)
+	n, err := file.ReadAt(data, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data[:n]), "�"), nil
+}
+
+// bookmarkPath keys read positions by the log path's hash, so each file has its own state
+func (f *File) bookmarkPath() string {
+	sum := sha256.Sum256([]byte(f.path))
+	return filepath.Join(f.stateDir, bookmarkDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+func (f *File) loadBookmark() (bookmark, bool) {
+	data, err := os.ReadFile(f.bookmarkPath())
+	if err != nil {
+		return bookmark{}, false
+	}
+	var b bookmark
+	if json.Unmarshal(data, &b) != nil {
+		return bookmark{}, false
+	}
+	return b, true
+}
+
+// saveBookmark writes the read position atomically via temp file and rename
+func (f *File) saveBookmark(b bookmark) error {
+	return saveJSON(f.bookmarkPath(), b)
+}
+
+// saveJSON writes v to path atomically via temp file and rename
+func saveJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,146 @@
+package markdown
+
+import "strings"
+
+// Entity is a formatted range of a message, as Telegram's "entities" parameter takes it
+// Offsets and lengths are in UTF-16 code units of the plain text
+type Entity struct {
+	Type     string `json:"type"` // bold, italic, code, pre or text_link
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`      // text_link only
+	Language string `json:"language,omitempty"` // pre only
+}
+
+// Entities parses legacy Markdown the way Telegram does and returns the plain text with the ranges it formats
+// Sending the result with the entities parameter instead of parse_mode leaves nothing for Telegram to parse,
+// so a marker left unmatched by log output shows literally instead of failing the whole message
+func Entities(s string) (string, []Entity) {
+	text, entities, _ := parse(strings.ToValidUTF8(s, "�"))
+	return text, entities
+}
+
+// span is the byte range of an escape or entity in Markdown source, markers included
+// For entities, body is where the formatted text starts and ends
+type span struct {
+	from, to         int
+	bodyFrom, bodyTo int
+	pre              bool
+}
+
+// parse is Entities on valid UTF-8, also returning the spans of the escapes and entities it found
+func parse(s string) (string, []Entity, []span) {
+	var (
+		b        strings.Builder
+		entities []Entity
+		spans    []span
+		offset   int // UTF-16 length of b
+	)
+	write := func(text string) {
+		b.WriteString(text)
+		offset += utf16Len(text)
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && isMarker(s[i+1]) {
+			spans = append(spans, span{from: i, to: i + 2, bodyFrom: i + 1, bodyTo: i + 2})
+			i++
+			write(s[i : i+1])
+			continue
+		}
+		if !isMarker(c) {
+			// Markers are ASCII, so runs between them are whole runes
+			n := strings.IndexAny(s[i+1:], "\\_*`[")
+			if n < 0 {
+				n = len(s) - i - 1
+			}
+			write(s[i : i+1+n])
+			i += n
+			continue
+		}
+
+		// Entities don't nest and escapes don't apply inside them, so the body runs to the next end marker
+		marker, start, end := s[i:i+1], i+1, s[i:i+1]
+		var entity Entity
+		switch c {
+		case '`':
+			entity.Type = "code"
+			if strings.HasPrefix(s[i:], "```") {
+				marker, start, end = "```", i+3, "```"
+				entity.Type = "pre"
+				if n := strings.IndexAny(s[start:], " \t\r\n`"); n > 0 && s[start+n] != '`' {
+					entity.Language, start = s[start:start+n], start+n
+				}
+				// A line break right after the opening fence isn't part of the block
+				switch {
+				case strings.HasPrefix(s[start:], "\r\n"), strings.HasPrefix(s[start:], "\n\r"):
+					start += 2
+				case strings.HasPrefix(s[start:], "\n"), strings.HasPrefix(s[start:], "\r"):
+					start++
+				}
+			}
+		case '*':
+			entity.Type = "bold"
+		case '_':
+			entity.Type = "italic"
+		case '[':
+			entity.Type, end = "text_link", "]"
+		}
+
+		n := strings.Index(s[start:], end)
+		if n < 0 {
+			// Telegram would reject the message; showing the marker is the point of sending entities
+			write(marker)
+			i += len(marker) - 1
+			continue
+		}
+		body := s[start : start+n]
+		from := i
+		i = start + n + len(end) - 1
+
+		if entity.Type == "text_link" {
+			// "[text]" without a URL is just its text
+			entity.Type = ""
+			if strings.HasPrefix(s[i+1:], "(") {
+				if paren := strings.IndexByte(s[i+2:], ')'); paren >= 0 {
+					if paren > 0 {
+						entity.Type, entity.URL = "text_link", s[i+2:i+2+paren]
+					}
+					i += paren + 2
+				}
+			}
+		}
+
+		if entity.Type != "" && body != "" {
+			entity.Offset, entity.Length = offset, utf16Len(body)
+			entities = append(entities, entity)
+		}
+		spans = append(spans, span{from: from, to: i + 1, bodyFrom: start, bodyTo: start + n, pre: entity.Type == "pre"})
+		write(body)
+	}
+	return b.String(), entities, spans
+}
+
+// Plain returns the text legacy Markdown displays, without its formatting
+func Plain(s string) string {
+	text, _ := Entities(s)
+	return text
+}
+
+// isMarker reports whether c starts an entity in legacy Markdown
+func isMarker(c byte) bool {
+	return c == '_' || c == '*' || c == '`' || c == '['
+}
+
+// utf16Len counts UTF-16 code units, the unit of entity offsets
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
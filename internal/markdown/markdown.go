@@ -0,0 +1,23 @@
+// Package markdown makes untrusted text safe to interpolate into Telegram's legacy Markdown parse mode
+// An unmatched "_", "*" or "`" from a description or log line makes Telegram reject the whole message
+package markdown
+
+import "strings"
+
+// escaper backslash-escapes the characters legacy Markdown treats as entity markers
+var escaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// Escape makes s display literally outside code spans and blocks
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// Code wraps s in a code span; backticks can't be escaped inside one, so they become quotes
+func Code(s string) string {
+	return "`" + Literal(s) + "`"
+}
+
+// Literal prepares text for a code span or ``` block the caller writes, where a backtick would end it early
+func Literal(s string) string {
+	return strings.ReplaceAll(s, "`", "'")
+}
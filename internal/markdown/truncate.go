@@ -0,0 +1,58 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"telegram-notifier/internal/constants"
+)
+
+// reopenFence starts the ``` block a cut went through again
+const reopenFence = "```\n"
+
+// TruncateTail keeps the end of Markdown s within maxSize UTF-16 code units, behind the truncation marker
+// The cut never splits an escape from its character or an entity from its markers: one it would cross is
+// dropped whole, except a ``` block, which is reopened so the output it holds keeps its latest lines
+func TruncateTail(s string, maxSize int) string {
+	s = strings.ToValidUTF8(s, "�")
+	if utf16Len(s) <= maxSize {
+		return s
+	}
+	marker := constants.OutputTruncatedMsg
+	available := maxSize - utf16Len(marker) - utf16Len(reopenFence)
+	if available <= 0 {
+		return ""
+	}
+
+	start := fitSuffix(s, available)
+	_, _, spans := parse(s)
+	for _, sp := range spans {
+		if start <= sp.from || start >= sp.to {
+			continue
+		}
+		if sp.pre && start < sp.bodyTo {
+			return marker + reopenFence + strings.TrimPrefix(s[max(start, sp.bodyFrom):], "\n")
+		}
+		start = sp.to
+		break
+	}
+	return marker + s[start:]
+}
+
+// fitSuffix returns the byte offset of the longest run of whole runes at the end of s within maxSize UTF-16 code units
+func fitSuffix(s string, maxSize int) int {
+	start, size := len(s), 0
+	for start > 0 {
+		r, n := utf8.DecodeLastRuneInString(s[:start])
+		w := 1
+		if r >= 0x10000 {
+			w = 2
+		}
+		if size+w > maxSize {
+			break
+		}
+		size += w
+		start -= n
+	}
+	return start
+}
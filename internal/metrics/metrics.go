@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric name prefix shared by all exported series
+const namespace = "telegram_notifier"
+
+// latencyBuckets are histogram upper bounds in seconds
+// Deliveries include retries with backoff, so the range extends well past a single request
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Observation describes one delivery attempt as seen by the notifier
+type Observation struct {
+	Service       string
+	Result        string // history result: delivered, queued, spooled or failed
+	Backend       string
+	Attempts      int
+	Latency       time.Duration
+	RateLimitWait time.Duration
+}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// gauge is sampled when metrics are scraped
+type gauge struct {
+	name, help string
+	value      func() float64
+}
+
+// resultKey labels notification counters
+type resultKey struct {
+	service, result string
+}
+
+// Collector aggregates delivery metrics in memory and renders them in the
+// Prometheus text exposition format
+type Collector struct {
+	mu                   sync.Mutex
+	notifications        map[resultKey]uint64
+	latency              map[string]*histogram // by backend
+	retries              uint64
+	rateLimitWaits       uint64
+	rateLimitWaitSeconds float64
+	gauges               []gauge
+}
+
+// New creates an empty collector
+func New() *Collector {
+	return &Collector{
+		notifications: make(map[resultKey]uint64),
+		latency:       make(map[string]*histogram),
+	}
+}
+
+// Observe records the outcome of a delivery attempt
+func (c *Collector) Observe(obs Observation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.notifications[resultKey{obs.Service, obs.Result}]++
+
+	// Queued notifications haven't been sent yet, so there's no delivery to time
+	if obs.Attempts == 0 {
+		return
+	}
+
+	backend := obs.Backend
+	if backend == "" {
+		backend = "none"
+	}
+	h, ok := c.latency[backend]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		c.latency[backend] = h
+	}
+	h.observe(obs.Latency.Seconds())
+
+	if obs.Attempts > 1 {
+		c.retries += uint64(obs.Attempts - 1)
+	}
+	if obs.RateLimitWait > time.Millisecond {
+		c.rateLimitWaits++
+		c.rateLimitWaitSeconds += obs.RateLimitWait.Seconds()
+	}
+}
+
+// RegisterGauge adds a gauge whose value is read at scrape time
+func (c *Collector) RegisterGauge(name, help string, value func() float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gauges = append(c.gauges, gauge{name: namespace + "_" + name, help: help, value: value})
+}
+
+// WriteTo renders all metrics in the Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+
+	name := namespace + "_notifications_total"
+	writeHeader(&b, name, "Notifications processed, by service and result.", "counter")
+	keys := make([]resultKey, 0, len(c.notifications))
+	for k := range c.notifications {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].service != keys[j].service {
+			return keys[i].service < keys[j].service
+		}
+		return keys[i].result < keys[j].result
+	})
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s{service=\"%s\",result=\"%s\"} %d\n", name, escapeLabel(k.service), escapeLabel(k.result), c.notifications[k])
+	}
+
+	name = namespace + "_delivery_duration_seconds"
+	writeHeader(&b, name, "Time to deliver a notification, including retries.", "histogram")
+	backends := make([]string, 0, len(c.latency))
+	for backend := range c.latency {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	for _, backend := range backends {
+		h := c.latency[backend]
+		label := escapeLabel(backend)
+		var cumulative uint64
+		for i, bound := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{backend=\"%s\",le=\"%g\"} %d\n", name, label, bound, cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{backend=\"%s\",le=\"+Inf\"} %d\n", name, label, h.count)
+		fmt.Fprintf(&b, "%s_sum{backend=\"%s\"} %g\n", name, label, h.sum)
+		fmt.Fprintf(&b, "%s_count{backend=\"%s\"} %d\n", name, label, h.count)
+	}
+
+	name = namespace + "_delivery_retries_total"
+	writeHeader(&b, name, "Delivery attempts beyond the first.", "counter")
+	fmt.Fprintf(&b, "%s %d\n", name, c.retries)
+
+	name = namespace + "_rate_limit_waits_total"
+	writeHeader(&b, name, "Deliveries that waited for the rate limiter.", "counter")
+	fmt.Fprintf(&b, "%s %d\n", name, c.rateLimitWaits)
+
+	name = namespace + "_rate_limit_wait_seconds_total"
+	writeHeader(&b, name, "Total time spent waiting for the rate limiter.", "counter")
+	fmt.Fprintf(&b, "%s %g\n", name, c.rateLimitWaitSeconds)
+
+	for _, g := range c.gauges {
+		writeHeader(&b, g.name, g.help, "gauge")
+		fmt.Fprintf(&b, "%s %g\n", g.name, g.value())
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the collected metrics
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.WriteTo(w)
+	})
+}
+
+func writeHeader(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelEscaper escapes the characters the text exposition format requires in quoted label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for writing between double quotes
+// Go's %q would also escape non-ASCII and control characters, which Prometheus reads back literally
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
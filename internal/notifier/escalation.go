@@ -0,0 +1,317 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/state"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+// AckAction is the bot command and button action acknowledging a service's failures
+const AckAction = "ack"
+
+// MoreAction is the button action posting the output a truncated notification left out
+const MoreAction = "more"
+
+// maxTrackedAlerts bounds the alerts kept per service for marking on acknowledgement
+const maxTrackedAlerts = 10
+
+// deliverFailure sends a failure alert with its acknowledge button and escalates it when configured
+func (s *Service) deliverFailure(ctx context.Context, serviceName, message string, run runInfo, report *Report, opts ...telegram.SendOption) error {
+	s.escalate(serviceName, message, run.severity)
+	run.alert = true
+	err := s.deliver(ctx, serviceName, message, run, report, opts...)
+	s.trackAlert(serviceName, message, run.severity, report.MessageID)
+	return err
+}
+
+// escalate starts repeating a reported failure until someone acknowledges it, for services and severities configured to escalate
+// A failure while reminders already run replaces the message they repeat; acknowledged failures stay quiet
+func (s *Service) escalate(serviceName, message, level string) {
+	if s.state == nil || !s.resolvePolicy(serviceName, level).escalate {
+		return
+	}
+	now := time.Now()
+	_, err := s.state.Update(serviceName, func(st *state.Service) {
+		switch {
+		case st.Ack != nil:
+		case st.Escalation != nil:
+			st.Escalation.Message = message
+			st.Escalation.Severity = level
+		default:
+			st.Escalation = &state.Escalation{
+				Message:  message,
+				Interval: s.config.EscalationInterval,
+				NextAt:   now.Add(s.config.EscalationInterval),
+				Severity: level,
+			}
+		}
+	})
+	if err != nil {
+		slog.Warn("Starting escalation failed", logging.KeyService, serviceName, logging.Err(err))
+	}
+}
+
+// trackAlert remembers a delivered alert carrying an acknowledge button, so acknowledging can mark it
+// Spooled alerts are tracked when the flush delivers them
+func (s *Service) trackAlert(serviceName, message, level string, messageID int64) {
+	if s.state == nil || s.editor == nil || messageID == 0 || len(s.ackButton(serviceName)) == 0 {
+		return
+	}
+	_, err := s.state.Update(serviceName, func(st *state.Service) {
+		if st.Ack != nil || st.Failures == 0 {
+			return
+		}
+		st.Alerts = append(st.Alerts, state.Alert{MessageID: messageID, Message: message, Chat: s.resolvePolicy(serviceName, level).chat})
+		if len(st.Alerts) > maxTrackedAlerts {
+			st.Alerts = st.Alerts[len(st.Alerts)-maxTrackedAlerts:]
+		}
+	})
+	if err != nil {
+		slog.Warn("Tracking alert failed", logging.KeyService, serviceName, logging.Err(err))
+	}
+}
+
+// Escalate sends the reminders that are due for unacknowledged failures and returns how many were sent
+// A reminder whose delivery failed still advances, since the spool retries it
+func (s *Service) Escalate(ctx context.Context) (int, error) {
+	if s.state == nil {
+		return 0, nil
+	}
+	services, err := s.state.All()
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	sent := 0
+	for _, name := range names {
+		st := services[name]
+		if st.Escalation == nil || st.Ack != nil || now.Before(st.Escalation.NextAt) {
+			continue
+		}
+		// Reminders wait for the maintenance or snooze to end
+		if _, ok := s.inMaintenance(name); ok || st.Snoozed(now) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+
+		var report Report
+		message := s.reminderMessage(name, st)
+		run := runInfo{severity: st.Escalation.Severity, alert: true}
+		if err := s.deliver(ctx, name, message, run, &report); err != nil {
+			slog.Warn("Sending failure reminder failed", logging.KeyService, name, logging.Err(err))
+		} else {
+			sent++
+		}
+		s.trackAlert(name, message, run.severity, report.MessageID)
+
+		// Acknowledged or recovered meanwhile: the reminder was the last one
+		_, err := s.state.Update(name, func(cur *state.Service) {
+			if cur.Escalation == nil {
+				return
+			}
+			cur.Escalation.Repeats++
+			cur.Escalation.Interval = min(cur.Escalation.Interval*2, constants.MaxEscalationInterval)
+			cur.Escalation.NextAt = now.Add(cur.Escalation.Interval)
+		})
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// reminderMessage puts a reminder header above the original failure, keeping it within the size limit
+func (s *Service) reminderMessage(serviceName string, st state.Service) string {
+	header := fmt.Sprintf("🔁 *Reminder %d:* %s is still failing and nobody acknowledged it", st.Escalation.Repeats+1, markdown.Code(serviceName))
+	if !st.FailingSince.IsZero() {
+		header += " (since " + s.config.FormatDateTime(st.FailingSince) + ")"
+	}
+	if s.config.GetBotPolicy().Enabled() {
+		header += ". Press Acknowledge or send /" + AckAction
+		if s.config.AckReaction != "" {
+			header += " or react " + s.config.AckReaction
+		}
+		header += " to stop reminders"
+	}
+	header += "\n\n"
+
+	return header + fitBeside(st.Escalation.Message, header)
+}
+
+// fitBeside cuts the start of a formatted message, keeping its Markdown whole, so it fits in one Telegram message with extra
+func fitBeside(message, extra string) string {
+	room := constants.TelegramMaxMessageSize - constants.MessageSafetyMargin - validation.UTF16Length(extra)
+	if validation.UTF16Length(message) <= room {
+		return message
+	}
+	return markdown.TruncateTail(message, max(0, room))
+}
+
+// ackButton returns the acknowledge button for a service's alerts, when the bot can receive presses
+func (s *Service) ackButton(serviceName string) []telegram.SendOption {
+	data := AckAction + ":" + serviceName
+	if !s.config.GetBotPolicy().Enabled() || len(data) > telegram.MaxCallbackData {
+		return nil
+	}
+	return []telegram.SendOption{telegram.WithButtons([]telegram.Button{{Text: "✋ Acknowledge", CallbackData: data}})}
+}
+
+// Acknowledge records who took on a service's failures, which stops its reminders until it recovers
+// The alerts sent for the failures are edited to show the acknowledgement and lose their buttons
+// A bare name finds the unit of that name; returns false when the service isn't failing or was already acknowledged
+func (s *Service) Acknowledge(ctx context.Context, serviceName, by string) (bool, error) {
+	if s.state == nil {
+		return false, nil
+	}
+	serviceName, err := s.state.Resolve(serviceName)
+	if err != nil {
+		return false, err
+	}
+	var (
+		acked  bool
+		alerts []state.Alert
+	)
+	now := time.Now()
+	_, err = s.state.Update(serviceName, func(st *state.Service) {
+		if st.Failures == 0 || st.Ack != nil {
+			return
+		}
+		st.Ack = &state.Ack{By: by, At: now}
+		alerts = st.Alerts
+		st.Escalation, st.Alerts = nil, nil
+		acked = true
+	})
+	if err != nil || !acked {
+		return acked, err
+	}
+	s.recordAck(serviceName, by, now)
+	if s.editor == nil {
+		return true, nil
+	}
+
+	// Names can't break out of the code span, whatever characters they contain
+	note := fmt.Sprintf("\n\n✋ *ACK* by %s at %s", markdown.Code(by), s.config.FormatDateTime(now))
+	for _, alert := range alerts {
+		if err := s.editor.EditMessage(ctx, alert.Chat, alert.MessageID, fitBeside(alert.Message, note)+note); err != nil {
+			slog.Warn("Marking alert as acknowledged failed", logging.KeyService, serviceName, "message_id", alert.MessageID, logging.Err(err))
+		}
+	}
+	return true, nil
+}
+
+// AcknowledgeMessage acknowledges the service whose failure alert or reminder is message messageID in chat
+// Returns the service, or "" when the message isn't an alert waiting for acknowledgement
+func (s *Service) AcknowledgeMessage(ctx context.Context, chatID, messageID int64, by string) (string, error) {
+	if s.state == nil {
+		return "", nil
+	}
+	services, err := s.state.All()
+	if err != nil {
+		return "", err
+	}
+	for name, st := range services {
+		if st.Ack != nil || !slices.ContainsFunc(st.Alerts, func(a state.Alert) bool { return s.isAlert(a, chatID, messageID) }) {
+			continue
+		}
+		acked, err := s.Acknowledge(ctx, name, by)
+		if err != nil || !acked {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+// isAlert reports whether alert is message messageID in chat
+// Alerts sent to a channel username can't be compared by chat and match on the message alone
+func (s *Service) isAlert(alert state.Alert, chatID, messageID int64) bool {
+	if alert.MessageID != messageID {
+		return false
+	}
+	chat := alert.Chat
+	if chat == "" {
+		chat = s.config.ChatID
+	}
+	id, err := strconv.ParseInt(chat, 10, 64)
+	return err != nil || id == chatID
+}
+
+// recordAck notes an acknowledgement in the audit log
+func (s *Service) recordAck(serviceName, by string, at time.Time) {
+	s.recordAction(history.Record{Time: at, Service: serviceName, Result: history.ResultAcknowledged, By: by})
+}
+
+// RecordUnitAction notes in the audit log that a bot user restarted or stopped a unit, with the error if it failed
+// result is history.ResultRestarted or history.ResultStopped
+func (s *Service) RecordUnitAction(serviceName, result, by string, actionErr error) {
+	rec := history.Record{Time: time.Now(), Service: serviceName, Result: result, By: by}
+	if actionErr != nil {
+		rec.Error = validation.SanitizeErrorMessage(actionErr)
+	}
+	s.recordAction(rec)
+}
+
+// recordAction appends a record of a user's action, when the audit log is enabled
+func (s *Service) recordAction(rec history.Record) {
+	if s.history == nil {
+		return
+	}
+	if err := s.history.Append(rec); err != nil {
+		slog.Warn("Failed to write history record", logging.KeyService, rec.Service, logging.Err(err))
+	}
+}
+
+// AcknowledgeAll acknowledges every failing service nobody acknowledged yet, returning their names
+func (s *Service) AcknowledgeAll(ctx context.Context, by string) ([]string, error) {
+	if s.state == nil {
+		return nil, nil
+	}
+	services, err := s.state.All()
+	if err != nil {
+		return nil, err
+	}
+	var acked []string
+	for name, st := range services {
+		if st.Failures == 0 || st.Ack != nil {
+			continue
+		}
+		ok, err := s.Acknowledge(ctx, name, by)
+		if err != nil {
+			return acked, err
+		}
+		if ok {
+			acked = append(acked, name)
+		}
+	}
+	sort.Strings(acked)
+	return acked, nil
+}
+
+// Snooze mutes a unit's or job's notifications and reminders until the given time; a zero time ends the snooze
+// Returns the name the snooze applies to, which gains a ".service" suffix when that unit is the one known
+func (s *Service) Snooze(serviceName string, until time.Time) (string, error) {
+	if s.state == nil {
+		return serviceName, fmt.Errorf("service state is disabled")
+	}
+	return s.state.Snooze(serviceName, until)
+}
@@ -2,20 +2,47 @@ package notifier
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"telegram-notifier/internal/config"
 	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/deadletter"
+	"telegram-notifier/internal/history"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
+	"telegram-notifier/internal/metrics"
+	"telegram-notifier/internal/poolstatus"
+	"telegram-notifier/internal/severity"
+	"telegram-notifier/internal/spool"
+	"telegram-notifier/internal/state"
+	"telegram-notifier/internal/sysinfo"
 	"telegram-notifier/internal/systemd"
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/tracing"
 	"telegram-notifier/internal/validation"
 )
 
+// AdHocService labels free-form notifications in history, spool and dead-letter records
+const AdHocService = "ad-hoc"
+
+// ErrSpooled indicates delivery failed but the notification was persisted for retry
+var ErrSpooled = errors.New("notification spooled for later delivery")
+
+// OpValidation is the NotificationError operation for rejected input
+const OpValidation = "validation failed"
+
 // NotificationError provides structured error context for notification failures
 type NotificationError struct {
 	Op      string
 	Service string
 	Err     error
+	Spooled bool // Delivery failed but the notification was persisted for retry
 }
 
 func (e *NotificationError) Error() string {
@@ -29,67 +56,302 @@ func (e *NotificationError) Unwrap() error {
 	return e.Err
 }
 
+// Is allows errors.Is(err, ErrSpooled) to detect deferred deliveries
+func (e *NotificationError) Is(target error) bool {
+	return target == ErrSpooled && e.Spooled
+}
+
+// Report summarizes the outcome of a single notification run
+type Report struct {
+	Delivered  bool          // Sent to Telegram or the fallback backend
+	Queued     bool          // Handed to the spool for the daemon (async mode)
+	Suppressed bool          // Not sent because NOTIFIER_POLICY doesn't report the run
+	Spooled    bool          // Delivery failed and the notification was spooled for retry
+	MessageID  int64         // Telegram message ID, when delivered to Telegram
+	Attempts   int           // Delivery attempts made
+	Backend    string        // Backend that delivered the message
+	Duration   time.Duration // Time spent delivering
+	Truncated  bool          // Output was shortened to fit size limits
+	Redactions int           // Secrets redacted from the message
+	Severity   string        // info, warning or critical; empty for free-form notifications
+}
+
 // NotificationData contains all information for formatting a notification
 type NotificationData struct {
+	Title           string // Set for free-form notifications not tied to a unit
 	Hostname        string
 	DateTime        string
 	ProcessExitCode int
 	ServiceStatus   string
+	Runtime         string // How long the run took, with a warning when far from the usual
 	ServiceName     string
 	ServiceDesc     string
+	StartedBy       string // What started the run, e.g. "timer backup.timer"
+	Dependencies    string // Dependencies that are down, set for failures
+	UnitChanged     string // Unit files edited since the last success, set for failures
+	InvocationID    string
+	Version         string
 	Message         string
+	Redactions      int    // Secrets filtered out of Message
+	Signal          string // The signal that killed the main process, with its core dump summary
+	OOM             string // Kernel OOM killer messages and memory peak, set instead of Signal for out-of-memory kills
+	RawOutput       string // Where the unfiltered output can be read; empty for custom messages
+	Health          string
+	SystemErrors    string // Latest error-priority lines of the system journal, set for failures
+	Cgroup          string // The unit's resource use against its limits, set for failures
+	Denials         string // SELinux and AppArmor denials logged during the run, set for failures
 	IsSuccess       bool
+	FailingSince    string // Set when a success ends a run of failures, making it a recovery
+	Severity        string // Level from NOTIFIER_SEVERITY, deciding the status emoji of failures
 }
 
-// SystemdService abstracts systemd operations for testing
-type SystemdService interface {
+// InitSystem provides what the notifier needs to know about a service from the init system managing it
+// systemd.Service is the full implementation; providers for other init systems return errors
+// for lookups they can't answer, which leaves those parts of a notification out
+type InitSystem interface {
 	GetServiceInfo(ctx context.Context, serviceName string) (systemd.ServiceInfo, error)
-	GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (string, error)
+	GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) (systemd.CommandOutput, error)
 	GetServiceExitCodeInfo(ctx context.Context, serviceName string) (systemd.ExitCodeInfo, error)
+	GetServiceVersion(ctx context.Context, serviceName string) (string, error)
+	FailedDependencies(ctx context.Context, serviceName string) ([]systemd.UnitStatus, error)
+	SystemErrors(ctx context.Context, lines int) (string, error)
+	CgroupUsage(ctx context.Context, serviceName string) (systemd.CgroupUsage, error)
+	UnitFileHashes(ctx context.Context, serviceName string) (map[string]string, error)
+	ActivationCause(ctx context.Context, serviceName string) (systemd.Activation, error)
+	CoreDump(ctx context.Context, pid int, since time.Time) (systemd.CoreDump, error)
+	SecurityDenials(ctx context.Context, pid int, since time.Time) (string, error)
+	OOMKills(ctx context.Context, serviceName string, pid int, since time.Time) (string, error)
 }
 
 // TelegramClient abstracts Telegram API for testing
 type TelegramClient interface {
-	SendNotification(ctx context.Context, message string) error
+	Send(ctx context.Context, message string, opts ...telegram.SendOption) (telegram.Delivery, error)
+}
+
+// History records every notification attempt for auditing
+// Past records give the usual run time of a unit
+type History interface {
+	Append(rec history.Record) error
+	Query(filter history.Filter) ([]history.Record, error)
+}
+
+// Spool persists undelivered notifications for later retry
+type Spool interface {
+	Enqueue(entry spool.Entry) error
+	Flush(ctx context.Context, send spool.SendFunc) (spool.FlushResult, error)
+	OnDrop(fn spool.DropFunc)
+}
+
+// Metrics receives delivery outcomes for monitoring
+type Metrics interface {
+	Observe(obs metrics.Observation)
+}
+
+// OutputSource supplies a unit's output from somewhere other than the journal, such as its log file
+type OutputSource interface {
+	Read(ctx context.Context) (string, error)
+	Location() string // Where the unfiltered output can be read
+}
+
+// RunPinger reports each monitored run to an external check, such as healthchecks.io
+type RunPinger interface {
+	PingRun(ctx context.Context, service string, success bool) error
+}
+
+// StateStore remembers each service's previous run, for policies that depend on it
+type StateStore interface {
+	Update(service string, fn func(*state.Service)) (state.Service, error)
+	All() (map[string]state.Service, error)
+	Snooze(service string, until time.Time) (string, error)
+	Resolve(name string) (string, error)
+}
+
+// MaintenanceWindows tells which units and jobs are under planned maintenance
+type MaintenanceWindows interface {
+	Active() (state.Maintenance, error)
+}
+
+// FullOutputs keeps the output truncated notifications left out, for "Show more" to page through
+type FullOutputs interface {
+	Save(output state.Output) (string, error)
+}
+
+// MessageEditor changes sent messages, to mark alerts as acknowledged
+type MessageEditor interface {
+	EditMessage(ctx context.Context, chatID string, messageID int64, text string) error
+}
+
+// DeadLetter records notifications that were permanently lost
+type DeadLetter interface {
+	Record(rec deadletter.Record) error
 }
 
 type Service struct {
-	systemd  SystemdService
-	telegram TelegramClient
-	config   *config.Config
+	initSystem InitSystem
+	telegram   TelegramClient
+	config     *config.Config
+	spool      Spool
+	deadLetter DeadLetter
+	history    History
+	observers  []Metrics
+	pinger     RunPinger
+	state      StateStore
+	editor     MessageEditor
+	windows    MaintenanceWindows
+	outputs    map[string]OutputSource // Per-unit replacements for journal output
+	full       FullOutputs
 }
 
-func New(systemdService SystemdService, telegramClient TelegramClient, cfg *config.Config) *Service {
-	return &Service{
-		systemd:  systemdService,
-		telegram: telegramClient,
-		config:   cfg,
+// Option configures optional Service collaborators
+type Option func(*Service)
+
+// WithSpool enables persisting undelivered notifications for retry
+func WithSpool(sp Spool) Option {
+	return func(s *Service) {
+		s.spool = sp
 	}
 }
 
+// WithHistory enables the delivery audit log
+func WithHistory(h History) Option {
+	return func(s *Service) {
+		s.history = h
+	}
+}
+
+// WithMetrics reports delivery outcomes to a metrics collector
+// May be given more than once; every collector observes each attempt
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		s.observers = append(s.observers, m)
+	}
+}
+
+// WithDeadLetter enables auditing of notifications that could not be delivered
+func WithDeadLetter(dl DeadLetter) Option {
+	return func(s *Service) {
+		s.deadLetter = dl
+	}
+}
+
+// WithRunPings pings a check URL with the result of every service and job run
+func WithRunPings(p RunPinger) Option {
+	return func(s *Service) {
+		s.pinger = p
+	}
+}
+
+// WithState remembers run outcomes, which the recovery policy needs to spot recoveries
+func WithState(st StateStore) Option {
+	return func(s *Service) {
+		s.state = st
+	}
+}
+
+// WithMaintenance holds back notifications for units and jobs under planned maintenance
+func WithMaintenance(m MaintenanceWindows) Option {
+	return func(s *Service) {
+		s.windows = m
+	}
+}
+
+// WithEditor lets acknowledgements mark the alerts they cover in the chat
+func WithEditor(e MessageEditor) Option {
+	return func(s *Service) {
+		s.editor = e
+	}
+}
+
+// WithFullOutputs keeps the output truncated notifications leave out, and offers it with a "Show more" button
+func WithFullOutputs(f FullOutputs) Option {
+	return func(s *Service) {
+		s.full = f
+	}
+}
+
+// WithOutputSource reads a unit's output from src instead of the journal
+func WithOutputSource(serviceName string, src OutputSource) Option {
+	return func(s *Service) {
+		if s.outputs == nil {
+			s.outputs = map[string]OutputSource{}
+		}
+		s.outputs[serviceName] = src
+	}
+}
+
+func New(initSystem InitSystem, telegramClient TelegramClient, cfg *config.Config, opts ...Option) *Service {
+	s := &Service{
+		initSystem: initSystem,
+		telegram:   telegramClient,
+		config:     cfg,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Entries discarded by the spool are lost, so audit them in the dead-letter log
+	if s.spool != nil {
+		s.spool.OnDrop(s.recordSpoolDrop)
+	}
+	return s
+}
+
 // SendServiceNotification orchestrates notification creation and delivery
+// logs, when set, is shown as the command output instead of the journal, e.g. a wrapped script's piped output
 // SECURITY: Validates inputs, filters secrets, and sanitizes all output
-func (s *Service) SendServiceNotification(ctx context.Context, exitInfo systemd.ExitCodeInfo, serviceName, serviceDesc, customMessage string) error {
+func (s *Service) SendServiceNotification(ctx context.Context, exitInfo systemd.ExitCodeInfo, serviceName, serviceDesc, customMessage, logs string) (Report, error) {
+	var report Report
+
 	// Check for context cancellation early
 	select {
 	case <-ctx.Done():
-		return s.wrapError("context cancelled", serviceName, ctx.Err())
+		return report, s.wrapError("context cancelled", serviceName, ctx.Err())
 	default:
 	}
 
 	// SECURITY: Validate service name to prevent injection attacks
 	if err := validation.ValidateServiceName(serviceName); err != nil {
-		return s.wrapError("validation failed", serviceName, err)
+		return report, s.wrapError(OpValidation, serviceName, err)
 	}
 
-	// Get service description from systemd or use provided value
-	finalServiceDesc := s.getServiceDescription(ctx, serviceName, serviceDesc)
+	slog.Debug("Preparing notification", logging.KeyService, serviceName,
+		logging.KeyInvocationID, exitInfo.InvocationID, "exit_code", exitInfo.ProcessExitCode)
+
+	ctx, span := tracing.Start(ctx, "notification")
+	span.SetAttr(logging.KeyService, serviceName)
+	span.SetAttr(logging.KeyInvocationID, exitInfo.InvocationID)
+	span.SetAttr("exit_code", exitInfo.ProcessExitCode)
+	defer span.End()
 
 	// Get command output with automatic secret filtering
-	finalMessage := s.getCommandOutput(ctx, serviceName, exitInfo, customMessage)
+	stepCtx, step := tracing.Start(ctx, "journal.collect")
+	finalMessage, plain, fullOutput := s.getCommandOutput(stepCtx, serviceName, exitInfo, customMessage, logs, &report)
+	step.End()
 
-	// Get hostname (uses privacy alias if configured)
-	hostname := s.config.GetHostname()
+	// Scrub units print pool reports; zpool and btrfs exit 0 for degraded pools, so even a brief success
+	// needs the output read, and one with an unhealthy pool is reported in full
+	pools := poolstatus.Parse(finalMessage)
+	if exitInfo.ServiceSuccess && customMessage == "" && s.config.SuccessFormat == constants.SuccessFormatBrief && !anyUnhealthy(pools) {
+		return s.sendBriefSuccess(ctx, exitInfo, serviceName)
+	}
+
+	// Get service description from systemd or use provided value
+	stepCtx, step = tracing.Start(ctx, "systemd.description")
+	finalServiceDesc := s.getServiceDescription(stepCtx, serviceName, serviceDesc)
+	step.End()
+
+	body := finalMessage
+	if plain {
+		// Log lines and piped output routinely contain "_" and "*"; unescaped they break the message
+		body = s.escapeAndTruncate(finalMessage, &report)
+	}
+
+	stepCtx, step = tracing.Start(ctx, "systemd.version")
+	version := s.getServiceVersion(stepCtx, serviceName, exitInfo.ServiceSuccess && !anyUnhealthy(pools))
+	step.End()
+
+	// Get hostname (uses privacy alias if configured) and optional IP addresses
+	hostname := s.getHostDisplay()
 
 	// Build notification data structure
 	data := NotificationData{
@@ -99,12 +361,408 @@ func (s *Service) SendServiceNotification(ctx context.Context, exitInfo systemd.
 		ServiceStatus:   exitInfo.ExitStatus,
 		ServiceName:     serviceName,
 		ServiceDesc:     finalServiceDesc,
-		Message:         finalMessage,
+		InvocationID:    exitInfo.InvocationID,
+		Version:         version,
+		Message:         body,
+		Redactions:      report.Redactions,
 		IsSuccess:       exitInfo.ServiceSuccess,
 	}
 
+	// Custom messages and piped logs were never logged, so only collected output has a raw copy to point at
+	if customMessage == "" && logs == "" {
+		data.RawOutput = journalCommand(serviceName, exitInfo.InvocationID)
+		if src, ok := s.outputs[serviceName]; ok {
+			data.RawOutput = src.Location()
+		}
+	}
+
+	// Summarize pool reports instead of dumping the raw table; an unhealthy pool counts as a failure
+	if len(pools) > 0 {
+		data.Message = formatPools(pools, data.RawOutput)
+		data.IsSuccess = data.IsSuccess && !anyUnhealthy(pools)
+	}
+
+	// Successes remember the unit files, so a failure can tell whether the unit was edited since
+	var unitFiles map[string]string
+	if s.config.IsFieldVisible(constants.FieldUnitChanged) {
+		stepCtx, step = tracing.Start(ctx, "systemd.unit_files")
+		unitFiles = s.getUnitFileHashes(stepCtx, serviceName)
+		step.End()
+	}
+
+	s.pingRun(ctx, serviceName, data.IsSuccess)
+	prev, known := s.recordRun(serviceName, data.IsSuccess, unitFiles)
+	if !s.shouldReport(serviceName, data.IsSuccess, prev, known) {
+		report.Suppressed = true
+		return report, nil
+	}
+	if data.IsSuccess && prev.Alerted {
+		data.FailingSince = s.failingSince(prev)
+	}
+	if !data.IsSuccess {
+		data.UnitChanged = unitFileChanges(prev.UnitFiles, unitFiles)
+	}
+	data.Severity = s.config.GetSeverity(exitInfo.ProcessExitCode, exitInfo.ExitSignal != "", data.IsSuccess)
+	report.Severity = data.Severity
+	if s.config.IsFieldVisible(constants.FieldRuntime) {
+		data.Runtime = s.formatRuntime(serviceName, exitInfo.Runtime)
+	}
+	// Tells a scheduled run from an operator's, whose failure may already be known to them
+	if s.config.IsFieldVisible(constants.FieldStartedBy) {
+		stepCtx, step = tracing.Start(ctx, "systemd.activation")
+		data.StartedBy = s.getActivationCause(stepCtx, serviceName)
+		step.End()
+	}
+
+	// The OOM killer's SIGKILL looks like any other; the kernel log and memory peak tell why
+	if !data.IsSuccess && mayBeOOMKill(exitInfo) {
+		stepCtx, step = tracing.Start(ctx, "journal.oom")
+		data.OOM = s.getOOMReport(stepCtx, serviceName, exitInfo)
+		step.End()
+	}
+	// A crash leaves nothing in the unit's own log, but systemd-coredump may have a stack trace
+	if !data.IsSuccess && exitInfo.ExitSignal != "" && data.OOM == "" {
+		stepCtx, step = tracing.Start(ctx, "coredump.info")
+		data.Signal = s.getSignalReport(stepCtx, exitInfo)
+		step.End()
+	}
+
+	// Attach system health snapshot to failures to speed up triage
+	if !data.IsSuccess && s.config.IncludeHealth {
+		data.Health = s.getHealthSnapshot()
+	}
+	// A dependency that is down is the likely cause, so the alert points at it rather than only the symptom
+	if !data.IsSuccess && s.config.IsFieldVisible(constants.FieldDependencies) {
+		stepCtx, step = tracing.Start(ctx, "systemd.dependencies")
+		data.Dependencies = s.getFailedDependencies(stepCtx, serviceName)
+		step.End()
+	}
+	// The kernel, OOM killer or a failing disk may explain a failure the unit's own log says nothing about
+	if !data.IsSuccess && s.config.SystemErrorLines > 0 {
+		stepCtx, step = tracing.Start(ctx, "journal.system_errors")
+		data.SystemErrors = s.getSystemErrors(stepCtx)
+		step.End()
+	}
+	// A unit killed at its memory or task limit, or starved by its CPU quota, logs little about why
+	if !data.IsSuccess && s.config.IncludeCgroup {
+		stepCtx, step = tracing.Start(ctx, "systemd.cgroup")
+		data.Cgroup = s.getCgroupUsage(stepCtx, serviceName)
+		step.End()
+	}
+	// A refused open or connect usually surfaces as a generic error in the unit's own log
+	if !data.IsSuccess && s.config.IncludeDenials {
+		stepCtx, step = tracing.Start(ctx, "journal.denials")
+		data.Denials = s.getSecurityDenials(stepCtx, exitInfo)
+		step.End()
+	}
+
 	// Format message and ensure it fits Telegram limits
-	formattedMessage := s.formatAndValidateMessage(data)
+	_, step = tracing.Start(ctx, "message.format")
+	formattedMessage, truncated := s.formatAndValidateMessage(data)
+	report.Truncated = report.Truncated || truncated
+	step.SetAttr("message.length", len(formattedMessage))
+	step.End()
+
+	run := runInfo{outcome: history.OutcomeFailure, runtime: exitInfo.Runtime, severity: data.Severity}
+	if data.IsSuccess {
+		run.outcome = history.OutcomeSuccess
+	}
+	more := s.showMore(serviceName, fullOutput, &report)
+	var err error
+	if data.IsSuccess {
+		err = s.deliver(ctx, serviceName, formattedMessage, run, &report, more...)
+	} else {
+		err = s.deliverFailure(ctx, serviceName, formattedMessage, run, &report, more...)
+	}
+	if err != nil {
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+	return report, err
+}
+
+// sendBriefSuccess reports a successful run in one line, without collecting its output
+func (s *Service) sendBriefSuccess(ctx context.Context, exitInfo systemd.ExitCodeInfo, serviceName string) (Report, error) {
+	var report Report
+	// The unit files are remembered here too, or a later failure would have nothing to compare them with
+	var unitFiles map[string]string
+	if s.config.IsFieldVisible(constants.FieldUnitChanged) {
+		unitFiles = s.getUnitFileHashes(ctx, serviceName)
+	}
+	s.pingRun(ctx, serviceName, true)
+	prev, known := s.recordRun(serviceName, true, unitFiles)
+	if !s.shouldReport(serviceName, true, prev, known) {
+		report.Suppressed = true
+		return report, nil
+	}
+
+	message := fmt.Sprintf("🟢 `%s` succeeded on %s", serviceName, markdown.Code(s.config.GetHostname()))
+	if prev.Alerted {
+		message = fmt.Sprintf("✅ `%s` recovered on %s", serviceName, markdown.Code(s.config.GetHostname()))
+	}
+	if exitInfo.Runtime >= time.Second {
+		message += " in " + s.formatRuntime(serviceName, exitInfo.Runtime)
+	}
+	if prev.Alerted {
+		message += ", failing since `" + s.failingSince(prev) + "`"
+	}
+
+	report.Severity = severity.Info
+	run := runInfo{outcome: history.OutcomeSuccess, runtime: exitInfo.Runtime, severity: severity.Info}
+	return report, s.deliver(ctx, serviceName, message, run, &report)
+}
+
+// SendMessage sends a free-form notification that isn't tied to a systemd unit
+// Uses the same formatting, secret filtering and delivery stack as service notifications
+func (s *Service) SendMessage(ctx context.Context, title, message string) (Report, error) {
+	var report Report
+
+	select {
+	case <-ctx.Done():
+		return report, s.wrapError("context cancelled", "", ctx.Err())
+	default:
+	}
+
+	if strings.TrimSpace(title) == "" {
+		return report, s.wrapError(OpValidation, "", fmt.Errorf("title is required"))
+	}
+
+	// SECURITY: Titles are user input like messages and may contain secrets
+	filteredTitle, redactions := validation.FilterSecretsCount(title)
+	report.Redactions += redactions
+
+	data := NotificationData{
+		Title:    filteredTitle,
+		Hostname: s.getHostDisplay(),
+		DateTime: s.config.FormatDateTime(time.Now()),
+		Message:  s.filterAndTruncateMarkdown(message, &report),
+	}
+	data.Redactions = report.Redactions
+
+	ctx, span := tracing.Start(ctx, "notification")
+	span.SetAttr(logging.KeyService, AdHocService)
+	defer span.End()
+
+	formattedMessage, truncated := s.formatAndValidateMessage(data)
+	report.Truncated = report.Truncated || truncated
+
+	err := s.deliver(ctx, AdHocService, formattedMessage, runInfo{}, &report, s.showMore(AdHocService, message, &report)...)
+	if err != nil {
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+	return report, err
+}
+
+// JobRun is the result of a command wrapped by "telegram-notifier run" outside systemd
+type JobRun struct {
+	Name     string        // Labels the job in notifications and history
+	Command  string        // Command line, shown as the description
+	ExitCode int           // 128+N when killed by signal N
+	Signaled bool          // Killed by a signal rather than exiting
+	Output   string        // Combined stdout and stderr, tail-truncated
+	Runtime  time.Duration // Wall-clock run time
+	Quiet    bool          // A success is recorded but only reported when it ends reported failures
+}
+
+// SendJobNotification reports a wrapped command's result like a service notification
+// No systemd lookups are made; everything comes from the run itself
+func (s *Service) SendJobNotification(ctx context.Context, job JobRun) (Report, error) {
+	var report Report
+
+	if err := validation.ValidateJobName(job.Name); err != nil {
+		return report, s.wrapError(OpValidation, job.Name, err)
+	}
+
+	ctx, span := tracing.Start(ctx, "notification")
+	span.SetAttr(logging.KeyService, job.Name)
+	span.SetAttr("exit_code", job.ExitCode)
+	defer span.End()
+
+	// SECURITY: Command lines often carry credentials as arguments
+	command, redactions := validation.FilterSecretsCount(job.Command)
+	report.Redactions += redactions
+
+	data := NotificationData{
+		Hostname:        s.getHostDisplay(),
+		DateTime:        s.config.FormatDateTime(time.Now()),
+		ProcessExitCode: job.ExitCode,
+		ServiceStatus:   systemd.GetExitStatusString(job.ExitCode),
+		ServiceName:     job.Name,
+		ServiceDesc:     command,
+		Message:         s.escapeAndTruncate(s.filterSecrets(job.Output, &report), &report),
+		IsSuccess:       job.ExitCode == 0,
+	}
+	data.Redactions = report.Redactions
+
+	s.pingRun(ctx, job.Name, data.IsSuccess)
+	prev, known := s.recordRun(job.Name, data.IsSuccess, nil)
+	if (job.Quiet && data.IsSuccess && !prev.Alerted) || !s.shouldReport(job.Name, data.IsSuccess, prev, known) {
+		report.Suppressed = true
+		return report, nil
+	}
+	if data.IsSuccess && prev.Alerted {
+		data.FailingSince = s.failingSince(prev)
+	}
+	data.Severity = s.config.GetSeverity(job.ExitCode, job.Signaled, data.IsSuccess)
+	report.Severity = data.Severity
+	if s.config.IsFieldVisible(constants.FieldRuntime) {
+		data.Runtime = s.formatRuntime(job.Name, job.Runtime)
+	}
+	if !data.IsSuccess && s.config.IncludeHealth {
+		data.Health = s.getHealthSnapshot()
+	}
+
+	formattedMessage, truncated := s.formatAndValidateMessage(data)
+	report.Truncated = report.Truncated || truncated
+
+	run := runInfo{outcome: history.OutcomeFailure, runtime: job.Runtime, severity: data.Severity}
+	if data.IsSuccess {
+		run.outcome = history.OutcomeSuccess
+	}
+	more := s.showMore(job.Name, job.Output, &report)
+	var err error
+	if data.IsSuccess {
+		err = s.deliver(ctx, job.Name, formattedMessage, run, &report, more...)
+	} else {
+		err = s.deliverFailure(ctx, job.Name, formattedMessage, run, &report, more...)
+	}
+	if err != nil {
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+	return report, err
+}
+
+// pingRun reports a run to the configured check, independently of Telegram delivery
+// Ping failures are logged; the notification is still sent
+func (s *Service) pingRun(ctx context.Context, serviceName string, success bool) {
+	if s.pinger == nil {
+		return
+	}
+	ctx, span := tracing.Start(ctx, "run.ping")
+	defer span.End()
+	if err := s.pinger.PingRun(ctx, serviceName, success); err != nil {
+		slog.Warn("Run ping failed", logging.KeyService, serviceName, logging.Err(err))
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+}
+
+// recordRun saves a run's outcome and returns the service's state before it
+// known is false without a state store or when the state couldn't be updated
+func (s *Service) recordRun(serviceName string, success bool, unitFiles map[string]string) (prev state.Service, known bool) {
+	if s.state == nil {
+		return state.Service{}, false
+	}
+	threshold := s.resolvePolicy(serviceName, "").threshold
+	now := time.Now()
+	prev, err := s.state.Update(serviceName, func(st *state.Service) {
+		st.LastRun = now
+		if success {
+			st.Failures, st.Alerted, st.FailingSince = 0, false, time.Time{}
+			st.Escalation, st.Ack, st.Alerts = nil, nil, nil
+			st.UnitFiles = unitFiles
+			return
+		}
+		if st.Failures == 0 {
+			st.FailingSince = now
+		}
+		st.Failures++
+		st.Alerted = st.Alerted || st.Failures >= threshold
+	})
+	if err != nil {
+		slog.Warn("Updating service state failed", logging.KeyService, serviceName, logging.Err(err))
+		return state.Service{}, false
+	}
+	return prev, true
+}
+
+// shouldReport decides whether a run is reported, given the service's state before it
+// Nothing is reported for units and jobs under maintenance or snoozed
+// Failures wait for the service's failure threshold; successes follow its notify policy and quiet hours
+// An unknown previous state reports the run, so a broken state directory can't hide failures or recoveries
+func (s *Service) shouldReport(serviceName string, success bool, prev state.Service, known bool) bool {
+	if until, ok := s.inMaintenance(serviceName); ok {
+		slog.Debug("Run not reported during maintenance", logging.KeyService, serviceName, "until", until)
+		return false
+	}
+	if prev.Snoozed(time.Now()) {
+		slog.Debug("Run not reported while snoozed", logging.KeyService, serviceName, "until", prev.SnoozedUntil)
+		return false
+	}
+
+	p := s.resolvePolicy(serviceName, "")
+	report := true
+	switch {
+	case !success:
+		report = !known || prev.Failures+1 >= p.threshold
+	case p.notify == constants.PolicyFailureOnly:
+		report = false
+	case p.notify == constants.PolicyRecovery:
+		report = prev.Alerted || !known
+	}
+
+	// Quiet hours hold back routine successes; failures and recoveries still change what someone should know
+	if report && s.inQuietHours(p, time.Now()) {
+		report = (!success || prev.Alerted) && s.config.QuietFailures
+	}
+	if !report {
+		slog.Debug("Run not reported", logging.KeyService, serviceName, "success", success,
+			"previous_failures", prev.Failures, "policy", p.notify)
+	}
+	return report
+}
+
+// inMaintenance returns when the maintenance window covering a unit or job ends, if one is active
+// Windows that can't be read are ignored, so a damaged file doesn't silence notifications
+func (s *Service) inMaintenance(serviceName string) (time.Time, bool) {
+	if s.windows == nil {
+		return time.Time{}, false
+	}
+	windows, err := s.windows.Active()
+	if err != nil {
+		slog.Warn("Reading maintenance windows failed", logging.Err(err))
+		return time.Time{}, false
+	}
+	return windows.Covers(serviceName, time.Now())
+}
+
+// failingSince formats when a recovered service started failing, how long ago and how often it failed
+func (s *Service) failingSince(prev state.Service) string {
+	if prev.FailingSince.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (%s, %d failures)", s.config.FormatDateTime(prev.FailingSince),
+		time.Since(prev.FailingSince).Round(time.Second), prev.Failures)
+}
+
+// runInfo describes the service run a notification reports on, for the audit log
+type runInfo struct {
+	outcome  string        // history.OutcomeSuccess or OutcomeFailure; empty when not tied to a run
+	runtime  time.Duration // zero when unknown
+	retry    bool          // redelivery of a spooled notification
+	severity string        // Level deciding the chat and sound; empty for free-form notifications
+	alert    bool          // Failure alert or reminder, sent with an acknowledge button
+}
+
+// deliver sends a formatted notification, spooling or dead-lettering it on failure
+// serviceName identifies the notification in history, spool and dead-letter records
+// The delivery outcome is recorded in report
+func (s *Service) deliver(ctx context.Context, serviceName, formattedMessage string, run runInfo, report *Report, opts ...telegram.SendOption) error {
+	// The spool keeps the buttons; an alert's acknowledge button is added again on redelivery
+	buttons := buttonRows(opts)
+	if run.alert {
+		opts = append(s.ackButton(serviceName), opts...)
+	}
+
+	// Async mode: hand off to the spool and return immediately, the daemon delivers
+	if s.config.Async && s.spool != nil {
+		entry := spool.Entry{Service: serviceName, Message: formattedMessage, Severity: run.severity, Alert: run.alert, Buttons: buttons}
+		if err := s.spool.Enqueue(entry); err != nil {
+			s.recordAttempt(serviceName, formattedMessage, history.ResultFailed, telegram.Delivery{}, 0, run, err)
+			return s.wrapError("queueing notification", serviceName, err)
+		}
+		s.recordAttempt(serviceName, formattedMessage, history.ResultQueued, telegram.Delivery{}, 0, run, nil)
+		report.Queued = true
+		return nil
+	}
 
 	// Final context check before sending
 	select {
@@ -114,13 +772,220 @@ func (s *Service) SendServiceNotification(ctx context.Context, exitInfo systemd.
 	}
 
 	// Send notification via Telegram API
-	if err := s.telegram.SendNotification(ctx, formattedMessage); err != nil {
-		return s.wrapError("sending telegram notification", serviceName, err)
+	start := time.Now()
+	opts = append(s.sendOptions(serviceName, run.severity), opts...)
+	delivery, err := s.telegram.Send(ctx, formattedMessage, opts...)
+	report.Duration = time.Since(start)
+	report.Attempts = delivery.Attempts
+	if err != nil {
+		sendErr := s.spoolOrFail(serviceName, formattedMessage, run, buttons, err)
+		result := history.ResultFailed
+		if errors.Is(sendErr, ErrSpooled) {
+			result = history.ResultSpooled
+			report.Spooled = true
+		}
+		s.recordAttempt(serviceName, formattedMessage, result, delivery, report.Duration, run, err)
+		return sendErr
+	}
+	s.recordAttempt(serviceName, formattedMessage, history.ResultDelivered, delivery, report.Duration, run, nil)
+	report.Delivered = true
+	report.MessageID = delivery.MessageID
+	report.Backend = delivery.Backend
+
+	// Opportunistically deliver notifications left over from earlier failures
+	if s.spool != nil {
+		s.FlushSpool(ctx)
 	}
 
 	return nil
 }
 
+// FlushSpool retries delivery of spooled notifications
+func (s *Service) FlushSpool(ctx context.Context) (spool.FlushResult, error) {
+	if s.spool == nil {
+		return spool.FlushResult{}, nil
+	}
+
+	retry := runInfo{retry: true}
+	result, err := s.spool.Flush(ctx, func(ctx context.Context, entry spool.Entry) error {
+		opts := s.sendOptions(entry.Service, entry.Severity)
+		if entry.Alert {
+			opts = append(opts, s.ackButton(entry.Service)...)
+		}
+		if len(entry.Buttons) > 0 {
+			opts = append(opts, telegram.WithButtons(entry.Buttons...))
+		}
+
+		start := time.Now()
+		delivery, err := s.telegram.Send(ctx, entry.Message, opts...)
+		if err != nil {
+			s.recordAttempt(entry.Service, entry.Message, history.ResultSpooled, delivery, time.Since(start), retry, err)
+			if telegram.IsPermanentError(err) {
+				return fmt.Errorf("%w: %v", spool.ErrPermanent, err)
+			}
+			return err
+		}
+		s.recordAttempt(entry.Service, entry.Message, history.ResultDelivered, delivery, time.Since(start), retry, nil)
+		if entry.Alert {
+			s.trackAlert(entry.Service, entry.Message, entry.Severity, delivery.MessageID)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, s.wrapError("flushing spool", "", err)
+	}
+	return result, nil
+}
+
+// sendOptions routes a notification to the chat its policy names and silences it when configured
+// With NOTIFIER_FORUM_TOPICS the Telegram client posts it in the service's topic
+// Free-form notifications have no level and always go to the notification chat
+func (s *Service) sendOptions(serviceName, level string) []telegram.SendOption {
+	if level == "" {
+		return nil
+	}
+	p := s.resolvePolicy(serviceName, level)
+	return []telegram.SendOption{telegram.WithChat(p.chat), telegram.WithSilent(p.silent), telegram.WithTopic(topicName(serviceName))}
+}
+
+// buttonRows returns the inline keyboard rows opts attach, for the spool to keep
+func buttonRows(opts []telegram.SendOption) [][]telegram.Button {
+	var m telegram.Message
+	for _, opt := range opts {
+		opt(&m)
+	}
+	if m.ReplyMarkup == nil {
+		return nil
+	}
+	return m.ReplyMarkup.Rows
+}
+
+// topicName names a service's forum topic: the unit without its ".service" suffix, or the job
+func topicName(serviceName string) string {
+	name := strings.TrimSuffix(serviceName, ".service")
+	return validation.TruncateHead(name, constants.MaxTopicName, validation.UTF16Length)
+}
+
+// spoolOrFail persists a notification that couldn't be delivered
+// Returns an error matching ErrSpooled when persisted so callers can treat the failure as deferred.
+// Notifications that can't be retried are written to the dead-letter log instead.
+func (s *Service) spoolOrFail(serviceName, message string, run runInfo, buttons [][]telegram.Button, sendErr error) error {
+	wrapped := s.wrapError("sending telegram notification", serviceName, sendErr)
+
+	if telegram.IsPermanentError(sendErr) {
+		s.recordDeadLetter(serviceName, message, deadletter.ReasonPermanentError, sendErr, 1, time.Time{})
+		return wrapped
+	}
+
+	if s.spool == nil {
+		s.recordDeadLetter(serviceName, message, deadletter.ReasonSpoolDisabled, sendErr, 1, time.Time{})
+		return wrapped
+	}
+
+	entry := spool.Entry{
+		Service:   serviceName,
+		Message:   message,
+		Attempts:  1,
+		LastError: validation.SanitizeErrorMessage(sendErr),
+		Severity:  run.severity,
+		Alert:     run.alert,
+		Buttons:   buttons,
+	}
+	if err := s.spool.Enqueue(entry); err != nil {
+		s.recordDeadLetter(serviceName, message, deadletter.ReasonSpoolFailed,
+			fmt.Errorf("%v (spooling failed: %v)", sendErr, err), 1, time.Time{})
+		return s.wrapError("sending telegram notification", serviceName,
+			fmt.Errorf("%w (spooling also failed: %v)", sendErr, err))
+	}
+
+	notifErr := wrapped.(*NotificationError)
+	notifErr.Spooled = true
+	return notifErr
+}
+
+// recordAttempt reports a delivery attempt to metrics and appends it to the audit log
+// Audit failures are logged but never block notification delivery
+func (s *Service) recordAttempt(serviceName, message, result string, delivery telegram.Delivery, latency time.Duration, run runInfo, err error) {
+	obs := metrics.Observation{
+		Service:       serviceName,
+		Result:        result,
+		Backend:       delivery.Backend,
+		Attempts:      delivery.Attempts,
+		Latency:       latency,
+		RateLimitWait: delivery.RateLimitWait,
+	}
+	for _, o := range s.observers {
+		o.Observe(obs)
+	}
+
+	if s.history == nil {
+		return
+	}
+
+	rec := history.Record{
+		Service:     serviceName,
+		Result:      result,
+		Backend:     delivery.Backend,
+		MessageHash: history.HashMessage(message),
+		MessageID:   delivery.MessageID,
+		Attempts:    delivery.Attempts,
+		LatencyMS:   latency.Milliseconds(),
+		Outcome:     run.outcome,
+		RuntimeMS:   run.runtime.Milliseconds(),
+		Retry:       run.retry,
+	}
+	for _, a := range delivery.AttemptLog {
+		rec.AttemptLog = append(rec.AttemptLog, history.Attempt{
+			Backend:   a.Backend,
+			Status:    a.StatusCode,
+			LatencyMS: a.Latency.Milliseconds(),
+			BackoffMS: a.Backoff.Milliseconds(),
+		})
+	}
+	if err != nil {
+		rec.Error = validation.SanitizeErrorMessage(err)
+	}
+
+	if err := s.history.Append(rec); err != nil {
+		slog.Warn("Failed to write history record", logging.KeyService, serviceName, logging.Err(err))
+	}
+}
+
+// recordSpoolDrop dead-letters entries the spool discarded without delivering
+func (s *Service) recordSpoolDrop(entry spool.Entry, reason string) {
+	reasons := map[string]string{
+		spool.DropEvicted:          deadletter.ReasonSpoolEvicted,
+		spool.DropPermanent:        deadletter.ReasonPermanentError,
+		spool.DropAttemptsExceeded: deadletter.ReasonAttemptsExceeded,
+	}
+	s.recordDeadLetter(entry.Service, entry.Message, reasons[reason],
+		errors.New(entry.LastError), entry.Attempts, entry.CreatedAt)
+}
+
+// recordDeadLetter writes a lost notification to the dead-letter log and the journal
+// SECURITY: Error text is sanitized before being persisted
+func (s *Service) recordDeadLetter(serviceName, message, reason string, err error, attempts int, createdAt time.Time) {
+	sanitized := validation.SanitizeErrorMessage(err)
+	slog.Error("Notification dead-lettered", logging.KeyService, serviceName, "reason", reason,
+		"attempts", attempts, logging.KeyError, sanitized)
+
+	if s.deadLetter == nil {
+		return
+	}
+
+	rec := deadletter.Record{
+		Service:   serviceName,
+		Reason:    reason,
+		Error:     sanitized,
+		Attempts:  attempts,
+		CreatedAt: createdAt,
+		Message:   message,
+	}
+	if err := s.deadLetter.Record(rec); err != nil {
+		slog.Warn("Failed to write dead-letter record", logging.KeyService, serviceName, logging.Err(err))
+	}
+}
+
 // getServiceDescription retrieves service description from systemd or uses provided value
 func (s *Service) getServiceDescription(ctx context.Context, serviceName, providedDesc string) string {
 	// Use provided description if it's meaningful (not empty or same as service name)
@@ -129,7 +994,7 @@ func (s *Service) getServiceDescription(ctx context.Context, serviceName, provid
 	}
 
 	// Fallback to systemd's description
-	serviceInfo, err := s.systemd.GetServiceInfo(ctx, serviceName)
+	serviceInfo, err := s.initSystem.GetServiceInfo(ctx, serviceName)
 	if err != nil {
 		return "Service description not available"
 	}
@@ -138,78 +1003,641 @@ func (s *Service) getServiceDescription(ctx context.Context, serviceName, provid
 
 // getCommandOutput retrieves and filters command output
 // SECURITY: Filters secrets from both custom messages and systemd output
-func (s *Service) getCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo, customMessage string) string {
-	// Use custom message if provided
+// Custom messages and log files are plain text; journal output comes formatted as Markdown, which plain reports
+// Piped logs are put in a Command Output section like the journal's, after the custom message if there is one
+// full is the uncut plain-text output, still unfiltered, for "Show more"
+func (s *Service) getCommandOutput(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo, customMessage, logs string, report *Report) (output string, plain bool, full string) {
+	if logs != "" {
+		// The logs are cut to the room the message and code block leave, so the cut can't reach the markup
+		const header, footer = "*Command Output*\n```\n", "\n```"
+		var intro string
+		if customMessage != "" {
+			// Escaping may double the message; it gets at most half of the output size
+			message := s.filterAndTruncateTo(customMessage, s.config.MaxOutputSize/4, report)
+			intro = markdown.Escape(message) + "\n\n"
+		}
+		room := max(0, s.config.MaxOutputSize-validation.UTF16Length(intro+header+footer))
+		body := s.filterAndTruncateTo(strings.TrimRight(logs, "\n"), room, report)
+		return intro + header + markdown.Literal(body) + footer, false, logs
+	}
+	// Use custom message if provided (may be arbitrary piped output, so truncate too)
 	if customMessage != "" {
-		return validation.FilterSecrets(customMessage)
+		return s.filterSecrets(customMessage, report), true, customMessage
 	}
 
-	// Get output from systemd journal
-	output, err := s.systemd.GetServiceCommandOutput(ctx, serviceName, exitInfo)
+	// Get output from the unit's log file when configured, otherwise from the systemd journal
+	var err error
+	if src, ok := s.outputs[serviceName]; ok {
+		output, err = src.Read(ctx)
+		plain, full = true, output
+	} else {
+		var journal systemd.CommandOutput
+		journal, err = s.initSystem.GetServiceCommandOutput(ctx, serviceName, exitInfo)
+		output, full = journal.Markdown, journal.Full
+	}
 	if err != nil {
 		// SECURITY: Filter secrets from error messages to prevent leakage
 		sanitized := validation.SanitizeErrorMessage(err)
-		return fmt.Sprintf("Unable to retrieve command output: %s", sanitized)
+		return fmt.Sprintf("Unable to retrieve command output: %s", sanitized), true, ""
 	}
 
-	// Filter secrets and truncate to size limits
-	filtered := validation.FilterSecrets(output)
-	return validation.TruncateMessage(filtered, s.config.MaxOutputSize)
+	// Filter secrets and truncate to size limits; plain output is cut once escaped
+	if plain {
+		return s.filterSecrets(output, report), plain, full
+	}
+	return s.filterAndTruncate(output, report), plain, full
 }
 
-// formatAndValidateMessage creates Telegram-formatted message with size validation
-func (s *Service) formatAndValidateMessage(data NotificationData) string {
-	// Select status emoji based on success/failure
-	status := "SUCCESS 🟢"
-	if !data.IsSuccess {
-		status = "FAILURE 🔴"
+// showMore keeps the part of a truncated notification's output it left out and returns a button paging through it
+// Only the output's end is shown, so the button pages backwards from there
+func (s *Service) showMore(serviceName, full string, report *Report) []telegram.SendOption {
+	if !report.Truncated || s.full == nil || !s.config.GetBotPolicy().Enabled() {
+		return nil
 	}
+	// Only the latest output is kept, at most as much as may be piped in, so a chatty unit can't fill the state directory
+	if start := len(full) - constants.MaxStdinSize; start > 0 {
+		full = full[start:]
+		if i := strings.IndexByte(full, '\n'); i >= 0 {
+			full = full[i+1:]
+		}
+		full = strings.ToValidUTF8(full, "")
+	}
+	// SECURITY: The kept output is redacted like the notification
+	full = validation.FilterSecrets(full)
+	end := validation.TailStart(full, s.config.MaxOutputSize, validation.UTF16Length)
+	if end == 0 {
+		return nil
+	}
+	// The notification starts mid-line; the first page repeats that line whole
+	if i := strings.IndexByte(full[end:], '\n'); i >= 0 {
+		end += i
+	}
+	id, err := s.full.Save(state.Output{Service: serviceName, Text: full[:end]})
+	if err != nil {
+		slog.Warn("Keeping full output failed", logging.KeyService, serviceName, logging.Err(err))
+		return nil
+	}
+	return []telegram.SendOption{telegram.WithButtons(MoreButton(id, end))}
+}
 
-	exitCodeDisplay := fmt.Sprintf("%d", data.ProcessExitCode)
+// MoreButton returns the button showing the output before byte offset end of stored output id
+func MoreButton(id string, end int) []telegram.Button {
+	return []telegram.Button{{Text: "📜 Show more", CallbackData: fmt.Sprintf("%s:%s:%d", MoreAction, id, end)}}
+}
 
-	// Format message using Markdown for Telegram
-	message := fmt.Sprintf(`*Automated Notification:* %s
+// filterSecrets redacts secrets, counting them in report
+func (s *Service) filterSecrets(text string, report *Report) string {
+	filtered, redactions := validation.FilterSecretsCount(text)
+	report.Redactions += redactions
+	return filtered
+}
 
-- 🖥️  *Host:* `+"`%s`"+`
-- 🕒  *Date/Time:* `+"`%s`"+`
-- 🔢  *Process Exit Code:* `+"`%s`"+`
-- ⚙️  *Service:* `+"`%s`"+`
-- 📄  *Description:* `+"`%s`"+`
+// escapeAndTruncate escapes redacted plain text for Markdown and enforces the output size limit on the result,
+// so escaping can't push it over and the cut never separates a backslash from the character it escapes
+func (s *Service) escapeAndTruncate(text string, report *Report) string {
+	escaped := markdown.Escape(text)
+	if validation.UTF16Length(escaped) > s.config.MaxOutputSize || strings.Contains(text, constants.OutputTruncatedMsg) {
+		report.Truncated = true
+	}
+	return markdown.TruncateTail(escaped, s.config.MaxOutputSize)
+}
 
-%s`,
-		status,
-		data.Hostname,
-		data.DateTime,
-		exitCodeDisplay,
-		data.ServiceName,
-		data.ServiceDesc,
-		data.Message)
+// filterAndTruncate redacts secrets and enforces the output size limit, noting both in report
+// Journal output may already carry the truncation marker from collection
+func (s *Service) filterAndTruncate(text string, report *Report) string {
+	return s.filterAndTruncateTo(text, s.config.MaxOutputSize, report)
+}
+
+// filterAndTruncateMarkdown is filterAndTruncate for Markdown, cutting only between its escapes and entities
+func (s *Service) filterAndTruncateMarkdown(text string, report *Report) string {
+	filtered := s.filterSecrets(text, report)
+	if validation.UTF16Length(filtered) > s.config.MaxOutputSize || strings.Contains(filtered, constants.OutputTruncatedMsg) {
+		report.Truncated = true
+	}
+	return markdown.TruncateTail(filtered, s.config.MaxOutputSize)
+}
+
+// filterAndTruncateTo is filterAndTruncate with a smaller limit, for output sharing the room with other text
+func (s *Service) filterAndTruncateTo(text string, maxSize int, report *Report) string {
+	filtered, redactions := validation.FilterSecretsCount(text)
+	report.Redactions += redactions
+	if validation.UTF16Length(filtered) > maxSize || strings.Contains(filtered, constants.OutputTruncatedMsg) {
+		report.Truncated = true
+	}
+	return validation.TruncateMessage(filtered, maxSize)
+}
+
+// getServiceVersion detects the monitored application version if a source is configured
+// An execstart source runs the unit's own binary with a guessed --version flag, so only failed runs pay that risk
+func (s *Service) getServiceVersion(ctx context.Context, serviceName string, success bool) string {
+	// Skip running version commands when the field won't be displayed
+	if !s.config.IsFieldVisible(constants.FieldVersion) {
+		return ""
+	}
+	if success && s.config.VersionSources[serviceName] == systemd.VersionSourceExecStart {
+		return ""
+	}
+
+	version, err := s.initSystem.GetServiceVersion(ctx, serviceName)
+	if err != nil {
+		return "unknown"
+	}
+	return version
+}
+
+// getFailedDependencies lists the unit's dependencies that are down, e.g. "postgresql.service (failed)"
+// Lookup errors leave the field out; they must not hold up the alert
+func (s *Service) getFailedDependencies(ctx context.Context, serviceName string) string {
+	down, err := s.initSystem.FailedDependencies(ctx, serviceName)
+	if err != nil {
+		slog.Debug("Checking dependencies failed", logging.KeyService, serviceName, logging.Err(err))
+		return ""
+	}
+	parts := make([]string, len(down))
+	for i, st := range down {
+		state := st.ActiveState
+		if st.LoadState == "not-found" {
+			state = "not found"
+		}
+		parts[i] = fmt.Sprintf("%s (%s)", st.Name, state)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatRuntime describes how long a run took, warning when it's far from the unit's usual run time
+// Returns an empty string when the run time is unknown
+func (s *Service) formatRuntime(serviceName string, runtime time.Duration) string {
+	if runtime <= 0 {
+		return ""
+	}
+	text := roundRuntime(runtime)
+	if s.config.RuntimeDeviation == 0 {
+		return text
+	}
+	avg, ok := s.averageRuntime(serviceName)
+	if !ok || max(runtime, avg) < constants.MinRuntimeDeviation {
+		return text
+	}
+	factor := float64(s.config.RuntimeDeviation)
+	ratio := float64(runtime) / float64(avg)
+	if ratio < factor && ratio > 1/factor {
+		return text
+	}
+	return fmt.Sprintf("%s (⚠️ %.1f× the average of %s)", text, ratio, roundRuntime(avg))
+}
+
+// averageRuntime returns a unit's average run time over its recent successful runs in the audit log
+// Failed runs often stop early, so they would drag the average down; too few runs give no average
+func (s *Service) averageRuntime(serviceName string) (time.Duration, bool) {
+	if s.history == nil {
+		return 0, false
+	}
+	records, err := s.history.Query(history.Filter{Service: serviceName, Limit: constants.RuntimeBaselineRecords})
+	if err != nil {
+		slog.Debug("Reading run times from history failed", logging.KeyService, serviceName, logging.Err(err))
+		return 0, false
+	}
+	successes := slices.DeleteFunc(records, func(rec history.Record) bool { return rec.Outcome != history.OutcomeSuccess })
+	for _, st := range history.Summarize(successes) {
+		if st.TimedRuns >= constants.MinRuntimeBaselineRuns {
+			return st.AvgRuntime, true
+		}
+	}
+	return 0, false
+}
+
+// roundRuntime formats a run time to the second, or to the millisecond when shorter than a second
+func roundRuntime(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
+
+// getHostDisplay returns the hostname, followed by primary IP addresses when enabled
+func (s *Service) getHostDisplay() string {
+	hostname := s.config.GetHostname()
+	if !s.config.IncludeIP {
+		return hostname
+	}
+
+	addrs, err := sysinfo.IPAddresses(s.config.IPInterfaces)
+	if err != nil || len(addrs) == 0 {
+		return hostname
+	}
+	return fmt.Sprintf("%s (%s)", hostname, strings.Join(addrs, ", "))
+}
+
+// getActivationCause describes what started the run; lookup errors leave the field out
+func (s *Service) getActivationCause(ctx context.Context, serviceName string) string {
+	activation, err := s.initSystem.ActivationCause(ctx, serviceName)
+	if err != nil {
+		slog.Debug("Finding the activation cause failed", logging.KeyService, serviceName, logging.Err(err))
+		return ""
+	}
+	return activation.String()
+}
+
+// getUnitFileHashes hashes the unit's files; nil when unknown, which never flags a change
+func (s *Service) getUnitFileHashes(ctx context.Context, serviceName string) map[string]string {
+	hashes, err := s.initSystem.UnitFileHashes(ctx, serviceName)
+	if err != nil {
+		slog.Debug("Hashing unit files failed", logging.KeyService, serviceName, logging.Err(err))
+		return nil
+	}
+	return hashes
+}
+
+// unitFileChanges lists the unit files edited, added or removed between two sets of hashes,
+// e.g. "/etc/systemd/system/backup.service.d/override.conf (added)"
+// Empty when either set is unknown or nothing changed
+func unitFileChanges(before, now map[string]string) string {
+	if before == nil || now == nil {
+		return ""
+	}
+	var paths []string
+	for path := range now {
+		paths = append(paths, path)
+	}
+	for path := range before {
+		if _, ok := now[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var changes []string
+	for _, path := range paths {
+		prev, existed := before[path]
+		hash, exists := now[path]
+		switch {
+		case !existed:
+			changes = append(changes, path+" (added)")
+		case !exists:
+			changes = append(changes, path+" (removed)")
+		case prev != hash:
+			changes = append(changes, path+" (edited)")
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// getSystemErrors reads the system journal's latest errors, redacted and cut to leave room for the unit's output
+// An empty or unreadable journal leaves the section out; users outside the adm group only see their own entries
+func (s *Service) getSystemErrors(ctx context.Context) string {
+	errs, err := s.initSystem.SystemErrors(ctx, s.config.SystemErrorLines)
+	if err != nil {
+		slog.Debug("Reading system errors failed", logging.Err(err))
+		return ""
+	}
+	// SECURITY: Other units' messages can carry credentials like any output
+	return validation.TruncateMessage(validation.FilterSecrets(errs), constants.MaxSystemErrorsSize)
+}
+
+// getSignalReport names the signal that killed the main process, adding the core dump's backtrace
+// and the coredumpctl command to inspect it when one was dumped
+// A dump that can't be found, e.g. without systemd-coredump, leaves just the signal
+func (s *Service) getSignalReport(ctx context.Context, exitInfo systemd.ExitCodeInfo) string {
+	report := systemd.SignalName(exitInfo.ProcessExitCode)
+	if exitInfo.ExitSignal != "dumped" {
+		return report
+	}
+	report += ", core dumped"
+	if exitInfo.MainPID <= 0 {
+		return report
+	}
+	dump, err := s.initSystem.CoreDump(ctx, exitInfo.MainPID, time.Now().Add(-constants.CoreDumpLookback))
+	if err != nil {
+		slog.Debug("Looking up the core dump failed", "pid", exitInfo.MainPID, logging.Err(err))
+		return report
+	}
+	// SECURITY: Paths and symbols are the program's, but get the same treatment as its output
+	return validation.FilterSecrets(formatCoreDump(report, dump))
+}
+
+// formatCoreDump renders the signal line followed by the executable, the backtrace and the coredumpctl reference
+func formatCoreDump(signal string, dump systemd.CoreDump) string {
+	lines := []string{signal}
+	if dump.Executable != "" {
+		lines = append(lines, "Executable: "+dump.Executable)
+	}
+	lines = append(lines, dump.Backtrace...)
+	if more := dump.Frames - len(dump.Backtrace); more > 0 {
+		lines = append(lines, fmt.Sprintf("… %d more frames", more))
+	}
+	return strings.Join(append(lines, "Details: "+dump.Reference()), "\n")
+}
+
+// mayBeOOMKill reports whether a run's end could be an out-of-memory kill: systemd says so,
+// or the main process got the SIGKILL the kernel OOM killer sends
+func mayBeOOMKill(exitInfo systemd.ExitCodeInfo) bool {
+	return exitInfo.Result == "oom-kill" || (exitInfo.ExitSignal == "killed" && exitInfo.ProcessExitCode == 9)
+}
+
+// getOOMReport describes an out-of-memory kill of the unit with the kernel's messages and its memory peak against the limit
+// Empty when neither systemd nor the kernel log tells of one, leaving the signal to be reported as such
+func (s *Service) getOOMReport(ctx context.Context, serviceName string, exitInfo systemd.ExitCodeInfo) string {
+	since := time.Now().Add(-exitInfo.Runtime - constants.RunStartSlack)
+	kills, err := s.initSystem.OOMKills(ctx, serviceName, exitInfo.MainPID, since)
+	if err != nil {
+		slog.Debug("Reading OOM kills failed", logging.KeyService, serviceName, logging.Err(err))
+	}
+	if kills == "" && exitInfo.Result != "oom-kill" {
+		return ""
+	}
+
+	// systemd-oomd and OOMPolicy= kills leave no kernel message
+	lines := []string{"Killed for running out of memory"}
+	if kills != "" {
+		lines = append(lines, kills)
+	}
+	if usage, err := s.initSystem.CgroupUsage(ctx, serviceName); err == nil {
+		if line := usageAgainstLimit(max(usage.MemoryCurrent, usage.MemoryPeak), usage.MemoryMax, sysinfo.FormatBytes); line != "" {
+			lines = append(lines, "Memory peak: "+line)
+		}
+	}
+	// SECURITY: Process names are the program's, but get the same treatment as its output
+	return validation.FilterSecrets(strings.Join(lines, "\n"))
+}
+
+// getSecurityDenials reads the MAC denials logged since the run started, preferring the main process's own
+// Reading audit entries needs the same journal access as system errors; none found leaves the section out
+func (s *Service) getSecurityDenials(ctx context.Context, exitInfo systemd.ExitCodeInfo) string {
+	since := time.Now().Add(-exitInfo.Runtime - constants.RunStartSlack)
+	denials, err := s.initSystem.SecurityDenials(ctx, exitInfo.MainPID, since)
+	if err != nil {
+		slog.Debug("Reading security denials failed", logging.Err(err))
+		return ""
+	}
+	// SECURITY: Denials name the paths and arguments the process used
+	return validation.TruncateMessage(validation.FilterSecrets(denials), constants.MaxDenialsSize)
+}
+
+// getCgroupUsage describes the unit's resource use against its limits; lookup errors leave the section out
+func (s *Service) getCgroupUsage(ctx context.Context, serviceName string) string {
+	usage, err := s.initSystem.CgroupUsage(ctx, serviceName)
+	if err != nil {
+		slog.Debug("Reading cgroup usage failed", logging.KeyService, serviceName, logging.Err(err))
+		return ""
+	}
+	return formatCgroupUsage(usage)
+}
+
+// getHealthSnapshot collects and formats system health for failure notifications
+func (s *Service) getHealthSnapshot() string {
+	snapshot, err := sysinfo.CollectHealth()
+	if err != nil {
+		return ""
+	}
+	return snapshot.Format()
+}
+
+// formatAndValidateMessage creates Telegram-formatted message with size validation
+// Reports whether the message content had to be truncated to fit
+func (s *Service) formatAndValidateMessage(data NotificationData) (string, bool) {
+	// Select status emoji based on success/failure and the failure's severity
+	status := "SUCCESS 🟢"
+	switch {
+	case data.Title != "":
+		status = markdown.Escape(data.Title) + " 📣"
+	case !data.IsSuccess && data.Severity == severity.Critical:
+		status = "CRITICAL FAILURE 🚨"
+	case !data.IsSuccess && data.Severity == severity.Info:
+		status = "FAILURE 🔵"
+	case !data.IsSuccess:
+		status = "FAILURE 🔴"
+	case data.FailingSince != "":
+		status = "RECOVERED ✅"
+	}
+
+	message := s.renderMessage(status, data, data.Message)
 
 	// Ensure message fits within Telegram's 4096 character limit with safety margin
+	// Telegram counts UTF-16 code units, so emoji-heavy headers take more room than their runes suggest
 	maxSize := constants.TelegramMaxMessageSize - constants.MessageSafetyMargin
-	if len(message) > maxSize {
+	if size := validation.UTF16Length(message); size > maxSize {
 		// Calculate how much space is available for the message content
-		headerSize := len(message) - len(data.Message)
+		headerSize := size - validation.UTF16Length(data.Message)
 		allowedMessageSize := maxSize - headerSize
 
 		if allowedMessageSize > 0 {
-			// Truncate just the message content, keep headers intact
-			truncatedMsg := validation.TruncateMessage(data.Message, allowedMessageSize)
-			message = fmt.Sprintf(`*Automated Notification:* %s
+			// Truncate just the message content, keep headers intact; the cut keeps its Markdown whole
+			truncatedMsg := markdown.TruncateTail(data.Message, allowedMessageSize)
+			return s.renderMessage(status, data, truncatedMsg), true
+		}
+	}
+
+	return message, false
+}
+
+// renderMessage formats notification fields using Markdown for Telegram
+// Fields hidden via configuration or without a value are omitted
+func (s *Service) renderMessage(status string, data NotificationData, body string) string {
+	var b strings.Builder
+
+	// Free-form notifications have no process, so there's no exit code to show
+	exitCode := fmt.Sprintf("%d", data.ProcessExitCode)
+	if data.Title != "" {
+		exitCode = ""
+	}
+
+	fields := []struct {
+		name, emoji, label, value string
+	}{
+		{constants.FieldHost, "🖥️", "Host", data.Hostname},
+		{constants.FieldTimestamp, "🕒", "Date/Time", data.DateTime},
+		{constants.FieldFailingSince, "⏳", "Failing Since", data.FailingSince},
+		{constants.FieldExitCode, "🔢", "Process Exit Code", exitCode},
+		{constants.FieldRuntime, "⏱️", "Run Time", data.Runtime},
+		{constants.FieldService, "⚙️", "Service", serviceField(data.ServiceName)},
+		{constants.FieldDescription, "📄", "Description", data.ServiceDesc},
+		{constants.FieldStartedBy, "🎯", "Started By", data.StartedBy},
+		{constants.FieldDependencies, "🔗", "Dependencies Down", data.Dependencies},
+		{constants.FieldUnitChanged, "📝", "Unit Changed", data.UnitChanged},
+		{constants.FieldInvocationID, "🆔", "Invocation ID", data.InvocationID},
+		{constants.FieldVersion, "🏷️", "Version", data.Version},
+	}
 
-- 🖥️  *Host:* `+"`%s`"+`
-- 🕒  *Date/Time:* `+"`%s`"+`
-- 🔢  *Process Exit Code:* `+"`%s`"+`
-- ⚙️  *Service:* `+"`%s`"+`
-- 📄  *Description:* `+"`%s`"+`
+	fmt.Fprintf(&b, "*Automated Notification:* %s\n\n", status)
+	for _, f := range fields {
+		if f.value == "" || !s.config.IsFieldVisible(f.name) {
+			continue
+		}
+		writeField(&b, f.emoji, f.label, f.value)
+	}
+	b.WriteString("\n")
+	b.WriteString(body)
+
+	// Tell the reader the output was altered and where the original is
+	if data.Redactions > 0 {
+		b.WriteString("\n\n" + redactionNote(data.Redactions, data.RawOutput))
+	}
+
+	if data.OOM != "" {
+		b.WriteString("\n\n*Out of Memory*\n```\n" + markdown.Literal(data.OOM) + "\n```")
+	}
+	if data.Signal != "" {
+		b.WriteString("\n\n*Signal*\n```\n" + markdown.Literal(data.Signal) + "\n```")
+	}
+
+	// Append system health snapshot when collected
+	if data.Health != "" {
+		b.WriteString("\n\n*System Health*\n```\n" + markdown.Literal(data.Health) + "\n```")
+	}
+	if data.Cgroup != "" {
+		b.WriteString("\n\n*Resource Usage*\n```\n" + markdown.Literal(data.Cgroup) + "\n```")
+	}
+	if data.Denials != "" {
+		b.WriteString("\n\n*Security Denials*\n```\n" + markdown.Literal(data.Denials) + "\n```")
+	}
+	if data.SystemErrors != "" {
+		b.WriteString("\n\n*System Errors*\n```\n" + markdown.Literal(data.SystemErrors) + "\n```")
+	}
+
+	return b.String()
+}
+
+// nearLimitPercent is the share of a limit from which resource use is marked as close to it
+const nearLimitPercent = 90
+
+// formatCgroupUsage renders a unit's task, memory and CPU use against its limits, one line each
+// Limits the unit reached or came close to are marked; unknown and unlimited values are left out
+func formatCgroupUsage(u systemd.CgroupUsage) string {
+	var lines []string
+	if line := usageAgainstLimit(u.TasksCurrent, u.TasksMax, func(n uint64) string { return fmt.Sprint(n) }); line != "" {
+		lines = append(lines, "Tasks: "+line)
+	}
+
+	// A unit stopped at its limit uses nothing by the time it's reported, so the peak says more
+	if line := usageAgainstLimit(max(u.MemoryCurrent, u.MemoryPeak), u.MemoryMax, sysinfo.FormatBytes); line != "" {
+		if u.MemoryPeak > u.MemoryCurrent {
+			line = "peak " + line
+			if u.MemoryCurrent > 0 {
+				line += ", now " + sysinfo.FormatBytes(u.MemoryCurrent)
+			}
+		}
+		if u.MemoryHigh > 0 {
+			line += ", throttled above " + sysinfo.FormatBytes(u.MemoryHigh)
+		}
+		if u.MemoryMaxHits > 0 {
+			line += fmt.Sprintf(", reached the limit %d times ⚠️", u.MemoryMaxHits)
+		}
+		lines = append(lines, "Memory: "+line)
+	}
+
+	var cpu []string
+	if u.CPUQuota > 0 {
+		cpu = append(cpu, fmt.Sprintf("quota %.0f%%", u.CPUQuota.Seconds()*100))
+	}
+	if u.ThrottledPeriods > 0 {
+		cpu = append(cpu, fmt.Sprintf("throttled in %d periods (%s) ⚠️", u.ThrottledPeriods, roundRuntime(u.ThrottledTime)))
+	}
+	if len(cpu) > 0 {
+		lines = append(lines, "CPU: "+strings.Join(cpu, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
 
-%s`,
-				status, data.Hostname, data.DateTime,
-				exitCodeDisplay, data.ServiceName, data.ServiceDesc, truncatedMsg)
+// usageAgainstLimit formats use as "used / limit (percent)", marked when close to the limit
+func usageAgainstLimit(used, limit uint64, format func(uint64) string) string {
+	switch {
+	case used == 0 && limit == 0:
+		return ""
+	case limit == 0:
+		return format(used)
+	case used == 0:
+		return "limit " + format(limit)
+	}
+	line := fmt.Sprintf("%s / %s (%.0f%%)", format(used), format(limit), float64(used)/float64(limit)*100)
+	if used*100 >= limit*nearLimitPercent {
+		line += " ⚠️"
+	}
+	return line
+}
+
+// anyUnhealthy reports whether a pool needs attention
+func anyUnhealthy(pools []poolstatus.Pool) bool {
+	for _, p := range pools {
+		if !p.Healthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPools renders pool health as fields, listing only devices that need attention
+// Values are code spans since device paths and scan results contain Markdown characters
+func formatPools(pools []poolstatus.Pool, rawOutput string) string {
+	var b strings.Builder
+	for i, p := range pools {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		mark := "✅"
+		if !p.Healthy() {
+			mark = "⚠️"
+		}
+		fmt.Fprintf(&b, "%s *%s* %s\n", mark, p.Kind, markdown.Code(p.Name))
+		if p.State != "" {
+			fmt.Fprintf(&b, "- *State:* %s\n", markdown.Code(p.State))
+		}
+		if p.Scan != "" {
+			fmt.Fprintf(&b, "- *Scan:* %s\n", markdown.Code(p.Scan))
+		}
+		if p.Errors != "" {
+			fmt.Fprintf(&b, "- *Errors:* %s\n", markdown.Code(p.Errors))
+		}
+		if p.Corrected > 0 || p.Uncorrectable > 0 {
+			fmt.Fprintf(&b, "- *Corrected:* `%d`, *uncorrectable:* `%d`\n", p.Corrected, p.Uncorrectable)
+		}
+		for _, d := range p.Devices {
+			fmt.Fprintf(&b, "- *Device:* %s read %s, write %s, checksum %s\n",
+				markdown.Code(d.Name+" "+d.State), markdown.Code(d.Read), markdown.Code(d.Write), markdown.Code(d.Checksum))
 		}
 	}
+	if rawOutput != "" {
+		fmt.Fprintf(&b, "\nFull output: %s", markdown.Code(rawOutput))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// redactionNote summarizes secret filtering, pointing at the raw output when there is one
+func redactionNote(count int, rawOutput string) string {
+	noun := "secrets"
+	if count == 1 {
+		noun = "secret"
+	}
+	note := fmt.Sprintf("⚠️ %d %s redacted", count, noun)
+	if rawOutput != "" {
+		note += ", full output: " + markdown.Code(rawOutput)
+	}
+	return note
+}
+
+// journalCommand returns the journalctl invocation showing a unit's unfiltered output
+// The invocation ID narrows it to the run being reported
+func journalCommand(serviceName, invocationID string) string {
+	if invocationID != "" {
+		return "journalctl _SYSTEMD_INVOCATION_ID=" + invocationID
+	}
+	// Escaped names (\xNN) must reach journalctl intact when the command is pasted into a shell
+	if strings.Contains(serviceName, `\`) {
+		return "journalctl -u '" + serviceName + "'"
+	}
+	return "journalctl -u " + serviceName
+}
+
+// serviceField shows a unit name, followed by the path or instance it escapes when that is more readable
+func serviceField(serviceName string) string {
+	if readable := validation.UnescapedUnitName(serviceName); readable != "" {
+		return serviceName + " (" + readable + ")"
+	}
+	return serviceName
+}
 
-	return message
+// writeField writes a single "- emoji  *Label:* `value`" header line
+// Values are code spans since descriptions and hostnames are free text
+func writeField(b *strings.Builder, emoji, label, value string) {
+	fmt.Fprintf(b, "- %s  *%s:* %s\n", emoji, label, markdown.Code(value))
 }
 
 // wrapError wraps errors with context and filters secrets
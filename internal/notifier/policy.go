@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"time"
+
+	"telegram-notifier/internal/schedule"
+)
+
+// policy is the notification policy in effect for one unit or job
+type policy struct {
+	notify     string            // always, failure-only or recovery
+	threshold  int               // Consecutive failures before alerting
+	quietHours []schedule.Window // Daily windows in which successes aren't sent
+	chat       string            // Chat its notifications go to; empty for TELEGRAM_CHAT_ID
+	silent     bool              // Sent without a notification sound
+	escalate   bool              // Failures repeat until acknowledged
+}
+
+// resolvePolicy computes the effective policy for a run of a unit or job with the given severity level
+// The global settings and severity routing apply first; the services file's entry for the unit overrides them
+func (s *Service) resolvePolicy(serviceName, level string) policy {
+	p := policy{
+		notify:     s.config.GetNotifyPolicy(serviceName),
+		threshold:  s.config.GetFailureThreshold(serviceName),
+		quietHours: s.config.QuietHours,
+		chat:       s.config.GetSeverityChat(level),
+		silent:     s.config.IsSilentSeverity(level),
+		escalate:   s.config.ShouldEscalate(serviceName, level),
+	}
+
+	override, ok := s.config.GetServicePolicy(serviceName)
+	if !ok {
+		return p
+	}
+	if override.Threshold > 0 {
+		p.threshold = override.Threshold
+	}
+	if override.QuietHoursSet {
+		p.quietHours = override.QuietHours
+	}
+	if override.Chat != "" {
+		p.chat = override.Chat
+	}
+	if override.Escalate != nil {
+		p.escalate = *override.Escalate
+	}
+	return p
+}
+
+// inQuietHours reports whether t falls inside one of the policy's quiet windows, in the configured timezone
+func (s *Service) inQuietHours(p policy, t time.Time) bool {
+	return schedule.Active(p.quietHours, t.In(s.config.TimeLocation))
+}
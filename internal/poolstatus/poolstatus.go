@@ -0,0 +1,210 @@
+// Package poolstatus extracts storage pool health from "zpool status" and "btrfs scrub status" output
+// Scrub units print these reports; parsing them lets notifications show state and error counts instead of a raw dump
+package poolstatus
+
+import (
+	"strings"
+)
+
+// Kinds of parsed reports
+const (
+	KindZFS   = "ZFS pool"
+	KindBtrfs = "btrfs filesystem"
+)
+
+// healthyZFSStates are vdev and pool states that need no attention
+var healthyZFSStates = map[string]bool{"ONLINE": true, "AVAIL": true, "INUSE": true}
+
+// DeviceErrors is a pool member with non-zero error counters or an unhealthy state
+type DeviceErrors struct {
+	Name     string
+	State    string
+	Read     string // Counters are kept as printed, zpool abbreviates large ones ("1.2K")
+	Write    string
+	Checksum string
+}
+
+// Pool is the health of one ZFS pool or btrfs filesystem
+type Pool struct {
+	Kind    string
+	Name    string // Pool name, or the UUID for btrfs
+	State   string // zpool state, or the scrub status for btrfs
+	Scan    string // Last scrub or resilver result
+	Errors  string // Data error summary
+	Devices []DeviceErrors
+
+	// btrfs error summary counts; zero for ZFS
+	Corrected     int
+	Uncorrectable int
+
+	healthy bool
+}
+
+// Healthy reports whether the pool needs no attention
+func (p Pool) Healthy() bool {
+	return p.healthy
+}
+
+// Parse extracts every pool report found in output, ignoring unrelated lines around them
+// Returns nil when output contains no recognizable report
+func Parse(output string) []Pool {
+	lines := strings.Split(output, "\n")
+	if pools := parseZFS(lines); len(pools) > 0 {
+		return pools
+	}
+	return parseBtrfs(lines)
+}
+
+// parseZFS reads "zpool status" blocks, which start with "pool:" and list vdevs under "config:"
+//
+//	  pool: tank
+//	 state: DEGRADED
+//	  scan: scrub repaired 0B in 00:10:02 with 0 errors on Sun Jan 14 00:34:03 2024
+//	config:
+//
+//		NAME        STATE     READ WRITE CKSUM
+//		tank        DEGRADED     0     0     0
+//		  sdb       FAULTED      3     0    12
+//
+//	errors: No known data errors
+func parseZFS(lines []string) []Pool {
+	var pools []Pool
+	var current *Pool
+	inConfig := false
+
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		key, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "pool":
+			pools = append(pools, Pool{Kind: KindZFS, Name: value})
+			current = &pools[len(pools)-1]
+			inConfig = false
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "state":
+			current.State = value
+		case "scan":
+			current.Scan = value
+		case "config":
+			inConfig = true
+		case "errors":
+			current.Errors = value
+			inConfig = false
+		default:
+			if inConfig {
+				if device, ok := parseVdevLine(line); ok && device.Name != current.Name {
+					current.Devices = append(current.Devices, device)
+				}
+			}
+		}
+	}
+
+	for i := range pools {
+		p := &pools[i]
+		p.healthy = healthyZFSStates[p.State] && len(p.Devices) == 0 &&
+			(p.Errors == "" || p.Errors == "No known data errors")
+	}
+	return pools
+}
+
+// parseVdevLine parses a "NAME STATE READ WRITE CKSUM" row, reporting only devices needing attention
+func parseVdevLine(line string) (DeviceErrors, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] == "NAME" {
+		return DeviceErrors{}, false
+	}
+	device := DeviceErrors{Name: fields[0], State: fields[1], Read: fields[2], Write: fields[3], Checksum: fields[4]}
+	clean := healthyZFSStates[device.State] && device.Read == "0" && device.Write == "0" && device.Checksum == "0"
+	return device, !clean
+}
+
+// parseBtrfs reads "btrfs scrub status" and "btrfs scrub start -B" reports
+//
+//	UUID:             5bd2a3b5-…
+//	Status:           finished
+//	Duration:         0:10:12
+//	Error summary:    csum=3
+//	  Corrected:      2
+//	  Uncorrectable:  1
+//
+// Older btrfs-progs print "total bytes scrubbed: 1.20TiB with 3 errors" instead of a summary
+func parseBtrfs(lines []string) []Pool {
+	var pools []Pool
+	var current *Pool
+
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+
+		// Each report starts with the filesystem it covers
+		if uuid, ok := btrfsReportStart(line); ok {
+			pools = append(pools, Pool{Kind: KindBtrfs, Name: uuid})
+			current = &pools[len(pools)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "status":
+			current.State = value
+		case "duration":
+			current.Scan = "scrub took " + value
+		case "error summary":
+			current.Errors = value
+		case "corrected":
+			current.Corrected = atoi(value)
+		case "uncorrectable":
+			current.Uncorrectable = atoi(value)
+		case "total bytes scrubbed":
+			current.Errors = value
+			if _, errors, ok := strings.Cut(value, " with "); ok {
+				current.Uncorrectable = atoi(errors)
+			}
+		default:
+			if strings.HasPrefix(line, "scrub started at") {
+				current.Scan = line
+			}
+		}
+	}
+
+	for i := range pools {
+		p := &pools[i]
+		p.healthy = p.Uncorrectable == 0 && p.Corrected == 0 &&
+			(p.Errors == "" || p.Errors == "no errors found" || strings.HasSuffix(p.Errors, " with 0 errors")) &&
+			(p.State == "" || p.State == "finished" || p.State == "running")
+	}
+	return pools
+}
+
+// btrfsReportStart recognizes the first line of a scrub report and returns the filesystem UUID
+func btrfsReportStart(line string) (string, bool) {
+	for _, prefix := range []string{"UUID:", "scrub status for ", "Scrub done for ", "scrub done for "} {
+		if rest, ok := strings.CutPrefix(line, prefix); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// atoi parses a leading decimal count, returning 0 for anything else
+func atoi(s string) int {
+	n := 0
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			break
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
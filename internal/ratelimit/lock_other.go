@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ratelimit
+
+// lock is a no-op on platforms without flock; concurrent processes may both take the last token there
+func lock(path string) (func(), error) {
+	return func() {}, nil
+}
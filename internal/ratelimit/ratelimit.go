@@ -2,7 +2,9 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"time"
 
@@ -15,6 +17,7 @@ type TokenBucket struct {
 	maxTokens  float64
 	refillRate float64
 	lastRefill time.Time
+	path       string // File the state is shared through; empty keeps it in this process
 	mu         sync.Mutex
 }
 
@@ -30,42 +33,65 @@ func NewTokenBucket(maxTokens int, refillRate time.Duration) *TokenBucket {
 
 // Wait blocks until a token is available or context is cancelled
 func (tb *TokenBucket) Wait(ctx context.Context) error {
-	deadline := time.Now().Add(constants.RateLimitMaxWaitTime)
+	return tb.wait(ctx, time.Now().Add(constants.RateLimitMaxWaitTime))
+}
+
+// WaitContext blocks until a token is available, bounded only by the context
+func (tb *TokenBucket) WaitContext(ctx context.Context) error {
+	return tb.wait(ctx, time.Time{})
+}
 
+// Allow takes a token when one is available, without waiting
+func (tb *TokenBucket) Allow() bool {
+	return tb.take() == 0
+}
+
+// wait sleeps until the next token is due, then takes it, until the deadline (zero = none) or context cancellation
+// Another caller may take the token first, in which case it waits for the following one
+// A token not due before the deadline fails right away instead of sleeping until it passes
+func (tb *TokenBucket) wait(ctx context.Context, deadline time.Time) error {
 	for {
-		if tb.tryTake() {
+		delay := tb.take()
+		if delay == 0 {
 			return nil
 		}
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("rate limit wait timeout: next token due in %v, past the wait limit", delay.Round(time.Millisecond))
+		}
 
+		timer := time.NewTimer(delay)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return fmt.Errorf("rate limit wait cancelled: %w", ctx.Err())
-		case <-time.After(100 * time.Millisecond):
-			if time.Now().After(deadline) {
-				return fmt.Errorf("rate limit wait timeout after %v", constants.RateLimitMaxWaitTime)
-			}
+		case <-timer.C:
 		}
 	}
 }
 
-// tryTake attempts to take a token, returns true if successful
-func (tb *TokenBucket) tryTake() bool {
+// take takes a token when one is available and returns zero, or else how long until the next one is due
+func (tb *TokenBucket) take() time.Duration {
 	tb.mu.Lock()
 	defer tb.mu.Unlock()
 
+	if tb.path != "" {
+		defer tb.sync()()
+	}
 	tb.refill()
 
 	if tb.tokens >= 1.0 {
 		tb.tokens--
-		return true
+		return 0
 	}
-	return false
+	// Rounded up, so the timer doesn't fire a moment before the token is whole
+	return time.Duration(math.Ceil((1.0 - tb.tokens) / tb.refillRate * float64(time.Second)))
 }
 
 // refill adds tokens based on time elapsed
 func (tb *TokenBucket) refill() {
 	now := time.Now()
-	elapsed := now.Sub(tb.lastRefill).Seconds()
+	// A clock stepped back, or another process's later refill, adds nothing
+	elapsed := max(now.Sub(tb.lastRefill).Seconds(), 0)
 	tb.tokens += elapsed * tb.refillRate
 
 	if tb.tokens > tb.maxTokens {
@@ -74,3 +100,54 @@ func (tb *TokenBucket) refill() {
 
 	tb.lastRefill = now
 }
+
+// ErrQueueFull is returned when too many callers are already waiting for tokens
+var ErrQueueFull = errors.New("rate limit queue full")
+
+// Queue serializes bursts against a token bucket instead of failing them
+// At most capacity callers wait at once, each for at most maxWait; further callers are rejected
+// with ErrQueueFull, and late ones time out, so they can fall back to persistent storage
+// rather than blocking indefinitely.
+type Queue struct {
+	bucket  *TokenBucket
+	slots   chan struct{}
+	turn    chan struct{}
+	maxWait time.Duration
+}
+
+// NewQueue creates a bounded wait queue in front of a token bucket
+func NewQueue(bucket *TokenBucket, capacity int, maxWait time.Duration) *Queue {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Queue{
+		bucket:  bucket,
+		slots:   make(chan struct{}, capacity),
+		turn:    make(chan struct{}, 1),
+		maxWait: maxWait,
+	}
+}
+
+// Acquire waits in line for a token, for at most the queue's maxWait
+func (q *Queue) Acquire(ctx context.Context) error {
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return ErrQueueFull
+	}
+	defer func() { <-q.slots }()
+
+	deadline := time.Now().Add(q.maxWait)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	// Serialize waiters so tokens are handed out in arrival order
+	select {
+	case q.turn <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("rate limit wait ended in the queue: %w", ctx.Err())
+	}
+	defer func() { <-q.turn }()
+
+	return q.bucket.wait(ctx, deadline)
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"telegram-notifier/internal/logging"
+)
+
+const (
+	dirPerm  = 0o700
+	filePerm = 0o600
+)
+
+// sharedState is a bucket's state as kept on disk
+type sharedState struct {
+	Tokens     float64   `json:"tokens"`
+	LastRefill time.Time `json:"last_refill"`
+}
+
+// NewSharedTokenBucket creates a rate limiter whose tokens are kept in path,
+// so every process using the file draws from the same bucket
+// Each notification runs in its own hook process, which a bucket in memory would not limit together
+func NewSharedTokenBucket(path string, maxTokens int, refillRate time.Duration) *TokenBucket {
+	tb := NewTokenBucket(maxTokens, refillRate)
+	tb.path = path
+	return tb
+}
+
+// sync takes the file lock and loads the bucket's state, returning a function that stores
+// the updated state and releases the lock
+// Without a usable file the bucket carries on with the state it has in this process
+func (tb *TokenBucket) sync() func() {
+	if err := os.MkdirAll(filepath.Dir(tb.path), dirPerm); err != nil {
+		slog.Debug("Rate limit state unavailable; limiting this process alone", logging.Err(err))
+		return func() {}
+	}
+	unlock, err := lock(tb.path + ".lock")
+	if err != nil {
+		slog.Debug("Rate limit state unavailable; limiting this process alone", logging.Err(err))
+		return func() {}
+	}
+
+	// A missing or damaged file leaves the in-process state, which starts full
+	var st sharedState
+	if data, err := os.ReadFile(tb.path); err == nil && json.Unmarshal(data, &st) == nil && !st.LastRefill.IsZero() {
+		tb.tokens, tb.lastRefill = min(st.Tokens, tb.maxTokens), st.LastRefill
+	}
+
+	return func() {
+		defer unlock()
+		if err := writeJSON(tb.path, sharedState{Tokens: tb.tokens, LastRefill: tb.lastRefill}); err != nil {
+			slog.Debug("Saving rate limit state failed", logging.Err(err))
+		}
+	}
+}
+
+// writeJSON writes v to path atomically via temp file and rename
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,139 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock system calls, shared by all architectures since Linux 5.13
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	prSetNoNewPrivs = 38
+	oPath           = 0x200000 // O_PATH; not exported by package syscall
+)
+
+// Landlock filesystem access rights (ABI 1, plus truncate from ABI 3)
+const (
+	accessExecute    = 1 << 0
+	accessWriteFile  = 1 << 1
+	accessReadFile   = 1 << 2
+	accessReadDir    = 1 << 3
+	accessRemoveDir  = 1 << 4
+	accessRemoveFile = 1 << 5
+	accessMakeChar   = 1 << 6
+	accessMakeDir    = 1 << 7
+	accessMakeReg    = 1 << 8
+	accessMakeSock   = 1 << 9
+	accessMakeFifo   = 1 << 10
+	accessMakeBlock  = 1 << 11
+	accessMakeSym    = 1 << 12
+	accessTruncate   = 1 << 14
+
+	accessABI1 = accessExecute | accessWriteFile | accessReadFile | accessReadDir |
+		accessRemoveDir | accessRemoveFile | accessMakeChar | accessMakeDir |
+		accessMakeReg | accessMakeSock | accessMakeFifo | accessMakeBlock | accessMakeSym
+
+	// Rights that apply to regular files; rules on files may only grant these
+	accessFileRights = accessExecute | accessWriteFile | accessReadFile | accessTruncate
+
+	accessRead      = accessReadFile | accessReadDir
+	accessReadWrite = accessRead | accessWriteFile | accessTruncate | accessRemoveFile | accessMakeReg | accessMakeDir | accessRemoveDir | accessMakeSock
+	accessRunnable  = accessExecute | accessReadFile | accessReadDir
+)
+
+// Restrict confines the whole process (all threads) to the policy with Landlock
+// Children such as systemctl and journalctl inherit the restrictions
+// Returns ErrUnsupported when the kernel lacks Landlock or the build can't apply it to every thread
+func Restrict(policy Policy) error {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 || int(abi) < 1 {
+		return fmt.Errorf("%w: landlock unavailable (%v)", ErrUnsupported, errno)
+	}
+
+	handled := uint64(accessABI1)
+	if abi >= 3 {
+		handled |= accessTruncate
+	}
+
+	// struct landlock_ruleset_attr { __u64 handled_access_fs; }
+	attr := handled
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	rules := []struct {
+		paths  []string
+		access uint64
+	}{
+		{policy.ReadOnly, accessRead},
+		{policy.ReadWrite, accessReadWrite},
+		{policy.Exec, accessRunnable},
+	}
+	for _, rule := range rules {
+		for _, path := range rule.paths {
+			if err := addPathRule(int(fd), path, rule.access&handled); err != nil {
+				return fmt.Errorf("adding landlock rule for %s: %w", path, err)
+			}
+		}
+	}
+
+	// Landlock requires no_new_privs so a setuid binary can't shed the sandbox
+	// AllThreadsSyscall applies both calls to every Go thread; it fails in cgo builds
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		if errno == syscall.ENOTSUP {
+			return fmt.Errorf("%w: rebuild with CGO_ENABLED=0", ErrUnsupported)
+		}
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+	return nil
+}
+
+// addPathRule allows access beneath path
+// Missing paths, and paths the process couldn't use anyway, are skipped
+func addPathRule(rulesetFD int, path string, access uint64) error {
+	f, err := os.OpenFile(path, os.O_RDONLY|oPath|syscall.O_CLOEXEC, 0)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		access &= accessFileRights
+	}
+
+	// struct landlock_path_beneath_attr { __u64 allowed_access; __s32 parent_fd; } __attribute__((packed))
+	var attr [12]byte
+	binary.NativeEndian.PutUint64(attr[0:8], access)
+	binary.NativeEndian.PutUint32(attr[8:12], uint32(f.Fd()))
+
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFD), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
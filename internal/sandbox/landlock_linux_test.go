@@ -0,0 +1,72 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// restrictedEnv makes the test binary run restrictedChild instead of the tests,
+// since Landlock can't be lifted from the process that applies it
+const restrictedEnv = "SANDBOX_TEST_RESTRICTED_DIR"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(restrictedEnv); dir != "" {
+		os.Exit(restrictedChild(dir))
+	}
+	os.Exit(m.Run())
+}
+
+// restrictedChild binds sockets in dir under a policy that only lets it write there
+// Exit codes: 0 bound both, 1 failed, 2 Landlock unsupported
+func restrictedChild(dir string) int {
+	if err := Restrict(Policy{ReadWrite: []string{dir}}); err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			return 2
+		}
+		os.Stderr.WriteString("restrict: " + err.Error() + "\n")
+		return 1
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "stream.sock"))
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		return 1
+	}
+	listener.Close()
+
+	conn, err := net.ListenPacket("unixgram", filepath.Join(dir, "dgram.sock"))
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		return 1
+	}
+	conn.Close()
+	return 0
+}
+
+// TestRestrictAllowsSockets checks that ReadWrite directories take Unix sockets, as the daemon's
+// fast path and syslog receiver need
+func TestRestrictAllowsSockets(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(exe, "-test.run=^$")
+	cmd.Env = append(os.Environ(), restrictedEnv+"="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		t.Skip("Landlock is not available here")
+	default:
+		t.Fatalf("binding sockets under Restrict failed: %v\n%s", err, out)
+	}
+}
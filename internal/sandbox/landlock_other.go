@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sandbox
+
+// Restrict is unsupported without Landlock
+func Restrict(policy Policy) error {
+	return ErrUnsupported
+}
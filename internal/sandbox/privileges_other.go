@@ -0,0 +1,8 @@
+//go:build !unix
+
+package sandbox
+
+// DropPrivileges is unsupported on this platform
+func DropPrivileges(username string) error {
+	return ErrUnsupported
+}
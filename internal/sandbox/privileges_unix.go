@@ -0,0 +1,59 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// DropPrivileges switches the process to an unprivileged user and its groups
+// Must be called while still root; it's irreversible, so do everything that needs root first
+func DropPrivileges(username string) error {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %q", u.Uid)
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return fmt.Errorf("invalid gid %q", u.Gid)
+	}
+	if uid == 0 {
+		return fmt.Errorf("user %s is root", username)
+	}
+
+	// Supplementary groups matter: systemd-journal membership grants journal access
+	groupIDs, err := u.GroupIds()
+	if err != nil {
+		return fmt.Errorf("looking up groups of %s: %w", username, err)
+	}
+	groups := make([]int, 0, len(groupIDs))
+	for _, g := range groupIDs {
+		if id, err := strconv.Atoi(g); err == nil {
+			groups = append(groups, id)
+		}
+	}
+
+	// Order matters: groups can only be changed while the uid is still privileged
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+
+	// SECURITY: Verify the drop is permanent
+	if syscall.Setuid(0) == nil {
+		return fmt.Errorf("privileges could be regained after dropping to %s", username)
+	}
+	return nil
+}
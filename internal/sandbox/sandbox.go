@@ -0,0 +1,14 @@
+package sandbox
+
+import "errors"
+
+// ErrUnsupported is returned when the kernel or build can't enforce a sandbox
+var ErrUnsupported = errors.New("sandboxing is not supported here")
+
+// Policy lists the paths a sandboxed process may use; everything else is denied
+// Paths that don't exist are skipped so one policy works across distributions
+type Policy struct {
+	ReadOnly  []string // Files and directories that may be read
+	ReadWrite []string // Directories where files and Unix sockets may be created, written and removed
+	Exec      []string // Executables (or directories of them) that may be run
+}
@@ -0,0 +1,70 @@
+// Package schedule parses daily time windows such as quiet hours
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily span of wall-clock time; it wraps past midnight when End is before Start
+type Window struct {
+	Start time.Duration // Offset from midnight
+	End   time.Duration
+}
+
+// ParseWindows parses comma-separated "HH:MM-HH:MM" windows such as "23:00-07:00,12:00-13:00"
+func ParseWindows(v string) ([]Window, error) {
+	var windows []Window
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q (expected HH:MM-HH:MM)", entry)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", entry, err)
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", entry, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("window %q is empty", entry)
+		}
+		windows = append(windows, Window{Start: start, End: end})
+	}
+	return windows, nil
+}
+
+// parseClock parses "HH:MM" as an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", strings.TrimSpace(s))
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t's wall-clock time, in t's location, falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start < w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	return clock >= w.Start || clock < w.End
+}
+
+// Active reports whether t falls inside any of the windows
+func Active(windows []Window, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Package severity maps run outcomes to alert levels that decide how loudly a notification is sent
+package severity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity levels, from least to most severe
+const (
+	Info     = "info"     // Successful runs
+	Warning  = "warning"  // Failures by default
+	Critical = "critical" // Failures that need someone now
+)
+
+// Valid reports whether level is a known severity level
+func Valid(level string) bool {
+	return level == Info || level == Warning || level == Critical
+}
+
+// ParseLevel validates a level name, ignoring case
+func ParseLevel(v string) (string, error) {
+	level := strings.ToLower(strings.TrimSpace(v))
+	if !Valid(level) {
+		return "", fmt.Errorf("unknown severity %q (expected %s, %s or %s)", v, Info, Warning, Critical)
+	}
+	return level, nil
+}
+
+// Rule maps a range of exit codes, a death by signal or everything else to a level
+type Rule struct {
+	Min, Max int    // Inclusive exit code range
+	Signal   bool   // Matches runs killed by a signal rather than exiting
+	Default  bool   // "*": matches runs no other rule matches
+	Level    string // One of Info, Warning or Critical
+}
+
+// Rules are checked in the order they were written
+type Rules []Rule
+
+// Parse parses "codes=level;..." where codes is an exit code, a range such as "1-127", "signal" or "*"
+// e.g. "1-127=warning;200-255=critical;signal=critical"
+func Parse(v string) (Rules, error) {
+	var rules Rules
+	for _, entry := range strings.Split(v, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		codes, level, ok := strings.Cut(entry, "=")
+		codes = strings.TrimSpace(codes)
+		if !ok || codes == "" {
+			return nil, fmt.Errorf("invalid entry %q (expected codes=level)", entry)
+		}
+		rule := Rule{}
+		var err error
+		if rule.Level, err = ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("%s: %w", codes, err)
+		}
+
+		switch strings.ToLower(codes) {
+		case "*":
+			rule.Default = true
+		case "signal":
+			rule.Signal = true
+		default:
+			if rule.Min, rule.Max, err = parseRange(codes); err != nil {
+				return nil, err
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseRange parses "N" or "N-M" as an inclusive exit code range within 0-255
+func parseRange(codes string) (int, int, error) {
+	from, to, isRange := strings.Cut(codes, "-")
+	if !isRange {
+		to = from
+	}
+	lo, errLo := strconv.Atoi(strings.TrimSpace(from))
+	hi, errHi := strconv.Atoi(strings.TrimSpace(to))
+	if errLo != nil || errHi != nil || lo < 0 || hi > 255 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid exit codes %q (expected N or N-M within 0-255, \"signal\" or \"*\")", codes)
+	}
+	return lo, hi, nil
+}
+
+// Level returns the severity of a run
+// Successes are info. For failures a signal rule decides deaths by signal, then the first exit code
+// rule matching wins, then "*"; without a match failures are warnings
+func (r Rules) Level(exitCode int, signaled, success bool) string {
+	if success {
+		return Info
+	}
+	level := ""
+	if signaled {
+		level = r.find(func(rule Rule) bool { return rule.Signal })
+	}
+	if level == "" {
+		level = r.find(func(rule Rule) bool {
+			return !rule.Signal && !rule.Default && exitCode >= rule.Min && exitCode <= rule.Max
+		})
+	}
+	if level == "" {
+		level = r.find(func(rule Rule) bool { return rule.Default })
+	}
+	if level == "" {
+		return Warning
+	}
+	return level
+}
+
+// find returns the level of the first rule matching, or "" when none does
+func (r Rules) find(match func(Rule) bool) string {
+	for _, rule := range r {
+		if match(rule) {
+			return rule.Level
+		}
+	}
+	return ""
+}
@@ -0,0 +1,207 @@
+// Package smart checks disk health with smartmontools' JSON output (smartctl --json)
+package smart
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// smartctl is the smartmontools command-line client
+const smartctl = "smartctl"
+
+// smartctl exit status bits that mean no SMART data was returned
+// Higher bits report disk problems alongside valid JSON and are evaluated from the data itself
+const (
+	exitBitCommandLine = 1 << 0
+	exitBitDeviceOpen  = 1 << 1
+)
+
+// criticalRawAttributes are ATA counters where any non-zero raw value means media damage
+var criticalRawAttributes = map[int]string{
+	5:   "Reallocated_Sector_Ct",
+	187: "Reported_Uncorrect",
+	197: "Current_Pending_Sector",
+	198: "Offline_Uncorrectable",
+}
+
+// Limits configures the optional threshold checks; zero disables a check
+type Limits struct {
+	MaxTemperature int // Celsius
+	MaxWearPercent int // NVMe percentage_used
+}
+
+// Breach is a health problem found on a device
+// Key identifies the problem across runs without its changing value, so repeated checks can
+// tell new problems from known ones
+type Breach struct {
+	Key    string
+	Detail string
+}
+
+// Device is the health check result of one disk
+type Device struct {
+	Name     string
+	Model    string
+	Serial   string
+	Breaches []Breach
+}
+
+// report is the subset of "smartctl --json --all" output that is evaluated
+type report struct {
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current int `json:"current"`
+	} `json:"temperature"`
+	ATAAttributes struct {
+		Table []struct {
+			ID         int    `json:"id"`
+			Name       string `json:"name"`
+			Value      int    `json:"value"`
+			Thresh     int    `json:"thresh"`
+			WhenFailed string `json:"when_failed"`
+			Raw        struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeLog *struct {
+		CriticalWarning         int   `json:"critical_warning"`
+		AvailableSpare          int   `json:"available_spare"`
+		AvailableSpareThreshold int   `json:"available_spare_threshold"`
+		PercentageUsed          int   `json:"percentage_used"`
+		MediaErrors             int64 `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// Scan lists the devices smartctl can address
+func Scan(ctx context.Context) ([]string, error) {
+	var result struct {
+		Devices []struct {
+			Name string `json:"name"`
+		} `json:"devices"`
+	}
+	output, err := run(ctx, "--scan", "--json")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("parsing smartctl --scan output: %w", err)
+	}
+
+	devices := make([]string, 0, len(result.Devices))
+	for _, d := range result.Devices {
+		devices = append(devices, d.Name)
+	}
+	return devices, nil
+}
+
+// Check reads a device's SMART data and evaluates it against limits
+func Check(ctx context.Context, device string, limits Limits) (Device, error) {
+	// SECURITY: Device names come from configuration; reject anything smartctl could take as an option
+	if !strings.HasPrefix(device, "/dev/") {
+		return Device{}, fmt.Errorf("device %q must be a /dev path", device)
+	}
+
+	output, err := run(ctx, "--json", "--all", device)
+	if err != nil {
+		return Device{}, err
+	}
+	var r report
+	if err := json.Unmarshal(output, &r); err != nil {
+		return Device{}, fmt.Errorf("parsing smartctl output for %s: %w", device, err)
+	}
+	return Device{Name: device, Model: r.ModelName, Serial: r.SerialNumber, Breaches: evaluate(r, limits)}, nil
+}
+
+// evaluate returns the problems reported in r
+func evaluate(r report, limits Limits) []Breach {
+	var breaches []Breach
+	add := func(key, format string, args ...any) {
+		breaches = append(breaches, Breach{Key: key, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if r.SmartStatus != nil && !r.SmartStatus.Passed {
+		add("overall", "overall health self-assessment FAILED")
+	}
+
+	for _, a := range r.ATAAttributes.Table {
+		switch {
+		case a.WhenFailed != "":
+			add("attr-"+a.Name, "%s (%d) failed %s: value %d, threshold %d", a.Name, a.ID, a.WhenFailed, a.Value, a.Thresh)
+		case a.Thresh > 0 && a.Value <= a.Thresh:
+			add("attr-"+a.Name, "%s (%d) at threshold: value %d, threshold %d", a.Name, a.ID, a.Value, a.Thresh)
+		case criticalRawAttributes[a.ID] != "" && a.Raw.Value > 0:
+			// The count is part of the key, so growth is reported again
+			add(fmt.Sprintf("raw-%s-%d", a.Name, a.Raw.Value), "%s (%d) raw value %d", a.Name, a.ID, a.Raw.Value)
+		}
+	}
+
+	if log := r.NVMeLog; log != nil {
+		if log.CriticalWarning != 0 {
+			add("nvme-critical-warning", "NVMe critical warning 0x%02x", log.CriticalWarning)
+		}
+		if log.AvailableSpareThreshold > 0 && log.AvailableSpare <= log.AvailableSpareThreshold {
+			add("nvme-spare", "available spare %d%% at threshold %d%%", log.AvailableSpare, log.AvailableSpareThreshold)
+		}
+		if log.MediaErrors > 0 {
+			add(fmt.Sprintf("nvme-media-errors-%d", log.MediaErrors), "%d media errors", log.MediaErrors)
+		}
+		if limits.MaxWearPercent > 0 && log.PercentageUsed >= limits.MaxWearPercent {
+			add("nvme-wear", "%d%% of rated endurance used (limit %d%%)", log.PercentageUsed, limits.MaxWearPercent)
+		}
+	}
+
+	if limits.MaxTemperature > 0 && r.Temperature.Current >= limits.MaxTemperature {
+		add("temperature", "temperature %d°C (limit %d°C)", r.Temperature.Current, limits.MaxTemperature)
+	}
+	return breaches
+}
+
+// run executes smartctl, accepting exit statuses that still come with SMART data
+// SECURITY: Fixed arguments plus a validated device path; exec.CommandContext avoids any shell
+func run(ctx context.Context, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath(smartctl); err != nil {
+		return nil, fmt.Errorf("%s not found in PATH (install smartmontools)", smartctl)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, smartctl, args...)
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code&(exitBitCommandLine|exitBitDeviceOpen) != 0 {
+			return nil, fmt.Errorf("%s %s failed with status %d%s", smartctl, strings.Join(args, " "), code, smartctlMessages(stdout.Bytes()))
+		}
+		return stdout.Bytes(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", smartctl, strings.Join(args, " "), err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// smartctlMessages extracts the error text smartctl puts in its JSON output
+func smartctlMessages(output []byte) string {
+	var result struct {
+		Smartctl struct {
+			Messages []struct {
+				String string `json:"string"`
+			} `json:"messages"`
+		} `json:"smartctl"`
+	}
+	if json.Unmarshal(output, &result) != nil || len(result.Smartctl.Messages) == 0 {
+		return ""
+	}
+	return ": " + result.Smartctl.Messages[0].String
+}
@@ -0,0 +1,89 @@
+package smart
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// StateFileName holds the problems already reported, under the state directory
+const StateFileName = "smart.json"
+
+// State maps device names to the breach keys already notified about
+// Timer-driven checks would otherwise repeat a permanent problem on every run
+type State map[string][]string
+
+// LoadState reads the notified breaches; a missing file is an empty state
+func LoadState(path string) (State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := State{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// Unreported returns devices reduced to breaches not yet in the state, dropping healthy ones
+func (s State) Unreported(devices []Device) []Device {
+	var result []Device
+	for _, d := range devices {
+		var fresh []Breach
+		for _, b := range d.Breaches {
+			if !slices.Contains(s[d.Name], b.Key) {
+				fresh = append(fresh, b)
+			}
+		}
+		if len(fresh) > 0 {
+			d.Breaches = fresh
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// Update replaces each checked device's entry with its current breaches
+// Resolved problems are forgotten, so they are reported again if they come back
+func (s State) Update(devices []Device) {
+	for _, d := range devices {
+		keys := make([]string, 0, len(d.Breaches))
+		for _, b := range d.Breaches {
+			keys = append(keys, b.Key)
+		}
+		s[d.Name] = keys
+	}
+}
+
+// Save writes the state atomically via temp file and rename
+func (s State) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,157 @@
+// Package socket is the fast path between notifier invocations and a running daemon
+// A hook ships its request over a Unix socket and the daemon sends it with warm connections,
+// saving the process setup and TLS handshakes of a direct send
+package socket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/notifier"
+	"telegram-notifier/internal/systemd"
+)
+
+// maxRequestSize bounds a request line: a message or logs of MaxStdinSize plus JSON escaping
+const maxRequestSize = 8 * 1024 * 1024
+
+// ResponseGrace is how much longer than the send timeout a client waits for the daemon's answer
+const ResponseGrace = 5 * time.Second
+
+// ErrUnavailable means no daemon is listening; the caller sends directly instead
+var ErrUnavailable = errors.New("no daemon listening on the socket")
+
+// Request is a notification handed to the daemon
+type Request struct {
+	ServiceName string               `json:"service,omitempty"`
+	ExitInfo    systemd.ExitCodeInfo `json:"exit_info"`
+	ServiceDesc string               `json:"description,omitempty"`
+	Message     string               `json:"message,omitempty"`
+	Logs        string               `json:"logs,omitempty"`  // Shown as the command output instead of the journal
+	Title       string               `json:"title,omitempty"` // Free-form notification when set
+}
+
+// Response carries the daemon's send result back to the hook
+type Response struct {
+	Report  notifier.Report `json:"report"`
+	Error   string          `json:"error,omitempty"`
+	Op      string          `json:"op,omitempty"`
+	Spooled bool            `json:"spooled,omitempty"`
+}
+
+// Err rebuilds the daemon's error so callers handle it like a direct send's
+func (r Response) Err(serviceName string) error {
+	if r.Error == "" {
+		return nil
+	}
+	return &notifier.NotificationError{Op: r.Op, Service: serviceName, Err: errors.New(r.Error), Spooled: r.Spooled}
+}
+
+// Handler sends a request, as the daemon's notifier service does
+type Handler func(ctx context.Context, req Request) (notifier.Report, error)
+
+// Listen creates the socket, replacing a stale one left by a daemon that didn't shut down cleanly
+// SECURITY: The socket is only accessible to the daemon's user; anyone who can write to it can send messages
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another daemon is listening on %s", path)
+	}
+	os.Remove(path)
+
+	// The umask is process-wide, so other goroutines' files would share a narrowed one; chmod instead
+	// Under the default state dir, which is private, nobody else can reach the socket before that
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve answers requests until ctx is cancelled, each connection carrying one request
+func Serve(ctx context.Context, listener net.Listener, timeout time.Duration, handle Handler) {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("Socket accept failed", logging.Err(err))
+				time.Sleep(time.Second)
+				continue
+			}
+			return
+		}
+		go serveConn(ctx, conn, timeout, handle)
+	}
+}
+
+// serveConn reads one request line, sends it and writes the response line
+func serveConn(ctx context.Context, conn net.Conn, timeout time.Duration, handle Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout + ResponseGrace))
+
+	// The limit keeps a runaway client from exhausting memory
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(conn, maxRequestSize)).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: "invalid request: " + err.Error(), Op: notifier.OpValidation})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	report, err := handle(ctx, req)
+
+	resp := Response{Report: report}
+	if err != nil {
+		resp.Error = err.Error()
+		var notifErr *notifier.NotificationError
+		if errors.As(err, &notifErr) {
+			resp.Op, resp.Spooled = notifErr.Op, notifErr.Spooled
+			resp.Error = notifErr.Err.Error()
+		}
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Send hands a request to the daemon and waits for its result
+// Returns ErrUnavailable when the request never reached the daemon, so falling back can't duplicate it
+func Send(ctx context.Context, path string, req Request) (Response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return Response{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// A request that didn't arrive whole can't have been sent by the daemon
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("%w: sending request: %v", ErrUnavailable, err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("reading daemon response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package spool
+
+// lock is a no-op on platforms without flock; spool access is best-effort there
+func lock(path string) (func(), error) {
+	return func() {}, nil
+}
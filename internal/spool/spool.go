@@ -0,0 +1,316 @@
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/telegram"
+	"telegram-notifier/internal/validation"
+)
+
+const (
+	entrySuffix = ".json"
+	lockFile    = ".lock"
+	dirPerm     = 0o700
+	filePerm    = 0o600
+)
+
+// Reasons passed to DropFunc when an entry leaves the spool undelivered
+const (
+	DropEvicted          = "evicted"
+	DropPermanent        = "permanent_error"
+	DropAttemptsExceeded = "attempts_exceeded"
+)
+
+// ErrPermanent marks delivery errors that will not succeed on retry
+var ErrPermanent = errors.New("permanent delivery error")
+
+// Entry is a rendered notification awaiting delivery
+type Entry struct {
+	ID        string    `json:"id"`
+	Service   string    `json:"service"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	Severity  string    `json:"severity,omitempty"` // Decides the chat and sound on redelivery
+
+	Alert   bool                `json:"alert,omitempty"`   // Failure alert, redelivered with an acknowledge button and tracked for acknowledgement
+	Buttons [][]telegram.Button `json:"buttons,omitempty"` // Other buttons the notification carried, such as Show more
+}
+
+// FlushResult summarizes a spool flush run
+type FlushResult struct {
+	Delivered int
+	Remaining int
+}
+
+// SendFunc delivers a spooled entry
+type SendFunc func(ctx context.Context, entry Entry) error
+
+// DropFunc is notified when an entry is discarded without delivery
+type DropFunc func(entry Entry, reason string)
+
+// Spool persists undelivered notifications on disk for later retry
+// SECURITY: Directory is created 0700 and entries 0600 since messages may contain log output
+type Spool struct {
+	dir         string
+	maxEntries  int
+	maxAttempts int
+	onDrop      DropFunc
+}
+
+// New creates a spool rooted at dir holding at most maxEntries notifications
+// Entries are discarded after maxAttempts failed deliveries (0 = unlimited)
+func New(dir string, maxEntries, maxAttempts int) *Spool {
+	return &Spool{dir: dir, maxEntries: maxEntries, maxAttempts: maxAttempts}
+}
+
+// OnDrop registers a handler for entries discarded without delivery
+func (s *Spool) OnDrop(fn DropFunc) {
+	s.onDrop = fn
+}
+
+// Dir returns the spool directory
+func (s *Spool) Dir() string {
+	return s.dir
+}
+
+// Enqueue persists an entry, evicting the oldest entries when the cap is reached
+func (s *Spool) Enqueue(entry Entry) error {
+	if err := s.ensureDir(); err != nil {
+		return err
+	}
+
+	unlock, err := lock(filepath.Join(s.dir, lockFile))
+	if err != nil {
+		return fmt.Errorf("locking spool: %w", err)
+	}
+	defer unlock()
+
+	if entry.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	if err := s.evict(s.maxEntries - 1); err != nil {
+		return err
+	}
+	return s.write(entry)
+}
+
+// List returns all spooled entries, oldest first
+func (s *Spool) List() ([]Entry, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+entrySuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(content, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// Len returns the number of spooled entries
+func (s *Spool) Len() int {
+	entries, err := s.List()
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
+
+// Flush attempts delivery of all spooled entries, oldest first
+// Stops at the first transient failure since the backend is most likely still unreachable.
+// Entries failing permanently or exceeding the attempt limit are dropped.
+func (s *Spool) Flush(ctx context.Context, send SendFunc) (FlushResult, error) {
+	var result FlushResult
+
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return result, nil
+	}
+
+	unlock, err := lock(filepath.Join(s.dir, lockFile))
+	if err != nil {
+		return result, fmt.Errorf("locking spool: %w", err)
+	}
+	defer unlock()
+
+	entries, err := s.List()
+	if err != nil {
+		return result, err
+	}
+
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			result.Remaining = len(entries) - i
+			return result, err
+		}
+
+		sendErr := send(ctx, entry)
+		if sendErr == nil {
+			if err := s.remove(entry.ID); err != nil {
+				return result, err
+			}
+			result.Delivered++
+			continue
+		}
+
+		entry.Attempts++
+		entry.LastError = validation.SanitizeErrorMessage(sendErr)
+
+		// Give up on entries that can never be delivered so they don't block the queue
+		if errors.Is(sendErr, ErrPermanent) {
+			if err := s.drop(entry, DropPermanent); err != nil {
+				return result, err
+			}
+			continue
+		}
+		if s.maxAttempts > 0 && entry.Attempts >= s.maxAttempts {
+			if err := s.drop(entry, DropAttemptsExceeded); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if err := s.write(entry); err != nil {
+			return result, err
+		}
+		result.Remaining = len(entries) - i
+		return result, sendErr
+	}
+
+	return result, nil
+}
+
+// drop removes an entry and reports it to the drop handler
+func (s *Spool) drop(entry Entry, reason string) error {
+	if err := s.remove(entry.ID); err != nil {
+		return err
+	}
+	if s.onDrop != nil {
+		s.onDrop(entry, reason)
+	}
+	return nil
+}
+
+// ensureDir creates the spool directory with owner-only permissions
+func (s *Spool) ensureDir() error {
+	if s.dir == "" {
+		return fmt.Errorf("spool directory not configured")
+	}
+	if err := os.MkdirAll(s.dir, dirPerm); err != nil {
+		return fmt.Errorf("creating spool directory: %w", err)
+	}
+	// Tighten permissions if the directory already existed with looser mode
+	return os.Chmod(s.dir, dirPerm)
+}
+
+// evict removes the oldest entries until at most keep remain
+func (s *Spool) evict(keep int) error {
+	entries, err := s.List()
+	if err != nil {
+		return err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	for len(entries) > keep {
+		if err := s.drop(entries[0], DropEvicted); err != nil {
+			return err
+		}
+		entries = entries[1:]
+	}
+	return nil
+}
+
+// write stores an entry atomically via temp file and rename
+func (s *Spool) write(entry Entry) error {
+	path, err := s.entryPath(entry.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding spool entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating spool entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(filePerm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing spool entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// remove deletes a spooled entry by ID
+func (s *Spool) remove(id string) error {
+	path, err := s.entryPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing spool entry: %w", err)
+	}
+	return nil
+}
+
+// entryPath resolves an entry file path inside the spool directory
+// SECURITY: Rejects IDs that would escape the spool directory
+func (s *Spool) entryPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid spool entry id %q", id)
+	}
+	return validation.SanitizePath(s.dir, id+entrySuffix)
+}
+
+// newID generates a sortable, unique entry identifier
+func newID() (string, error) {
+	random := make([]byte, 4)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("generating spool id: %w", err)
+	}
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(random)), nil
+}
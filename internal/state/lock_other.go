@@ -0,0 +1,8 @@
+//go:build !unix
+
+package state
+
+// lock is a no-op on platforms without flock; concurrent updates may be lost there
+func lock(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AllServices is the maintenance key covering every unit and job
+const AllServices = "*"
+
+// Maintenance maps unit and job names, or AllServices, to when their maintenance window ends
+type Maintenance map[string]time.Time
+
+// Covers returns when the window covering a unit or job ends, if one is active at now
+// Units match with or without their ".service" suffix; the longer of two matching windows wins
+func (m Maintenance) Covers(name string, now time.Time) (time.Time, bool) {
+	var until time.Time
+	for _, key := range []string{name, strings.TrimSuffix(name, ".service"), AllServices} {
+		if end, ok := m[key]; ok && end.After(now) && end.After(until) {
+			until = end
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// MaintenanceStore keeps maintenance windows in a JSON file
+type MaintenanceStore struct {
+	path string
+}
+
+// NewMaintenance creates a store backed by the file at path
+func NewMaintenance(path string) *MaintenanceStore {
+	return &MaintenanceStore{path: path}
+}
+
+// Active returns the windows that haven't ended yet
+func (m *MaintenanceStore) Active() (Maintenance, error) {
+	windows := Maintenance{}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return windows, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &windows); err != nil {
+		return nil, err
+	}
+	windows.prune(time.Now())
+	return windows, nil
+}
+
+// Start opens or extends a window for name until the given time
+func (m *MaintenanceStore) Start(name string, until time.Time) error {
+	return m.update(func(windows Maintenance) {
+		windows[name] = until
+	})
+}
+
+// End closes the window for name, returning false when there was none
+func (m *MaintenanceStore) End(name string) (bool, error) {
+	var found bool
+	err := m.update(func(windows Maintenance) {
+		_, found = windows[name]
+		delete(windows, name)
+	})
+	return found, err
+}
+
+// update changes the windows under the file lock, dropping ended ones
+func (m *MaintenanceStore) update(fn func(Maintenance)) error {
+	if err := os.MkdirAll(filepath.Dir(m.path), dirPerm); err != nil {
+		return err
+	}
+	unlock, err := lock(m.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	windows, err := m.Active()
+	if err != nil {
+		// A damaged file would otherwise block maintenance changes for good
+		windows = Maintenance{}
+	}
+	fn(windows)
+	windows.prune(time.Now())
+	return writeJSON(m.path, windows)
+}
+
+// prune removes windows that ended before now
+func (m Maintenance) prune(now time.Time) {
+	for name, until := range m {
+		if !until.After(now) {
+			delete(m, name)
+		}
+	}
+}
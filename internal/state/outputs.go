@@ -0,0 +1,86 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrOutputExpired is returned for output that was pruned or never stored
+var ErrOutputExpired = errors.New("output is no longer available")
+
+// Output is the full output of a notification that showed only its end
+type Output struct {
+	Service string `json:"service"`
+	Text    string `json:"text"` // Redacted like the notification; never the raw output, and only its latest part when long
+}
+
+// OutputStore keeps truncated notifications' full output in a directory, one JSON file each, for a limited time
+type OutputStore struct {
+	dir       string
+	retention time.Duration
+}
+
+// NewOutputs creates a store in dir that keeps output for retention
+func NewOutputs(dir string, retention time.Duration) *OutputStore {
+	return &OutputStore{dir: dir, retention: retention}
+}
+
+// Save stores output and returns its ID, pruning output older than the retention first
+func (o *OutputStore) Save(output Output) (string, error) {
+	if err := os.MkdirAll(o.dir, dirPerm); err != nil {
+		return "", err
+	}
+	o.prune(time.Now())
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b[:])
+	return id, writeJSON(o.path(id), output)
+}
+
+// Load returns the output stored under id
+func (o *OutputStore) Load(id string) (Output, error) {
+	var output Output
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 16 {
+		return output, fmt.Errorf("invalid output ID")
+	}
+	info, err := os.Stat(o.path(id))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && time.Since(info.ModTime()) > o.retention) {
+		return output, ErrOutputExpired
+	}
+	if err != nil {
+		return output, err
+	}
+	data, err := os.ReadFile(o.path(id))
+	if err != nil {
+		return output, err
+	}
+	return output, json.Unmarshal(data, &output)
+}
+
+// prune removes output stored longer ago than the retention
+func (o *OutputStore) prune(now time.Time) {
+	entries, _ := os.ReadDir(o.dir)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > o.retention {
+			os.Remove(filepath.Join(o.dir, entry.Name()))
+		}
+	}
+}
+
+func (o *OutputStore) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
@@ -0,0 +1,221 @@
+// Package state remembers how each service's previous runs ended
+// Policies that depend on what came before, such as only reporting recoveries, read it
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/validation"
+)
+
+const (
+	dirPerm  = 0o700
+	filePerm = 0o600
+)
+
+// Forever is the snooze end of a muted service, which lasts until it is unmuted
+var Forever = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Service is what is remembered about a service's runs
+type Service struct {
+	Failures     int       `json:"failures"`      // Consecutive failed runs, 0 after a success
+	Alerted      bool      `json:"alerted"`       // The failures reached the alert threshold and were reported
+	FailingSince time.Time `json:"failing_since"` // First failure of the current run of failures
+	LastRun      time.Time `json:"last_run"`      // When the last run was reported
+	SnoozedUntil time.Time `json:"snoozed_until"` // Runs aren't reported before this time
+
+	UnitFiles map[string]string `json:"unit_files,omitempty"` // Unit file and drop-in hashes at the last success; nil when unknown
+
+	Escalation *Escalation `json:"escalation,omitempty"` // Failure alert repeating until acknowledged
+	Ack        *Ack        `json:"ack,omitempty"`        // Who acknowledged the current failures
+	Alerts     []Alert     `json:"alerts,omitempty"`     // Sent alerts with an acknowledge button, newest last
+}
+
+// Alert is a sent failure alert, kept so acknowledging can mark it in the chat
+type Alert struct {
+	MessageID int64  `json:"message_id"`
+	Message   string `json:"message"`
+	Chat      string `json:"chat,omitempty"` // Set when the alert's severity routed it away from the notification chat
+}
+
+// Escalation is a failure alert that is sent again until someone acknowledges it
+type Escalation struct {
+	Message  string        `json:"message"`  // The failure notification, sent again on each repeat
+	Repeats  int           `json:"repeats"`  // Repeats sent so far
+	Interval time.Duration `json:"interval"` // Wait before the next repeat
+	NextAt   time.Time     `json:"next_at"`
+	Severity string        `json:"severity,omitempty"` // Level of the failure, deciding where repeats go
+}
+
+// Snoozed reports whether the service's runs are muted at now
+func (s Service) Snoozed(now time.Time) bool {
+	return now.Before(s.SnoozedUntil)
+}
+
+// Muted reports whether the service is snoozed until it is unmuted rather than for a while
+func (s Service) Muted() bool {
+	return s.SnoozedUntil.Equal(Forever)
+}
+
+// Ack records who took responsibility for a service's failures
+type Ack struct {
+	By string    `json:"by"`
+	At time.Time `json:"at"`
+}
+
+// Store keeps the state of all services in one JSON file
+type Store struct {
+	path string
+}
+
+// New creates a store backed by the file at path
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// Update applies fn to a service's state and saves the result, returning the state before fn
+// The file is locked for the whole read-modify-write, so concurrent hooks don't lose updates
+func (s *Store) Update(service string, fn func(*Service)) (Service, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), dirPerm); err != nil {
+		return Service{}, err
+	}
+	unlock, err := lock(s.path + ".lock")
+	if err != nil {
+		return Service{}, err
+	}
+	defer unlock()
+
+	services := s.load()
+	prev := Lookup(services, service)
+	if _, ok := services[service]; !ok {
+		// The unit's own entry takes over a pending snooze Lookup found under its bare name
+		if bare := strings.TrimSuffix(service, ".service"); bare != service && services[bare].LastRun.IsZero() {
+			delete(services, bare)
+		}
+	}
+	next := prev
+	fn(&next)
+	services[service] = next
+	// Services that never ran, such as ones only named in a command, aren't stored unless snoozed ahead of their first run
+	if next.LastRun.IsZero() && !next.Snoozed(time.Now()) {
+		delete(services, service)
+	}
+	return prev, s.save(services)
+}
+
+// Lookup returns a service's state in services
+// A unit nothing ran as yet takes a snooze its bare name got ahead of its first run, as systemctl reads "backup" as backup.service
+func Lookup(services map[string]Service, name string) Service {
+	if st, ok := services[name]; ok {
+		return st
+	}
+	if bare := strings.TrimSuffix(name, ".service"); bare != name {
+		if pending, ok := services[bare]; ok && pending.LastRun.IsZero() {
+			return Service{SnoozedUntil: pending.SnoozedUntil}
+		}
+	}
+	return Service{}
+}
+
+// All returns the state of every service
+func (s *Store) All() (map[string]Service, error) {
+	unlock, err := lock(s.path + ".lock")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return map[string]Service{}, nil
+		}
+		return nil, err
+	}
+	defer unlock()
+	return s.load(), nil
+}
+
+// Resolve returns the name a service's state is kept under
+// Jobs and units are stored by the name they ran as, so a bare name finds the unit when only the unit is known
+func (s *Store) Resolve(name string) (string, error) {
+	services, err := s.All()
+	if err != nil {
+		return name, err
+	}
+	if _, ok := services[name]; !ok {
+		if unit := validation.NormalizeUnitName(name); unit != name {
+			if _, ok := services[unit]; ok {
+				return unit, nil
+			}
+		}
+	}
+	return name, nil
+}
+
+// Snooze mutes a service's notifications until the given time; a zero time ends the snooze
+// A bare name matches a known unit of that name, so "backup" snoozes backup.service;
+// one nothing ran as yet moves to the unit of that name when the unit first runs
+// Returns the name the snooze was recorded under
+func (s *Store) Snooze(name string, until time.Time) (string, error) {
+	name, err := s.Resolve(name)
+	if err != nil {
+		return name, err
+	}
+	_, err = s.Update(name, func(st *Service) {
+		st.SnoozedUntil = until
+	})
+	return name, err
+}
+
+// Snoozed returns when each currently snoozed service's snooze ends
+func (s *Store) Snoozed(now time.Time) (map[string]time.Time, error) {
+	services, err := s.All()
+	if err != nil {
+		return nil, err
+	}
+	snoozed := map[string]time.Time{}
+	for name, st := range services {
+		if st.Snoozed(now) {
+			snoozed[name] = st.SnoozedUntil
+		}
+	}
+	return snoozed, nil
+}
+
+// load reads all services' state; a missing or damaged file is an empty state
+func (s *Store) load() map[string]Service {
+	services := map[string]Service{}
+	if data, err := os.ReadFile(s.path); err == nil {
+		json.Unmarshal(data, &services)
+	}
+	return services
+}
+
+// save writes the state atomically
+func (s *Store) save(services map[string]Service) error {
+	return writeJSON(s.path, services)
+}
+
+// writeJSON writes v to path atomically via temp file and rename
+func writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,73 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// TopicStore remembers the forum topic created for each service in each chat, in a JSON file
+type TopicStore struct {
+	path string
+}
+
+// NewTopics creates a store backed by the file at path
+func NewTopics(path string) *TopicStore {
+	return &TopicStore{path: path}
+}
+
+// Resolve returns the thread ID of the named topic in chat, calling create to make it when none is known
+// The file stays locked while create runs, so concurrent hooks for a new service don't create two topics
+func (t *TopicStore) Resolve(chat, name string, create func() (int64, error)) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(t.path), dirPerm); err != nil {
+		return 0, err
+	}
+	unlock, err := lock(t.path + ".lock")
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	topics := t.load()
+	key := topicKey(chat, name)
+	if id, ok := topics[key]; ok {
+		return id, nil
+	}
+	id, err := create()
+	if err != nil {
+		return 0, err
+	}
+	topics[key] = id
+	return id, writeJSON(t.path, topics)
+}
+
+// Forget drops the named topic of chat if it still has the given thread ID, so the next Resolve creates it again
+func (t *TopicStore) Forget(chat, name string, threadID int64) error {
+	unlock, err := lock(t.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	topics := t.load()
+	key := topicKey(chat, name)
+	if topics[key] != threadID {
+		return nil
+	}
+	delete(topics, key)
+	return writeJSON(t.path, topics)
+}
+
+// load reads the known topics; a missing or damaged file knows none
+func (t *TopicStore) load() map[string]int64 {
+	topics := map[string]int64{}
+	if data, err := os.ReadFile(t.path); err == nil {
+		json.Unmarshal(data, &topics)
+	}
+	return topics
+}
+
+// topicKey identifies a topic; chat IDs and @usernames never contain "/", so it can't be ambiguous
+func topicKey(chat, name string) string {
+	return chat + "/" + name
+}
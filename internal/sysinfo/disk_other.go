@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sysinfo
+
+import "errors"
+
+// readDiskUsage is unsupported on this platform
+func readDiskUsage(path string) (used, total uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on this platform")
+}
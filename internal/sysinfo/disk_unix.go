@@ -0,0 +1,18 @@
+//go:build unix
+
+package sysinfo
+
+import "syscall"
+
+// readDiskUsage reports used and total bytes for the filesystem containing path
+// Blocks reserved for root count as neither, so the percentage matches df(1)'s Use%
+func readDiskUsage(path string) (used, total uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	blockSize := uint64(stat.Bsize)
+	used = (uint64(stat.Blocks) - uint64(stat.Bfree)) * blockSize
+	return used, used + uint64(stat.Bavail)*blockSize, nil
+}
@@ -0,0 +1,186 @@
+package sysinfo
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Health sources read from procfs and the root filesystem
+const (
+	procLoadAvg = "/proc/loadavg"
+	procUptime  = "/proc/uptime"
+	rootFS      = "/"
+)
+
+// rebootRequiredPaths lists marker files distributions use to flag pending reboots
+var rebootRequiredPaths = []string{
+	"/run/reboot-required",
+	"/var/run/reboot-required",
+}
+
+// rebootRequiredPkgs lists the packages that caused the Debian/Ubuntu reboot marker
+const rebootRequiredPkgs = "/run/reboot-required.pkgs"
+
+// HealthSnapshot captures host health indicators useful for triaging failures
+type HealthSnapshot struct {
+	LoadAvg        [3]float64
+	HasLoadAvg     bool
+	DiskUsedBytes  uint64
+	DiskTotalBytes uint64
+	HasDisk        bool
+	Uptime         time.Duration
+	HasUptime      bool
+	RebootRequired bool
+}
+
+// CollectHealth gathers a best-effort snapshot of system health
+// Individual sources that cannot be read are skipped rather than failing the snapshot
+func CollectHealth() (HealthSnapshot, error) {
+	var snap HealthSnapshot
+
+	if load, err := readLoadAvg(); err == nil {
+		snap.LoadAvg = load
+		snap.HasLoadAvg = true
+	}
+
+	if used, total, err := readDiskUsage(rootFS); err == nil {
+		snap.DiskUsedBytes = used
+		snap.DiskTotalBytes = total
+		snap.HasDisk = true
+	}
+
+	if uptime, err := readUptime(); err == nil {
+		snap.Uptime = uptime
+		snap.HasUptime = true
+	}
+
+	snap.RebootRequired = RebootRequired()
+
+	if !snap.HasLoadAvg && !snap.HasDisk && !snap.HasUptime {
+		return snap, fmt.Errorf("no system health sources available")
+	}
+	return snap, nil
+}
+
+// RebootRequired reports whether the distribution flagged a pending reboot
+func RebootRequired() bool {
+	for _, path := range rebootRequiredPaths {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RebootRequiredPackages returns the packages that flagged the pending reboot, when recorded
+func RebootRequiredPackages() []string {
+	content, err := os.ReadFile(rebootRequiredPkgs)
+	if err != nil {
+		return nil
+	}
+	var pkgs []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !slices.Contains(pkgs, line) {
+			pkgs = append(pkgs, line)
+		}
+	}
+	return pkgs
+}
+
+// Format renders the snapshot as compact lines suitable for a notification
+func (h HealthSnapshot) Format() string {
+	var lines []string
+
+	if h.HasLoadAvg {
+		lines = append(lines, fmt.Sprintf("Load: %.2f %.2f %.2f", h.LoadAvg[0], h.LoadAvg[1], h.LoadAvg[2]))
+	}
+	if h.HasDisk && h.DiskTotalBytes > 0 {
+		percent := float64(h.DiskUsedBytes) / float64(h.DiskTotalBytes) * 100
+		lines = append(lines, fmt.Sprintf("Disk (/): %s / %s (%.0f%%)",
+			FormatBytes(h.DiskUsedBytes), FormatBytes(h.DiskTotalBytes), percent))
+	}
+	if h.HasUptime {
+		lines = append(lines, fmt.Sprintf("Uptime: %s", formatUptime(h.Uptime)))
+	}
+	if h.RebootRequired {
+		lines = append(lines, "Reboot: pending")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// readLoadAvg parses the 1, 5 and 15 minute load averages
+// Format: "0.52 0.58 0.59 1/467 12345"
+func readLoadAvg() ([3]float64, error) {
+	var load [3]float64
+
+	content, err := os.ReadFile(procLoadAvg)
+	if err != nil {
+		return load, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("unexpected %s format", procLoadAvg)
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return load, fmt.Errorf("parsing load average: %w", err)
+		}
+		load[i] = value
+	}
+	return load, nil
+}
+
+// readUptime parses system uptime in seconds
+// Format: "350735.47 234388.90"
+func readUptime() (time.Duration, error) {
+	content, err := os.ReadFile(procUptime)
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("unexpected %s format", procUptime)
+	}
+
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing uptime: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FormatBytes renders a byte count with binary unit suffixes
+func FormatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatUptime renders a duration as days, hours and minutes
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
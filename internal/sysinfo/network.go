@@ -0,0 +1,57 @@
+package sysinfo
+
+import (
+	"net"
+	"slices"
+)
+
+// IPAddresses returns the primary IPv4 and IPv6 addresses of the host
+// Only interfaces that are up and, when allowedInterfaces is non-empty, listed are considered.
+// Loopback and link-local addresses are skipped since they don't identify the host.
+func IPAddresses(allowedInterfaces []string) ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ipv4, ipv6 string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if len(allowedInterfaces) > 0 && !slices.Contains(allowedInterfaces, iface.Name) {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			if ipNet.IP.To4() != nil {
+				if ipv4 == "" {
+					ipv4 = ipNet.IP.String()
+				}
+			} else if ipv6 == "" {
+				ipv6 = ipNet.IP.String()
+			}
+		}
+
+		if ipv4 != "" && ipv6 != "" {
+			break
+		}
+	}
+
+	var result []string
+	for _, ip := range []string{ipv4, ipv6} {
+		if ip != "" {
+			result = append(result, ip)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,275 @@
+// Package syslog receives syslog messages over UDP or a unix datagram socket, for devices that can't run the notifier
+// RFC 5424 messages are parsed in full; the older RFC 3164 format most appliances still send is accepted too
+package syslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDatagram is the largest message read; longer datagrams are cut off
+const maxDatagram = 64 * 1024
+
+// severityNames are the RFC 5424 severities by number, as syslog.conf and logger(1) name them
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// SeverityName gives a severity's name, e.g. "err" for 3
+func SeverityName(severity int) string {
+	if severity >= 0 && severity < len(severityNames) {
+		return severityNames[severity]
+	}
+	return strconv.Itoa(severity)
+}
+
+// ParseSeverity accepts a severity by name ("err", also "error" and "warn") or number
+func ParseSeverity(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "error":
+		s = "err"
+	case "warn":
+		s = "warning"
+	}
+	for i, name := range severityNames {
+		if s == name || s == strconv.Itoa(i) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (expected emerg, alert, crit, err, warning, notice, info, debug or 0-7)", s)
+}
+
+// Message is a received syslog message; fields the sender left out are empty
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time // Zero when missing or unparsable
+	Hostname  string
+	AppName   string // Program, e.g. "sshd"
+	ProcID    string
+	Text      string
+	Source    netip.Addr // Sending address of a UDP message; invalid for unix sockets
+}
+
+// Filter selects the messages to report
+type Filter struct {
+	MaxSeverity int            // Least severe level reported, e.g. 3 for err and worse
+	Programs    []string       // App names reported; empty for all
+	Sources     []netip.Prefix // Addresses UDP messages are accepted from; empty for all
+}
+
+// Match reports whether a message passes the filter
+func (f Filter) Match(m Message) bool {
+	if m.Severity > f.MaxSeverity || !f.fromSource(m) {
+		return false
+	}
+	if len(f.Programs) == 0 {
+		return true
+	}
+	for _, program := range f.Programs {
+		if strings.EqualFold(program, m.AppName) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromSource reports whether a message came from an accepted address; unix socket messages are local
+func (f Filter) fromSource(m Message) bool {
+	if len(f.Sources) == 0 || !m.Source.IsValid() {
+		return true
+	}
+	for _, prefix := range f.Sources {
+		if prefix.Contains(m.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads an RFC 5424 message, or an RFC 3164 one when no version follows the priority
+func Parse(data []byte) (Message, error) {
+	line := strings.TrimRight(strings.ToValidUTF8(string(data), "�"), "\r\n\x00")
+	if !strings.HasPrefix(line, "<") {
+		return Message{}, errors.New("missing priority")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return Message{}, errors.New("malformed priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return Message{}, fmt.Errorf("invalid priority %q", line[1:end])
+	}
+	m := Message{Facility: pri / 8, Severity: pri % 8}
+	rest := line[end+1:]
+
+	if version, after, ok := strings.Cut(rest, " "); ok && version == "1" {
+		parse5424(&m, after)
+	} else {
+		parse3164(&m, rest)
+	}
+	return m, nil
+}
+
+// parse5424 reads "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG", where "-" marks a missing field
+func parse5424(m *Message, rest string) {
+	fields := make([]string, 5)
+	for i := range fields {
+		fields[i], rest, _ = strings.Cut(rest, " ")
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+	m.Timestamp, _ = time.Parse(time.RFC3339Nano, fields[0])
+	m.Hostname, m.AppName, m.ProcID = fields[1], fields[2], fields[3]
+	m.Text = strings.TrimPrefix(skipStructuredData(rest), "\uFEFF")
+}
+
+// skipStructuredData drops the structured data elements, "-" or "[id key=\"value\"]...", before the message
+func skipStructuredData(rest string) string {
+	if strings.HasPrefix(rest, "-") {
+		return strings.TrimPrefix(rest[1:], " ")
+	}
+	inValue := false
+	for i := 0; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case c == '\\' && inValue:
+			i++
+		case c == '"':
+			inValue = !inValue
+		case c == ']' && !inValue && (i+1 == len(rest) || rest[i+1] != '['):
+			return strings.TrimPrefix(rest[i+1:], " ")
+		}
+	}
+	return ""
+}
+
+// parse3164 reads "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG"; senders that leave out the timestamp or hostname
+// are read as far as they go
+func parse3164(m *Message, rest string) {
+	if len(rest) >= 16 && rest[15] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, rest[:15], time.Local); err == nil {
+			now := time.Now()
+			m.Timestamp = ts.AddDate(now.Year(), 0, 0)
+			// A December message read in January
+			if m.Timestamp.After(now.Add(24 * time.Hour)) {
+				m.Timestamp = m.Timestamp.AddDate(-1, 0, 0)
+			}
+			rest = rest[16:]
+			if host, after, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(host, ":") {
+				m.Hostname, rest = host, after
+			}
+		}
+	}
+
+	// The tag ends at the first colon, "[" or space, e.g. "sshd[123]:"
+	end := strings.IndexAny(rest, ":[ ")
+	if end <= 0 || end > 48 {
+		m.Text = rest
+		return
+	}
+	m.AppName = rest[:end]
+	rest = rest[end:]
+	if strings.HasPrefix(rest, "[") {
+		if pid, after, ok := strings.Cut(rest[1:], "]"); ok {
+			m.ProcID, rest = pid, after
+		}
+	}
+	m.Text = strings.TrimPrefix(strings.TrimPrefix(rest, ":"), " ")
+}
+
+// socketPerm lets the daemon's user and group send to a unix socket; a local syslog daemon running as
+// its own user, such as rsyslog's "syslog", needs to be added to that group
+const socketPerm = 0o660
+
+// Listen opens the receiving socket: a unix datagram socket when addr is an absolute path, else a UDP host:port
+// A stale socket file left by a daemon that didn't shut down cleanly is replaced
+// SECURITY: Anything else at the path is left alone, so a misconfigured path can't delete a file
+func Listen(addr string) (net.PacketConn, error) {
+	if !filepath.IsAbs(addr) {
+		return net.ListenPacket("udp", addr)
+	}
+	if err := os.MkdirAll(filepath.Dir(addr), 0o755); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(addr); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", addr)
+		}
+		if err := os.Remove(addr); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.ListenPacket("unixgram", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, socketPerm); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Serve hands each message received on conn to handle, one at a time, until ctx is cancelled
+// Messages that can't be parsed are passed to bad instead
+func Serve(ctx context.Context, conn net.PacketConn, handle func(Message), bad func(error)) error {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, maxDatagram)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		m, err := Parse(buf[:n])
+		if err != nil {
+			bad(err)
+			continue
+		}
+		if udp, ok := from.(*net.UDPAddr); ok {
+			m.Source = udp.AddrPort().Addr().Unmap()
+		}
+		handle(m)
+	}
+}
+
+// Repeats remembers the messages reported lately, so a sender repeating one isn't reported again and again
+type Repeats struct {
+	window time.Duration
+	seen   map[string]time.Time
+}
+
+// NewRepeats creates a memory of the messages reported within window
+func NewRepeats(window time.Duration) *Repeats {
+	return &Repeats{window: window, seen: map[string]time.Time{}}
+}
+
+// Seen reports whether the same message from the same host and program was reported within the window,
+// and remembers this one as reported at now when it wasn't
+func (r *Repeats) Seen(m Message, now time.Time) bool {
+	for key, at := range r.seen {
+		if now.Sub(at) >= r.window {
+			delete(r.seen, key)
+		}
+	}
+	key := strings.Join([]string{m.Source.String(), m.Hostname, m.AppName, strconv.Itoa(m.Severity), m.Text}, "\x00")
+	if _, ok := r.seen[key]; ok {
+		return true
+	}
+	r.seen[key] = now
+	return false
+}
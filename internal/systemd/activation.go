@@ -0,0 +1,130 @@
+package systemd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/validation"
+)
+
+// Activation causes ActivationCause tells apart
+const (
+	ActivationTimer   = "timer"   // A timer elapsed
+	ActivationSocket  = "socket"  // A connection or datagram on a listening socket
+	ActivationPath    = "path"    // A watched path changed
+	ActivationRestart = "restart" // Restart= started the unit again after it stopped
+	ActivationBoot    = "boot"    // Started while the system manager was bringing up the system
+	ActivationLogin   = "login"   // Started while the user manager was starting up for the user's first session
+	ActivationManual  = "manual"  // Started by an operator, or pulled in by a unit that was
+)
+
+// timerActivationWindow is how long after its timer elapsed a run still counts as started by it;
+// the start job can wait on the units it's ordered after
+const timerActivationWindow = time.Minute
+
+// Activation describes what started a unit's latest run
+type Activation struct {
+	Cause    string
+	Trigger  string // Timer, socket or path unit, for those causes
+	Restarts int    // Automatic restarts since the unit was last started otherwise, for ActivationRestart
+}
+
+// String describes the activation, e.g. "timer backup.timer" or "automatic restart (2)"
+func (a Activation) String() string {
+	switch a.Cause {
+	case ActivationTimer, ActivationSocket, ActivationPath:
+		return a.Cause + " " + a.Trigger
+	case ActivationRestart:
+		return "automatic restart (" + strconv.Itoa(a.Restarts) + ")"
+	case ActivationManual:
+		return "manual start or dependency"
+	}
+	return a.Cause
+}
+
+// ActivationCause tells what started the unit's latest run, from the units that trigger it and when the run began
+// A timer counts when it elapsed shortly before the run; sockets and path units can't be checked that way,
+// so a unit with one is taken to have been started by it
+// Returns a zero Activation when the run's start time is unknown
+// SECURITY: Validates the unit name; trigger names systemd reports are validated before being queried
+func (s *Service) ActivationCause(ctx context.Context, serviceName string) (Activation, error) {
+	scope, found, err := s.unitScope(ctx, serviceName)
+	if err != nil || !found {
+		return Activation{}, err
+	}
+	values, err := s.GetSystemctlProperties(ctx, serviceName,
+		[]string{"TriggeredBy", "NRestarts", "ExecMainStartTimestampMonotonic", "InactiveExitTimestampMonotonic"}, scope)
+	if err != nil {
+		return Activation{}, err
+	}
+	start, _ := strconv.ParseUint(values["ExecMainStartTimestampMonotonic"], 10, 64)
+	if start == 0 {
+		start, _ = strconv.ParseUint(values["InactiveExitTimestampMonotonic"], 10, 64)
+	}
+	if start == 0 {
+		return Activation{}, nil
+	}
+
+	var timers []string
+	for _, trigger := range strings.Fields(values["TriggeredBy"]) {
+		if validation.ValidateServiceName(trigger) != nil {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(trigger, ".timer"):
+			timers = append(timers, trigger)
+		case strings.HasSuffix(trigger, ".socket"):
+			return Activation{Cause: ActivationSocket, Trigger: trigger}, nil
+		case strings.HasSuffix(trigger, ".path"):
+			return Activation{Cause: ActivationPath, Trigger: trigger}, nil
+		}
+	}
+	if timer := s.elapsedTimer(ctx, scope, timers, start); timer != "" {
+		return Activation{Cause: ActivationTimer, Trigger: timer}, nil
+	}
+
+	// A manual start resets the counter, so restarts since then mean Restart= started this run
+	if n, _ := strconv.Atoi(values["NRestarts"]); n > 0 {
+		return Activation{Cause: ActivationRestart, Restarts: n}, nil
+	}
+	if s.startedDuringStartup(ctx, scope, start) {
+		if scope == ScopeUser {
+			return Activation{Cause: ActivationLogin}, nil
+		}
+		return Activation{Cause: ActivationBoot}, nil
+	}
+	return Activation{Cause: ActivationManual}, nil
+}
+
+// elapsedTimer returns the timer that elapsed within timerActivationWindow before start (monotonic microseconds), if any
+func (s *Service) elapsedTimer(ctx context.Context, scope SystemdScope, timers []string, start uint64) string {
+	if len(timers) == 0 {
+		return ""
+	}
+	args := append([]string{"show", "--property=Id,LastTriggerUSecMonotonic", "--no-pager", "--"}, timers...)
+	result := s.ExecSystemctl(ctx, scope, args...)
+	if result.Error != nil {
+		return ""
+	}
+	for _, block := range parseShowBlocks(string(result.Output)) {
+		last, _ := strconv.ParseUint(block["LastTriggerUSecMonotonic"], 10, 64)
+		if last > 0 && last <= start && time.Duration(start-last)*time.Microsecond <= timerActivationWindow {
+			return block["Id"]
+		}
+	}
+	return ""
+}
+
+// startedDuringStartup reports whether start (monotonic microseconds) came before the manager finished starting up
+func (s *Service) startedDuringStartup(ctx context.Context, scope SystemdScope, start uint64) bool {
+	result := s.ExecSystemctl(ctx, scope, "show", "--property=FinishTimestampMonotonic", "--no-pager")
+	if result.Error != nil {
+		return false
+	}
+	value := strings.TrimPrefix(strings.TrimSpace(string(result.Output)), "FinishTimestampMonotonic=")
+	finish, err := strconv.ParseUint(value, 10, 64)
+	// Zero while the manager is still starting up
+	return err == nil && (finish == 0 || start < finish)
+}
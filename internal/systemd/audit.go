@@ -0,0 +1,93 @@
+package systemd
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"telegram-notifier/internal/validation"
+)
+
+// secretVariablePattern matches variable names that conventionally hold credentials
+var secretVariablePattern = regexp.MustCompile(`(?i)(pass(word|wd)?|secret|token|api_?key|private_?key|credential)`)
+
+// EnvironmentFinding is an inline credential in a unit file
+// SECURITY: Only the variable name is kept; the value is never reported
+type EnvironmentFinding struct {
+	Path     string
+	Line     int
+	Variable string
+}
+
+// AuditEnvironment scans unit files for Environment= assignments that look like credentials
+// Anything set this way is visible to every local user through "systemctl show" and ends up
+// in the properties the notifier reads
+func AuditEnvironment(paths []string) []EnvironmentFinding {
+	var findings []EnvironmentFinding
+	for _, path := range paths {
+		findings = append(findings, auditUnitFile(path)...)
+	}
+	return findings
+}
+
+// auditUnitFile returns the credential-like Environment= assignments in one unit file
+// Unreadable files are skipped; drop-ins may belong to other users
+func auditUnitFile(path string) []EnvironmentFinding {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var findings []EnvironmentFinding
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "Environment=")
+		if !ok {
+			continue
+		}
+		for _, assignment := range splitEnvironment(value) {
+			name, _, _ := strings.Cut(assignment, "=")
+			if looksLikeSecret(name, assignment) {
+				findings = append(findings, EnvironmentFinding{Path: path, Line: lineNo, Variable: name})
+			}
+		}
+	}
+	return findings
+}
+
+// looksLikeSecret flags assignments by variable name or by the built-in redaction patterns
+func looksLikeSecret(name, assignment string) bool {
+	if secretVariablePattern.MatchString(name) {
+		return true
+	}
+	_, redactions := validation.FilterSecretsCount(assignment)
+	return redactions > 0
+}
+
+// splitEnvironment splits an Environment= value into assignments, honoring double and single quotes
+func splitEnvironment(value string) []string {
+	var assignments []string
+	var current strings.Builder
+	var quote rune
+	for _, r := range value {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && (r == ' ' || r == '\t'):
+			if current.Len() > 0 {
+				assignments = append(assignments, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		assignments = append(assignments, current.String())
+	}
+	return assignments
+}
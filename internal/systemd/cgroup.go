@@ -0,0 +1,109 @@
+package systemd
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupRoot is where the unified cgroup hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupProperties are the properties CgroupUsage asks for
+var cgroupProperties = []string{"ControlGroup", "TasksCurrent", "TasksMax", "MemoryCurrent", "MemoryPeak", "MemoryMax", "MemoryHigh", "CPUQuotaPerSecUSec"}
+
+// CgroupUsage is a unit's resource use against its limits; zero values are unknown or unlimited
+type CgroupUsage struct {
+	TasksCurrent     uint64
+	TasksMax         uint64
+	MemoryCurrent    uint64
+	MemoryPeak       uint64 // Highest memory use of the run
+	MemoryMax        uint64
+	MemoryHigh       uint64        // Throttling threshold below MemoryMax
+	MemoryMaxHits    uint64        // Times memory use reached MemoryMax
+	CPUQuota         time.Duration // CPU time allowed per second; 500ms is 50% of one CPU
+	ThrottledPeriods uint64        // Scheduler periods in which the quota ran out
+	ThrottledTime    time.Duration // Time spent waiting for the next period
+}
+
+// CgroupUsage reads a unit's task, memory and CPU use and limits from systemd,
+// and throttling counters from its cgroup while it still exists
+// The cgroup is removed once the unit has stopped, but is still there while ExecStopPost= commands run
+// SECURITY: Validates the unit name; the cgroup path systemd reports must stay below the cgroup mount
+func (s *Service) CgroupUsage(ctx context.Context, serviceName string) (CgroupUsage, error) {
+	scope, found, err := s.unitScope(ctx, serviceName)
+	if err != nil || !found {
+		return CgroupUsage{}, err
+	}
+	values, err := s.GetSystemctlProperties(ctx, serviceName, cgroupProperties, scope)
+	if err != nil {
+		return CgroupUsage{}, err
+	}
+
+	usage := CgroupUsage{
+		TasksCurrent:  parseCgroupValue(values["TasksCurrent"]),
+		TasksMax:      parseCgroupValue(values["TasksMax"]),
+		MemoryCurrent: parseCgroupValue(values["MemoryCurrent"]),
+		MemoryPeak:    parseCgroupValue(values["MemoryPeak"]),
+		MemoryMax:     parseCgroupValue(values["MemoryMax"]),
+		MemoryHigh:    parseCgroupValue(values["MemoryHigh"]),
+		CPUQuota:      parseTimespan(values["CPUQuotaPerSecUSec"]),
+	}
+
+	dir := filepath.Join(cgroupRoot, values["ControlGroup"])
+	if values["ControlGroup"] == "" || !strings.HasPrefix(dir, cgroupRoot+"/") {
+		return usage, nil
+	}
+	if stat := readCgroupKeys(filepath.Join(dir, "cpu.stat")); stat != nil {
+		usage.ThrottledPeriods = stat["nr_throttled"]
+		usage.ThrottledTime = time.Duration(stat["throttled_usec"]) * time.Microsecond
+	}
+	if events := readCgroupKeys(filepath.Join(dir, "memory.events")); events != nil {
+		usage.MemoryMaxHits = events["max"]
+	}
+	// Older systemd versions don't report MemoryPeak, but the kernel has kept it since 5.19
+	if usage.MemoryPeak == 0 {
+		if content, err := os.ReadFile(filepath.Join(dir, "memory.peak")); err == nil {
+			usage.MemoryPeak = parseCgroupValue(strings.TrimSpace(string(content)))
+		}
+	}
+	return usage, nil
+}
+
+// parseCgroupValue reads a counter or limit; "[not set]", "infinity" and the all-ones "unlimited" give zero
+func parseCgroupValue(value string) uint64 {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n == math.MaxUint64 {
+		return 0
+	}
+	return n
+}
+
+// parseTimespan reads a systemd timespan such as "500ms" or "1s 500ms"; "infinity" and unreadable values give zero
+func parseTimespan(value string) time.Duration {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "min", "m")
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// readCgroupKeys parses a flat-keyed cgroup file of "key value" lines; nil when it can't be read
+func readCgroupKeys(path string) map[string]uint64 {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	keys := map[string]uint64{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(line, " "); ok {
+			keys[key] = parseCgroupValue(value)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,43 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"telegram-notifier/internal/validation"
+)
+
+// Unit actions ControlUnit can run
+const (
+	ActionRestart = "restart"
+	ActionStop    = "stop"
+)
+
+// ControlUnit restarts or stops a unit, in the manager that has it loaded, and waits for the job to finish
+// SECURITY: Only ActionRestart and ActionStop run; the unit name is validated and errors are filtered for secrets
+func (s *Service) ControlUnit(ctx context.Context, action, serviceName string) error {
+	if action != ActionRestart && action != ActionStop {
+		return fmt.Errorf("unsupported unit action %q", action)
+	}
+	statuses, err := s.UnitStatuses(ctx, []string{serviceName})
+	if err != nil {
+		return err
+	}
+	status := statuses[0]
+	if status.LoadState == "not-found" {
+		return fmt.Errorf("unit '%s' not found", serviceName)
+	}
+
+	// Unlike ExecSystemctl, this never falls back to the other scope: the action must run exactly once
+	args := s.buildCommandArgs(status.User, []string{action, "--", serviceName})
+	if _, err := s.executeWithRateLimit(ctx, "systemctl", args...); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, firstLine(string(exitErr.Stderr), 200))
+		}
+		return validation.FilterSecretsFromError(fmt.Errorf("%s %s: %w", action, serviceName, err))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package systemd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/validation"
+)
+
+// Kinds of ManagerEvent
+const (
+	EventDaemonReload = "daemon-reload" // The manager reloaded its configuration
+	EventRestart      = "restart"       // A restart job began for a unit
+	EventReload       = "reload"        // A reload job began for a unit
+)
+
+// restartScheduledID is the journal MESSAGE_ID of "Scheduled restart job", logged before Restart= restarts a unit
+const restartScheduledID = "5eb03494b6584870a536b337290809b3"
+
+// ManagerEvent is an operator action the service manager logged
+type ManagerEvent struct {
+	Kind    string
+	Unit    string // Empty for EventDaemonReload
+	Message string // The manager's log message, naming the requesting client for reloads on systemd 253 and later
+	Time    time.Time
+}
+
+// managerEntry holds the journal fields WatchManagerEvents reads
+// Fields logged as binary data are arrays rather than strings and fail to decode, skipping the entry
+type managerEntry struct {
+	Message   string `json:"MESSAGE"`
+	MessageID string `json:"MESSAGE_ID"`
+	Unit      string `json:"UNIT"`
+	JobType   string `json:"JOB_TYPE"`
+	JobResult string `json:"JOB_RESULT"`
+	Realtime  string `json:"__REALTIME_TIMESTAMP"` // Microseconds since the epoch
+}
+
+// WatchManagerEvents follows the system manager's journal entries, calling fn for daemon reloads
+// and for restart and reload jobs other than the ones Restart= schedules
+// Jobs are reported when they begin: systemd logs who asked for a reload, but not who queued a job
+// Blocks until ctx is cancelled or journalctl exits
+// SECURITY: Arguments are fixed; messages are passed on as logged, callers redact them
+func (s *Service) WatchManagerEvents(ctx context.Context, fn func(ManagerEvent)) error {
+	cmd := exec.CommandContext(ctx, "journalctl", "--follow", "--lines=0", "--output=json", "--no-pager", "_PID=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return validation.FilterSecretsFromError(fmt.Errorf("following the journal: %w", err))
+	}
+
+	parser := &managerParser{scheduled: map[string]bool{}}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), constants.MaxJournalLineBytes)
+	for scanner.Scan() {
+		var entry managerEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if event, ok := parser.event(entry); ok {
+			fn(event)
+		}
+	}
+	// Entries too long for the buffer end the scan; the caller starts following again
+	scanErr := scanner.Err()
+	cmd.Process.Kill()
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if scanErr != nil {
+		return fmt.Errorf("reading the journal: %w", scanErr)
+	}
+	return validation.FilterSecretsFromError(fmt.Errorf("journalctl stopped following: %w", waitErr))
+}
+
+// managerParser tells which operator action each manager journal entry records, remembering what earlier entries announced
+type managerParser struct {
+	scheduled map[string]bool // Units whose next restart job comes from Restart= rather than an operator
+	requester string          // Who asked for the reload about to begin
+}
+
+// event returns the action an entry records, if any
+func (p *managerParser) event(entry managerEntry) (ManagerEvent, bool) {
+	event := ManagerEvent{Unit: entry.Unit, Message: entry.Message, Time: time.Now()}
+	if usec, err := strconv.ParseInt(entry.Realtime, 10, 64); err == nil {
+		event.Time = time.UnixMicro(usec)
+	}
+
+	switch {
+	case entry.MessageID == restartScheduledID:
+		p.scheduled[entry.Unit] = true
+	case entry.Unit == "" && strings.HasPrefix(entry.Message, "Reloading requested from client"):
+		// Since systemd 253: "Reloading requested from client PID 1234 ('systemctl') (unit session-3.scope)...", then "Reloading..."
+		p.requester = entry.Message
+	case entry.Unit == "" && (entry.Message == "Reloading..." || entry.Message == "Reloading."):
+		event.Kind = EventDaemonReload
+		if p.requester != "" {
+			event.Message, p.requester = p.requester, ""
+		}
+		return event, true
+	case entry.Unit == "" || entry.JobResult != "":
+		// Jobs log again when done; the restart job's stop half would be reported twice
+	case entry.JobType == EventRestart && p.scheduled[entry.Unit]:
+		delete(p.scheduled, entry.Unit)
+	case entry.JobType == EventRestart || entry.JobType == EventReload:
+		event.Kind = entry.JobType
+		return event, true
+	}
+	return event, false
+}
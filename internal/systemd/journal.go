@@ -3,10 +3,13 @@ package systemd
 import (
 	"context"
 	"fmt"
-	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/markdown"
 	"telegram-notifier/internal/validation"
 )
 
@@ -17,64 +20,150 @@ type JournalOutput struct {
 	StartTime        time.Time // Service start timestamp
 }
 
-// GetCurrentExecutionLogs retrieves logs for the current service execution
-// SECURITY: Uses invocation ID from environment to prevent race conditions
-func (s *Service) GetCurrentExecutionLogs(ctx context.Context, serviceName string) (JournalOutput, error) {
-	var output JournalOutput
-
-	// Check context cancellation
-	select {
-	case <-ctx.Done():
-		return output, validation.FilterSecretsFromError(ctx.Err())
-	default:
-	}
-
-	// Use invocation ID from environment if available (prevents TOCTOU race)
-	// This ensures we get logs for THIS exact execution, not a concurrent one
-	invocationID := os.Getenv("INVOCATION_ID")
-	sinceTime := time.Now().Add(-s.config.JournalLookback).Format("2006-01-02 15:04:05")
-
+// queryJournal runs the single journal query a notification needs, in short format
+// SECURITY: The invocation ID scopes the query to this exact run, preventing races with concurrent runs;
+// without one, the lookback window is searched and the parsers keep only the latest run
+func (s *Service) queryJournal(ctx context.Context, serviceName, invocationID string) (Tail, error) {
 	config := CommandConfig{
 		ServiceName:  serviceName,
 		InvocationID: invocationID,
-		SinceTime:    sinceTime,
+		SinceTime:    time.Now().Add(-s.config.JournalLookback).Format("2006-01-02 15:04:05"),
 		OutputFormat: "short",
 	}
 
-	journalRaw, err := s.ExecJournalctl(ctx, config, ScopeBoth)
+	tail, err := s.ExecJournalctl(ctx, config, ScopeBoth)
+	if err != nil {
+		return Tail{}, validation.FilterSecretsFromError(fmt.Errorf("executing journalctl: %w", err))
+	}
+	return tail, nil
+}
+
+// RecentLogs returns the last lines journal entries of a unit, whatever run they belong to, for on-demand checks
+// Lines are "time message": the date and host are dropped, so a hostname alias isn't undone
+// SECURITY: The service name is validated before journalctl runs; callers redact the result
+func (s *Service) RecentLogs(ctx context.Context, serviceName string, lines int) (string, error) {
+	config := CommandConfig{ServiceName: serviceName, OutputFormat: "short", Lines: lines}
+	tail, err := s.ExecJournalctl(ctx, config, ScopeBoth)
+	if err != nil {
+		return "", err
+	}
+	entries := make([]string, 0, len(tail.Lines))
+	for _, line := range tail.Lines {
+		if strings.HasPrefix(line, "-- ") {
+			continue
+		}
+		// Short format: "Oct 18 02:25:50 host process[pid]: message"
+		if fields := strings.Fields(line); len(fields) > 2 {
+			line = fields[2] + " " + extractMessage(line)
+		}
+		entries = append(entries, line)
+	}
+	return strings.Join(entries, "\n"), nil
+}
+
+// SystemErrors returns the last lines error-priority entries of the system journal since boot, oldest first
+// Kernel, OOM killer and disk errors behind a failure are logged by other units or none at all
+// Lines are "time source: message", dropping the date and host like RecentLogs
+// SECURITY: Arguments are fixed; callers redact the result
+func (s *Service) SystemErrors(ctx context.Context, lines int) (string, error) {
+	args := []string{"--priority=err", "--boot", "--lines=" + strconv.Itoa(lines), "--output=short", "--no-pager", "--quiet"}
+	tail, err := s.executeTailWithRateLimit(ctx, lines, "journalctl", args...)
 	if err != nil {
-		return output, validation.FilterSecretsFromError(fmt.Errorf("executing journalctl: %w", err))
+		return "", validation.FilterSecretsFromError(fmt.Errorf("reading system errors: %w", err))
 	}
+	entries := make([]string, 0, len(tail.Lines))
+	for _, line := range tail.Lines {
+		if strings.HasPrefix(line, "-- ") {
+			continue
+		}
+		entries = append(entries, stripDateAndHost(line))
+	}
+	return strings.Join(entries, "\n"), nil
+}
+
+// SecurityDenials returns the SELinux AVC and AppArmor denials the kernel and audit subsystem logged since a time,
+// oldest first and at most constants.MaxDenialLines
+// Denials of the given process are kept when there are any; otherwise every denial since then is,
+// as the one that failed may have been a child of the main process
+// SECURITY: Arguments are a number and a timestamp; callers redact the result
+func (s *Service) SecurityDenials(ctx context.Context, pid int, since time.Time) (string, error) {
+	args := []string{"--since=@" + strconv.FormatInt(since.Unix(), 10), "--output=short", "--no-pager", "--quiet",
+		"_TRANSPORT=audit", "_TRANSPORT=kernel"}
+	tail, err := s.executeTailWithRateLimit(ctx, constants.MaxJournalLines, "journalctl", args...)
+	if err != nil {
+		return "", validation.FilterSecretsFromError(fmt.Errorf("reading security denials: %w", err))
+	}
+	var denials, own []string
+	pidField := " pid=" + strconv.Itoa(pid) + " "
+	for _, line := range tail.Lines {
+		if !isDenial(line) {
+			continue
+		}
+		line = stripDateAndHost(line)
+		denials = append(denials, line)
+		if pid > 0 && strings.Contains(line+" ", pidField) {
+			own = append(own, line)
+		}
+	}
+	if len(own) > 0 {
+		denials = own
+	}
+	if len(denials) > constants.MaxDenialLines {
+		denials = denials[len(denials)-constants.MaxDenialLines:]
+	}
+	return strings.Join(denials, "\n"), nil
+}
 
-	// Parse journal output line by line
-	lines := strings.Split(string(journalRaw), "\n")
-	foundStart := invocationID != "" // If we have invocation ID, already scoped
+// isDenial reports whether a kernel or audit message records a refused access
+// SELinux logs "avc:  denied  { read } for  pid=...", AppArmor "apparmor=\"DENIED\" operation=..."
+func isDenial(line string) bool {
+	return strings.Contains(line, `apparmor="DENIED"`) || (strings.Contains(line, "avc:") && strings.Contains(line, "denied"))
+}
+
+// stripDateAndHost shortens a short-format line to "time source: message"
+// Short format: "Oct 18 02:25:50 host kernel: message"; day numbers are space-padded
+func stripDateAndHost(line string) string {
+	if fields := strings.Fields(line); len(fields) > 4 {
+		rest := line[strings.Index(line, fields[2])+len(fields[2]):]
+		line = fields[2] + strings.TrimPrefix(strings.TrimLeft(rest, " "), fields[3])
+	}
+	return line
+}
+
+// parseExecutionLogs separates lifecycle messages from command output in the latest run's entries
+func parseExecutionLogs(lines []string, serviceName string, scoped bool) JournalOutput {
+	var output JournalOutput
+	foundStart := scoped // Scoped entries all belong to the run
 	var lastProcessName string
 	inCommandOutput := false
 
 	for _, line := range lines {
 		processJournalLine(line, serviceName, &output, &foundStart, &lastProcessName, &inCommandOutput)
 	}
-
-	return output, nil
+	return output
 }
 
-// GetSimpleCommandOutput retrieves command output in compact format
-// Falls back to cat format for cleaner output without timestamps
-func (s *Service) GetSimpleCommandOutput(ctx context.Context, serviceName string) (string, error) {
-	select {
-	case <-ctx.Done():
-		return "", validation.FilterSecretsFromError(ctx.Err())
-	default:
+// journalMessages strips the timestamp, host and process prefix of short-format lines,
+// giving the same text as journalctl --output=cat
+func journalMessages(lines []string) string {
+	messages := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, "-- ") {
+			continue
+		}
+		messages = append(messages, extractMessage(line))
 	}
+	return strings.Join(messages, "\n")
+}
 
-	sinceTime := s.config.JournalSinceDefault
-
+// simpleCommandOutput extracts command output from the messages alone, for runs whose
+// output couldn't be attributed to a process
+func (s *Service) simpleCommandOutput(ctx context.Context, lines []string, serviceName string) (string, error) {
 	// Try to get the command name for better output filtering
-	execStart, _ := s.GetSystemctlProperty(ctx, serviceName, "ExecStart", ScopeBoth)
+	metadata, _ := s.unitMetadata(ctx, serviceName)
 	var execCommand string
-	if execStart != "" {
-		parts := strings.Fields(execStart)
+	if metadata.ExecStart != "" {
+		parts := strings.Fields(metadata.ExecStart)
 		if len(parts) > 0 {
 			execCommand = parts[0]
 			// Extract just the command name (strip path)
@@ -84,60 +173,60 @@ func (s *Service) GetSimpleCommandOutput(ctx context.Context, serviceName string
 		}
 	}
 
-	// Use 'cat' format for cleaner output (no timestamps/metadata)
-	config := CommandConfig{
-		ServiceName:  serviceName,
-		SinceTime:    sinceTime,
-		OutputFormat: "cat",
+	if result := s.processSimpleOutput(journalMessages(lines), serviceName, execCommand); result != "" {
+		return result, nil
 	}
-
-	output, err := s.ExecJournalctl(ctx, config, ScopeBoth)
-	if err == nil && len(output) > 0 {
-		result := s.processSimpleOutput(string(output), serviceName, execCommand)
-		if result != "" {
-			return result, nil
-		}
-	}
-
 	return "", fmt.Errorf("no command output found for service '%s'", serviceName)
 }
 
-// GetServiceCommandOutput retrieves command output with fallback strategies
+// CommandOutput is what the journal holds about a run
+type CommandOutput struct {
+	Markdown string // Lifecycle and command output in code blocks, the command output cut to NOTIFIER_MAX_OUTPUT_SIZE
+	Full     string // The command output alone as plain text, uncut
+}
+
+// GetServiceCommandOutput retrieves command output with a single journal query
+// Every view of the output (the run's own entries, lifecycle and command output, bare messages)
+// is derived from that one result set
+// The Markdown has the output in code blocks, where log lines can't break the layout
 // SECURITY: Uses invocation ID from exitInfo to ensure consistency across calls
-func (s *Service) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo ExitCodeInfo) (string, error) {
+func (s *Service) GetServiceCommandOutput(ctx context.Context, serviceName string, exitInfo ExitCodeInfo) (CommandOutput, error) {
 	select {
 	case <-ctx.Done():
-		return "", validation.FilterSecretsFromError(ctx.Err())
+		return CommandOutput{}, validation.FilterSecretsFromError(ctx.Err())
 	default:
 	}
 
-	// Try using invocation ID first (most reliable, prevents race conditions)
-	if exitInfo.InvocationID != "" {
-		config := CommandConfig{
-			ServiceName:  serviceName,
-			InvocationID: exitInfo.InvocationID,
-			OutputFormat: "cat",
-		}
-		if output, err := s.ExecJournalctl(ctx, config, ScopeBoth); err == nil && len(output) > 0 {
-			result := s.processSimpleOutput(string(output), serviceName, "")
-			if result != "" {
-				return result, nil
-			}
-		}
+	tail, err := s.queryJournal(ctx, serviceName, exitInfo.InvocationID)
+	if err != nil {
+		return CommandOutput{}, validation.FilterSecretsFromError(fmt.Errorf("getting execution logs: %w", err))
 	}
+	lines := tail.Lines
 
-	// Fallback to time-based log retrieval
-	output, err := s.GetCurrentExecutionLogs(ctx, serviceName)
-	if err != nil {
-		return "", validation.FilterSecretsFromError(fmt.Errorf("getting execution logs: %w", err))
+	// Entries of this exact run need no lifecycle parsing (most reliable, prevents race conditions)
+	if exitInfo.InvocationID != "" {
+		if result := s.processSimpleOutput(journalMessages(lines), serviceName, ""); result != "" {
+			return CommandOutput{
+				Markdown: "*Command Output*\n```\n" + markdown.Literal(validation.TruncateMessage(result, s.config.MaxOutputSize)) + "\n```",
+				Full:     result,
+			}, nil
+		}
 	}
 
-	return s.FormatServiceOutput(ctx, output, exitInfo, serviceName), nil
+	// Without an invocation ID the latest start line scopes the output to the run; only when the run logged more
+	// than the tail holds and its start line is gone is everything kept its output
+	scoped := exitInfo.InvocationID != "" ||
+		(tail.Dropped > 0 && !slices.ContainsFunc(lines, func(line string) bool { return isStartLine(line, serviceName) }))
+	output := parseExecutionLogs(lines, serviceName, scoped)
+	return s.formatServiceOutput(ctx, output, lines, exitInfo, serviceName), nil
 }
 
-// FormatServiceOutput formats systemd logs and command output for notification
-func (s *Service) FormatServiceOutput(ctx context.Context, output JournalOutput, exitInfo ExitCodeInfo, serviceName string) string {
-	var result strings.Builder
+// formatServiceOutput formats systemd logs and command output for notification
+func (s *Service) formatServiceOutput(ctx context.Context, output JournalOutput, lines []string, exitInfo ExitCodeInfo, serviceName string) CommandOutput {
+	var (
+		result strings.Builder
+		full   string
+	)
 
 	// Format systemd lifecycle logs
 	result.WriteString("*Systemd Service*\n```\n")
@@ -153,7 +242,7 @@ func (s *Service) FormatServiceOutput(ctx context.Context, output JournalOutput,
 			if strings.Contains(log, "Main process exited") && exitInfo.ProcessExitCode != 0 {
 				log = fmt.Sprintf("%s\n→ Process exit code: %s", log, GetExitStatusString(exitInfo.ProcessExitCode))
 			}
-			result.WriteString(log)
+			result.WriteString(markdown.Literal(log))
 			result.WriteString("\n")
 		}
 	}
@@ -162,8 +251,8 @@ func (s *Service) FormatServiceOutput(ctx context.Context, output JournalOutput,
 	// Format command output
 	result.WriteString("\n*Command Output*\n```\n")
 	if len(output.ExecutionResults) == 0 {
-		// Try fallback method if no execution results captured
-		simpleOutput, err := s.GetSimpleCommandOutput(ctx, serviceName)
+		// Fall back to the bare messages if no execution results were attributed
+		simpleOutput, err := s.simpleCommandOutput(ctx, lines, serviceName)
 		if err != nil {
 			if exitInfo.ServiceSuccess {
 				result.WriteString("Command completed with no output")
@@ -171,18 +260,20 @@ func (s *Service) FormatServiceOutput(ctx context.Context, output JournalOutput,
 				result.WriteString(fmt.Sprintf("Command failed with exit code %d (no output)", exitInfo.ProcessExitCode))
 			}
 		} else {
-			result.WriteString(simpleOutput)
+			full = simpleOutput
+			result.WriteString(markdown.Literal(validation.TruncateMessage(simpleOutput, s.config.MaxOutputSize)))
 		}
 	} else {
-		fullOutput := strings.Join(output.ExecutionResults, "\n")
-		result.WriteString(validation.TruncateMessage(fullOutput, s.config.MaxOutputSize))
+		full = strings.Join(output.ExecutionResults, "\n")
+		result.WriteString(markdown.Literal(validation.TruncateMessage(full, s.config.MaxOutputSize)))
 	}
 	result.WriteString("\n```")
 
-	return result.String()
+	return CommandOutput{Markdown: result.String(), Full: full}
 }
 
 // processSimpleOutput extracts command output from journal, filtering systemd metadata
+// The output is returned whole; callers cut it to size
 func (s *Service) processSimpleOutput(output, serviceName, execCommand string) string {
 	lines := strings.Split(output, "\n")
 	var commandOutput []string
@@ -223,12 +314,17 @@ func (s *Service) processSimpleOutput(output, serviceName, execCommand string) s
 		// Clean up extra whitespace
 		result = strings.TrimPrefix(result, "\n\n")
 		result = strings.TrimSuffix(result, "\n\n")
-		return validation.TruncateMessage(result, s.config.MaxOutputSize)
+		return result
 	}
 
 	return ""
 }
 
+// isStartLine reports whether a journal line is systemd starting the unit, which begins a new run
+func isStartLine(line, serviceName string) bool {
+	return strings.Contains(line, "Starting") && strings.Contains(line, serviceName)
+}
+
 // processJournalLine parses a single journal line and categorizes it
 // Separates systemd lifecycle messages from actual command output
 func processJournalLine(line, serviceName string, output *JournalOutput, foundStart *bool, lastProcessName *string, inCommandOutput *bool) {
@@ -238,7 +334,7 @@ func processJournalLine(line, serviceName string, output *JournalOutput, foundSt
 	}
 
 	// Detect service start to reset state (new execution)
-	if strings.Contains(line, "Starting") && strings.Contains(line, serviceName) {
+	if isStartLine(line, serviceName) {
 		*foundStart = true
 		output.SystemdLogs = []string{}
 		output.ExecutionResults = []string{}
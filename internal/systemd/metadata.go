@@ -0,0 +1,169 @@
+package systemd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
+)
+
+// metadataProperties are fetched together: what notifications show plus what keys the cache
+var metadataProperties = []string{"Description", "ExecStart", "FragmentPath", "DropInPaths", "NeedDaemonReload"}
+
+// unitMetadata is the part of a unit's definition notifications use
+// It only changes when the unit files do, so timer-driven units reuse it across runs
+type unitMetadata struct {
+	Description string               `json:"description"`
+	ExecStart   string               `json:"exec_start"`
+	Files       map[string]time.Time `json:"files"` // Fragment and drop-ins with their mtimes when fetched
+}
+
+// fresh reports whether none of the unit files changed since the metadata was fetched
+func (m unitMetadata) fresh() bool {
+	if len(m.Files) == 0 {
+		return false
+	}
+	for path, mtime := range m.Files {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(mtime) {
+			return false
+		}
+	}
+	return true
+}
+
+// unitMetadata returns the unit's description and ExecStart, from memory or the state file while its unit files are unchanged
+// The daemon outlives edits to unit files, so the copy in memory is checked like the cached one
+func (s *Service) unitMetadata(ctx context.Context, serviceName string) (unitMetadata, error) {
+	s.metadataMu.Lock()
+	defer s.metadataMu.Unlock()
+	if m, ok := s.metadata[serviceName]; ok && m.fresh() {
+		return m, nil
+	}
+
+	cache := s.loadMetadataCache()
+	if m, ok := cache[serviceName]; ok && m.fresh() {
+		slog.Debug("Using cached unit metadata", logging.KeyService, serviceName)
+		s.metadata[serviceName] = m
+		return m, nil
+	}
+
+	values, err := s.GetSystemctlProperties(ctx, serviceName, metadataProperties, ScopeBoth)
+	if err != nil {
+		return unitMetadata{}, err
+	}
+	m := unitMetadata{Description: values["Description"], ExecStart: values["ExecStart"]}
+
+	// Edited files systemd hasn't reloaded would be cached under their new mtimes with the old values
+	if values["NeedDaemonReload"] != "yes" && values["FragmentPath"] != "" {
+		m.Files = unitFileTimes(values["FragmentPath"], values["DropInPaths"])
+	}
+	if m.Files == nil {
+		return m, nil
+	}
+	s.metadata[serviceName] = m
+	cache[serviceName] = m
+	if err := s.saveMetadataCache(cache); err != nil {
+		slog.Debug("Saving unit metadata cache failed", logging.Err(err))
+	}
+	return m, nil
+}
+
+// UnitFileHashes returns a hash of the contents of the unit's fragment and each drop-in, keyed by path
+// Returns nil when they can't all be read, or when systemd hasn't loaded their latest edits,
+// since the files then don't describe the unit that ran
+// SECURITY: Only a hash of each file is returned; unit files can hold credentials in Environment= lines
+func (s *Service) UnitFileHashes(ctx context.Context, serviceName string) (map[string]string, error) {
+	m, err := s.unitMetadata(ctx, serviceName)
+	if err != nil || m.Files == nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(m.Files))
+	for path := range m.Files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil
+		}
+		sum := sha256.Sum256(content)
+		hashes[path] = hex.EncodeToString(sum[:8])
+	}
+	return hashes, nil
+}
+
+// unitFileTimes returns the mtimes of the unit fragment and its drop-ins, or nil if one can't be read
+func unitFileTimes(fragment, dropIns string) map[string]time.Time {
+	files := map[string]time.Time{}
+	for _, path := range append([]string{fragment}, strings.Fields(dropIns)...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		files[path] = info.ModTime()
+	}
+	return files
+}
+
+// metadataCachePath is the cache file under the state directory; empty disables caching
+func (s *Service) metadataCachePath() string {
+	if s.config.StateDir == "" {
+		return ""
+	}
+	return filepath.Join(s.config.StateDir, constants.UnitCacheFileName)
+}
+
+// loadMetadataCache reads the cache; a missing or damaged file is an empty cache
+func (s *Service) loadMetadataCache() map[string]unitMetadata {
+	cache := map[string]unitMetadata{}
+	path := s.metadataCachePath()
+	if path == "" {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// saveMetadataCache writes the cache atomically via temp file and rename
+// Entries whose unit files changed or disappeared are dropped, so removed units don't accumulate
+func (s *Service) saveMetadataCache(cache map[string]unitMetadata) error {
+	path := s.metadataCachePath()
+	if path == "" {
+		return nil
+	}
+	for name, m := range cache {
+		if !m.fresh() {
+			delete(cache, name)
+		}
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,61 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/validation"
+)
+
+// OOMKills returns the kernel's "Killed process" messages for the unit's processes the OOM killer ended since a time,
+// oldest first and at most constants.MaxOOMKillLines
+// A process counts as the unit's when it's the main process, or when the kernel's oom-kill summary
+// puts it in the unit's cgroup
+// SECURITY: Validates the unit name; callers redact the result
+func (s *Service) OOMKills(ctx context.Context, serviceName string, pid int, since time.Time) (string, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return "", validation.FilterSecretsFromError(err)
+	}
+	args := []string{"--dmesg", "--since=@" + strconv.FormatInt(since.Unix(), 10), "--output=short", "--no-pager", "--quiet"}
+	tail, err := s.executeTailWithRateLimit(ctx, constants.MaxJournalLines, "journalctl", args...)
+	if err != nil {
+		return "", validation.FilterSecretsFromError(fmt.Errorf("reading the kernel log: %w", err))
+	}
+
+	// "oom-kill:constraint=CONSTRAINT_MEMCG,...,task_memcg=/system.slice/app.service,task=app,pid=4242,uid=0"
+	// comes before the "Killed process 4242 (app) total-vm:..." line of the same kill
+	pids := map[string]bool{strconv.Itoa(pid): pid > 0}
+	var kills []string
+	for _, line := range tail.Lines {
+		if _, summary, ok := strings.Cut(line, "oom-kill:"); ok {
+			fields := map[string]string{}
+			for _, field := range strings.Split(summary, ",") {
+				if key, value, ok := strings.Cut(field, "="); ok {
+					fields[key] = value
+				}
+			}
+			if memcgOfUnit(fields["task_memcg"], serviceName) {
+				pids[fields["pid"]] = true
+			}
+			continue
+		}
+		if _, killed, ok := strings.Cut(line, "Killed process "); ok {
+			if fields := strings.Fields(killed); len(fields) > 0 && pids[fields[0]] {
+				kills = append(kills, stripDateAndHost(line))
+			}
+		}
+	}
+	if len(kills) > constants.MaxOOMKillLines {
+		kills = kills[len(kills)-constants.MaxOOMKillLines:]
+	}
+	return strings.Join(kills, "\n"), nil
+}
+
+// memcgOfUnit reports whether a cgroup path is the unit's cgroup or one below it
+func memcgOfUnit(memcg, serviceName string) bool {
+	return strings.HasSuffix(memcg, "/"+serviceName) || strings.Contains(memcg, "/"+serviceName+"/")
+}
@@ -3,16 +3,22 @@ package systemd
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"telegram-notifier/internal/config"
 	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/logging"
 	"telegram-notifier/internal/ratelimit"
+	"telegram-notifier/internal/tracing"
 	"telegram-notifier/internal/validation"
 )
 
@@ -41,6 +47,27 @@ type ExitCodeInfo struct {
 	ExitSignal      string
 	ExitStatus      string
 	InvocationID    string
+	Runtime         time.Duration // Duration of the main process run; zero when unknown
+	MainPID         int           // Main process of the run, still reported after it exited; zero when unknown
+	Result          string        // systemd's result for the run, e.g. exit-code, signal or oom-kill
+}
+
+// execTiming collects monotonic timestamps (microseconds) to derive run time
+type execTiming struct {
+	start, exit                 uint64 // Main process start and exit
+	inactiveExit, inactiveEnter uint64 // Unit leaving and reentering the inactive or failed state
+}
+
+// runtime is the main process run time, or how long the unit was up when it had none
+// Timestamps are zero when the process never started, and the exit is older than the start while it's running
+func (t execTiming) runtime() time.Duration {
+	switch {
+	case t.start > 0 && t.exit > t.start:
+		return time.Duration(t.exit-t.start) * time.Microsecond
+	case t.inactiveExit > 0 && t.inactiveEnter > t.inactiveExit:
+		return time.Duration(t.inactiveEnter-t.inactiveExit) * time.Microsecond
+	}
+	return 0
 }
 
 type CommandConfig struct {
@@ -48,11 +75,14 @@ type CommandConfig struct {
 	InvocationID string
 	SinceTime    string
 	OutputFormat string
+	Lines        int // Only the last Lines entries; 0 for all
 }
 
 // CommandExecutor abstracts command execution for testing and security
 type CommandExecutor interface {
 	Execute(ctx context.Context, name string, args ...string) ([]byte, error)
+	// ExecuteTail runs a command keeping only the last maxLines lines of its output
+	ExecuteTail(ctx context.Context, maxLines int, name string, args ...string) (Tail, error)
 }
 
 type DefaultCommandExecutor struct{}
@@ -68,18 +98,49 @@ func (e *DefaultCommandExecutor) Execute(ctx context.Context, name string, args
 	return cmd.Output()
 }
 
+// ExecuteTail streams the command's output through a ring buffer instead of collecting all of it
+// SECURITY: Bounds the hook's memory when a unit logged megabytes
+func (e *DefaultCommandExecutor) ExecuteTail(ctx context.Context, maxLines int, name string, args ...string) (Tail, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stderr := &headWriter{max: 4096}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return Tail{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return Tail{}, err
+	}
+	tail, readErr := readTail(stdout, maxLines, constants.MaxJournalLineBytes, constants.MaxJournalBytes)
+	if readErr != nil {
+		// Unblock the command before waiting for it
+		io.Copy(io.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		// Like Execute's exit errors, say why the command failed
+		if len(stderr.buf) > 0 {
+			err = fmt.Errorf("%w: %s", err, firstLine(string(stderr.buf), 200))
+		}
+		return Tail{}, err
+	}
+	return tail, readErr
+}
+
 type Service struct {
 	executor           CommandExecutor
 	config             *config.Config
 	commandRateLimiter *ratelimit.TokenBucket
 	commandCheckOnce   sync.Once
 	commandCheckErr    error
+	metadataMu         sync.Mutex
+	metadata           map[string]unitMetadata // Looked up during this process, cached or not
 }
 
 func NewService(executor CommandExecutor, cfg *config.Config) *Service {
 	return &Service{
 		executor: executor,
 		config:   cfg,
+		metadata: map[string]unitMetadata{},
 		// Rate limiter prevents abuse by limiting command execution rate
 		commandRateLimiter: ratelimit.NewTokenBucket(
 			constants.CommandRateLimitTokens,
@@ -92,6 +153,11 @@ func NewService(executor CommandExecutor, cfg *config.Config) *Service {
 // SECURITY: Prevents confusing error messages and ensures systemd is installed
 func (s *Service) checkCommandAvailability() error {
 	s.commandCheckOnce.Do(func() {
+		// Plain mode is for hosts without systemd, where a lookup would only fail less clearly
+		if s.config.Plain {
+			s.commandCheckErr = fmt.Errorf("systemd lookups are disabled by NOTIFIER_PLAIN")
+			return
+		}
 		requiredCommands := []string{"systemctl", "journalctl"}
 		var missing []string
 
@@ -111,9 +177,39 @@ func (s *Service) checkCommandAvailability() error {
 // executeWithRateLimit wraps command execution with rate limiting and availability checks
 // SECURITY: Prevents command execution DoS by limiting rate of execution
 func (s *Service) executeWithRateLimit(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if err := s.waitToExecute(ctx); err != nil {
+		return nil, err
+	}
+
+	var output []byte
+	err := s.traceExec(ctx, name, args, func() ([]any, error) {
+		var err error
+		output, err = s.executor.Execute(ctx, name, args...)
+		return []any{"bytes", len(output)}, err
+	})
+	return output, err
+}
+
+// executeTailWithRateLimit is executeWithRateLimit keeping only the end of the output
+func (s *Service) executeTailWithRateLimit(ctx context.Context, maxLines int, name string, args ...string) (Tail, error) {
+	if err := s.waitToExecute(ctx); err != nil {
+		return Tail{}, err
+	}
+
+	var tail Tail
+	err := s.traceExec(ctx, name, args, func() ([]any, error) {
+		var err error
+		tail, err = s.executor.ExecuteTail(ctx, maxLines, name, args...)
+		return []any{"lines", len(tail.Lines), "dropped_lines", tail.Dropped}, err
+	})
+	return tail, err
+}
+
+// waitToExecute checks that the systemd commands exist and waits for a rate limit token
+func (s *Service) waitToExecute(ctx context.Context) error {
 	// Verify commands exist before attempting execution
 	if err := s.checkCommandAvailability(); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Apply rate limiting to prevent command execution abuse
@@ -121,14 +217,34 @@ func (s *Service) executeWithRateLimit(ctx context.Context, name string, args ..
 	defer cancel()
 
 	if err := s.commandRateLimiter.Wait(rateLimitCtx); err != nil {
-		return nil, fmt.Errorf("command rate limit exceeded: %w", err)
+		return fmt.Errorf("command rate limit exceeded: %w", err)
 	}
+	return nil
+}
 
-	return s.executor.Execute(ctx, name, args...)
+// traceExec runs a command execution in a span and logs its outcome with the attributes run returns
+func (s *Service) traceExec(ctx context.Context, name string, args []string, run func() ([]any, error)) error {
+	_, span := tracing.Start(ctx, "exec "+name)
+	span.SetAttr("command.args", strings.Join(args, " "))
+	defer span.End()
+
+	start := time.Now()
+	outputAttrs, err := run()
+	if err != nil {
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+	attrs := []any{"command", name + " " + strings.Join(args, " "), logging.KeyDuration, time.Since(start).Round(time.Millisecond)}
+	if err != nil {
+		slog.Debug("Command failed", append(attrs, logging.Err(err))...)
+	} else {
+		slog.Debug("Command executed", append(attrs, outputAttrs...)...)
+	}
+	return err
 }
 
 // ExecSystemctl executes systemctl commands with automatic scope fallback
 // Tries user scope first (safer), then system scope
+// Callers put unit names after a "--" argument so none is read as an option
 func (s *Service) ExecSystemctl(ctx context.Context, scope SystemdScope, args ...string) SystemctlResult {
 	select {
 	case <-ctx.Done():
@@ -140,6 +256,7 @@ func (s *Service) ExecSystemctl(ctx context.Context, scope SystemdScope, args ..
 
 	var lastErr error
 	for _, isUser := range tryScopes {
+		slog.Debug("Trying systemctl scope", "scope", scopeName(isUser))
 		cmdArgs := s.buildCommandArgs(isUser, args)
 		output, err := s.executeWithRateLimit(ctx, "systemctl", cmdArgs...)
 		if err == nil && len(output) > 0 {
@@ -154,36 +271,37 @@ func (s *Service) ExecSystemctl(ctx context.Context, scope SystemdScope, args ..
 	return SystemctlResult{Scope: scope, Error: validation.FilterSecretsFromError(lastErr)}
 }
 
-// ExecJournalctl executes journalctl with validated service name
+// ExecJournalctl executes journalctl with validated service name, keeping the last MaxJournalLines lines
 // SECURITY: Validates service name before execution and filters secrets from errors
-func (s *Service) ExecJournalctl(ctx context.Context, config CommandConfig, scope SystemdScope) ([]byte, error) {
+func (s *Service) ExecJournalctl(ctx context.Context, config CommandConfig, scope SystemdScope) (Tail, error) {
 	select {
 	case <-ctx.Done():
-		return nil, validation.FilterSecretsFromError(ctx.Err())
+		return Tail{}, validation.FilterSecretsFromError(ctx.Err())
 	default:
 	}
 
 	// Prevent command injection via service name
 	if err := validation.ValidateServiceName(config.ServiceName); err != nil {
-		return nil, validation.FilterSecretsFromError(err)
+		return Tail{}, validation.FilterSecretsFromError(err)
 	}
 
 	tryScopes := s.getScopesToTry(scope)
 
 	var lastErr error
 	for _, isUser := range tryScopes {
+		slog.Debug("Trying journalctl scope", "scope", scopeName(isUser), logging.KeyService, config.ServiceName)
 		cmdArgs := s.buildJournalArgs(isUser, config)
-		output, err := s.executeWithRateLimit(ctx, "journalctl", cmdArgs...)
-		if err == nil && len(output) > 0 {
-			return output, nil
+		tail, err := s.executeTailWithRateLimit(ctx, constants.MaxJournalLines, "journalctl", cmdArgs...)
+		if err == nil && len(tail.Lines) > 0 {
+			return tail, nil
 		}
 		lastErr = err
 	}
 
 	if lastErr != nil {
-		return nil, validation.FilterSecretsFromError(fmt.Errorf("journalctl failed for '%s': %w", config.ServiceName, lastErr))
+		return Tail{}, validation.FilterSecretsFromError(fmt.Errorf("journalctl failed for '%s': %w", config.ServiceName, lastErr))
 	}
-	return nil, fmt.Errorf("no journal output for '%s'", config.ServiceName)
+	return Tail{}, fmt.Errorf("no journal output for '%s'", config.ServiceName)
 }
 
 // GetSystemctlProperty retrieves a specific systemctl property
@@ -194,7 +312,7 @@ func (s *Service) GetSystemctlProperty(ctx context.Context, serviceName, propert
 		return "", validation.FilterSecretsFromError(err)
 	}
 
-	result := s.ExecSystemctl(ctx, scope, "show", serviceName, "--property="+property, "--no-pager")
+	result := s.ExecSystemctl(ctx, scope, "show", "--property="+property, "--no-pager", "--", serviceName)
 	if result.Error != nil {
 		return "", validation.FilterSecretsFromError(fmt.Errorf("getting property '%s': %w", property, result.Error))
 	}
@@ -203,6 +321,30 @@ func (s *Service) GetSystemctlProperty(ctx context.Context, serviceName, propert
 	return strings.TrimPrefix(value, property+"="), nil
 }
 
+// GetSystemctlProperties retrieves several properties with a single systemctl call
+// Properties systemctl doesn't report are missing from the result; repeated ones keep their first value
+// SECURITY: Validates service name and filters secrets from errors
+func (s *Service) GetSystemctlProperties(ctx context.Context, serviceName string, properties []string, scope SystemdScope) (map[string]string, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return nil, validation.FilterSecretsFromError(err)
+	}
+
+	result := s.ExecSystemctl(ctx, scope, "show", "--property="+strings.Join(properties, ","), "--no-pager", "--", serviceName)
+	if result.Error != nil {
+		return nil, validation.FilterSecretsFromError(fmt.Errorf("getting properties '%s': %w", strings.Join(properties, ","), result.Error))
+	}
+
+	// Units with several ExecStart= lines repeat the property; the first one is the main command
+	values := make(map[string]string, len(properties))
+	for _, line := range strings.Split(string(result.Output), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if _, seen := values[key]; ok && !seen {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
 // GetServiceInfo retrieves service description from systemctl or service files
 func (s *Service) GetServiceInfo(ctx context.Context, serviceName string) (ServiceInfo, error) {
 	// Validate service name to prevent path traversal and injection
@@ -216,10 +358,10 @@ func (s *Service) GetServiceInfo(ctx context.Context, serviceName string) (Servi
 	default:
 	}
 
-	// Prefer systemctl (authoritative source)
-	description, err := s.GetSystemctlProperty(ctx, serviceName, "Description", ScopeBoth)
-	if err == nil && description != "" && description != serviceName {
-		return ServiceInfo{Name: serviceName, Description: description}, nil
+	// Prefer systemctl (authoritative source), cached while the unit files are unchanged
+	metadata, err := s.unitMetadata(ctx, serviceName)
+	if err == nil && metadata.Description != "" && metadata.Description != serviceName {
+		return ServiceInfo{Name: serviceName, Description: metadata.Description}, nil
 	}
 
 	// Fallback to reading service files directly
@@ -265,15 +407,32 @@ func (s *Service) GetServiceExitCodeInfo(ctx context.Context, serviceName string
 
 	if serviceResult := os.Getenv("SERVICE_RESULT"); serviceResult != "" {
 		info.ServiceSuccess = (serviceResult == "success")
+		info.Result = serviceResult
+	}
+
+	// EXIT_CODE tells a death by signal apart from an exit whose status happens to match the signal number
+	if exitCode := os.Getenv("EXIT_CODE"); exitCode == "killed" || exitCode == "dumped" {
+		info.ExitSignal = exitCode
 	}
 
-	// Fallback to systemctl properties
-	for prop, handler := range s.getPropertyHandlers(&info) {
-		if value, err := s.GetSystemctlProperty(ctx, serviceName, prop, ScopeBoth); err == nil {
-			handler(value)
+	// Fallback to systemctl properties, all fetched with one call
+	var timing execTiming
+	handlers := s.getPropertyHandlers(&info, &timing)
+	properties := make([]string, 0, len(handlers))
+	for prop := range handlers {
+		properties = append(properties, prop)
+	}
+	sort.Strings(properties)
+	if values, err := s.GetSystemctlProperties(ctx, serviceName, properties, ScopeBoth); err == nil {
+		for _, prop := range properties {
+			if value, ok := values[prop]; ok {
+				handlers[prop](value)
+			}
 		}
 	}
 
+	info.Runtime = timing.runtime()
+
 	return info, nil
 }
 
@@ -300,8 +459,19 @@ func (s *Service) readServiceFileDescription(serviceName string) (string, error)
 }
 
 // getServicePaths generates possible service file locations
+// Directories from NOTIFIER_UNIT_PATHS come first; their files go through SanitizePath, so a symlink
+// or name leading outside the configured directory is skipped rather than read
 func (s *Service) getServicePaths(serviceName string) []string {
 	var paths []string
+	for _, dir := range s.config.UnitPaths {
+		path, err := validation.SanitizePath(dir, serviceName)
+		if err != nil {
+			slog.Debug("Skipping unit path", "dir", dir, logging.KeyService, serviceName, logging.Err(err))
+			continue
+		}
+		paths = append(paths, path)
+	}
+
 	baseDirs := []string{
 		"/etc/systemd/system",
 		"/usr/lib/systemd/system",
@@ -339,6 +509,14 @@ func (s *Service) getScopesToTry(scope SystemdScope) []bool {
 	}
 }
 
+// scopeName returns a readable scope label for diagnostics
+func scopeName(isUser bool) string {
+	if isUser {
+		return "user"
+	}
+	return "system"
+}
+
 // buildCommandArgs adds --user flag for user scope commands
 func (s *Service) buildCommandArgs(isUser bool, args []string) []string {
 	cmdArgs := make([]string, 0, len(args)+1)
@@ -356,25 +534,32 @@ func (s *Service) buildJournalArgs(isUser bool, config CommandConfig) []string {
 		cmdArgs = append(cmdArgs, "--user")
 	}
 
-	cmdArgs = append(cmdArgs, "-u", config.ServiceName)
+	// Attached to the option, a name like "-.mount" can't be read as options
+	cmdArgs = append(cmdArgs, "--unit="+config.ServiceName)
 
-	// Use invocation ID for precise log scoping (prevents race conditions)
-	if config.InvocationID != "" {
-		cmdArgs = append(cmdArgs, "_SYSTEMD_INVOCATION_ID="+config.InvocationID)
-	} else if config.SinceTime != "" {
+	if config.InvocationID == "" && config.SinceTime != "" {
 		cmdArgs = append(cmdArgs, "--since", config.SinceTime)
 	}
 
+	if config.Lines > 0 {
+		cmdArgs = append(cmdArgs, "-n", strconv.Itoa(config.Lines))
+	}
+
 	cmdArgs = append(cmdArgs, "--no-pager")
 
 	if config.OutputFormat != "" {
 		cmdArgs = append(cmdArgs, "--output="+config.OutputFormat)
 	}
 
+	// Use invocation ID for precise log scoping (prevents race conditions)
+	if config.InvocationID != "" {
+		cmdArgs = append(cmdArgs, "--", "_SYSTEMD_INVOCATION_ID="+config.InvocationID)
+	}
+
 	return cmdArgs
 }
 
-func (s *Service) getPropertyHandlers(info *ExitCodeInfo) map[string]func(string) {
+func (s *Service) getPropertyHandlers(info *ExitCodeInfo, timing *execTiming) map[string]func(string) {
 	return map[string]func(string){
 		"ExecMainStatus": func(value string) {
 			if code, err := strconv.Atoi(value); err == nil {
@@ -385,12 +570,33 @@ func (s *Service) getPropertyHandlers(info *ExitCodeInfo) map[string]func(string
 			}
 		},
 		"ExecMainCode": func(value string) {
-			if value == "2" || strings.Contains(value, "killed") {
+			// CLD_KILLED and CLD_DUMPED: ExecMainStatus then holds the signal number
+			switch {
+			case value == "2" || strings.Contains(value, "killed"):
 				info.ExitSignal = "killed"
+			case value == "3" || strings.Contains(value, "dumped"):
+				info.ExitSignal = "dumped"
 			}
 		},
 		"Result": func(value string) {
 			info.ServiceSuccess = (value == "success")
+			info.Result = value
+		},
+		"ExecMainPID": func(value string) {
+			info.MainPID, _ = strconv.Atoi(value)
+		},
+		"ExecMainStartTimestampMonotonic": func(value string) {
+			timing.start, _ = strconv.ParseUint(value, 10, 64)
+		},
+		"ExecMainExitTimestampMonotonic": func(value string) {
+			timing.exit, _ = strconv.ParseUint(value, 10, 64)
+		},
+		// Units without a main process, such as mounts and ExecStart-less services, still change state
+		"InactiveExitTimestampMonotonic": func(value string) {
+			timing.inactiveExit, _ = strconv.ParseUint(value, 10, 64)
+		},
+		"InactiveEnterTimestampMonotonic": func(value string) {
+			timing.inactiveEnter, _ = strconv.ParseUint(value, 10, 64)
 		},
 	}
 }
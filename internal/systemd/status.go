@@ -0,0 +1,212 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/validation"
+)
+
+// UnitStatus is a unit's current state as systemctl show reports it
+type UnitStatus struct {
+	Name        string
+	LoadState   string // "not-found" when neither manager knows the unit
+	ActiveState string
+	SubState    string
+	Result      string    // Result of the last run, e.g. success or exit-code
+	Since       time.Time // When ActiveState last changed; zero when unknown
+	LastExit    time.Time // When the main process last exited; zero if it never ran
+	User        bool      // Loaded by the user manager rather than the system one
+}
+
+// statusProperties are the properties UnitStatuses asks for, Id first so each unit's block can be told apart
+var statusProperties = []string{"Id", "LoadState", "ActiveState", "SubState", "Result", "StateChangeTimestamp", "ExecMainExitTimestamp"}
+
+// timestampLayout is how systemctl show prints timestamps, in the local time zone
+const timestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// UnitStatuses queries the state of several units at once, with one systemctl call per scope
+// The user manager is asked first; units it doesn't know are then looked up in the system one
+// Statuses are returned in the order of names
+// SECURITY: Validates every unit name and filters secrets from errors
+func (s *Service) UnitStatuses(ctx context.Context, names []string) ([]UnitStatus, error) {
+	for _, name := range names {
+		if err := validation.ValidateServiceName(name); err != nil {
+			return nil, validation.FilterSecretsFromError(err)
+		}
+	}
+
+	statuses := make([]UnitStatus, len(names))
+	pending := make([]int, 0, len(names))
+	for i, name := range names {
+		statuses[i] = UnitStatus{Name: name, LoadState: "not-found"}
+		pending = append(pending, i)
+	}
+
+	var lastErr error
+	queried := false
+	for _, isUser := range s.getScopesToTry(ScopeBoth) {
+		if len(pending) == 0 {
+			break
+		}
+		args := []string{"show", "--property=" + strings.Join(statusProperties, ","), "--no-pager", "--"}
+		for _, i := range pending {
+			args = append(args, names[i])
+		}
+		scope := ScopeSystem
+		if isUser {
+			scope = ScopeUser
+		}
+		result := s.ExecSystemctl(ctx, scope, args...)
+		if result.Error != nil {
+			lastErr = result.Error
+			continue
+		}
+		queried = true
+
+		// systemctl prints one block per unit, in the order given, separated by a blank line
+		blocks := parseShowBlocks(string(result.Output))
+		var missing []int
+		for n, i := range pending {
+			if n >= len(blocks) || blocks[n]["LoadState"] == "" || blocks[n]["LoadState"] == "not-found" {
+				missing = append(missing, i)
+				continue
+			}
+			statuses[i] = unitStatus(names[i], blocks[n], isUser)
+		}
+		pending = missing
+	}
+
+	if !queried && lastErr != nil {
+		return nil, validation.FilterSecretsFromError(fmt.Errorf("querying unit states: %w", lastErr))
+	}
+	return statuses, nil
+}
+
+// unitScope finds the manager a unit is loaded in; found is false when neither knows it
+// GetSystemctlProperties would take the first scope that answers, and either one answers for any name
+func (s *Service) unitScope(ctx context.Context, serviceName string) (scope SystemdScope, found bool, err error) {
+	statuses, err := s.UnitStatuses(ctx, []string{serviceName})
+	if err != nil {
+		return ScopeSystem, false, err
+	}
+	switch {
+	case statuses[0].LoadState == "not-found":
+		return ScopeSystem, false, nil
+	case statuses[0].User:
+		return ScopeUser, true, nil
+	}
+	return ScopeSystem, true, nil
+}
+
+// parseShowBlocks splits systemctl show output for several units into their properties
+func parseShowBlocks(output string) []map[string]string {
+	var blocks []map[string]string
+	for _, block := range strings.Split(strings.TrimSpace(output), "\n\n") {
+		values := map[string]string{}
+		for _, line := range strings.Split(block, "\n") {
+			key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if _, seen := values[key]; ok && !seen {
+				values[key] = value
+			}
+		}
+		blocks = append(blocks, values)
+	}
+	return blocks
+}
+
+// unitStatus builds a UnitStatus from one unit's properties
+func unitStatus(name string, values map[string]string, isUser bool) UnitStatus {
+	return UnitStatus{
+		Name:        name,
+		LoadState:   values["LoadState"],
+		ActiveState: values["ActiveState"],
+		SubState:    values["SubState"],
+		Result:      values["Result"],
+		Since:       parseTimestamp(values["StateChangeTimestamp"]),
+		LastExit:    parseTimestamp(values["ExecMainExitTimestamp"]),
+		User:        isUser,
+	}
+}
+
+// parseTimestamp reads a systemctl show timestamp; empty, "n/a" and unreadable values give the zero time
+func parseTimestamp(value string) time.Time {
+	t, err := time.ParseInLocation(timestampLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// maxDependencies bounds the dependencies FailedDependencies looks at; After= lists grow long on busy hosts
+const maxDependencies = 64
+
+// dependencyProperties name the units FailedDependencies checks
+var dependencyProperties = []string{"Requires", "Requisite", "BindsTo", "Wants", "After"}
+
+// requiredDependency reports whether a unit can't start without the dependencies a property lists
+func requiredDependency(property string) bool {
+	return property == "Requires" || property == "Requisite" || property == "BindsTo"
+}
+
+// FailedDependencies returns the dependencies of a unit that are down, the likely cause when it fails:
+// units it can't run without (Requires=, Requisite=, BindsTo=) that aren't active,
+// and units it merely wants or is ordered after (Wants=, After=) that failed
+// SECURITY: Validates the unit name; dependency names systemd reports are validated before being queried
+func (s *Service) FailedDependencies(ctx context.Context, serviceName string) ([]UnitStatus, error) {
+	scope, found, err := s.unitScope(ctx, serviceName)
+	if err != nil || !found {
+		return nil, err
+	}
+	values, err := s.GetSystemctlProperties(ctx, serviceName, dependencyProperties, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	required := map[string]bool{}
+	var names []string
+	for _, property := range dependencyProperties {
+		for _, name := range strings.Fields(values[property]) {
+			if validation.ValidateServiceName(name) != nil || slices.Contains(names, name) || len(names) == maxDependencies {
+				continue
+			}
+			names = append(names, name)
+			required[name] = requiredDependency(property)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var down []UnitStatus
+	for _, st := range s.dependencyStatuses(ctx, serviceName, names) {
+		switch {
+		case st.ActiveState == "failed":
+			down = append(down, st)
+		case required[st.Name] && !slices.Contains([]string{"active", "activating", "reloading"}, st.ActiveState):
+			down = append(down, st)
+		}
+	}
+	return down, nil
+}
+
+// dependencyStatuses queries the states of dependencies, leaving out the ones systemctl refuses
+// One refused name fails the whole call, so a failed batch is split in halves until the culprits are found,
+// which takes a few calls instead of one per dependency
+func (s *Service) dependencyStatuses(ctx context.Context, serviceName string, names []string) []UnitStatus {
+	statuses, err := s.UnitStatuses(ctx, names)
+	if err == nil {
+		return statuses
+	}
+	if len(names) == 1 || ctx.Err() != nil {
+		slog.Debug("Querying dependencies failed", logging.KeyService, serviceName, "dependencies", names, logging.Err(err))
+		return nil
+	}
+	half := len(names) / 2
+	return append(s.dependencyStatuses(ctx, serviceName, names[:half]), s.dependencyStatuses(ctx, serviceName, names[half:])...)
+}
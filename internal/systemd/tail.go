@@ -0,0 +1,87 @@
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Tail is the end of a command's output, as kept by ExecuteTail
+type Tail struct {
+	Lines   []string
+	Dropped int // Earlier lines that didn't fit
+}
+
+// readTail reads r line by line, keeping the last maxLines lines and at most maxBytes of them, each cut to maxLineBytes
+// Memory stays bounded however much a unit logged, unlike reading the whole output and splitting it
+func readTail(r io.Reader, maxLines, maxLineBytes, maxBytes int) (Tail, error) {
+	var (
+		lines []string // Kept lines start at head; dropped ones are compacted away now and then
+		head  int
+		size  int // Bytes of the kept lines
+		seen  int
+	)
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br, maxLineBytes)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Tail{}, err
+		}
+		if line != nil || err == nil {
+			seen++
+			lines = append(lines, string(line))
+			size += len(line)
+			for len(lines)-head > maxLines || (size > maxBytes && len(lines)-head > 1) {
+				size -= len(lines[head])
+				lines[head] = ""
+				head++
+			}
+			if head > len(lines)/2 && head > 256 {
+				lines, head = append(lines[:0], lines[head:]...), 0
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	kept := lines[head:]
+	return Tail{Lines: kept, Dropped: seen - len(kept)}, nil
+}
+
+// headWriter keeps the first max bytes written to it and discards the rest, for a command's stderr
+type headWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if room := w.max - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(len(p), room)]...)
+	}
+	return len(p), nil
+}
+
+// readLine returns the next line without its newline, keeping at most maxBytes of it
+// At the end of input it returns the unterminated last line, or nil, with io.EOF
+func readLine(br *bufio.Reader, maxBytes int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if room := maxBytes - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == nil:
+			return bytes.TrimSuffix(line, []byte("\n")), nil
+		default:
+			if len(line) == 0 {
+				return nil, err
+			}
+			return line, err
+		}
+	}
+}
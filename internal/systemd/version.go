@@ -0,0 +1,133 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/validation"
+)
+
+// Version source kinds accepted in NOTIFIER_VERSION_SOURCES
+const (
+	VersionSourceFile      = "file"
+	VersionSourceCommand   = "command"
+	VersionSourceExecStart = "execstart"
+)
+
+// GetServiceVersion resolves the monitored application's version from its configured source
+// Returns an empty string without error when no source is configured for the service
+func (s *Service) GetServiceVersion(ctx context.Context, serviceName string) (string, error) {
+	if err := validation.ValidateServiceName(serviceName); err != nil {
+		return "", validation.FilterSecretsFromError(err)
+	}
+
+	source, ok := s.config.VersionSources[serviceName]
+	if !ok {
+		return "", nil
+	}
+
+	kind, arg, _ := strings.Cut(source, ":")
+
+	var (
+		raw string
+		err error
+	)
+	switch kind {
+	case VersionSourceFile:
+		raw, err = readVersionFile(arg)
+	case VersionSourceCommand:
+		raw, err = s.runVersionCommand(ctx, strings.Fields(arg))
+	case VersionSourceExecStart:
+		raw, err = s.execStartVersion(ctx, serviceName)
+	default:
+		err = fmt.Errorf("unknown version source '%s'", kind)
+	}
+	if err != nil {
+		return "", validation.FilterSecretsFromError(fmt.Errorf("detecting version of '%s': %w", serviceName, err))
+	}
+
+	return validation.FilterSecrets(firstLine(raw, constants.MaxVersionLength)), nil
+}
+
+// execStartVersion runs the service's ExecStart binary with --version
+func (s *Service) execStartVersion(ctx context.Context, serviceName string) (string, error) {
+	metadata, err := s.unitMetadata(ctx, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	binary := ParseExecStartPath(metadata.ExecStart)
+	if binary == "" {
+		return "", fmt.Errorf("no ExecStart binary found")
+	}
+	return s.runVersionCommand(ctx, []string{binary, "--version"})
+}
+
+// runVersionCommand executes a version command without a shell under a short timeout
+// SECURITY: Requires an absolute binary path so PATH manipulation cannot redirect execution
+func (s *Service) runVersionCommand(ctx context.Context, argv []string) (string, error) {
+	if len(argv) == 0 {
+		return "", fmt.Errorf("empty version command")
+	}
+	if !filepath.IsAbs(argv[0]) {
+		return "", fmt.Errorf("version command must use an absolute path: %s", argv[0])
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, constants.VersionCommandTimeout)
+	defer cancel()
+
+	output, err := s.executeWithRateLimit(cmdCtx, argv[0], argv[1:]...)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// readVersionFile reads a version string from a file such as VERSION
+func readVersionFile(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("version file must use an absolute path: %s", path)
+	}
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ParseExecStartPath extracts the binary path from a systemctl ExecStart property value
+// Format: "{ path=/usr/bin/foo ; argv[]=/usr/bin/foo --bar ; ignore_errors=no ; ... }"
+func ParseExecStartPath(execStart string) string {
+	for _, field := range strings.Split(execStart, ";") {
+		field = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(field), "{"))
+		if path, ok := strings.CutPrefix(field, "path="); ok {
+			return strings.TrimSpace(path)
+		}
+	}
+
+	// Plain command line without systemctl's structured formatting
+	if parts := strings.Fields(execStart); len(parts) > 0 && filepath.IsAbs(parts[0]) {
+		return parts[0]
+	}
+	return ""
+}
+
+// firstLine returns the first non-empty line, capped at maxLen bytes
+func firstLine(s string, maxLen int) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > maxLen {
+			line = strings.ToValidUTF8(line[:maxLen], "")
+		}
+		return line
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"telegram-notifier/internal/httpclient"
+)
+
+// Update is an event delivered to the bot by getUpdates
+type Update struct {
+	UpdateID      int64            `json:"update_id"`
+	Message       *IncomingMessage `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery   `json:"callback_query,omitempty"`
+	Reaction      *Reaction        `json:"message_reaction,omitempty"`
+}
+
+// IncomingMessage is a message the bot received, or the message a button belongs to
+type IncomingMessage struct {
+	MessageID int64            `json:"message_id"`
+	From      *User            `json:"from,omitempty"`
+	Chat      Chat             `json:"chat"`
+	Text      string           `json:"text"`
+	ReplyTo   *IncomingMessage `json:"reply_to_message,omitempty"`
+}
+
+// User is the sender of a message or button press
+type User struct {
+	ID        int64  `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+}
+
+// Name returns "@username", or the first name for users without one
+func (u User) Name() string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	if u.FirstName != "" {
+		return u.FirstName
+	}
+	return fmt.Sprintf("user %d", u.ID)
+}
+
+// Chat identifies where a message was sent; getChat fills in the rest
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type,omitempty"` // private, group, supergroup or channel
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	IsForum  bool   `json:"is_forum,omitempty"` // Supergroup with topics enabled
+}
+
+// CallbackQuery is a press of an inline keyboard button
+type CallbackQuery struct {
+	ID      string           `json:"id"`
+	From    User             `json:"from"`
+	Message *IncomingMessage `json:"message,omitempty"`
+	Data    string           `json:"data"`
+}
+
+// Reaction is a change to the reactions a user put on a message
+// Bots only receive them in chats where they are an administrator
+type Reaction struct {
+	Chat      Chat           `json:"chat"`
+	MessageID int64          `json:"message_id"`
+	User      *User          `json:"user,omitempty"` // Missing for anonymous administrators and channels
+	Old       []ReactionType `json:"old_reaction"`
+	New       []ReactionType `json:"new_reaction"`
+}
+
+// ReactionType is one reaction; custom emoji and paid reactions have no Emoji
+type ReactionType struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji,omitempty"`
+}
+
+// Added reports whether the change put emoji on the message, rather than keeping or removing it
+func (r Reaction) Added(emoji string) bool {
+	has := func(reactions []ReactionType) bool {
+		for _, reaction := range reactions {
+			if reaction.Type == "emoji" && reaction.Emoji == emoji {
+				return true
+			}
+		}
+		return false
+	}
+	return has(r.New) && !has(r.Old)
+}
+
+// allowedUpdates are the update types the bot asks for, by polling or through a webhook
+var allowedUpdates = []string{"message", "callback_query", "message_reaction"}
+
+// SecretTokenHeader carries the secret given to SetWebhook in every webhook request
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// GetUpdates waits up to wait for events after offset, the last update ID handled plus one
+func (c *Client) GetUpdates(ctx context.Context, offset int64, wait time.Duration) ([]Update, error) {
+	payload := map[string]any{
+		"offset":          offset,
+		"timeout":         int(wait.Seconds()),
+		"allowed_updates": allowedUpdates,
+	}
+	var updates []Update
+	if err := c.call(ctx, c.pollClient, "getUpdates", payload, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SetWebhook has Telegram post updates to url instead of holding them for GetUpdates
+// Each request carries secret in SecretTokenHeader, so the receiver can tell Telegram from anyone else
+func (c *Client) SetWebhook(ctx context.Context, url, secret string) error {
+	payload := map[string]any{"url": url, "secret_token": secret, "allowed_updates": allowedUpdates}
+	return c.call(ctx, c.httpClient, "setWebhook", payload, nil)
+}
+
+// WebhookInfo is the webhook registration and Telegram's latest attempt to deliver through it
+type WebhookInfo struct {
+	URL                string `json:"url"` // Empty when updates are held for GetUpdates
+	PendingUpdateCount int    `json:"pending_update_count"`
+	LastErrorDate      int64  `json:"last_error_date,omitempty"` // Unix time
+	LastErrorMessage   string `json:"last_error_message,omitempty"`
+}
+
+// GetWebhookInfo returns the current webhook registration, for diagnostics
+func (c *Client) GetWebhookInfo(ctx context.Context) (WebhookInfo, error) {
+	var info WebhookInfo
+	err := c.call(ctx, c.httpClient, "getWebhookInfo", map[string]any{}, &info)
+	return info, err
+}
+
+// DeleteWebhook removes a registered webhook, which GetUpdates can't be used alongside
+// Updates Telegram is holding are kept for the next GetUpdates
+func (c *Client) DeleteWebhook(ctx context.Context) error {
+	return c.call(ctx, c.httpClient, "deleteWebhook", map[string]any{}, nil)
+}
+
+// AnswerCallback acknowledges a button press, showing text to the user who pressed it
+// Telegram keeps the button's loading indicator until the press is answered
+func (c *Client) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	payload := map[string]any{"callback_query_id": callbackID, "text": text}
+	return c.call(ctx, c.httpClient, "answerCallbackQuery", payload, nil)
+}
+
+// Reply sends a Markdown message to a chat, as a reply to replyTo when it isn't zero, with a row of buttons per argument
+// Used for command responses, which may come from chats other than the notification chat
+func (c *Client) Reply(ctx context.Context, chatID, replyTo int64, text string, buttons ...[]Button) error {
+	if err := c.rateLimiter.Acquire(ctx); err != nil {
+		return fmt.Errorf("rate limit error: %w", err)
+	}
+	payload := map[string]any{"chat_id": chatID}
+	c.setText(payload, text)
+	if replyTo != 0 {
+		payload["reply_parameters"] = map[string]any{"message_id": replyTo, "allow_sending_without_reply": true}
+	}
+	if len(buttons) > 0 {
+		payload["reply_markup"] = InlineKeyboard{Rows: buttons}
+	}
+	return c.call(ctx, c.httpClient, "sendMessage", payload, nil)
+}
+
+// EditMessage replaces the text of a message, removing its buttons
+// An empty chatID means the notification chat
+func (c *Client) EditMessage(ctx context.Context, chatID string, messageID int64, text string) error {
+	if err := c.rateLimiter.Acquire(ctx); err != nil {
+		return fmt.Errorf("rate limit error: %w", err)
+	}
+	if chatID == "" {
+		chatID = c.config.ChatID
+	}
+	payload := map[string]any{"chat_id": chatID, "message_id": messageID}
+	c.setText(payload, c.sign(chatID, text))
+	return c.call(ctx, c.httpClient, "editMessageText", payload, nil)
+}
+
+// call posts a Bot API method and decodes its result into result, when not nil
+func (c *Client) call(ctx context.Context, httpClient HTTPClient, method string, payload, result any) error {
+	url := fmt.Sprintf("%s/bot%s/%s", c.apiBaseURL, c.config.BotToken, method)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal error: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// SECURITY: url.Error embeds the request URL, which contains the bot token
+		return fmt.Errorf("http error: %s", strings.ReplaceAll(err.Error(), c.config.BotToken, "[REDACTED]"))
+	}
+	defer httpclient.DrainAndClose(resp.Body)
+
+	var response struct {
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&response)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && response.Description != "" {
+			return &HTTPError{StatusCode: resp.StatusCode, Message: response.Description}
+		}
+		return &HTTPError{StatusCode: resp.StatusCode, Message: "unknown error"}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("decode error: %w", decodeErr)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("decode error: %w", err)
+	}
+	return nil
+}
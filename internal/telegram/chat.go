@@ -0,0 +1,76 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChatTypeChannel is the Chat.Type of broadcast channels, where only admins allowed to post may send
+const ChatTypeChannel = "channel"
+
+// ChatMember is a user's membership in a chat, as far as posting is concerned
+type ChatMember struct {
+	Status          string `json:"status"` // creator, administrator, member, restricted, left or kicked
+	CanPostMessages bool   `json:"can_post_messages"`
+	CanManageTopics bool   `json:"can_manage_topics"`
+}
+
+// CanPost reports whether the member may post in a chat of the given type
+// In channels that takes the creator or an admin with the "Post messages" right; elsewhere any member may send
+func (m ChatMember) CanPost(chatType string) bool {
+	switch m.Status {
+	case "creator":
+		return true
+	case "administrator":
+		return chatType != ChatTypeChannel || m.CanPostMessages
+	case "member", "restricted":
+		return chatType != ChatTypeChannel
+	}
+	return false
+}
+
+// GetChat looks up a chat by numeric ID or @username
+// Used for diagnostics, e.g. to find the numeric ID of a channel known by its username
+func (c *Client) GetChat(ctx context.Context, chatID string) (Chat, error) {
+	var chat Chat
+	err := c.call(ctx, c.httpClient, "getChat", map[string]any{"chat_id": chatID}, &chat)
+	return chat, err
+}
+
+// CreateForumTopic creates a topic in a forum supergroup and returns its message thread ID
+// The bot needs the "Manage topics" admin right
+func (c *Client) CreateForumTopic(ctx context.Context, chatID, name string) (int64, error) {
+	var topic struct {
+		MessageThreadID int64 `json:"message_thread_id"`
+	}
+	if err := c.call(ctx, c.httpClient, "createForumTopic", map[string]any{"chat_id": chatID, "name": name}, &topic); err != nil {
+		return 0, err
+	}
+	if topic.MessageThreadID == 0 {
+		return 0, fmt.Errorf("createForumTopic returned no thread ID")
+	}
+	return topic.MessageThreadID, nil
+}
+
+// GetBotMembership returns the bot's own membership in a chat
+func (c *Client) GetBotMembership(ctx context.Context, chatID string) (ChatMember, error) {
+	botID, err := c.botID()
+	if err != nil {
+		return ChatMember{}, err
+	}
+	var member ChatMember
+	err = c.call(ctx, c.httpClient, "getChatMember", map[string]any{"chat_id": chatID, "user_id": botID}, &member)
+	return member, err
+}
+
+// botID is the numeric prefix of the bot token, which is the bot's user ID
+func (c *Client) botID() (int64, error) {
+	prefix, _, _ := strings.Cut(c.config.BotToken, ":")
+	id, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bot token has no numeric bot ID")
+	}
+	return id, nil
+}
@@ -4,22 +4,96 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"telegram-notifier/internal/config"
 	"telegram-notifier/internal/constants"
+	"telegram-notifier/internal/httpclient"
+	"telegram-notifier/internal/logging"
+	"telegram-notifier/internal/markdown"
 	"telegram-notifier/internal/ratelimit"
+	"telegram-notifier/internal/tracing"
 	"telegram-notifier/internal/validation"
 )
 
+// BackendName identifies Telegram in delivery reports
+const BackendName = "telegram"
+
 // Message represents a Telegram API message request
 type Message struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"` // "Markdown" for formatted messages
+	ChatID      string            `json:"chat_id"`
+	Text        string            `json:"text"`
+	ParseMode   string            `json:"parse_mode,omitempty"` // "Markdown" for formatted messages
+	Entities    []markdown.Entity `json:"entities,omitempty"`   // Formatting of Text when there's no parse mode
+	ReplyMarkup *InlineKeyboard   `json:"reply_markup,omitempty"`
+	Silent      bool              `json:"disable_notification,omitempty"` // Delivered without a sound
+	ThreadID    int64             `json:"message_thread_id,omitempty"`    // Forum topic the message is posted in
+
+	topic string // Forum topic to post in, resolved to ThreadID when forum topics are enabled
+}
+
+// InlineKeyboard is a grid of buttons shown under a message
+type InlineKeyboard struct {
+	Rows [][]Button `json:"inline_keyboard"`
+}
+
+// Button is an inline keyboard button; pressing it sends CallbackData to the bot
+type Button struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// MaxCallbackData is the most bytes Telegram accepts as a button's callback data
+const MaxCallbackData = 64
+
+// SendOption adjusts an outgoing message
+type SendOption func(*Message)
+
+// WithButtons attaches an inline keyboard with one row per argument
+// Rows add to those of earlier WithButtons options
+func WithButtons(rows ...[]Button) SendOption {
+	return func(m *Message) {
+		if m.ReplyMarkup == nil {
+			m.ReplyMarkup = &InlineKeyboard{}
+		}
+		m.ReplyMarkup.Rows = append(m.ReplyMarkup.Rows, rows...)
+	}
+}
+
+// WithChat sends the message to chatID instead of the configured chat; empty keeps the configured one
+func WithChat(chatID string) SendOption {
+	return func(m *Message) {
+		if chatID != "" {
+			m.ChatID = chatID
+		}
+	}
+}
+
+// WithSilent delivers the message without a notification sound when silent is true
+func WithSilent(silent bool) SendOption {
+	return func(m *Message) {
+		m.Silent = m.Silent || silent
+	}
+}
+
+// WithTopic posts the message in the forum topic of that name, created on first use
+// Ignored unless the client has a topic store; empty posts in the general topic
+func WithTopic(name string) SendOption {
+	return func(m *Message) {
+		m.topic = name
+	}
+}
+
+// TopicStore remembers the forum topics created in each chat
+type TopicStore interface {
+	Resolve(chat, name string, create func() (int64, error)) (int64, error)
+	Forget(chat, name string, threadID int64) error
 }
 
 // HTTPClient abstracts HTTP operations for testing and customization
@@ -31,92 +105,229 @@ type HTTPClient interface {
 type Client struct {
 	config      *config.Config
 	httpClient  HTTPClient
+	pollClient  HTTPClient // Allows for the long wait of getUpdates
 	apiBaseURL  string
-	rateLimiter *ratelimit.TokenBucket
+	rateLimiter *ratelimit.Queue
+	topics      TopicStore // Forum topics per service; nil posts everything in the general topic
 }
 
 // NewClient creates a new Telegram API client with rate limiting
 func NewClient(cfg *config.Config, httpClient HTTPClient) *Client {
+	pollClient := httpClient
 	if httpClient == nil {
-		httpClient = &http.Client{Timeout: cfg.HTTPTimeout}
+		httpClient = httpclient.NewPinned(cfg)
+		pollClient = httpclient.NewPinnedTimeout(cfg, cfg.HTTPTimeout+constants.BotPollWait)
 	}
 
 	return &Client{
 		config:     cfg,
 		httpClient: httpClient,
-		apiBaseURL: "https://api.telegram.org",
+		pollClient: pollClient,
+		apiBaseURL: cfg.TelegramAPIURL,
 		// SECURITY: Rate limiter prevents API abuse and respects Telegram's limits
-		rateLimiter: ratelimit.NewTokenBucket(constants.RateLimitTokens, constants.RateLimitRefillRate),
+		// The bucket lives in the state dir so concurrent hooks and the daemon share it;
+		// bursts queue for tokens instead of failing once it's drained
+		rateLimiter: ratelimit.NewQueue(
+			ratelimit.NewSharedTokenBucket(cfg.GetRateLimitFile(), constants.RateLimitTokens, constants.RateLimitRefillRate),
+			cfg.RateLimitQueueSize,
+			constants.RateLimitQueueMaxWait,
+		),
 	}
 }
 
+// UseTopics posts messages sent WithTopic in per-name forum topics, remembered in store
+func (c *Client) UseTopics(store TopicStore) {
+	c.topics = store
+}
+
+// Delivery describes the outcome of a successful send
+type Delivery struct {
+	MessageID int64  // Telegram message ID (0 if the backend doesn't report one)
+	Attempts  int    // HTTP attempts made, including the successful one
+	Backend   string // Backend that delivered the message
+
+	RateLimitWait time.Duration // Time spent queued behind the rate limiter
+	AttemptLog    []Attempt     // Per-request outcomes, in order
+}
+
+// Attempt records a single delivery request
+type Attempt struct {
+	Backend    string
+	StatusCode int           // HTTP status; 0 when no response was received or the backend doesn't report one
+	Latency    time.Duration // Request duration
+	Backoff    time.Duration // Wait applied before this request
+}
+
 // SendNotification sends a message to Telegram with retry logic
-// SECURITY: Validates message size, applies rate limiting, and uses exponential backoff
 func (c *Client) SendNotification(ctx context.Context, message string) error {
+	_, err := c.Send(ctx, message)
+	return err
+}
+
+// Send sends a message to Telegram with retry logic and reports delivery details
+// SECURITY: Validates message size, applies rate limiting, and uses exponential backoff
+func (c *Client) Send(ctx context.Context, message string, opts ...SendOption) (Delivery, error) {
+	delivery := Delivery{Backend: BackendName}
+
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("context cancelled: %w", ctx.Err())
+		return delivery, fmt.Errorf("context cancelled: %w", ctx.Err())
 	default:
 	}
 
 	// SECURITY: Validate message doesn't exceed Telegram's limits
 	if err := validation.ValidateMessageSize(message); err != nil {
-		return fmt.Errorf("message validation failed: %w", err)
+		return delivery, fmt.Errorf("message validation failed: %w", err)
 	}
 
 	// SECURITY: Apply rate limiting to prevent API abuse
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limit error: %w", err)
+	waitStart := time.Now()
+	err := c.rateLimiter.Acquire(ctx)
+	delivery.RateLimitWait = time.Since(waitStart)
+	if err != nil {
+		return delivery, fmt.Errorf("rate limit error: %w", err)
 	}
 
+	ctx, span := tracing.Start(ctx, "telegram.send")
+	defer span.End()
+
 	// Retry with exponential backoff for transient failures
 	var lastErr error
 	for attempt := 0; attempt <= constants.MaxHTTPRetries; attempt++ {
+		var delay time.Duration
 		if attempt > 0 {
-			delay := c.calculateBackoff(attempt)
+			delay = c.calculateBackoff(attempt)
+			slog.Debug("Retrying Telegram request", logging.KeyAttempt, attempt+1, "backoff", delay)
 			select {
 			case <-time.After(delay):
 			case <-ctx.Done():
-				return fmt.Errorf("retry cancelled: %w", ctx.Err())
+				return delivery, fmt.Errorf("retry cancelled: %w", ctx.Err())
 			}
 		}
 
-		err := c.sendRequest(ctx, message)
+		delivery.Attempts++
+		span.SetAttr("attempts", delivery.Attempts)
+		attemptStart := time.Now()
+		attemptCtx, attemptSpan := tracing.Start(ctx, "telegram.sendMessage")
+		attemptSpan.SetAttr(logging.KeyAttempt, delivery.Attempts)
+		messageID, err := c.sendRequest(attemptCtx, message, opts)
+		endAttemptSpan(attemptSpan, err)
+		delivery.AttemptLog = append(delivery.AttemptLog, Attempt{
+			Backend:    BackendName,
+			StatusCode: statusCode(err),
+			Latency:    time.Since(attemptStart),
+			Backoff:    delay,
+		})
 		if err == nil {
-			return nil
+			slog.Debug("Telegram request succeeded", logging.KeyAttempt, delivery.Attempts,
+				logging.KeyDuration, time.Since(attemptStart).Round(time.Millisecond), "message_id", messageID)
+			delivery.MessageID = messageID
+			return delivery, nil
 		}
+		slog.Debug("Telegram request failed", logging.KeyAttempt, delivery.Attempts,
+			logging.KeyDuration, time.Since(attemptStart).Round(time.Millisecond), logging.Err(err))
 
 		lastErr = err
 
 		// Don't retry on client errors (4xx) - these won't succeed on retry
 		if isClientError(err) {
-			return err
+			span.RecordError(validation.SanitizeErrorMessage(err))
+			return delivery, err
 		}
 	}
 
-	return fmt.Errorf("failed after %d retries: %w", constants.MaxHTTPRetries, lastErr)
+	span.RecordError(validation.SanitizeErrorMessage(lastErr))
+	return delivery, fmt.Errorf("failed after %d retries: %w", constants.MaxHTTPRetries, lastErr)
 }
 
-// sendRequest performs the actual HTTP request to Telegram API
-// SECURITY: Uses context for timeout control and proper error handling
-func (c *Client) sendRequest(ctx context.Context, message string) error {
-	url := fmt.Sprintf("%s/bot%s/sendMessage", c.apiBaseURL, c.config.BotToken)
+// endAttemptSpan records the HTTP outcome of a single request on its span
+func endAttemptSpan(span *tracing.Span, err error) {
+	if status := statusCode(err); status != 0 {
+		span.SetAttr("http.status_code", status)
+	}
+	if err != nil {
+		span.RecordError(validation.SanitizeErrorMessage(err))
+	}
+	span.End()
+}
+
+// statusCode returns the HTTP status of a request outcome, or 0 if no response arrived
+func statusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
 
+// sendRequest builds the message from its options and posts it in its chat and forum topic
+// Returns the message ID assigned by Telegram on success
+func (c *Client) sendRequest(ctx context.Context, message string, opts []SendOption) (int64, error) {
 	msg := Message{
-		ChatID:    c.config.ChatID,
-		Text:      message,
-		ParseMode: "Markdown",
+		ChatID: c.config.ChatID,
+		Text:   message,
 	}
+	for _, opt := range opts {
+		opt(&msg)
+	}
+	msg.Text, msg.ParseMode, msg.Entities = c.format(c.sign(msg.ChatID, msg.Text))
+
+	msg.ThreadID = c.topicID(ctx, msg.ChatID, msg.topic)
+	messageID, err := c.postMessage(ctx, msg)
+	if msg.ThreadID != 0 && isTopicMissing(err) {
+		// Someone deleted the topic; start a new one rather than losing the notification
+		if err := c.topics.Forget(msg.ChatID, msg.topic, msg.ThreadID); err != nil {
+			slog.Warn("Forgetting deleted forum topic failed", "topic", msg.topic, logging.Err(err))
+		}
+		msg.ThreadID = c.topicID(ctx, msg.ChatID, msg.topic)
+		messageID, err = c.postMessage(ctx, msg)
+	}
+	return messageID, err
+}
+
+// topicID returns the thread of a message's forum topic, creating the topic when it's new
+// Returns 0, the general topic, when topics are off or the topic can't be created, so the message still goes out
+func (c *Client) topicID(ctx context.Context, chatID, topic string) int64 {
+	if c.topics == nil || topic == "" {
+		return 0
+	}
+	id, err := c.topics.Resolve(chatID, topic, func() (int64, error) {
+		slog.Debug("Creating forum topic", "topic", topic)
+		return c.CreateForumTopic(ctx, chatID, topic)
+	})
+	if err != nil {
+		slog.Warn("Forum topic unavailable, posting in the general topic", "topic", topic, logging.Err(err))
+		return 0
+	}
+	return id
+}
+
+// isTopicMissing reports whether Telegram refused a message because its forum topic no longer exists
+func isTopicMissing(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	return strings.Contains(httpErr.Message, "message thread not found") || strings.Contains(httpErr.Message, "TOPIC_DELETED")
+}
+
+// postMessage performs the actual HTTP request to Telegram API
+// SECURITY: Uses context for timeout control and proper error handling
+func (c *Client) postMessage(ctx context.Context, msg Message) (int64, error) {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", c.apiBaseURL, c.config.BotToken)
 
 	jsonData, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("marshal error: %w", err)
+		return 0, fmt.Errorf("marshal error: %w", err)
 	}
 
 	// Create request with context for cancellation support
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("request creation error: %w", err)
+		return 0, fmt.Errorf("request creation error: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
@@ -124,25 +335,102 @@ func (c *Client) sendRequest(ctx context.Context, message string) error {
 	if err != nil {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("request cancelled: %w", ctx.Err())
+			return 0, fmt.Errorf("request cancelled: %w", ctx.Err())
 		default:
-			return fmt.Errorf("http error: %w", err)
+			// SECURITY: url.Error embeds the request URL, which contains the bot token
+			return 0, fmt.Errorf("http error: %s", strings.ReplaceAll(err.Error(), c.config.BotToken, "[REDACTED]"))
 		}
 	}
-	defer resp.Body.Close()
+	defer httpclient.DrainAndClose(resp.Body)
 
 	// Check for API errors and extract meaningful error messages
 	if resp.StatusCode != http.StatusOK {
 		var errorResponse map[string]interface{}
 		if json.NewDecoder(resp.Body).Decode(&errorResponse) == nil {
 			if description, ok := errorResponse["description"].(string); ok {
-				return &HTTPError{StatusCode: resp.StatusCode, Message: description}
+				return 0, &HTTPError{StatusCode: resp.StatusCode, Message: description}
 			}
 		}
-		return &HTTPError{StatusCode: resp.StatusCode, Message: "unknown error"}
+		return 0, &HTTPError{StatusCode: resp.StatusCode, Message: "unknown error"}
+	}
+
+	// Message ID is informational; a response we can't parse still means success
+	var sendResponse struct {
+		Result struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&sendResponse) != nil {
+		return 0, nil
+	}
+	return sendResponse.Result.MessageID, nil
+}
+
+// sign appends the channel signature to posts to an @channel, like the author signature a person posting would get
+func (c *Client) sign(chatID, text string) string {
+	if signature := c.config.GetChannelSignature(chatID); signature != "" {
+		return text + "\n\n— " + markdown.Escape(signature)
+	}
+	return text
+}
+
+// format returns Markdown text as it's sent: with parse_mode Markdown, or with NOTIFIER_FORMATTING=entities
+// as plain text and the entities Telegram would have parsed, so it can't reject the message over a stray marker
+func (c *Client) format(text string) (string, string, []markdown.Entity) {
+	if c.config.Formatting == constants.FormattingEntities {
+		plain, entities := markdown.Entities(text)
+		return plain, "", entities
+	}
+	return text, "Markdown", nil
+}
+
+// setText adds Markdown text to a Bot API payload in the configured formatting
+func (c *Client) setText(payload map[string]any, text string) {
+	text, parseMode, entities := c.format(text)
+	payload["text"] = text
+	if parseMode != "" {
+		payload["parse_mode"] = parseMode
+	}
+	if len(entities) > 0 {
+		payload["entities"] = entities
+	}
+}
+
+// GetMe verifies the bot token with a single getMe request and returns the bot's username
+// Used for diagnostics; no message is sent and no retries are attempted
+func (c *Client) GetMe(ctx context.Context) (string, error) {
+	url := fmt.Sprintf("%s/bot%s/getMe", c.apiBaseURL, c.config.BotToken)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("request creation error: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// SECURITY: url.Error embeds the request URL, which contains the bot token
+		return "", fmt.Errorf("http error: %s", strings.ReplaceAll(err.Error(), c.config.BotToken, "[REDACTED]"))
+	}
+	defer httpclient.DrainAndClose(resp.Body)
+
+	var meResponse struct {
+		Description string `json:"description"`
+		Result      struct {
+			Username string `json:"username"`
+		} `json:"result"`
 	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&meResponse)
 
-	return nil
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && meResponse.Description != "" {
+			return "", &HTTPError{StatusCode: resp.StatusCode, Message: meResponse.Description}
+		}
+		return "", &HTTPError{StatusCode: resp.StatusCode, Message: "unknown error"}
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("decode error: %w", decodeErr)
+	}
+	return meResponse.Result.Username, nil
 }
 
 // calculateBackoff computes exponential backoff delay for retries
@@ -166,6 +454,17 @@ func (e *HTTPError) Error() string {
 	return fmt.Sprintf("telegram API error (status %d): %s", e.StatusCode, e.Message)
 }
 
+// IsPermanentError reports whether a delivery error will not succeed on retry
+// Client errors (4xx) such as bad tokens or malformed messages are permanent
+func IsPermanentError(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		// Rate limiting (429) clears up on its own and is worth retrying later
+		return isClientError(httpErr) && httpErr.StatusCode != http.StatusTooManyRequests
+	}
+	return false
+}
+
 // isClientError determines if error is a client error (4xx) that shouldn't be retried
 func isClientError(err error) bool {
 	if httpErr, ok := err.(*HTTPError); ok {
@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Span status codes, matching OTLP
+const (
+	statusUnset = 0
+	statusOK    = 1
+	statusError = 2
+)
+
+// Span records the timing of one pipeline step
+// A nil *Span is valid and does nothing, so callers never check whether tracing is enabled
+type Span struct {
+	tracer   *Tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	status   int
+	message  string
+}
+
+// Tracer buffers finished spans until they are exported
+type Tracer struct {
+	exporter *Exporter
+	mu       sync.Mutex
+	finished []*Span
+}
+
+type spanKey struct{}
+
+// global is the process tracer; nil when tracing isn't configured
+var global *Tracer
+
+// Setup enables tracing with spans exported to an OTLP/HTTP endpoint
+// An empty endpoint leaves tracing disabled
+func Setup(endpoint, serviceName string) {
+	if endpoint == "" {
+		global = nil
+		return
+	}
+	global = &Tracer{exporter: NewExporter(endpoint, serviceName)}
+}
+
+// Start begins a span, as a child of the span in ctx if there is one
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	t := global
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		spanID: newID(8),
+		name:   name,
+		start:  time.Now(),
+		attrs:  make(map[string]any),
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = newID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SetAttr attaches an attribute (string, bool, int, int64 or float64)
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.attrs[key] = value
+}
+
+// RecordError marks the span as failed
+// SECURITY: Callers must pass sanitized messages; spans leave the host
+func (s *Span) RecordError(message string) {
+	if s == nil {
+		return
+	}
+	s.status = statusError
+	s.message = message
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	if s.status == statusUnset {
+		s.status = statusOK
+	}
+
+	s.tracer.mu.Lock()
+	s.tracer.finished = append(s.tracer.finished, s)
+	s.tracer.mu.Unlock()
+}
+
+// Flush exports all finished spans
+// Export failures are returned but never affect notification delivery
+func Flush(ctx context.Context) error {
+	t := global
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	spans := t.finished
+	t.finished = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.exporter.Export(ctx, spans)
+}
+
+// newID returns a random hex identifier of n bytes
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,27 @@
+package updates
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+
+	"telegram-notifier/internal/sysinfo"
+)
+
+// needsRestarting is dnf-utils' reboot check; "-r" exits 1 when a reboot is needed
+const needsRestarting = "needs-restarting"
+
+// RebootRequired reports whether updates are waiting for a reboot, with the packages responsible when known
+// Debian-based systems leave a marker file; RPM-based ones are asked through needs-restarting
+func RebootRequired(ctx context.Context) (bool, []string) {
+	if sysinfo.RebootRequired() {
+		return true, sysinfo.RebootRequiredPackages()
+	}
+
+	if _, err := exec.LookPath(needsRestarting); err != nil {
+		return false, nil
+	}
+	err := exec.CommandContext(ctx, needsRestarting, "-r").Run()
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 1, nil
+}
@@ -0,0 +1,59 @@
+package updates
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateFileName records how far the package logs were reported, under the state directory
+const StateFileName = "updates.json"
+
+// state is the persisted end of the last reported window
+type state struct {
+	ReportedUntil time.Time `json:"reported_until"`
+}
+
+// LastReported returns the end of the previously reported window; zero when nothing was reported yet
+func LastReported(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return time.Time{}, err
+	}
+	return s.ReportedUntil, nil
+}
+
+// SaveReported records t as the end of the reported window, atomically via temp file and rename
+func SaveReported(path string, t time.Time) error {
+	data, err := json.Marshal(state{ReportedUntil: t})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,190 @@
+// Package updates summarizes package manager activity from apt and dnf logs
+package updates
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Package manager logs; unattended-upgrades and dnf-automatic write to these too
+const (
+	AptHistoryLog = "/var/log/apt/history.log"
+	DNFRPMLog     = "/var/log/dnf.rpm.log"
+)
+
+// Change actions, named after apt's history fields
+const (
+	ActionUpgrade   = "Upgrade"
+	ActionInstall   = "Install"
+	ActionRemove    = "Remove"
+	ActionDowngrade = "Downgrade"
+	ActionReinstall = "Reinstall"
+)
+
+// Change is one package affected by a transaction
+type Change struct {
+	Action  string
+	Package string
+	From    string // Previous version for upgrades and downgrades
+	To      string // New or installed version
+}
+
+// Transaction is a package manager run
+type Transaction struct {
+	Time    time.Time
+	Command string // Command line that started it, when logged
+	Error   string // Failure reported by the package manager
+	Changes []Change
+}
+
+// aptTimeLayout is the local-time format of Start-Date lines
+const aptTimeLayout = "2006-01-02  15:04:05"
+
+// aptPackagePattern matches "name:arch (versions)" entries in apt history fields
+var aptPackagePattern = regexp.MustCompile(`([^\s,]+) \(([^)]*)\)`)
+
+// aptActions maps apt history fields to change actions
+var aptActions = map[string]string{
+	"Upgrade":   ActionUpgrade,
+	"Install":   ActionInstall,
+	"Remove":    ActionRemove,
+	"Purge":     ActionRemove,
+	"Downgrade": ActionDowngrade,
+	"Reinstall": ActionReinstall,
+}
+
+// dnfActions maps dnf.rpm.log verbs to change actions; past-tense lines name the replaced package
+var dnfActions = map[string]string{
+	"Upgrade":   ActionUpgrade,
+	"Install":   ActionInstall,
+	"Erase":     ActionRemove,
+	"Downgrade": ActionDowngrade,
+	"Reinstall": ActionReinstall,
+}
+
+// Collect reads the log of whichever package manager is installed, keeping transactions after since
+// Returns the log read so the caller can name the package manager
+func Collect(since time.Time) (string, []Transaction, error) {
+	for _, source := range []struct {
+		path  string
+		parse func(io.Reader, time.Time) ([]Transaction, error)
+	}{
+		{AptHistoryLog, ParseAptHistory},
+		{DNFRPMLog, ParseDNFLog},
+	} {
+		file, err := os.Open(source.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return source.path, nil, err
+		}
+		transactions, err := source.parse(file, since)
+		file.Close()
+		return source.path, transactions, err
+	}
+	return "", nil, fmt.Errorf("no package manager log found (%s, %s)", AptHistoryLog, DNFRPMLog)
+}
+
+// ParseAptHistory parses /var/log/apt/history.log, a sequence of blank-line separated blocks:
+//
+//	Start-Date: 2024-01-15  06:12:01
+//	Commandline: /usr/bin/unattended-upgrade
+//	Upgrade: openssl:amd64 (3.0.11-1, 3.0.13-1), libssl3:amd64 (3.0.11-1, 3.0.13-1)
+//	End-Date: 2024-01-15  06:12:30
+func ParseAptHistory(r io.Reader, since time.Time) ([]Transaction, error) {
+	var transactions []Transaction
+	var current *Transaction
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024) // Upgrade lines list every package
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "Start-Date":
+			current = nil
+			started, err := time.ParseInLocation(aptTimeLayout, value, time.Local)
+			if err != nil || !started.After(since) {
+				continue
+			}
+			transactions = append(transactions, Transaction{Time: started})
+			current = &transactions[len(transactions)-1]
+		case "Commandline":
+			if current != nil {
+				current.Command = value
+			}
+		case "Error":
+			if current != nil {
+				current.Error = value
+			}
+		default:
+			action, known := aptActions[field]
+			if current == nil || !known {
+				continue
+			}
+			for _, m := range aptPackagePattern.FindAllStringSubmatch(value, -1) {
+				current.Changes = append(current.Changes, aptChange(action, m[1], m[2]))
+			}
+		}
+	}
+	return transactions, scanner.Err()
+}
+
+// aptChange builds a change from "name:arch" and its version list
+// Upgrades and downgrades list "old, new"; installs may add ", automatic"
+func aptChange(action, pkg, versions string) Change {
+	name, _, _ := strings.Cut(pkg, ":")
+	parts := strings.Split(versions, ", ")
+	change := Change{Action: action, Package: name, To: parts[0]}
+	if (action == ActionUpgrade || action == ActionDowngrade) && len(parts) >= 2 {
+		change.From, change.To = parts[0], parts[1]
+	}
+	return change
+}
+
+// ParseDNFLog parses /var/log/dnf.rpm.log lines such as
+//
+//	2024-01-15T06:12:01+0000 SUBDEBUG Upgrade: openssl-1:3.0.9-2.fc39.x86_64
+//
+// dnf logs no transaction boundaries there, so all changes after since form one transaction
+func ParseDNFLog(r io.Reader, since time.Time) ([]Transaction, error) {
+	var tx Transaction
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 4 || fields[1] != "SUBDEBUG" {
+			continue
+		}
+		logged, err := time.Parse("2006-01-02T15:04:05Z0700", fields[0])
+		if err != nil || !logged.After(since) {
+			continue
+		}
+		action, known := dnfActions[strings.TrimSuffix(fields[2], ":")]
+		if !known {
+			continue
+		}
+		if tx.Time.IsZero() {
+			tx.Time = logged
+		}
+		tx.Changes = append(tx.Changes, Change{Action: action, Package: strings.TrimSpace(fields[3])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tx.Changes) == 0 {
+		return nil, nil
+	}
+	return []Transaction{tx}, nil
+}
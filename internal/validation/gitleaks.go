@@ -0,0 +1,296 @@
+package validation
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GitleaksRuleset is a compiled gitleaks configuration, used in place of the built-in patterns
+// Only what redaction needs is read: each [[rules]] entry's regex, keywords and secretGroup,
+// and the regexes of the top-level allowlist; path-only rules and per-rule allowlists are skipped
+type GitleaksRuleset struct {
+	patterns []redactionPattern
+	allow    []*regexp.Regexp
+}
+
+// Len returns the number of rules loaded
+func (g GitleaksRuleset) Len() int {
+	return len(g.patterns)
+}
+
+// NewGitleaksRedactor returns an engine applying a gitleaks ruleset, adjusted by the redaction file's
+// extra patterns and allowlist; "disable" lines only name built-in patterns and have no effect here
+func NewGitleaksRedactor(ruleset GitleaksRuleset, rules RedactionRules) Redactor {
+	rules.Allow = append(append([]*regexp.Regexp{}, ruleset.allow...), rules.Allow...)
+	return newRedactor(ruleset.patterns, rules)
+}
+
+// gitleaksRule collects the keys of one [[rules]] table
+type gitleaksRule struct {
+	id          string
+	regex       string
+	keywords    []string
+	secretGroup int
+}
+
+// LoadGitleaksRules reads a gitleaks TOML configuration such as gitleaks' own config/gitleaks.toml
+func LoadGitleaksRules(path string) (GitleaksRuleset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GitleaksRuleset{}, err
+	}
+
+	var (
+		ruleset GitleaksRuleset
+		rules   []gitleaksRule
+		table   string
+	)
+	p := &tomlParser{src: string(data)}
+	for {
+		p.skipSpace(true)
+		if p.done() {
+			break
+		}
+
+		if p.peek() == '[' {
+			header, err := p.header()
+			if err != nil {
+				return ruleset, err
+			}
+			table = header
+			if table == "rules" {
+				rules = append(rules, gitleaksRule{})
+			}
+			continue
+		}
+
+		key, value, err := p.keyValue()
+		if err != nil {
+			return ruleset, err
+		}
+		switch {
+		case table == "rules":
+			rule := &rules[len(rules)-1]
+			switch key {
+			case "id":
+				rule.id, _ = value.(string)
+			case "regex":
+				rule.regex, _ = value.(string)
+			case "keywords":
+				rule.keywords, _ = value.([]string)
+			case "secretGroup":
+				s, _ := value.(string)
+				rule.secretGroup, _ = strconv.Atoi(s)
+			}
+		case table == "extend" && key == "useDefault" && value == "true":
+			return ruleset, fmt.Errorf("line %d: extending gitleaks' built-in rules isn't supported; use a complete ruleset", p.line())
+		case (table == "allowlist" || table == "allowlists") && key == "regexes":
+			exprs, _ := value.([]string)
+			for _, expr := range exprs {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					return ruleset, fmt.Errorf("allowlist: %w", err)
+				}
+				ruleset.allow = append(ruleset.allow, re)
+			}
+		}
+	}
+
+	for i, rule := range rules {
+		if rule.regex == "" {
+			continue // Rules matching file paths only have nothing to find in output
+		}
+		name := rule.id
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		// The whole match is group 1 when the rule has no groups, so only the secret is ever replaced
+		re, err := regexp.Compile("(" + rule.regex + ")")
+		if err != nil {
+			return ruleset, fmt.Errorf("rule %s: %w", name, err)
+		}
+		group := 1
+		switch {
+		case rule.secretGroup > 0:
+			group = rule.secretGroup + 1
+		case re.NumSubexp() > 1:
+			group = 2 // Like gitleaks, the first group is the secret when none is named
+		}
+		if group > re.NumSubexp() {
+			return ruleset, fmt.Errorf("rule %s: secretGroup %d doesn't exist", name, rule.secretGroup)
+		}
+
+		var hints []string
+		for _, keyword := range rule.keywords {
+			hints = append(hints, strings.ToLower(keyword))
+		}
+		ruleset.patterns = append(ruleset.patterns, redactionPattern{re: re, hints: hints, group: group})
+	}
+	if len(ruleset.patterns) == 0 {
+		return ruleset, fmt.Errorf("no rules with a regex found")
+	}
+	return ruleset, nil
+}
+
+// tomlParser reads the subset of TOML gitleaks configurations use: table headers, and keys whose values
+// are strings (all four quoting styles), arrays of strings, numbers or booleans
+// Scalars other than strings are returned as their literal text
+type tomlParser struct {
+	src string
+	pos int
+}
+
+func (p *tomlParser) done() bool { return p.pos >= len(p.src) }
+func (p *tomlParser) peek() byte { return p.src[p.pos] }
+
+// line returns the current line number, for errors
+func (p *tomlParser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: "+format, append([]any{p.line()}, args...)...)
+}
+
+// skipSpace skips blanks and comments, and line breaks too when newlines is set
+func (p *tomlParser) skipSpace(newlines bool) {
+	for !p.done() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+		case c == '#':
+			for !p.done() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// header reads "[table]" or "[[array.of.tables]]" and returns the table name
+func (p *tomlParser) header() (string, error) {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		end = len(p.src) - p.pos
+	}
+	line := strings.TrimSpace(p.src[p.pos : p.pos+end])
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	name := strings.Trim(line, "[]")
+	if name == "" || !strings.HasSuffix(line, "]") {
+		return "", p.errorf("invalid table header %q", line)
+	}
+	p.pos += end
+	return strings.TrimSpace(name), nil
+}
+
+// keyValue reads "key = value"
+func (p *tomlParser) keyValue() (string, any, error) {
+	eq := strings.IndexByte(p.src[p.pos:], '=')
+	nl := strings.IndexByte(p.src[p.pos:], '\n')
+	if eq < 0 || (nl >= 0 && nl < eq) {
+		return "", nil, p.errorf("expected key = value")
+	}
+	key := strings.Trim(strings.TrimSpace(p.src[p.pos:p.pos+eq]), `"'`)
+	p.pos += eq + 1
+	p.skipSpace(false)
+	value, err := p.value()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return key, value, nil
+}
+
+// value reads a string, an array of strings or a bare scalar
+func (p *tomlParser) value() (any, error) {
+	if p.done() {
+		return nil, p.errorf("missing value")
+	}
+	switch p.peek() {
+	case '"', '\'':
+		return p.str()
+	case '[':
+		p.pos++
+		var items []string
+		for {
+			p.skipSpace(true)
+			if p.done() {
+				return nil, p.errorf("unterminated array")
+			}
+			switch p.peek() {
+			case ']':
+				p.pos++
+				return items, nil
+			case ',':
+				p.pos++
+				continue
+			}
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+	default:
+		start := p.pos
+		for !p.done() && !strings.ContainsRune(",]\n#", rune(p.peek())) {
+			p.pos++
+		}
+		return strings.TrimSpace(p.src[start:p.pos]), nil
+	}
+}
+
+// str reads a basic (double-quoted) or literal (single-quoted) string, either of which may be triple-quoted
+func (p *tomlParser) str() (string, error) {
+	rest := p.src[p.pos:]
+	for _, delim := range []string{`'''`, `"""`, `'`, `"`} {
+		if !strings.HasPrefix(rest, delim) {
+			continue
+		}
+		body := rest[len(delim):]
+		multiline := len(delim) == 3
+		if multiline {
+			// A line break right after the opening delimiter isn't part of the string
+			body = strings.TrimPrefix(strings.TrimPrefix(body, "\r"), "\n")
+		}
+		end := closingDelim(body, delim)
+		if end < 0 || (!multiline && strings.Contains(body[:end], "\n")) {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos += len(rest) - len(body) + end + len(delim)
+
+		s := body[:end]
+		if delim[0] == '\'' {
+			return s, nil
+		}
+		unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(s, "\n", `\n`) + `"`)
+		if err != nil {
+			return "", p.errorf("invalid escape in string")
+		}
+		return unquoted, nil
+	}
+	return "", p.errorf("expected a string")
+}
+
+// closingDelim returns the index of the delimiter ending a string body, skipping escaped quotes in basic strings
+func closingDelim(body, delim string) int {
+	for i := 0; i < len(body); i++ {
+		if delim[0] == '"' && body[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(body[i:], delim) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,116 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"telegram-notifier/internal/constants"
+)
+
+// Measure counts a message the way a delivery channel enforces its size limit
+// Measures must be additive: the length of a string is the sum of the lengths of its runes
+type Measure func(string) int
+
+// UTF16Length counts UTF-16 code units, which is what Telegram's "characters" are:
+// emoji outside the Basic Multilingual Plane count twice, CJK characters once
+func UTF16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// ByteLength counts encoded bytes, for channels that limit the request body
+func ByteLength(s string) int {
+	return len(s)
+}
+
+// TruncateMessage ensures message fits within Telegram's limits, counted in UTF-16 code units
+// Shows most recent output (end of message) as it's typically most relevant
+func TruncateMessage(msg string, maxSize int) string {
+	return TruncateTo(msg, maxSize, UTF16Length)
+}
+
+// TruncateTo keeps the end of msg within maxSize as counted by measure, behind the truncation marker
+// Cuts only at rune boundaries, so multi-byte characters are never split
+func TruncateTo(msg string, maxSize int, measure Measure) string {
+	if measure(msg) <= maxSize {
+		return msg
+	}
+
+	truncMsg := constants.OutputTruncatedMsg
+	availableSize := maxSize - measure(truncMsg)
+
+	if availableSize <= 0 {
+		return strings.ToValidUTF8(msg[:fitPrefix(msg, maxSize, measure)], "�")
+	}
+
+	// Keep the END of the message (most recent output)
+	truncated := truncMsg + msg[fitSuffix(msg, availableSize, measure):]
+
+	// Ensure valid UTF-8 to prevent encoding issues
+	return strings.ToValidUTF8(truncated, "�")
+}
+
+// TailStart returns the byte offset where the part of msg kept by TruncateTo begins; 0 when msg fits
+func TailStart(msg string, maxSize int, measure Measure) int {
+	if measure(msg) <= maxSize {
+		return 0
+	}
+	return fitSuffix(msg, max(0, maxSize-measure(constants.OutputTruncatedMsg)), measure)
+}
+
+// TruncateHead keeps the start of msg within maxSize as counted by measure, ending it with an ellipsis
+// For channels where the header naming the unit matters more than the latest output
+func TruncateHead(msg string, maxSize int, measure Measure) string {
+	if measure(msg) <= maxSize {
+		return msg
+	}
+	const ellipsis = "…"
+	return strings.ToValidUTF8(msg[:fitPrefix(msg, max(0, maxSize-measure(ellipsis)), measure)], "�") + ellipsis
+}
+
+// fitPrefix returns the byte length of the longest run of whole runes at the start of s within maxSize
+func fitPrefix(s string, maxSize int, measure Measure) int {
+	end, size := 0, 0
+	for end < len(s) {
+		_, n := utf8.DecodeRuneInString(s[end:])
+		w := measure(s[end : end+n])
+		if size+w > maxSize {
+			break
+		}
+		size += w
+		end += n
+	}
+	return end
+}
+
+// fitSuffix returns the byte offset of the longest run of whole runes at the end of s within maxSize
+func fitSuffix(s string, maxSize int, measure Measure) int {
+	start, size := len(s), 0
+	for start > 0 {
+		_, n := utf8.DecodeLastRuneInString(s[:start])
+		w := measure(s[start-n : start])
+		if size+w > maxSize {
+			break
+		}
+		size += w
+		start -= n
+	}
+	return start
+}
+
+// ValidateMessageSize checks total message size before sending to Telegram
+// Telegram counts UTF-16 code units, so a message of emoji or CJK text may be far longer in bytes
+func ValidateMessageSize(msg string) error {
+	if size := UTF16Length(msg); size > constants.TelegramMaxMessageSize {
+		return fmt.Errorf("message length %d exceeds Telegram limit of %d characters", size, constants.TelegramMaxMessageSize)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package validation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"telegram-notifier/internal/constants"
+)
+
+// Redaction file directives
+const (
+	disableDirective      = "disable "       // Turns off a built-in pattern
+	allowDirective        = "allow "         // Regex; a secret value it matches in full is never redacted
+	allowLiteralDirective = "allow-literal " // Exact secret value that is never redacted
+)
+
+// RedactionRules adjusts secret filtering beyond the built-in patterns
+type RedactionRules struct {
+	Extra    []*regexp.Regexp // Additional patterns, applied after the built-ins
+	Disabled map[string]bool  // Built-in pattern names to skip
+	Allow    []*regexp.Regexp // Secret values matching any of these are kept
+	Lenient  bool             // Use the lenient variants of keyword patterns
+}
+
+// Redactor removes secrets from text, reporting how many it redacted
+// Implementations must be safe for concurrent use; FilterSecrets applies the one set with SetRedactor
+type Redactor interface {
+	Redact(input string) (string, int)
+}
+
+// nopRedactor passes text through unchanged, for hosts whose output is trusted
+type nopRedactor struct{}
+
+func (nopRedactor) Redact(input string) (string, int) {
+	return input, 0
+}
+
+// NopRedactor returns a Redactor that redacts nothing
+func NopRedactor() Redactor {
+	return nopRedactor{}
+}
+
+// redactor is the regex engine: a compiled pattern set with hints, allowlist and RedactionRules applied
+type redactor struct {
+	patterns []redactionPattern
+	allow    []*regexp.Regexp
+	hints    []string // Union of the patterns' hints
+	unhinted bool     // A pattern without hints can match anywhere, so every line is filtered
+}
+
+// redactionPattern is a pattern with the text one of which its matches contain
+// group selects the submatch holding the secret; 0 redacts the whole match, keeping its first characters
+// secret is the submatch allow rules are checked against when it differs from group
+type redactionPattern struct {
+	re     *regexp.Regexp
+	hints  []string
+	group  int
+	secret int
+}
+
+// secretGroup returns the submatch holding the secret value
+func (p redactionPattern) secretGroup() int {
+	if p.secret > 0 {
+		return p.secret
+	}
+	return p.group
+}
+
+// mayMatch reports whether lower, the lowercased text, contains one of the hints
+func (p redactionPattern) mayMatch(lower string) bool {
+	return p.hints == nil || containsAny(lower, p.hints)
+}
+
+// active holds the Redactor FilterSecrets applies; nil means the strict built-ins
+var active atomic.Pointer[Redactor]
+
+// builtinRedactor is applied until SetRedactor is called
+var builtinRedactor Redactor = NewRegexRedactor(RedactionRules{})
+
+// SetRedactor replaces the engine used by FilterSecrets for the whole process
+func SetRedactor(r Redactor) {
+	active.Store(&r)
+}
+
+// NewRegexRedactor returns the default engine: the built-in patterns adjusted by rules
+func NewRegexRedactor(rules RedactionRules) Redactor {
+	var builtins []redactionPattern
+	for _, sp := range constants.SecretPatterns {
+		if rules.Disabled[sp.Name] {
+			continue
+		}
+		pattern := redactionPattern{re: sp.Pattern, hints: sp.Hints, secret: sp.Secret}
+		if rules.Lenient && sp.Lenient != nil {
+			pattern.re = sp.Lenient
+		}
+		builtins = append(builtins, pattern)
+	}
+	return newRedactor(builtins, rules)
+}
+
+// newRedactor compiles a base pattern set with the extra patterns and allowlist of rules
+func newRedactor(base []redactionPattern, rules RedactionRules) *redactor {
+	r := &redactor{allow: rules.Allow}
+	for _, pattern := range base {
+		r.patterns = append(r.patterns, pattern)
+		r.hints = append(r.hints, pattern.hints...)
+		r.unhinted = r.unhinted || pattern.hints == nil
+	}
+	// User patterns have no hints, so with any of them every line goes through the regexes
+	for _, extra := range rules.Extra {
+		r.patterns = append(r.patterns, redactionPattern{re: extra})
+		r.unhinted = true
+	}
+	return r
+}
+
+// activeRedactor returns the Redactor currently applied by FilterSecrets
+func activeRedactor() Redactor {
+	if r := active.Load(); r != nil {
+		return *r
+	}
+	return builtinRedactor
+}
+
+// Redact filters input with the engine's patterns
+func (r *redactor) Redact(input string) (string, int) {
+	return r.filterLines(input)
+}
+
+// filterLines redacts input line by line, running the regexes only near lines containing a hint
+// Most output has no secrets, and a substring search is much cheaper than a regex on slow CPUs
+func (r *redactor) filterLines(input string) (string, int) {
+	var out strings.Builder
+	out.Grow(len(input))
+	count := 0
+	for pos := 0; pos < len(input); {
+		end := lineEnd(input, pos)
+		if !r.hinted(input[pos:end]) {
+			out.WriteString(input[pos:end])
+			pos = end
+			continue
+		}
+		// A keyword's value may follow on a later line, as the patterns' separators span line breaks, so hinted
+		// lines are filtered with the blank lines after them and the first line that isn't blank
+		for end < len(input) && r.hinted(input[end:lineEnd(input, end)]) {
+			end = lineEnd(input, end)
+		}
+		for end < len(input) && strings.TrimSpace(input[end:lineEnd(input, end)]) == "" {
+			end = lineEnd(input, end)
+		}
+		end = lineEnd(input, end)
+		out.WriteString(r.filter(input[pos:end], &count))
+		pos = end
+	}
+	return out.String(), count
+}
+
+// hinted reports whether any pattern may match line
+func (r *redactor) hinted(line string) bool {
+	return r.unhinted || containsAny(strings.ToLower(line), r.hints)
+}
+
+// filter applies each pattern that may match text, in order, adding redactions to count
+func (r *redactor) filter(text string, count *int) string {
+	lower := strings.ToLower(text)
+	for _, pattern := range r.patterns {
+		if pattern.mayMatch(lower) {
+			text = r.redact(text, pattern, count)
+		}
+	}
+	return text
+}
+
+// redact replaces the group of each match, leaving the surrounding text intact, unless its secret value is allowed
+// A whole match keeps its first characters, which are the keyword rather than the secret for most patterns
+func (r *redactor) redact(text string, pattern redactionPattern, count *int) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range pattern.re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2*pattern.group], m[2*pattern.group+1]
+		if start < 0 || start == end || r.allowed(submatch(text, m, pattern.secretGroup())) {
+			continue
+		}
+		*count++
+		out.WriteString(text[last:start])
+		if pattern.group == 0 && end-start > 20 {
+			out.WriteString(text[start : start+20])
+		}
+		out.WriteString("[REDACTED]")
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// lineEnd returns the index just past the newline ending the line at pos, or len(s) for the last line
+func lineEnd(s string, pos int) int {
+	if i := strings.IndexByte(s[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(s)
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// submatch returns group n of the match at m in text, or the whole match when that group didn't take part
+func submatch(text string, m []int, n int) string {
+	if m[2*n] < 0 {
+		return text[m[0]:m[1]]
+	}
+	return text[m[2*n]:m[2*n+1]]
+}
+
+// allowed reports whether a secret value is on the allowlist
+// SECURITY: Rules see only the value, so an allowed word elsewhere on the line can't exempt a secret next to it
+func (r *redactor) allowed(secret string) bool {
+	for _, pattern := range r.allow {
+		if pattern.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadRedactionRules reads a redaction file
+// Each line is a regular expression to redact, "disable <name>" to turn off a built-in pattern,
+// or "allow <regex>" / "allow-literal <text>" to keep a secret value they match in full unredacted;
+// blank lines and lines starting with # are ignored
+func LoadRedactionRules(path string) (RedactionRules, error) {
+	rules := RedactionRules{Disabled: make(map[string]bool)}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return rules, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if text, ok := strings.CutPrefix(line, allowLiteralDirective); ok {
+			rules.Allow = append(rules.Allow, regexp.MustCompile(`^`+regexp.QuoteMeta(strings.TrimSpace(text))+`$`))
+			continue
+		}
+		if expr, ok := strings.CutPrefix(line, allowDirective); ok {
+			pattern, err := regexp.Compile(`^(?:` + strings.TrimSpace(expr) + `)$`)
+			if err != nil {
+				return rules, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			rules.Allow = append(rules.Allow, pattern)
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, disableDirective); ok {
+			name = strings.TrimSpace(name)
+			if !isBuiltinPattern(name) {
+				return rules, fmt.Errorf("line %d: unknown built-in pattern %q", lineNo, name)
+			}
+			rules.Disabled[name] = true
+			continue
+		}
+
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			return rules, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		rules.Extra = append(rules.Extra, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+func isBuiltinPattern(name string) bool {
+	for _, sp := range constants.SecretPatterns {
+		if sp.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRedactPrefilter checks that skipping lines without a hint word redacts exactly what running every
+// pattern over the whole input does
+func TestRedactPrefilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		count int
+	}{
+		{
+			name:  "no secrets",
+			input: "Starting backup\nCopied 12 files\nDone\n",
+			want:  "Starting backup\nCopied 12 files\nDone\n",
+		},
+		{
+			name:  "same line",
+			input: "connecting\npassword=hunter2\ndone\n",
+			want:  "connecting\n[REDACTED]\ndone\n",
+			count: 1,
+		},
+		{
+			name:  "value on the next line",
+			input: "password:\nhunter2\ndone\n",
+			want:  "[REDACTED]\ndone\n",
+			count: 1,
+		},
+		{
+			name:  "value after a blank line",
+			input: "password:\n\nhunter2\ndone\n",
+			want:  "[REDACTED]\ndone\n",
+			count: 1,
+		},
+		{
+			name:  "value after blank lines with spaces",
+			input: "api_key =\n  \n\t\nabcdef123456\n",
+			want:  "api_key =\n  \n\t\nabcde[REDACTED]\n",
+			count: 1,
+		},
+		{
+			name:  "consecutive hinted lines",
+			input: "token: one\nsecret: two\nplain\n",
+			want:  "[REDACTED]\n[REDACTED]\nplain\n",
+			count: 2,
+		},
+		{
+			name:  "hint without a newline at the end",
+			input: "plain\nBearer abcdef123456",
+			want:  "plain\n[REDACTED]",
+			count: 1,
+		},
+	}
+
+	r := NewRegexRedactor(RedactionRules{}).(*redactor)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := r.Redact(tt.input)
+			if got != tt.want || count != tt.count {
+				t.Errorf("Redact(%q) = %q, %d; want %q, %d", tt.input, got, count, tt.want, tt.count)
+			}
+
+			full := 0
+			if unfiltered := r.filter(tt.input, &full); got != unfiltered || count != full {
+				t.Errorf("Redact(%q) = %q, %d; all patterns over the whole input give %q, %d", tt.input, got, count, unfiltered, full)
+			}
+		})
+	}
+}
+
+// benchmarkOutput is journal-like output with a secret every few hundred lines
+func benchmarkOutput() string {
+	var b strings.Builder
+	for i := 0; i < 5000; i++ {
+		b.WriteString("Oct 18 04:00:01 host backup[1234]: copied /srv/data/file-0001.tar.gz (1.2 MiB) in 35ms\n")
+		if i%500 == 0 {
+			b.WriteString("Oct 18 04:00:01 host backup[1234]: using password=hunter2 for the remote\n")
+		}
+	}
+	return b.String()
+}
+
+func BenchmarkRedactPrefilter(b *testing.B) {
+	r := NewRegexRedactor(RedactionRules{})
+	input := benchmarkOutput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Redact(input)
+	}
+}
+
+// BenchmarkRedactAllPatterns runs every pattern over the whole input, the cost the prefilter avoids
+func BenchmarkRedactAllPatterns(b *testing.B) {
+	r := NewRegexRedactor(RedactionRules{}).(*redactor)
+	input := benchmarkOutput()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count := 0
+		r.filter(input, &count)
+	}
+}
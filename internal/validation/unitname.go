@@ -0,0 +1,153 @@
+package validation
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"telegram-notifier/internal/constants"
+)
+
+// pathUnitTypes are the unit types whose names are escaped paths
+var pathUnitTypes = []string{"mount", "automount", "swap", "device"}
+
+// NormalizeUnitName turns user input into the unit name systemctl would use for it:
+// an absolute path becomes its mount (or, under /dev, device) unit, non-ASCII characters are escaped as \xNN,
+// and a name without a unit type suffix is a service, so "backup" means backup.service while "backup.timer" is kept
+// SECURITY: ASCII characters systemd doesn't allow are left in place for ValidateServiceName to reject
+func NormalizeUnitName(name string) string {
+	if name == "" {
+		return name
+	}
+	if strings.HasPrefix(name, "/") {
+		if strings.HasPrefix(name, "/dev/") {
+			return EscapeUnitPath(name) + ".device"
+		}
+		return EscapeUnitPath(name) + ".mount"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c >= utf8.RuneSelf {
+			fmt.Fprintf(&b, `\x%02x`, c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	name = b.String()
+
+	if i := strings.LastIndexByte(name, '.'); i >= 0 && slices.Contains(constants.UnitTypes, name[i+1:]) {
+		return name
+	}
+	return name + ".service"
+}
+
+// EscapeUnitString escapes s for use in a unit name like systemd-escape: "/" becomes "-",
+// and a leading "." and every byte other than ASCII letters, digits, ":", "_" and "." become \xNN
+func EscapeUnitString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0, !isUnitChar(c):
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// EscapeUnitPath escapes a path like systemd-escape --path: it is cleaned, the outer slashes are dropped,
+// and the root directory becomes "-"
+func EscapeUnitPath(p string) string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return "-"
+	}
+	return EscapeUnitString(p)
+}
+
+// UnescapeUnitString reverses EscapeUnitString like systemd-escape --unescape: \xNN become bytes and "-" becomes "/"
+func UnescapeUnitString(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '-':
+			b.WriteByte('/')
+		case '\\':
+			if i+3 >= len(s) || s[i+1] != 'x' {
+				return "", fmt.Errorf("invalid escape at offset %d", i)
+			}
+			v, err := strconv.ParseUint(s[i+2:i+4], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape %q", s[i:i+4])
+			}
+			b.WriteByte(byte(v))
+			i += 3
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// UnescapedUnitName tells what an escaped unit name stands for, for display next to the name:
+// the path of a mount, automount, swap or device unit, or the instance of a template unit
+// Returns "" when nothing in the name is escaped or the result isn't printable text
+func UnescapedUnitName(name string) string {
+	if !strings.Contains(name, `\x`) {
+		return ""
+	}
+	prefix, unitType := name, ""
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		prefix, unitType = name[:i], name[i+1:]
+	}
+
+	var (
+		readable string
+		err      error
+	)
+	switch _, instance, isInstance := strings.Cut(prefix, "@"); {
+	case slices.Contains(pathUnitTypes, unitType):
+		readable, err = UnescapeUnitString(prefix)
+		readable = "/" + readable
+	case isInstance:
+		readable, err = UnescapeUnitString(instance)
+	default:
+		readable, err = UnescapeUnitString(prefix)
+	}
+	if err != nil || !utf8.ValidString(readable) || strings.IndexFunc(readable, unicode.IsControl) >= 0 {
+		return ""
+	}
+	return readable
+}
+
+// validateUnitEscapes checks that every backslash in a unit name starts a \xNN escape
+func validateUnitEscapes(name string) error {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '\\' {
+			continue
+		}
+		if i+3 >= len(name) || name[i+1] != 'x' || !isHex(name[i+2]) || !isHex(name[i+3]) {
+			return fmt.Errorf("service name contains a backslash that isn't a \\xNN escape")
+		}
+		i += 3
+	}
+	return nil
+}
+
+// isUnitChar reports whether c is kept as is by systemd-escape
+func isUnitChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ':' || c == '_' || c == '.'
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -10,14 +10,14 @@ import (
 	"telegram-notifier/internal/constants"
 )
 
-// ValidateServiceName ensures service name follows systemd naming conventions
+// ValidateServiceName ensures a unit name follows systemd's unit name grammar (prefix[@instance].type)
 // and prevents command injection via shell metacharacters
 func ValidateServiceName(name string) error {
 	if name == "" {
 		return fmt.Errorf("service name cannot be empty")
 	}
-	if len(name) > 256 {
-		return fmt.Errorf("service name too long (max 256 characters)")
+	if len(name) > constants.MaxUnitNameLength {
+		return fmt.Errorf("service name too long (max %d characters)", constants.MaxUnitNameLength)
 	}
 
 	// Prevent null byte injection and control character attacks
@@ -28,20 +28,30 @@ func ValidateServiceName(name string) error {
 	}
 
 	// Prevent homograph attacks using Unicode lookalikes
+	// Units for non-ASCII paths carry them escaped (systemd-escape), which NormalizeUnitName produces
 	for _, r := range name {
 		if r > 127 {
-			return fmt.Errorf("service name must contain only ASCII characters")
+			return fmt.Errorf("service name must contain only ASCII characters (others are escaped as \\xNN)")
 		}
 	}
 
 	// Defense-in-depth: Block shell metacharacters even though we use exec.CommandContext
 	// This prevents potential injection if code is ever modified to use shell execution
-	dangerousChars := []rune{'$', '`', '|', ';', '&', '\\', '\n', '\r', '<', '>', '(', ')', '{', '}', '[', ']', '!', '*', '?', '~'}
+	dangerousChars := []rune{'$', '`', '|', ';', '&', '\n', '\r', '<', '>', '(', ')', '{', '}', '[', ']', '!', '*', '?', '~'}
 	for _, danger := range dangerousChars {
 		if strings.ContainsRune(name, danger) {
 			return fmt.Errorf("service name contains potentially dangerous character: %c", danger)
 		}
 	}
+	// A leading "-" would be read as an option; only the root directory's units, "-.mount" and "-.slice", start with one
+	if strings.HasPrefix(name, "-") && name != "-.mount" && name != "-.slice" {
+		return fmt.Errorf("service name cannot start with '-'")
+	}
+
+	// Backslashes only appear in systemd's \xNN escapes
+	if err := validateUnitEscapes(name); err != nil {
+		return err
+	}
 
 	if !constants.ServiceNamePattern.MatchString(name) {
 		return fmt.Errorf("invalid service name format: must match pattern %s", constants.ServiceNamePattern.String())
@@ -49,6 +59,32 @@ func ValidateServiceName(name string) error {
 	return nil
 }
 
+// ValidateJobName checks names given to commands wrapped by "telegram-notifier run"
+// They label history and notifications like unit names, without the .service suffix
+func ValidateJobName(name string) error {
+	if !constants.JobNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid job name %q: must match pattern %s", name, constants.JobNamePattern.String())
+	}
+	return nil
+}
+
+// ValidateBotToken checks the token looks like one issued by @BotFather, so a typo fails at startup instead of as a 401
+// SECURITY: The token itself is never part of the error, which is also worded so secret redaction leaves it readable
+func ValidateBotToken(token string) error {
+	if !constants.BotTokenPattern.MatchString(token) {
+		return fmt.Errorf("malformed bot token; expected <bot id>:<35 letters, digits, _ or -> as issued by @BotFather")
+	}
+	return nil
+}
+
+// ValidateChatID checks a chat is a numeric ID (negative for groups, -100... for supergroups and channels) or an @username
+func ValidateChatID(chat string) error {
+	if constants.ChatIDPattern.MatchString(chat) || constants.ChatUsernamePattern.MatchString(chat) {
+		return nil
+	}
+	return fmt.Errorf("invalid chat %q (expected a numeric chat ID such as -1001234567890 or an @username)", chat)
+}
+
 // ValidateExitCode ensures exit code is in valid range (0-255)
 func ValidateExitCode(code int) error {
 	if code < constants.ExitCodeMin || code > constants.ExitCodeMax {
@@ -97,19 +133,16 @@ func SanitizePath(baseDir, filename string) (string, error) {
 // FilterSecrets removes sensitive information from output using regex patterns
 // SECURITY: Prevents credential leakage in logs and notifications
 func FilterSecrets(input string) string {
-	result := input
-	// Apply all secret detection patterns and redact matches
-	for _, pattern := range constants.SecretPatterns {
-		result = pattern.ReplaceAllStringFunc(result, func(match string) string {
-			if len(match) > 20 {
-				return match[:20] + "[REDACTED]"
-			}
-			return "[REDACTED]"
-		})
-	}
+	result, _ := FilterSecretsCount(input)
 	return result
 }
 
+// FilterSecretsCount filters secrets like FilterSecrets and reports how many were redacted
+// The engine is the one set with SetRedactor; the regex engine only runs its patterns near lines with a hint word
+func FilterSecretsCount(input string) (string, int) {
+	return activeRedactor().Redact(input)
+}
+
 // FilterSecretsFromError filters sensitive information from error objects
 func FilterSecretsFromError(err error) error {
 	if err == nil {
@@ -143,32 +176,3 @@ func SanitizeErrorMessage(err error) string {
 
 	return msg
 }
-
-// TruncateMessage ensures message fits within Telegram's limits
-// Shows most recent output (end of message) as it's typically most relevant
-func TruncateMessage(msg string, maxSize int) string {
-	if len(msg) <= maxSize {
-		return msg
-	}
-
-	truncMsg := constants.OutputTruncatedMsg
-	availableSize := maxSize - len(truncMsg)
-
-	if availableSize <= 0 {
-		return msg[:maxSize]
-	}
-
-	// Keep the END of the message (most recent output)
-	truncated := truncMsg + msg[len(msg)-availableSize:]
-
-	// Ensure valid UTF-8 to prevent encoding issues
-	return strings.ToValidUTF8(truncated, "�")
-}
-
-// ValidateMessageSize checks total message size before sending to Telegram
-func ValidateMessageSize(msg string) error {
-	if len(msg) > constants.TelegramMaxMessageSize {
-		return fmt.Errorf("message size %d exceeds Telegram limit of %d", len(msg), constants.TelegramMaxMessageSize)
-	}
-	return nil
-}